@@ -40,6 +40,19 @@ type Config struct {
 	// TLSConfig when listening for encrypted connections (gRPC, DNS-over-TLS).
 	TLSConfig *tls.Config
 
+	// HTTPSPaths are the URL paths a DNS-over-HTTPS server answers queries on. When empty the
+	// path is /dns-query.
+	HTTPSPaths []string
+
+	// HTTP3 makes a DNS-over-HTTPS server also answer queries over HTTP/3, on the UDP port with
+	// the same number as its TCP port.
+	HTTP3 bool
+
+	// TsigSecret holds the TSIG secrets, keyed by canonical key name, that requests to this
+	// server may be signed with. When set, dynamic updates (RFC 2136) are also accepted by
+	// the server and handed to the plugin chain.
+	TsigSecret map[string]string
+
 	// Plugin stack.
 	Plugin []plugin.Plugin
 
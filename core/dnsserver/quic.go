@@ -0,0 +1,62 @@
+package dnsserver
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/plugin/pkg/doq"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// DoQWriter is a dns.ResponseWriter that writes the reply to a DNS-over-QUIC stream.
+type DoQWriter struct {
+	stream  quic.Stream
+	written bool
+
+	// raddr is the remote's address.
+	raddr net.Addr
+	// laddr is our address.
+	laddr net.Addr
+}
+
+// WriteMsg writes m to the stream.
+func (d *DoQWriter) WriteMsg(m *dns.Msg) error {
+	d.written = true
+	return doq.WriteMsg(d.stream, m)
+}
+
+// Write writes the packed message b to the stream.
+func (d *DoQWriter) Write(b []byte) (int, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(b); err != nil {
+		return 0, err
+	}
+	return len(b), d.WriteMsg(m)
+}
+
+// RemoteAddr returns the remote address. It is returned as a TCP address: like TCP, QUIC has no limit
+// on the size of the reply, so it must not be truncated.
+func (d *DoQWriter) RemoteAddr() net.Addr { return tcpAddr(d.raddr) }
+
+// LocalAddr returns the local address.
+func (d *DoQWriter) LocalAddr() net.Addr { return tcpAddr(d.laddr) }
+
+// Close closes the stream.
+func (d *DoQWriter) Close() error { return d.stream.Close() }
+
+// TsigStatus implements dns.ResponseWriter, TSIG isn't supported.
+func (d *DoQWriter) TsigStatus() error { return nil }
+
+// TsigTimersOnly implements dns.ResponseWriter.
+func (d *DoQWriter) TsigTimersOnly(bool) {}
+
+// Hijack implements dns.ResponseWriter.
+func (d *DoQWriter) Hijack() {}
+
+func tcpAddr(a net.Addr) net.Addr {
+	if u, ok := a.(*net.UDPAddr); ok {
+		return &net.TCPAddr{IP: u.IP, Port: u.Port, Zone: u.Zone}
+	}
+	return a
+}
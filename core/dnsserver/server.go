@@ -39,6 +39,7 @@ type Server struct {
 	trace        trace.Trace        // the trace plugin for the server
 	debug        bool               // disable recover()
 	classChaos   bool               // allow non-INET class queries
+	tsigSecret   map[string]string  // TSIG secrets of all zones, keyed by key name
 }
 
 // NewServer returns a new CoreDNS server and compiles all plugins in to it. By default CH class
@@ -67,6 +68,13 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 		// set the config per zone
 		s.zones[site.Zone] = site
 
+		for name, secret := range site.TsigSecret {
+			if s.tsigSecret == nil {
+				s.tsigSecret = make(map[string]string)
+			}
+			s.tsigSecret[name] = secret
+		}
+
 		// compile custom plugin for everything
 		var stack plugin.Handler
 		for i := len(site.Plugin) - 1; i >= 0; i-- {
@@ -97,7 +105,7 @@ func NewServer(addr string, group []*Config) (*Server, error) {
 // This implements caddy.TCPServer interface.
 func (s *Server) Serve(l net.Listener) error {
 	s.m.Lock()
-	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	s.server[tcp] = &dns.Server{Listener: l, Net: "tcp", TsigSecret: s.tsigSecret, MsgAcceptFunc: s.msgAcceptFunc(), Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
 		ctx := context.WithValue(context.Background(), Key{}, s)
 		s.ServeDNS(ctx, w, r)
 	})}
@@ -110,7 +118,7 @@ func (s *Server) Serve(l net.Listener) error {
 // This implements caddy.UDPServer interface.
 func (s *Server) ServePacket(p net.PacketConn) error {
 	s.m.Lock()
-	s.server[udp] = &dns.Server{PacketConn: p, Net: "udp", Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+	s.server[udp] = &dns.Server{PacketConn: p, Net: "udp", TsigSecret: s.tsigSecret, MsgAcceptFunc: s.msgAcceptFunc(), Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
 		ctx := context.WithValue(context.Background(), Key{}, s)
 		s.ServeDNS(ctx, w, r)
 	})}
@@ -119,6 +127,28 @@ func (s *Server) ServePacket(p net.PacketConn) error {
 	return s.server[udp].ActivateAndServe()
 }
 
+// msgAcceptFunc returns the dns.MsgAcceptFunc used by the server. When no TSIG secrets are
+// configured this is the default one, otherwise dynamic updates are let through as well; their
+// sections can hold many RRs, so only the header bits and question count are checked for those.
+func (s *Server) msgAcceptFunc() dns.MsgAcceptFunc {
+	if len(s.tsigSecret) == 0 {
+		return dns.DefaultMsgAcceptFunc
+	}
+	return func(dh dns.Header) dns.MsgAcceptAction {
+		opcode := int(dh.Bits>>11) & 0xF
+		if opcode != dns.OpcodeUpdate {
+			return dns.DefaultMsgAcceptFunc(dh)
+		}
+		if dh.Bits&(1<<15) != 0 { // QR bit: a response.
+			return dns.MsgIgnore
+		}
+		if dh.Qdcount != 1 {
+			return dns.MsgReject
+		}
+		return dns.MsgAccept
+	}
+}
+
 // Listen implements caddy.TCPServer interface.
 func (s *Server) Listen() (net.Listener, error) {
 	l, err := listen("tcp", s.Addr[len(transport.DNS+"://"):])
@@ -13,14 +13,21 @@ import (
 	"github.com/coredns/coredns/plugin/pkg/doh"
 	"github.com/coredns/coredns/plugin/pkg/response"
 	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/quic-go/quic-go/http3"
 )
 
 // ServerHTTPS represents an instance of a DNS-over-HTTPS server.
 type ServerHTTPS struct {
 	*Server
 	httpsServer *http.Server
+	http3Server *http3.Server // nil when HTTP/3 isn't enabled
 	listenAddr  net.Addr
 	tlsConfig   *tls.Config
+	paths       map[string]bool
 }
 
 // NewServerHTTPS returns a new CoreDNS GRPC server and compiles all plugins in to it.
@@ -32,14 +39,30 @@ func NewServerHTTPS(addr string, group []*Config) (*ServerHTTPS, error) {
 	// The *tls* plugin must make sure that multiple conflicting
 	// TLS configuration return an error: it can only be specified once.
 	var tlsConfig *tls.Config
+	paths := map[string]bool{}
+	enableHTTP3 := false
 	for _, conf := range s.zones {
 		// Should we error if some configs *don't* have TLS?
 		tlsConfig = conf.TLSConfig
+		for _, p := range conf.HTTPSPaths {
+			paths[p] = true
+		}
+		enableHTTP3 = enableHTTP3 || conf.HTTP3
+	}
+	if len(paths) == 0 {
+		paths[doh.Path] = true
 	}
 
-	sh := &ServerHTTPS{Server: s, tlsConfig: tlsConfig, httpsServer: new(http.Server)}
+	sh := &ServerHTTPS{Server: s, tlsConfig: tlsConfig, httpsServer: new(http.Server), paths: paths}
 	sh.httpsServer.Handler = sh
 
+	if enableHTTP3 {
+		if tlsConfig == nil {
+			return nil, fmt.Errorf("HTTP/3 needs a TLS configuration, set with the tls plugin, for %s", addr)
+		}
+		sh.http3Server = &http3.Server{Handler: sh, TLSConfig: tlsConfig}
+	}
+
 	return sh, nil
 }
 
@@ -55,8 +78,16 @@ func (s *ServerHTTPS) Serve(l net.Listener) error {
 	return s.httpsServer.Serve(l)
 }
 
-// ServePacket implements caddy.UDPServer interface.
-func (s *ServerHTTPS) ServePacket(p net.PacketConn) error { return nil }
+// ServePacket implements caddy.UDPServer interface. It serves HTTP/3 when that is enabled.
+func (s *ServerHTTPS) ServePacket(p net.PacketConn) error {
+	if s.http3Server == nil || p == nil {
+		return nil
+	}
+	if err := s.http3Server.Serve(p); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
 
 // Listen implements caddy.TCPServer interface.
 func (s *ServerHTTPS) Listen() (net.Listener, error) {
@@ -69,7 +100,16 @@ func (s *ServerHTTPS) Listen() (net.Listener, error) {
 }
 
 // ListenPacket implements caddy.UDPServer interface.
-func (s *ServerHTTPS) ListenPacket() (net.PacketConn, error) { return nil, nil }
+func (s *ServerHTTPS) ListenPacket() (net.PacketConn, error) {
+	if s.http3Server == nil {
+		return nil, nil
+	}
+	p, err := net.ListenPacket("udp", s.Addr[len(transport.HTTPS+"://"):])
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
 
 // OnStartupComplete lists the sites served by this server
 // and any relevant information, assuming Quiet is false.
@@ -92,19 +132,37 @@ func (s *ServerHTTPS) Stop() error {
 	if s.httpsServer != nil {
 		s.httpsServer.Shutdown(context.Background())
 	}
+	if s.http3Server != nil {
+		s.http3Server.Close()
+	}
 	return nil
 }
 
 // ServeHTTP is the handler that gets the HTTP request and converts to the dns format, calls the plugin
-// chain, converts it back and write it to the client.
+// chain, converts it back and write it to the client. Requests for the JSON API get their reply in
+// JSON, all others in the wire format.
 func (s *ServerHTTPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	if r.URL.Path != doh.Path {
+	if !s.paths[r.URL.Path] {
 		http.Error(w, "", http.StatusNotFound)
 		return
 	}
 
-	msg, err := doh.RequestToMsg(r)
+	// Tell HTTP/1.1 and HTTP/2 clients they can switch to HTTP/3.
+	if s.http3Server != nil && r.ProtoMajor < 3 {
+		s.http3Server.SetQuicHeaders(w.Header())
+	}
+
+	asJSON := doh.IsJSON(r)
+	var (
+		msg *dns.Msg
+		err error
+	)
+	if asJSON {
+		msg, err = doh.JSONRequestToMsg(r)
+	} else {
+		msg, err = doh.RequestToMsg(r)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -118,6 +176,16 @@ func (s *ServerHTTPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// We just call the normal chain handler - all error handling is done there.
 	// We should expect a packet to be returned that we can send to the client.
 	ctx := context.WithValue(context.Background(), Key{}, s.Server)
+
+	// Continue the trace of the client, if it sent its span context in the request's headers.
+	if t := s.Tracer(); t != nil {
+		if parent, err := t.Extract(ot.HTTPHeaders, ot.HTTPHeadersCarrier(r.Header)); err == nil {
+			span := t.StartSpan("doh", ext.RPCServerOption(parent))
+			defer span.Finish()
+			ctx = ot.ContextWithSpan(ctx, span)
+		}
+	}
+
 	s.ServeDNS(ctx, dw, msg)
 
 	// See section 4.2.1 of RFC 8484.
@@ -128,13 +196,25 @@ func (s *ServerHTTPS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	buf, _ := dw.Msg.Pack()
+	mimeType := doh.MimeType
+	var buf []byte
+	if asJSON {
+		mimeType = doh.JSONMimeType
+		buf, err = doh.MsgToJSON(dw.Msg)
+	} else {
+		buf, err = dw.Msg.Pack()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
+	// The reply may be cached as long as the shortest TTL in it, see section 5.1 of RFC 8484.
 	mt, _ := response.Typify(dw.Msg, time.Now().UTC())
 	age := dnsutil.MinimalTTL(dw.Msg, mt)
 
-	w.Header().Set("Content-Type", doh.MimeType)
-	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%f", age.Seconds()))
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(age.Seconds())))
 	w.Header().Set("Content-Length", strconv.Itoa(len(buf)))
 	w.WriteHeader(http.StatusOK)
 
@@ -146,5 +226,8 @@ func (s *ServerHTTPS) Shutdown() error {
 	if s.httpsServer != nil {
 		s.httpsServer.Shutdown(context.Background())
 	}
+	if s.http3Server != nil {
+		s.http3Server.Close()
+	}
 	return nil
 }
@@ -0,0 +1,125 @@
+package dnsserver
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/doh"
+	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go/http3"
+)
+
+func TestServeHTTP(t *testing.T) {
+	c := testConfig("https", replyPlugin{})
+	c.HTTPSPaths = []string{"/dns-query", "/resolve"}
+	s, err := NewServerHTTPS("https://127.0.0.1:443", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerHTTPS, got %s", err)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	get, _ := doh.NewRequest(http.MethodGet, "127.0.0.1:443", m)
+	post, _ := doh.NewRequest(http.MethodPost, "127.0.0.1:443", m)
+	resolve, _ := http.NewRequest(http.MethodGet, "https://127.0.0.1:443/resolve?name=example.com&type=A", nil)
+	other, _ := http.NewRequest(http.MethodGet, "https://127.0.0.1:443/other?name=example.com", nil)
+
+	tests := []struct {
+		req          *http.Request
+		expectedCode int
+		expectedType string
+	}{
+		{get, http.StatusOK, doh.MimeType},
+		{post, http.StatusOK, doh.MimeType},
+		{resolve, http.StatusOK, doh.JSONMimeType},
+		{other, http.StatusNotFound, ""},
+	}
+	for i, tc := range tests {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, tc.req)
+		if w.Code != tc.expectedCode {
+			t.Errorf("Test %d: expected status %d, got %d", i, tc.expectedCode, w.Code)
+			continue
+		}
+		if tc.expectedCode != http.StatusOK {
+			continue
+		}
+		if x := w.Header().Get("Content-Type"); x != tc.expectedType {
+			t.Errorf("Test %d: expected content type %s, got %s", i, tc.expectedType, x)
+		}
+		// The answer has a TTL of 3600.
+		if x := w.Header().Get("Cache-Control"); x != "max-age=3600" {
+			t.Errorf("Test %d: expected Cache-Control max-age=3600, got %s", i, x)
+		}
+
+		if tc.expectedType == doh.JSONMimeType {
+			var j struct {
+				Answer []struct {
+					Data string `json:"data"`
+				}
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &j); err != nil || len(j.Answer) != 1 || j.Answer[0].Data != "127.0.0.1" {
+				t.Errorf("Test %d: expected a JSON answer of 127.0.0.1, got %s", i, w.Body)
+			}
+			continue
+		}
+		ret := new(dns.Msg)
+		if err := ret.Unpack(w.Body.Bytes()); err != nil || len(ret.Answer) != 1 {
+			t.Errorf("Test %d: expected an answer, got %v", i, ret)
+		}
+	}
+}
+
+func TestServerHTTPS3(t *testing.T) {
+	c := testConfig("https", replyPlugin{})
+	c.HTTP3 = true
+	if _, err := NewServerHTTPS("https://127.0.0.1:0", []*Config{c}); err == nil || !strings.Contains(err.Error(), "TLS configuration") {
+		t.Errorf("Expected error for HTTP/3 without TLS configuration, got %v", err)
+	}
+
+	dir, rm, err := test.WritePEMFiles("")
+	if err != nil {
+		t.Fatalf("Could not write PEM files: %s", err)
+	}
+	defer rm()
+	c.TLSConfig, err = pkgtls.NewTLSConfig(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServerHTTPS("https://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerHTTPS, got %s", err)
+	}
+	pc, err := s.ListenPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServePacket(pc)
+	defer s.Stop()
+
+	rt := &http3.RoundTripper{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer rt.Close()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	req, _ := doh.NewRequest(http.MethodGet, pc.LocalAddr().String(), m)
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Failed to query over HTTP/3: %s", err)
+	}
+	if resp.ProtoMajor != 3 {
+		t.Errorf("Expected HTTP/3, got %s", resp.Proto)
+	}
+	ret, err := doh.ResponseToMsg(resp)
+	if err != nil || len(ret.Answer) != 1 {
+		t.Errorf("Expected an answer, got %v (%v)", ret, err)
+	}
+}
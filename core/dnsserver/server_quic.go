@@ -0,0 +1,151 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doq"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// ServerQUIC represents an instance of a DNS-over-QUIC server (RFC 9250).
+type ServerQUIC struct {
+	*Server
+	listener  *quic.EarlyListener
+	tlsConfig *tls.Config
+}
+
+// NewServerQUIC returns a new CoreDNS QUIC server and compiles all plugins in to it.
+func NewServerQUIC(addr string, group []*Config) (*ServerQUIC, error) {
+	s, err := NewServer(addr, group)
+	if err != nil {
+		return nil, err
+	}
+	// The *tls* plugin must make sure that multiple conflicting
+	// TLS configuration return an error: it can only be specified once.
+	var tlsConfig *tls.Config
+	for _, conf := range s.zones {
+		tlsConfig = conf.TLSConfig
+	}
+	if tlsConfig == nil {
+		return nil, fmt.Errorf("DNS-over-QUIC needs a TLS configuration, set with the tls plugin, for %s", addr)
+	}
+	// QUIC only uses TLS 1.3, and DoQ must be negotiated with ALPN.
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.NextProtos = []string{doq.NextProto}
+
+	return &ServerQUIC{Server: s, tlsConfig: tlsConfig}, nil
+}
+
+// Serve implements caddy.TCPServer interface.
+func (s *ServerQUIC) Serve(l net.Listener) error { return nil }
+
+// ServePacket implements caddy.UDPServer interface.
+func (s *ServerQUIC) ServePacket(p net.PacketConn) error {
+	// 0-RTT is allowed: DNS queries are idempotent, replaying them does no harm.
+	l, err := quic.ListenEarly(p, s.tlsConfig, &quic.Config{Allow0RTT: true, MaxIdleTimeout: quicIdleTimeout})
+	if err != nil {
+		return err
+	}
+	s.m.Lock()
+	s.listener = l
+	s.m.Unlock()
+
+	for {
+		conn, err := l.Accept(context.Background())
+		if err != nil {
+			if err == quic.ErrServerClosed {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// serveConn serves the queries on conn, each comes in on its own stream.
+func (s *ServerQUIC) serveConn(conn quic.Connection) {
+	for {
+		stream, err := conn.AcceptStream(context.Background())
+		if err != nil {
+			return
+		}
+		go s.serveStream(conn, stream)
+	}
+}
+
+// serveStream reads the query on stream, calls the plugin chain, and writes the reply to the client.
+func (s *ServerQUIC) serveStream(conn quic.Connection, stream quic.Stream) {
+	stream.SetReadDeadline(time.Now().Add(quicReadTimeout))
+	r, err := doq.ReadMsg(stream)
+	if err != nil {
+		if err == doq.ErrID {
+			conn.CloseWithError(doq.ProtocolError, err.Error())
+			return
+		}
+		stream.CancelRead(doq.ProtocolError)
+		stream.CancelWrite(doq.ProtocolError)
+		return
+	}
+	// The ID is 0 on the wire, give the query a real one for the plugins that forward it.
+	r.Id = dns.Id()
+
+	w := &DoQWriter{stream: stream, laddr: conn.LocalAddr(), raddr: conn.RemoteAddr()}
+	ctx := context.WithValue(context.Background(), Key{}, s.Server)
+	s.ServeDNS(ctx, w, r)
+
+	if !w.written {
+		stream.CancelWrite(doq.InternalError)
+		return
+	}
+	stream.Close()
+}
+
+// Listen implements caddy.TCPServer interface.
+func (s *ServerQUIC) Listen() (net.Listener, error) { return nil, nil }
+
+// ListenPacket implements caddy.UDPServer interface.
+func (s *ServerQUIC) ListenPacket() (net.PacketConn, error) {
+	p, err := net.ListenPacket("udp", s.Addr[len(transport.QUIC+"://"):])
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// OnStartupComplete lists the sites served by this server
+// and any relevant information, assuming Quiet is false.
+func (s *ServerQUIC) OnStartupComplete() {
+	if Quiet {
+		return
+	}
+
+	out := startUpZones(transport.QUIC+"://", s.Addr, s.zones)
+	if out != "" {
+		fmt.Print(out)
+	}
+}
+
+// Stop stops the server. It blocks until the server is totally stopped.
+func (s *ServerQUIC) Stop() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	return nil
+}
+
+// Shutdown stops the server (non gracefully).
+func (s *ServerQUIC) Shutdown() error { return s.Stop() }
+
+const (
+	quicIdleTimeout = 30 * time.Second // close connections that are idle for this long
+	quicReadTimeout = 5 * time.Second  // how long a client has to send its query on a stream
+)
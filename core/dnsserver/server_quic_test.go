@@ -0,0 +1,89 @@
+package dnsserver
+
+import (
+	"context"
+	"crypto/tls"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/doq"
+	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+type replyPlugin struct{}
+
+func (rp replyPlugin) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Answer = append(m.Answer, test.A(r.Question[0].Name+" 3600 IN A 127.0.0.1"))
+	w.WriteMsg(m)
+	return 0, nil
+}
+
+func (rp replyPlugin) Name() string { return "replyplugin" }
+
+func TestNewServerQUIC(t *testing.T) {
+	_, err := NewServerQUIC("quic://127.0.0.1:853", []*Config{testConfig("quic", testPlugin{})})
+	if err == nil || !strings.Contains(err.Error(), "TLS configuration") {
+		t.Errorf("Expected error for a server without TLS configuration, got %v", err)
+	}
+}
+
+func TestServerQUIC(t *testing.T) {
+	dir, rm, err := test.WritePEMFiles("")
+	if err != nil {
+		t.Fatalf("Could not write PEM files: %s", err)
+	}
+	defer rm()
+	tlsConfig, err := pkgtls.NewTLSConfig(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := testConfig("quic", replyPlugin{})
+	c.TLSConfig = tlsConfig
+	s, err := NewServerQUIC("quic://127.0.0.1:0", []*Config{c})
+	if err != nil {
+		t.Fatalf("Expected no error for NewServerQUIC, got %s", err)
+	}
+	pc, err := s.ListenPacket()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.ServePacket(pc)
+	defer s.Stop()
+
+	clientConfig := &tls.Config{InsecureSkipVerify: true, NextProtos: []string{doq.NextProto}}
+	conn, err := quic.DialAddr(context.Background(), pc.LocalAddr().String(), clientConfig, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	defer conn.CloseWithError(doq.NoError, "")
+
+	// Two queries on the same connection, each on its own stream.
+	for _, name := range []string{"a.example.com.", "b.example.com."} {
+		stream, err := conn.OpenStreamSync(context.Background())
+		if err != nil {
+			t.Fatalf("Failed to open stream: %s", err)
+		}
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		if err := doq.WriteMsg(stream, m); err != nil {
+			t.Fatalf("Failed to write query: %s", err)
+		}
+		stream.Close()
+
+		ret, err := doq.ReadMsg(stream)
+		if err != nil {
+			t.Fatalf("Failed to read reply: %s", err)
+		}
+		if len(ret.Answer) != 1 || ret.Answer[0].Header().Name != name {
+			t.Errorf("Expected an answer for %s, got %v", name, ret.Answer)
+		}
+	}
+}
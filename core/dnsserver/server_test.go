@@ -48,6 +48,25 @@ func TestNewServer(t *testing.T) {
 	}
 }
 
+func TestMsgAcceptFunc(t *testing.T) {
+	update := dns.Header{Bits: uint16(dns.OpcodeUpdate) << 11, Qdcount: 1, Ancount: 2, Nscount: 3, Arcount: 1}
+
+	s, _ := NewServer("127.0.0.1:53", []*Config{testConfig("dns", testPlugin{})})
+	if action := s.msgAcceptFunc()(update); action != dns.MsgRejectNotImplemented {
+		t.Errorf("Expected update to be rejected as not implemented, got %d", action)
+	}
+
+	c := testConfig("dns", testPlugin{})
+	c.TsigSecret = map[string]string{"key.": "c2VjcmV0"}
+	s, _ = NewServer("127.0.0.1:53", []*Config{c})
+	if action := s.msgAcceptFunc()(update); action != dns.MsgAccept {
+		t.Errorf("Expected update to be accepted, got %d", action)
+	}
+	if action := s.msgAcceptFunc()(dns.Header{Qdcount: 1, Nscount: 2}); action != dns.MsgReject {
+		t.Errorf("Expected query to be rejected, got %d", action)
+	}
+}
+
 func BenchmarkCoreServeDNS(b *testing.B) {
 	s, err := NewServer("127.0.0.1:53", []*Config{testConfig("dns", testPlugin{})})
 	if err != nil {
@@ -13,11 +13,13 @@ var Directives = []string{
 	"metadata",
 	"cancel",
 	"tls",
+	"doh",
 	"reload",
 	"nsid",
 	"root",
 	"bind",
 	"debug",
+	"logging",
 	"trace",
 	"ready",
 	"health",
@@ -44,8 +46,10 @@ var Directives = []string{
 	"secondary",
 	"etcd",
 	"loop",
+	"validator",
 	"forward",
 	"grpc",
+	"recursor",
 	"erratic",
 	"whoami",
 	"on",
@@ -0,0 +1,428 @@
+package cache
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// denialCache indexes the NSEC and NSEC3 records of validated denial of existence responses per zone,
+// so names covered by them can be denied without asking the next plugin (RFC 8198).
+type denialCache struct {
+	zones *cache.Cache // *denialZone keyed on the hash of the zone name, the least recently used are evicted
+	max   int          // maximum number of records per zone
+
+	sync.RWMutex
+}
+
+// maxDenialZones is the maximum number of zones in the denial cache.
+const maxDenialZones = 1024
+
+// denialZone holds the denial records of a single zone.
+type denialZone struct {
+	name string
+	soa  *signed
+
+	nsec  []*signed // NSEC records, sorted in canonical order of their owner names
+	nsec3 []*signed // NSEC3 records, sorted on their (upper cased) hashed owner names
+
+	// NSEC3 parameters, NSEC3 records with different parameters are ignored.
+	hash       uint8
+	iterations uint16
+	salt       string
+}
+
+// signed is a record with its signatures and the time it expires.
+type signed struct {
+	rr     dns.RR
+	sigs   []dns.RR
+	expire time.Time
+
+	key  string // owner name (NSEC) or upper cased hash (NSEC3) used for sorting and searching
+	next string // next domain name (NSEC) or upper cased next hash (NSEC3)
+}
+
+func newDenialCache(max int) *denialCache {
+	return &denialCache{zones: cache.NewWithPolicy(maxDenialZones, cache.LRU), max: max}
+}
+
+// zone returns the denial records of the zone name, or nil if there are none.
+func (d *denialCache) zone(name string) *denialZone {
+	el, ok := d.zones.Get(zoneHash(name))
+	if !ok {
+		return nil
+	}
+	// Guard against hash collisions.
+	if z := el.(*denialZone); z.name == name {
+		return z
+	}
+	return nil
+}
+
+func zoneHash(name string) uint64 {
+	h := fnv.New64()
+	h.Write([]byte(name))
+	return h.Sum64()
+}
+
+// add indexes the NSEC and NSEC3 records from the authority section of m, m must be a validated
+// NXDOMAIN or NODATA response.
+func (d *denialCache) add(m *dns.Msg, now time.Time) {
+	var (
+		soa  *signed
+		recs []*signed
+		sigs = map[string][]dns.RR{}
+	)
+	for _, rr := range m.Ns {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			k := strings.ToLower(sig.Hdr.Name) + "/" + dns.TypeToString[sig.TypeCovered]
+			sigs[k] = append(sigs[k], sig)
+		}
+	}
+	sigsFor := func(rr dns.RR) []dns.RR {
+		return sigs[strings.ToLower(rr.Header().Name)+"/"+dns.TypeToString[rr.Header().Rrtype]]
+	}
+
+	for _, rr := range m.Ns {
+		s := &signed{rr: rr, expire: now.Add(time.Duration(rr.Header().Ttl) * time.Second)}
+		switch x := rr.(type) {
+		case *dns.SOA:
+			soa = s
+		case *dns.NSEC:
+			s.key = strings.ToLower(x.Hdr.Name)
+			s.next = strings.ToLower(x.NextDomain)
+		case *dns.NSEC3:
+			label := x.Hdr.Name
+			if i := strings.Index(label, "."); i > 0 {
+				label = label[:i]
+			}
+			s.key = strings.ToUpper(label)
+			s.next = strings.ToUpper(x.NextDomain)
+		default:
+			continue
+		}
+		s.sigs = sigsFor(rr)
+		if len(s.sigs) == 0 {
+			// Only use records that were signed.
+			continue
+		}
+		if _, ok := rr.(*dns.SOA); !ok {
+			recs = append(recs, s)
+		}
+	}
+	if soa == nil || len(soa.sigs) == 0 || len(recs) == 0 {
+		return
+	}
+
+	zone := strings.ToLower(soa.rr.Header().Name)
+
+	d.Lock()
+	defer d.Unlock()
+
+	// The records of a zone whose SOA expired are dropped.
+	z := d.zone(zone)
+	if z == nil || !z.soa.expire.After(now) {
+		z = &denialZone{name: zone}
+		d.zones.Add(zoneHash(zone), z)
+	}
+	z.soa = soa
+
+	for _, s := range recs {
+		// The records must belong to the zone of the SOA record.
+		if signer := s.sigs[0].(*dns.RRSIG).SignerName; strings.ToLower(signer) != zone {
+			continue
+		}
+		switch x := s.rr.(type) {
+		case *dns.NSEC:
+			z.nsec = z.insert(z.nsec, s, now, d.max, dnsutil.CanonicalCompare)
+		case *dns.NSEC3:
+			if len(z.nsec3) == 0 {
+				z.hash, z.iterations, z.salt = x.Hash, x.Iterations, x.Salt
+			}
+			if x.Hash != z.hash || x.Iterations != z.iterations || !strings.EqualFold(x.Salt, z.salt) {
+				continue
+			}
+			z.nsec3 = z.insert(z.nsec3, s, now, d.max, strings.Compare)
+		}
+	}
+}
+
+// insert inserts s in the sorted list, replacing the record with the same key. When the list is full, the
+// expired records are removed first, if it is still full s isn't added.
+func (z *denialZone) insert(list []*signed, s *signed, now time.Time, max int, compare func(a, b string) int) []*signed {
+	i := sort.Search(len(list), func(i int) bool { return compare(list[i].key, s.key) >= 0 })
+	if i < len(list) && compare(list[i].key, s.key) == 0 {
+		list[i] = s
+		return list
+	}
+	if len(list) >= max {
+		j := 0
+		for _, l := range list {
+			if l.expire.After(now) {
+				list[j] = l
+				j++
+			}
+		}
+		list = list[:j]
+		if len(list) >= max {
+			return list
+		}
+		i = sort.Search(len(list), func(i int) bool { return compare(list[i].key, s.key) >= 0 })
+	}
+	list = append(list, nil)
+	copy(list[i+1:], list[i:])
+	list[i] = s
+	return list
+}
+
+// synthesize returns an NXDOMAIN or NODATA response for state, when the cached denial records prove
+// the name or type doesn't exist. Otherwise nil is returned.
+func (d *denialCache) synthesize(state request.Request, now time.Time) *dns.Msg {
+	qname := state.Name()
+
+	d.RLock()
+	defer d.RUnlock()
+
+	// Find the closest enclosing zone we have records for.
+	var z *denialZone
+	zone := qname
+	for ; zone != ""; zone = dnsutil.Parent(zone) {
+		if z = d.zone(zone); z != nil {
+			break
+		}
+	}
+	if z == nil {
+		return nil
+	}
+	if !z.soa.expire.After(now) {
+		d.zones.Remove(zoneHash(zone))
+		return nil
+	}
+
+	var (
+		rcode int
+		proof []*signed
+	)
+	if len(z.nsec) > 0 {
+		rcode, proof = z.nsecDenial(qname, state.QType(), zone, now)
+	}
+	if proof == nil && len(z.nsec3) > 0 {
+		rcode, proof = z.nsec3Denial(qname, state.QType(), zone, now)
+	}
+	if proof == nil {
+		return nil
+	}
+
+	// The TTL is the minimum of the remaining TTLs and the SOA's minimum TTL.
+	expire := z.soa.expire
+	for _, p := range proof {
+		if p.expire.Before(expire) {
+			expire = p.expire
+		}
+	}
+	ttl := uint32(expire.Sub(now).Seconds())
+	if min := z.soa.rr.(*dns.SOA).Minttl; min < ttl {
+		ttl = min
+	}
+	if ttl == 0 {
+		return nil
+	}
+
+	m := new(dns.Msg)
+	m.SetReply(state.Req)
+	m.Authoritative = true
+	m.RecursionAvailable = true
+	m.Rcode = rcode
+
+	do := state.Do()
+	m.AuthenticatedData = do || state.Req.AuthenticatedData
+	m.Ns = z.soa.records(ttl, do)
+	if !do {
+		// Clients that don't want DNSSEC only get the SOA record.
+		return m
+	}
+	dup := map[*signed]struct{}{}
+	for _, p := range proof {
+		if _, ok := dup[p]; ok {
+			continue
+		}
+		dup[p] = struct{}{}
+		m.Ns = append(m.Ns, p.records(ttl, do)...)
+	}
+	return m
+}
+
+// synthesizedType returns the label used in the synthesized metric for m.
+func synthesizedType(m *dns.Msg) string {
+	if m.Rcode == dns.RcodeNameError {
+		return "nxdomain"
+	}
+	return "nodata"
+}
+
+// records returns copies of s' record and, if do is true, its signatures with the TTL set to ttl.
+func (s *signed) records(ttl uint32, do bool) []dns.RR {
+	rrs := []dns.RR{dns.Copy(s.rr)}
+	if do {
+		for _, sig := range s.sigs {
+			rrs = append(rrs, dns.Copy(sig))
+		}
+	}
+	for _, rr := range rrs {
+		rr.Header().Ttl = ttl
+	}
+	return rrs
+}
+
+// nsecDenial returns the NSEC records that prove qname or qtype doesn't exist.
+func (z *denialZone) nsecDenial(qname string, qtype uint16, zone string, now time.Time) (int, []*signed) {
+	// NODATA: the name exists, but the type doesn't.
+	if s := z.nsecFind(qname, now); s != nil && s.key == qname {
+		bitmap := s.rr.(*dns.NSEC).TypeBitMap
+		if !nodata(bitmap, qtype) {
+			return 0, nil
+		}
+		return dns.RcodeSuccess, []*signed{s}
+	}
+
+	// NXDOMAIN: the name is covered, and so is the wildcard at the closest encloser.
+	covering := z.nsecFind(qname, now)
+	if covering == nil || !nsecCovers(covering, qname, zone) || !nsecUsable(covering, qname) {
+		return 0, nil
+	}
+	ce := dnsutil.ClosestEncloser(qname, covering.key, covering.next)
+	wildcard := "*." + ce
+	if ce == "." {
+		wildcard = "*."
+	}
+	wc := z.nsecFind(wildcard, now)
+	if wc == nil || wc.key == wildcard || !nsecCovers(wc, wildcard, zone) {
+		return 0, nil
+	}
+	return dns.RcodeNameError, []*signed{covering, wc}
+}
+
+// nsecFind returns the NSEC record with the largest owner name that sorts before or equal to name.
+func (z *denialZone) nsecFind(name string, now time.Time) *signed {
+	i := sort.Search(len(z.nsec), func(i int) bool { return dnsutil.CanonicalCompare(z.nsec[i].key, name) > 0 })
+	if i == 0 {
+		// Wrap around to the last record, it may cover names after the last owner name.
+		i = len(z.nsec)
+	}
+	s := z.nsec[i-1]
+	if !s.expire.After(now) {
+		return nil
+	}
+	return s
+}
+
+// nsecCovers returns true if name falls strictly between the owner and next name of s.
+func nsecCovers(s *signed, name, zone string) bool {
+	if dnsutil.CanonicalCompare(s.key, name) >= 0 {
+		return false
+	}
+	// The last NSEC in the zone points back to the apex.
+	if s.next == zone {
+		return true
+	}
+	return dnsutil.CanonicalCompare(name, s.next) < 0
+}
+
+// nsecUsable returns false when s is at a delegation point or a DNAME above name, then it can't deny
+// names below its owner.
+func nsecUsable(s *signed, name string) bool {
+	if !dns.IsSubDomain(s.key, name) {
+		return true
+	}
+	bitmap := s.rr.(*dns.NSEC).TypeBitMap
+	if dnsutil.HasType(bitmap, dns.TypeDNAME) {
+		return false
+	}
+	return !(dnsutil.HasType(bitmap, dns.TypeNS) && !dnsutil.HasType(bitmap, dns.TypeSOA))
+}
+
+// nsec3Denial returns the NSEC3 records that prove qname or qtype doesn't exist.
+func (z *denialZone) nsec3Denial(qname string, qtype uint16, zone string, now time.Time) (int, []*signed) {
+	hash := func(name string) string { return dns.HashName(name, z.hash, z.iterations, z.salt) }
+
+	h := hash(qname)
+	if h == "" {
+		return 0, nil
+	}
+	// NODATA: the name exists, but the type doesn't.
+	if s := z.nsec3Find(h, now); s != nil && s.key == h {
+		if !nodata(s.rr.(*dns.NSEC3).TypeBitMap, qtype) {
+			return 0, nil
+		}
+		return dns.RcodeSuccess, []*signed{s}
+	}
+
+	// NXDOMAIN: find the closest encloser, the next closer name and the wildcard must be covered.
+	nextCloser := qname
+	for ce := dnsutil.Parent(qname); ce != "" && dns.IsSubDomain(zone, ce); ce = dnsutil.Parent(ce) {
+		match := z.nsec3Find(hash(ce), now)
+		if match == nil || match.key != hash(ce) {
+			nextCloser = ce
+			continue
+		}
+		bitmap := match.rr.(*dns.NSEC3).TypeBitMap
+		if dnsutil.HasType(bitmap, dns.TypeDNAME) || (dnsutil.HasType(bitmap, dns.TypeNS) && !dnsutil.HasType(bitmap, dns.TypeSOA)) {
+			return 0, nil
+		}
+
+		nc := z.nsec3Find(hash(nextCloser), now)
+		if nc == nil || !nsec3Covers(nc, hash(nextCloser)) || nc.rr.(*dns.NSEC3).Flags&1 == 1 {
+			// Not covered, or opt-out: there may be an insecure delegation.
+			return 0, nil
+		}
+		wh := hash("*." + ce)
+		wc := z.nsec3Find(wh, now)
+		if wc == nil || !nsec3Covers(wc, wh) {
+			return 0, nil
+		}
+		return dns.RcodeNameError, []*signed{match, nc, wc}
+	}
+	return 0, nil
+}
+
+// nsec3Find returns the NSEC3 record with the largest hash that sorts before or equal to h.
+func (z *denialZone) nsec3Find(h string, now time.Time) *signed {
+	i := sort.Search(len(z.nsec3), func(i int) bool { return z.nsec3[i].key > h })
+	if i == 0 {
+		i = len(z.nsec3)
+	}
+	s := z.nsec3[i-1]
+	if !s.expire.After(now) {
+		return nil
+	}
+	return s
+}
+
+// nsec3Covers returns true if h falls strictly between the hashed owner and next hash of s.
+func nsec3Covers(s *signed, h string) bool {
+	if s.key < s.next {
+		return s.key < h && h < s.next
+	}
+	// Last record in the chain, wraps around.
+	return h > s.key || h < s.next
+}
+
+// nodata returns true if the bitmap proves there is no record of qtype, nor a CNAME.
+func nodata(bitmap []uint16, qtype uint16) bool {
+	if dnsutil.HasType(bitmap, qtype) || dnsutil.HasType(bitmap, dns.TypeCNAME) {
+		return false
+	}
+	// A DS record lives in the parent zone, the child's apex can't deny it.
+	if qtype == dns.TypeDS && dnsutil.HasType(bitmap, dns.TypeSOA) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,236 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+func newRR(s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		panic(err)
+	}
+	return rr
+}
+
+func sig(name, covered string) dns.RR {
+	return newRR(name + " 3600 IN RRSIG " + covered + " 8 2 3600 20300101000000 20200101000000 12345 example.org. AAAA")
+}
+
+// nsecDenial returns a validated NXDOMAIN for b.example.org. from the zone example.org. with
+// the names example.org., a.example.org. and d.example.org.
+func nsecDenial() *dns.Msg {
+	m := new(dns.Msg)
+	m.SetQuestion("b.example.org.", dns.TypeA)
+	m.Response, m.AuthenticatedData, m.Rcode = true, true, dns.RcodeNameError
+	m.Ns = []dns.RR{
+		test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 300"),
+		sig("example.org.", "SOA"),
+		newRR("a.example.org. 3600 IN NSEC d.example.org. A RRSIG NSEC"),
+		sig("a.example.org.", "NSEC"),
+		newRR("example.org. 3600 IN NSEC a.example.org. NS SOA RRSIG NSEC DNSKEY"),
+		sig("example.org.", "NSEC"),
+	}
+	return m
+}
+
+func TestAggressiveNSEC(t *testing.T) {
+	now := time.Now().UTC()
+	d := newDenialCache(defaultCap)
+	d.add(nsecDenial(), now)
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		do    bool
+		rcode int  // expected rcode
+		nil   bool // no answer can be synthesized
+		ns    int  // number of records in the authority section
+	}{
+		{qname: "c.example.org.", qtype: dns.TypeA, do: true, rcode: dns.RcodeNameError, ns: 6},
+		{qname: "x.c.example.org.", qtype: dns.TypeA, do: true, rcode: dns.RcodeNameError, ns: 6},
+		{qname: "c.example.org.", qtype: dns.TypeA, do: false, rcode: dns.RcodeNameError, ns: 1},
+		{qname: "a.example.org.", qtype: dns.TypeMX, do: true, rcode: dns.RcodeSuccess, ns: 4},
+		{qname: "a.example.org.", qtype: dns.TypeA, nil: true},
+		{qname: "e.example.org.", qtype: dns.TypeA, nil: true}, // range not cached
+		{qname: "example.org.", qtype: dns.TypeDS, nil: true},  // apex can't deny DS
+		{qname: "example.net.", qtype: dns.TypeA, nil: true},
+	}
+
+	for i, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion(tc.qname, tc.qtype)
+		if tc.do {
+			req.SetEdns0(4096, true)
+		}
+		m := d.synthesize(request.Request{W: &test.ResponseWriter{}, Req: req}, now.Add(10*time.Second))
+		if tc.nil {
+			if m != nil {
+				t.Errorf("Test %d: expected no synthesized answer, got %s", i, m)
+			}
+			continue
+		}
+		if m == nil {
+			t.Errorf("Test %d: expected a synthesized answer, got none", i)
+			continue
+		}
+		if m.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[m.Rcode])
+		}
+		if len(m.Ns) != tc.ns {
+			t.Errorf("Test %d: expected %d records in the authority section, got %d", i, tc.ns, len(m.Ns))
+		}
+		if m.Ns[0].Header().Ttl != 300 {
+			t.Errorf("Test %d: expected TTL of 300, got %d", i, m.Ns[0].Header().Ttl)
+		}
+	}
+}
+
+func TestAggressiveNSEC3(t *testing.T) {
+	hash := func(name string) string { return dns.HashName(name, dns.SHA1, 0, "") }
+
+	// The zone has the names example.org. and a.example.org., the two NSEC3 records cover all other hashes.
+	hashes := []string{hash("example.org."), hash("a.example.org.")}
+	types := map[string]string{hashes[0]: "NS SOA RRSIG DNSKEY NSEC3PARAM", hashes[1]: "A RRSIG"}
+	sort.Strings(hashes)
+
+	for _, flags := range []string{"0", "1"} {
+		m := new(dns.Msg)
+		m.SetQuestion("b.example.org.", dns.TypeA)
+		m.Response, m.AuthenticatedData, m.Rcode = true, true, dns.RcodeNameError
+		m.Ns = []dns.RR{
+			test.SOA("example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 1 3600 600 86400 300"),
+			sig("example.org.", "SOA"),
+		}
+		for i, h := range hashes {
+			owner := h + ".example.org."
+			next := hashes[(i+1)%len(hashes)]
+			m.Ns = append(m.Ns, newRR(owner+" 3600 IN NSEC3 1 "+flags+" 0 - "+next+" "+types[h]), sig(owner, "NSEC3"))
+		}
+
+		d := newDenialCache(defaultCap)
+		now := time.Now().UTC()
+		d.add(m, now)
+
+		tests := []struct {
+			qname string
+			qtype uint16
+			rcode int
+			nil   bool
+		}{
+			{qname: "x.example.org.", qtype: dns.TypeA, rcode: dns.RcodeNameError, nil: flags == "1"},
+			{qname: "a.example.org.", qtype: dns.TypeMX, rcode: dns.RcodeSuccess},
+			{qname: "a.example.org.", qtype: dns.TypeA, nil: true},
+		}
+		for i, tc := range tests {
+			req := new(dns.Msg)
+			req.SetQuestion(tc.qname, tc.qtype)
+			req.SetEdns0(4096, true)
+			m := d.synthesize(request.Request{W: &test.ResponseWriter{}, Req: req}, now)
+			if tc.nil {
+				if m != nil {
+					t.Errorf("Test %d, flags %s: expected no synthesized answer, got %s", i, flags, m)
+				}
+				continue
+			}
+			if m == nil {
+				t.Errorf("Test %d, flags %s: expected a synthesized answer, got none", i, flags)
+				continue
+			}
+			if m.Rcode != tc.rcode {
+				t.Errorf("Test %d, flags %s: expected rcode %s, got %s", i, flags, dns.RcodeToString[tc.rcode], dns.RcodeToString[m.Rcode])
+			}
+		}
+	}
+}
+
+func TestAggressiveZones(t *testing.T) {
+	now := time.Now().UTC()
+	d := newDenialCache(defaultCap)
+	d.add(nsecDenial(), now)
+
+	// A zone is dropped when its SOA expired.
+	req := new(dns.Msg)
+	req.SetQuestion("c.example.org.", dns.TypeA)
+	if m := d.synthesize(request.Request{W: &test.ResponseWriter{}, Req: req}, now.Add(2*time.Hour)); m != nil {
+		t.Errorf("Expected no synthesized answer after the SOA expired, got %s", m)
+	}
+	if x := d.zones.Len(); x != 0 {
+		t.Errorf("Expected the expired zone to be dropped, got %d zones", x)
+	}
+
+	// The number of zones is capped.
+	for i := 0; i < 2*maxDenialZones; i++ {
+		zone := fmt.Sprintf("z%d.", i)
+		m := new(dns.Msg)
+		m.SetQuestion("b."+zone, dns.TypeA)
+		m.Response, m.AuthenticatedData, m.Rcode = true, true, dns.RcodeNameError
+		m.Ns = []dns.RR{
+			test.SOA(zone + " 3600 IN SOA ns." + zone + " hostmaster." + zone + " 1 3600 600 86400 300"),
+			newRR(zone + " 3600 IN RRSIG SOA 8 1 3600 20300101000000 20200101000000 12345 " + zone + " AAAA"),
+			newRR("a." + zone + " 3600 IN NSEC d." + zone + " A RRSIG NSEC"),
+			newRR("a." + zone + " 3600 IN RRSIG NSEC 8 2 3600 20300101000000 20200101000000 12345 " + zone + " AAAA"),
+		}
+		d.add(m, now)
+	}
+	if x := d.zones.Len(); x == 0 || x > maxDenialZones {
+		t.Errorf("Expected at most %d zones, got %d", maxDenialZones, x)
+	}
+}
+
+func TestAggressiveNotValidated(t *testing.T) {
+	m := nsecDenial()
+	m.AuthenticatedData = false
+
+	c := New()
+	c.denial = newDenialCache(defaultCap)
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		w.WriteMsg(m)
+		return dns.RcodeNameError, nil
+	})
+
+	req := new(dns.Msg)
+	req.SetQuestion("b.example.org.", dns.TypeA)
+	c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+
+	if x := c.denial.zones.Len(); x != 0 {
+		t.Errorf("Expected no denial records for a response without the AD bit, got %d zones", x)
+	}
+}
+
+func TestAggressiveServeDNS(t *testing.T) {
+	calls := 0
+	c := New()
+	c.denial = newDenialCache(defaultCap)
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		calls++
+		m := nsecDenial()
+		m.SetReply(r)
+		m.Rcode = dns.RcodeNameError
+		w.WriteMsg(m)
+		return dns.RcodeNameError, nil
+	})
+
+	for _, name := range []string{"b.example.org.", "c.example.org."} {
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, req)
+		if rec.Msg == nil || rec.Msg.Rcode != dns.RcodeNameError {
+			t.Fatalf("Expected NXDOMAIN for %s, got %v", name, rec.Msg)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 query to the next plugin, got %d", calls)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/coredns/coredns/plugin/pkg/cache"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/plugin/pkg/response"
+	"github.com/coredns/coredns/plugin/pkg/singleflight"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -31,6 +32,22 @@ type Cache struct {
 	pttl    time.Duration
 	minpttl time.Duration
 
+	// Eviction policy of both caches.
+	policy cache.Policy
+
+	// scopes holds the EDNS0 Client Subnet scope prefix lengths of names, see ecs.go.
+	scopes *cache.Cache
+
+	// denial holds the validated NSEC and NSEC3 records used to synthesize negative answers, nil when disabled.
+	denial *denialCache
+
+	// inflight coalesces concurrent misses for the same query.
+	inflight *singleflight.Group
+
+	// Persist the cache to this file every persistInterval.
+	persist         string
+	persistInterval time.Duration
+
 	// Prefetch.
 	prefetch   int
 	duration   time.Duration
@@ -56,6 +73,8 @@ func New() *Cache {
 		prefetch:   0,
 		duration:   1 * time.Minute,
 		percentage: 10,
+		scopes:     cache.New(defaultCap),
+		inflight:   new(singleflight.Group),
 		now:        time.Now,
 	}
 }
@@ -158,6 +177,9 @@ func (w *ResponseWriter) WriteMsg(res *dns.Msg) error {
 
 	// key returns empty string for anything we don't want to cache.
 	hasKey, key := key(w.state.Name(), res, mt, do)
+	if hasKey {
+		key = w.storeKey(key, w.state, res)
+	}
 
 	msgTTL := dnsutil.MinimalTTL(res, mt)
 	var duration time.Duration
@@ -207,11 +229,18 @@ func (w *ResponseWriter) set(m *dns.Msg, key uint64, mt response.Type, duration
 	switch mt {
 	case response.NoError, response.Delegation:
 		i := newItem(m, w.now(), duration)
-		w.pcache.Add(key, i)
+		if w.pcache.Add(key, i) {
+			cacheEvictions.WithLabelValues(w.server, Success).Inc()
+		}
 
 	case response.NameError, response.NoData, response.ServerError:
 		i := newItem(m, w.now(), duration)
-		w.ncache.Add(key, i)
+		if w.ncache.Add(key, i) {
+			cacheEvictions.WithLabelValues(w.server, Denial).Inc()
+		}
+		if w.denial != nil && mt != response.ServerError && m.AuthenticatedData {
+			w.denial.add(m, w.now().UTC())
+		}
 
 	case response.OtherError:
 		// don't cache these
@@ -2,15 +2,21 @@ package cache
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
 	"github.com/coredns/coredns/plugin/pkg/response"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
 )
 
 type cacheTestCase struct {
@@ -317,3 +323,108 @@ func TestComputeTTL(t *testing.T) {
 		}
 	}
 }
+
+func TestCacheCoalesce(t *testing.T) {
+	var calls int32
+	entered := make(chan struct{})
+	release := make(chan struct{})
+
+	c := New()
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(entered)
+		}
+		<-release
+		return BackendHandler().ServeDNS(ctx, w, r)
+	})
+
+	const n = 10
+	recs := make([]*dnstest.Recorder, n)
+	var wg sync.WaitGroup
+	serve := func(i int) {
+		defer wg.Done()
+		req := new(dns.Msg)
+		req.SetQuestion("Example.org.", dns.TypeA)
+		req.Id = uint16(i)
+		recs[i] = dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), recs[i], req)
+	}
+
+	wg.Add(n)
+	go serve(0)
+	<-entered
+	for i := 1; i < n; i++ {
+		go serve(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if x := atomic.LoadInt32(&calls); x != 1 {
+		t.Errorf("Expected 1 query to the next plugin, got %d", x)
+	}
+	for i, rec := range recs {
+		if rec.Msg == nil {
+			t.Fatalf("Request %d: expected a response, got none", i)
+		}
+		if rec.Msg.Id != uint16(i) {
+			t.Errorf("Request %d: expected id %d, got %d", i, i, rec.Msg.Id)
+		}
+		if len(rec.Msg.Answer) != 1 {
+			t.Errorf("Request %d: expected 1 answer, got %d", i, len(rec.Msg.Answer))
+		}
+	}
+}
+
+func TestCacheCoalesceError(t *testing.T) {
+	release := make(chan struct{})
+	entered := make(chan struct{})
+	var calls int32
+
+	c := New()
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			close(entered)
+		}
+		<-release
+		return dns.RcodeServerFailure, nil
+	})
+
+	rcodes := make(chan int, 2)
+	serve := func() {
+		req := new(dns.Msg)
+		req.SetQuestion("example.org.", dns.TypeA)
+		rcode, _ := c.ServeDNS(context.TODO(), &test.ResponseWriter{}, req)
+		rcodes <- rcode
+	}
+
+	go serve()
+	<-entered
+	go serve()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < 2; i++ {
+		if rcode := <-rcodes; rcode != dns.RcodeServerFailure {
+			t.Errorf("Expected rcode %d, got %d", dns.RcodeServerFailure, rcode)
+		}
+	}
+}
+
+func TestCacheSpanTag(t *testing.T) {
+	c := New()
+	c.Next = BackendHandler()
+	tracer := mocktracer.New()
+
+	for _, want := range []string{"miss", "hit"} {
+		span := tracer.StartSpan("cache")
+		req := new(dns.Msg)
+		req.SetQuestion("example.org.", dns.TypeA)
+		c.ServeDNS(ot.ContextWithSpan(context.TODO(), span), &test.ResponseWriter{}, req)
+		span.Finish()
+
+		if x := span.(*mocktracer.MockSpan).Tag(trace.TagCache); x != want {
+			t.Errorf("Expected tag %s to be %q, got %v", trace.TagCache, want, x)
+		}
+	}
+}
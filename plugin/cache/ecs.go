@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"net"
+
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// Responses carrying an EDNS0 Client Subnet option with a non-zero scope prefix length are only valid for
+// clients in that subnet (RFC 7871). They are stored under a key that includes the subnet. The scope is
+// remembered per name, type and family in c.scopes, so lookups can mask the client's address with it.
+
+// subnet returns the family, address and source prefix length of the client of state. This is the
+// EDNS0 Client Subnet option of the request if it has one, otherwise the address of the client.
+func subnet(state request.Request) (uint16, net.IP, uint8) {
+	if e := edns.Subnet(state.Req); e != nil {
+		return e.Family, e.Address, e.SourceNetmask
+	}
+	if state.W == nil {
+		return 0, nil, 0
+	}
+	if state.Family() == 1 {
+		return 1, net.ParseIP(state.IP()), net.IPv4len * 8
+	}
+	return 2, net.ParseIP(state.IP()), net.IPv6len * 8
+}
+
+// subnetHash returns the key k extended with the family and (masked) address of a subnet.
+func subnetHash(k uint64, family uint16, ip net.IP) uint64 {
+	h := fnv.New64()
+	b := make([]byte, 10)
+	binary.BigEndian.PutUint64(b, k)
+	binary.BigEndian.PutUint16(b[8:], family)
+	h.Write(b)
+	h.Write(ip)
+	return h.Sum64()
+}
+
+// lookupKey returns the key to look up state with. It returns false if the response for the name was
+// scoped to a subnet longer than the request's source prefix, such a request can't be answered from the cache.
+func (c *Cache) lookupKey(state request.Request) (uint64, bool) {
+	k := hash(state.Name(), state.QType(), state.Do())
+	family, ip, bits := subnet(state)
+
+	s, ok := c.scopes.Get(subnetHash(k, family, nil))
+	if !ok {
+		return k, true
+	}
+	scope := s.(uint8)
+	if scope == 0 {
+		return k, true
+	}
+	if bits < scope {
+		return k, false
+	}
+	return subnetHash(k, family, edns.MaskSubnet(family, ip, scope)), true
+}
+
+// flightKey returns the key under which concurrent misses for state are coalesced. Until the response
+// for the name is known to have a scope of 0, requests are only coalesced with requests for the same
+// subnet: the request's EDNS0 Client Subnet option, or the client's address. A plugin further down,
+// e.g. forward with ecs, may add the client's subnet to the query and get an answer scoped to it.
+func (c *Cache) flightKey(state request.Request) uint64 {
+	k, ok := c.lookupKey(state)
+	if ok && k != hash(state.Name(), state.QType(), state.Do()) {
+		return k
+	}
+	family, ip, bits := subnet(state)
+	if s, known := c.scopes.Get(subnetHash(k, family, nil)); known && s.(uint8) == 0 {
+		return k
+	}
+	return subnetHash(k, family, edns.MaskSubnet(family, ip, bits))
+}
+
+// storeKey returns the key to store the response m to state under, k is the key of the name, type and
+// DO bit. The scope of m's EDNS0 Client Subnet option is remembered for the following lookups, a
+// response without the option has a scope of 0.
+func (c *Cache) storeKey(k uint64, state request.Request, m *dns.Msg) uint64 {
+	e := edns.Subnet(m)
+	if e == nil || e.SourceScope == 0 {
+		family, _, _ := subnet(state)
+		if e != nil {
+			family = e.Family
+		}
+		c.scopes.Add(subnetHash(k, family, nil), uint8(0))
+		return k
+	}
+
+	c.scopes.Add(subnetHash(k, e.Family, nil), e.SourceScope)
+
+	// A scope longer than the source prefix is treated as equal to it, RFC 7871, section 7.3.1. Lookups
+	// still use the scope, so the shorter prefix doesn't cause misses for all other clients.
+	scope := e.SourceScope
+	if scope > e.SourceNetmask {
+		scope = e.SourceNetmask
+	}
+	return subnetHash(k, e.Family, edns.MaskSubnet(e.Family, e.Address, scope))
+}
@@ -0,0 +1,141 @@
+package cache
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// ecsBackend answers with the address of the request's subnet, scoped to scope bits.
+func ecsBackend(scope uint8, calls *int) plugin.Handler {
+	return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		*calls++
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Response, m.RecursionAvailable = true, true
+		addr := "127.0.0.1"
+		if e := edns.Subnet(r); e != nil {
+			addr = e.Address.String()
+			m.SetEdns0(4096, false)
+			m.IsEdns0().Option = []dns.EDNS0{&dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: e.Family,
+				SourceNetmask: e.SourceNetmask, SourceScope: scope, Address: e.Address}}
+		}
+		m.Answer = []dns.RR{test.A("example.org. 300 IN A " + addr)}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+}
+
+func TestCacheSubnet(t *testing.T) {
+	tests := []struct {
+		subnet string
+		bits   uint8
+		calls  int    // expected number of calls to the next plugin after this query
+		answer string // expected address in the answer
+	}{
+		{"10.1.1.1", 24, 1, "10.1.1.0"},
+		{"10.1.1.2", 24, 1, "10.1.1.0"}, // cached, same /24
+		{"10.2.2.2", 24, 2, "10.2.2.0"}, // different /24
+		{"10.1.1.1", 16, 3, "10.1.0.0"}, // source prefix shorter than the scope
+		{"10.2.2.3", 24, 3, "10.2.2.0"},
+	}
+
+	calls := 0
+	c := New()
+	c.Next = ecsBackend(24, &calls)
+
+	for i, tc := range tests {
+		req := new(dns.Msg)
+		req.SetQuestion("example.org.", dns.TypeA)
+		edns.SetSubnet(req, net.ParseIP(tc.subnet), tc.bits, 0)
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		c.ServeDNS(context.TODO(), rec, req)
+
+		if calls != tc.calls {
+			t.Errorf("Test %d: expected %d calls to the next plugin, got %d", i, tc.calls, calls)
+		}
+		if rec.Msg == nil || len(rec.Msg.Answer) != 1 {
+			t.Fatalf("Test %d: expected 1 answer, got %v", i, rec.Msg)
+		}
+		if a := rec.Msg.Answer[0].(*dns.A).A.String(); a != tc.answer {
+			t.Errorf("Test %d: expected answer %s, got %s", i, tc.answer, a)
+		}
+	}
+}
+
+func TestCacheSubnetScopeZero(t *testing.T) {
+	calls := 0
+	c := New()
+	c.Next = ecsBackend(0, &calls)
+
+	for _, subnet := range []string{"10.1.1.1", "10.2.2.2"} {
+		req := new(dns.Msg)
+		req.SetQuestion("example.org.", dns.TypeA)
+		edns.SetSubnet(req, net.ParseIP(subnet), 24, 0)
+		c.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), req)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 call to the next plugin for a response with scope 0, got %d", calls)
+	}
+}
+
+func TestCacheCoalesceSubnet(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+
+	// Answer with the client's /24, like an upstream that got the subnet from forward's ecs option.
+	c := New()
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		ip := edns.MaskSubnet(1, net.ParseIP(w.RemoteAddr().(*net.UDPAddr).IP.String()), 24)
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.SetEdns0(4096, false)
+		m.IsEdns0().Option = []dns.EDNS0{&dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1,
+			SourceNetmask: 24, SourceScope: 24, Address: ip}}
+		m.Answer = []dns.RR{test.A("example.org. 300 IN A " + ip.String())}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	clients := []string{"10.0.0.1", "192.168.1.1"}
+	recs := make([]*dnstest.Recorder, len(clients))
+	var wg sync.WaitGroup
+	for i, client := range clients {
+		wg.Add(1)
+		go func(i int, client string) {
+			defer wg.Done()
+			req := new(dns.Msg)
+			req.SetQuestion("example.org.", dns.TypeA)
+			recs[i] = dnstest.NewRecorder(&test.ResponseWriter{RemoteIP: client})
+			c.ServeDNS(context.TODO(), recs[i], req)
+		}(i, client)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if x := atomic.LoadInt32(&calls); x != 2 {
+		t.Errorf("Expected 2 queries to the next plugin, got %d", x)
+	}
+	for i, expected := range []string{"10.0.0.0", "192.168.1.0"} {
+		if recs[i].Msg == nil || len(recs[i].Msg.Answer) != 1 {
+			t.Fatalf("Client %s: expected 1 answer, got %v", clients[i], recs[i].Msg)
+		}
+		if a := recs[i].Msg.Answer[0].(*dns.A).A.String(); a != expected {
+			t.Errorf("Client %s: expected answer %s, got %s", clients[i], expected, a)
+		}
+	}
+}
@@ -7,9 +7,11 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -28,6 +30,7 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 
 	i, found := c.get(now, state, server)
 	if i != nil && found {
+		spanTag(ctx, "hit")
 		resp := i.toMsg(r, now)
 
 		w.WriteMsg(resp)
@@ -55,15 +58,84 @@ func (c *Cache) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		return dns.RcodeSuccess, nil
 	}
 
-	crr := &ResponseWriter{ResponseWriter: w, Cache: c, state: state, server: server}
-	return plugin.NextOrFailure(c.Name(), c.Next, ctx, crr, r)
+	if c.denial != nil {
+		if m := c.denial.synthesize(state, now); m != nil {
+			spanTag(ctx, "synthesized")
+			cacheSynthesized.WithLabelValues(server, synthesizedType(m)).Inc()
+			w.WriteMsg(m)
+			return m.Rcode, nil
+		}
+	}
+
+	spanTag(ctx, "miss")
+	return c.coalesce(ctx, w, r, state, server)
+}
+
+// spanTag records how the query was handled on the span of the cache, if the query is traced.
+func spanTag(ctx context.Context, v string) {
+	if span := ot.SpanFromContext(ctx); span != nil {
+		span.SetTag(trace.TagCache, v)
+	}
+}
+
+// flight is the result of a query to the next plugin, shared with all concurrent misses for the same key.
+type flight struct {
+	msg   *dns.Msg // nil when nothing was written
+	rcode int
+	err   error
+}
+
+// coalesce sends the query to the next plugin, unless the same query is already in flight, then
+// we wait for that one to complete and write a copy of its response.
+func (c *Cache) coalesce(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, state request.Request, server string) (int, error) {
+	leader := false
+	v, _ := c.inflight.Do(c.flightKey(state), func() (interface{}, error) {
+		leader = true
+		rec := &recorder{ResponseWriter: w}
+		crr := &ResponseWriter{ResponseWriter: rec, Cache: c, state: state, server: server}
+		rcode, err := plugin.NextOrFailure(c.Name(), c.Next, ctx, crr, r)
+		return &flight{msg: rec.msg, rcode: rcode, err: err}, nil
+	})
+
+	f := v.(*flight)
+	if leader {
+		return f.rcode, f.err
+	}
+
+	cacheCoalesced.WithLabelValues(server).Inc()
+	if f.msg == nil {
+		return f.rcode, f.err
+	}
+
+	m := f.msg.Copy()
+	m.Id = r.Id
+	m.Question = r.Question
+	w.WriteMsg(m)
+	return f.rcode, f.err
+}
+
+// recorder records a copy of the message written, so it can be shared with coalesced requests.
+type recorder struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (r *recorder) WriteMsg(m *dns.Msg) error {
+	// Copy before writing, the server may truncate the message to fit the client's buffer.
+	r.msg = m.Copy()
+	return r.ResponseWriter.WriteMsg(m)
 }
 
 // Name implements the Handler interface.
 func (c *Cache) Name() string { return "cache" }
 
 func (c *Cache) get(now time.Time, state request.Request, server string) (*item, bool) {
-	k := hash(state.Name(), state.QType(), state.Do())
+	k, ok := c.lookupKey(state)
+	if !ok {
+		cacheMisses.WithLabelValues(server).Inc()
+		return nil, false
+	}
 
 	if i, ok := c.ncache.Get(k); ok && i.(*item).ttl(now) > 0 {
 		cacheHits.WithLabelValues(server, Denial).Inc()
@@ -79,7 +151,10 @@ func (c *Cache) get(now time.Time, state request.Request, server string) (*item,
 }
 
 func (c *Cache) exists(state request.Request) *item {
-	k := hash(state.Name(), state.QType(), state.Do())
+	k, ok := c.lookupKey(state)
+	if !ok {
+		return nil
+	}
 	if i, ok := c.ncache.Get(k); ok {
 		return i.(*item)
 	}
@@ -111,6 +186,27 @@ var (
 		Help:      "The count of cache misses.",
 	}, []string{"server"})
 
+	cacheEvictions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "The count of elements evicted from, or not admitted to, the cache.",
+	}, []string{"server", "type"})
+
+	cacheCoalesced = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "coalesced_total",
+		Help:      "The count of cache misses that waited for the same query already in flight.",
+	}, []string{"server"})
+
+	cacheSynthesized = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "cache",
+		Name:      "synthesized_total",
+		Help:      "The count of negative responses synthesized from cached NSEC and NSEC3 records.",
+	}, []string{"server", "type"})
+
 	cachePrefetches = prometheus.NewCounterVec(prometheus.CounterOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: "cache",
@@ -0,0 +1,161 @@
+package cache
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/coredns/plugin/cache/freq"
+
+	"github.com/miekg/dns"
+)
+
+// entry is an item as it is written to the persist file, one JSON object per line.
+type entry struct {
+	Key    uint64    `json:"key"`
+	Denial bool      `json:"denial,omitempty"`
+	Stored time.Time `json:"stored"`
+	TTL    uint32    `json:"ttl"`
+	Msg    []byte    `json:"msg"` // the item's sections, rcode and flags as a packed message
+}
+
+// defaultPersistInterval is the default interval between snapshots of the cache.
+const defaultPersistInterval = 5 * time.Minute
+
+// save writes all items that haven't expired to the persist file. The file is replaced
+// atomically, so a crash while saving leaves the previous snapshot intact.
+func (c *Cache) save() error {
+	tmp, err := ioutil.TempFile(filepath.Dir(c.persist), filepath.Base(c.persist)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after the rename
+
+	buf := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(buf)
+	now := c.now().UTC()
+
+	var werr error
+	walk := func(denial bool) func(uint64, interface{}) bool {
+		return func(key uint64, el interface{}) bool {
+			i := el.(*item)
+			if i.ttl(now) <= 0 {
+				return true
+			}
+			msg, err := i.pack()
+			if err != nil {
+				return true
+			}
+			werr = enc.Encode(entry{Key: key, Denial: denial, Stored: i.stored, TTL: i.origTTL, Msg: msg})
+			return werr == nil
+		}
+	}
+	c.pcache.Walk(walk(false))
+	if werr == nil {
+		c.ncache.Walk(walk(true))
+	}
+	if werr == nil {
+		werr = buf.Flush()
+	}
+	if err := tmp.Close(); werr == nil {
+		werr = err
+	}
+	if werr != nil {
+		return werr
+	}
+	return os.Rename(tmp.Name(), c.persist)
+}
+
+// load adds the items from the persist file to the cache, expired items are skipped. It returns
+// the number of items added.
+func (c *Cache) load() (int, error) {
+	f, err := os.Open(c.persist)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	defer f.Close()
+
+	now := c.now().UTC()
+	n := 0
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for dec.More() {
+		e := entry{}
+		if err := dec.Decode(&e); err != nil {
+			return n, err
+		}
+		i, err := unpackItem(e.Msg)
+		if err != nil {
+			continue
+		}
+		i.origTTL = e.TTL
+		i.stored = e.Stored
+		if i.ttl(now) <= 0 {
+			continue
+		}
+
+		ca := c.pcache
+		if e.Denial {
+			ca = c.ncache
+		}
+		ca.Add(e.Key, i)
+		n++
+	}
+	return n, nil
+}
+
+// pack returns the wire format of a message holding i's sections, rcode and flags.
+func (i *item) pack() ([]byte, error) {
+	m := new(dns.Msg)
+	m.Rcode = i.Rcode
+	m.AuthenticatedData = i.AuthenticatedData
+	m.RecursionAvailable = i.RecursionAvailable
+	m.Answer = i.Answer
+	m.Ns = i.Ns
+	m.Extra = i.Extra
+	return m.Pack()
+}
+
+// unpackItem returns the item packed in buf, its TTL and time of storage are not set.
+func unpackItem(buf []byte) (*item, error) {
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return nil, err
+	}
+	i := &item{
+		Rcode:              m.Rcode,
+		AuthenticatedData:  m.AuthenticatedData,
+		RecursionAvailable: m.RecursionAvailable,
+		Answer:             m.Answer,
+		Ns:                 m.Ns,
+		Extra:              m.Extra,
+		Freq:               new(freq.Freq),
+	}
+	return i, nil
+}
+
+// periodicSave saves the cache every interval until the returned channel is closed.
+func periodicSave(c *Cache, interval time.Duration) chan bool {
+	stop := make(chan bool)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := c.save(); err != nil {
+					log.Warningf("Failed to save cache to %q: %s", c.persist, err)
+				}
+			}
+		}
+	}()
+	return stop
+}
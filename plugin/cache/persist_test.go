@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	c := New()
+	c.persist = filepath.Join(dir, "cache.json")
+	c.now = func() time.Time { return now }
+	c.Next = plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.RecursionAvailable = true
+		switch r.Question[0].Name {
+		case "short.example.org.":
+			m.Answer = []dns.RR{test.A("short.example.org. 10 IN A 127.0.0.1")}
+		case "long.example.org.":
+			m.Answer = []dns.RR{test.A("long.example.org. 300 IN A 127.0.0.2")}
+		default:
+			m.Rcode = dns.RcodeNameError
+			m.Ns = []dns.RR{test.SOA("example.org. 300 IN SOA ns.example.org. hostmaster.example.org. 1 7200 1800 86400 300")}
+		}
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	})
+
+	for _, name := range []string{"short.example.org.", "long.example.org.", "nx.example.org."} {
+		req := new(dns.Msg)
+		req.SetQuestion(name, dns.TypeA)
+		c.ServeDNS(context.TODO(), &test.ResponseWriter{}, req)
+	}
+
+	if err := c.save(); err != nil {
+		t.Fatalf("Failed to save cache: %s", err)
+	}
+
+	// Load in a new cache a minute later, the short lived item has expired by then.
+	c1 := New()
+	c1.persist = c.persist
+	c1.now = func() time.Time { return now.Add(1 * time.Minute) }
+	c1.Next = test.ErrorHandler()
+
+	n, err := c1.load()
+	if err != nil {
+		t.Fatalf("Failed to load cache: %s", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 items to be loaded, got %d", n)
+	}
+	if x := c1.pcache.Len(); x != 1 {
+		t.Errorf("Expected 1 item in the positive cache, got %d", x)
+	}
+	if x := c1.ncache.Len(); x != 1 {
+		t.Errorf("Expected 1 item in the negative cache, got %d", x)
+	}
+
+	tests := []test.Case{
+		{
+			Qname: "long.example.org.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("long.example.org. 240 IN A 127.0.0.2")},
+		},
+		{
+			Qname: "nx.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError,
+			Ns: []dns.RR{test.SOA("example.org. 240 IN SOA ns.example.org. hostmaster.example.org. 1 7200 1800 86400 300")},
+		},
+	}
+	for i, tc := range tests {
+		rec := &test.ResponseWriter{}
+		w := &recorder{ResponseWriter: rec}
+		c1.ServeDNS(context.TODO(), w, tc.Msg())
+		if w.msg == nil {
+			t.Fatalf("Test %d: expected a response from the cache", i)
+		}
+		if err := test.SortAndCheck(w.msg, tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
+
+func TestPersistNoFile(t *testing.T) {
+	c := New()
+	c.persist = filepath.Join(os.TempDir(), "coredns-cache-does-not-exist.json")
+	n, err := c.load()
+	if err != nil || n != 0 {
+		t.Errorf("Expected nothing to be loaded without error, got %d items and error %v", n, err)
+	}
+}
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"time"
 
@@ -33,10 +34,38 @@ func setup(c *caddy.Controller) error {
 		return ca
 	})
 
+	if ca.persist != "" {
+		var stop chan bool
+		c.OnStartup(func() error {
+			n, err := ca.load()
+			if err != nil {
+				log.Warningf("Failed to load cache from %q: %s", ca.persist, err)
+			}
+			log.Infof("Loaded %d items from %q", n, ca.persist)
+			stop = periodicSave(ca, ca.persistInterval)
+			return nil
+		})
+		save := func() error {
+			if err := ca.save(); err != nil {
+				log.Warningf("Failed to save cache to %q: %s", ca.persist, err)
+			}
+			return nil
+		}
+		// Save on restart as well, the new instance loads the file before this one is shut down.
+		c.OnRestart(save)
+		c.OnFinalShutdown(save)
+		c.OnShutdown(func() error {
+			if stop != nil {
+				close(stop)
+			}
+			return nil
+		})
+	}
+
 	c.OnStartup(func() error {
 		metrics.MustRegister(c,
 			cacheSize, cacheHits, cacheMisses,
-			cachePrefetches, cacheDrops)
+			cacheEvictions, cacheCoalesced, cacheSynthesized, cachePrefetches, cacheDrops)
 		return nil
 	})
 
@@ -45,8 +74,10 @@ func setup(c *caddy.Controller) error {
 
 func cacheParse(c *caddy.Controller) (*Cache, error) {
 	ca := New()
+	config := dnsserver.GetConfig(c)
 
 	j := 0
+	aggressive := false
 	for c.Next() {
 		if j > 0 {
 			return nil, plugin.ErrOnce
@@ -180,6 +211,41 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 					}
 					ca.percentage = num
 				}
+			case "persist":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				ca.persist = args[0]
+				if !filepath.IsAbs(ca.persist) && config.Root != "" {
+					ca.persist = filepath.Join(config.Root, ca.persist)
+				}
+				ca.persistInterval = defaultPersistInterval
+				if len(args) > 1 {
+					interval, err := time.ParseDuration(args[1])
+					if err != nil {
+						return nil, c.Errf("invalid duration for persist '%s'", args[1])
+					}
+					if interval <= 0 {
+						return nil, c.Errf("persist interval should be positive: %s", interval)
+					}
+					ca.persistInterval = interval
+				}
+			case "aggressive_nsec":
+				if len(c.RemainingArgs()) != 0 {
+					return nil, c.ArgErr()
+				}
+				aggressive = true
+			case "policy":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				policy, err := cache.ParsePolicy(args[0])
+				if err != nil {
+					return nil, c.Errf("%s", err)
+				}
+				ca.policy = policy
 
 			default:
 				return nil, c.ArgErr()
@@ -191,8 +257,12 @@ func cacheParse(c *caddy.Controller) (*Cache, error) {
 		}
 		ca.Zones = origins
 
-		ca.pcache = cache.New(ca.pcap)
-		ca.ncache = cache.New(ca.ncap)
+		ca.pcache = cache.NewWithPolicy(ca.pcap, ca.policy)
+		ca.ncache = cache.NewWithPolicy(ca.ncap, ca.policy)
+		ca.scopes = cache.New(ca.pcap)
+		if aggressive {
+			ca.denial = newDenialCache(ca.ncap)
+		}
 	}
 
 	return ca, nil
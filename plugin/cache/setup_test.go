@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
 	"github.com/caddyserver/caddy"
 )
 
@@ -113,3 +115,124 @@ func TestSetup(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupPolicy(t *testing.T) {
+	tests := []struct {
+		input          string
+		shouldErr      bool
+		expectedPolicy cache.Policy
+	}{
+		{`cache`, false, cache.Random},
+		{`cache {
+				policy lru
+			}`, false, cache.LRU},
+		{`cache {
+				policy tinylfu
+			}`, false, cache.TinyLFU},
+		// fails
+		{`cache {
+				policy
+			}`, true, cache.Random},
+		{`cache {
+				policy fifo
+			}`, true, cache.Random},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr && err != nil {
+			continue
+		}
+		if ca.policy != test.expectedPolicy {
+			t.Errorf("Test %v: Expected policy %s but found: %s", i, test.expectedPolicy, ca.policy)
+		}
+	}
+}
+
+func TestSetupPersist(t *testing.T) {
+	tests := []struct {
+		input            string
+		shouldErr        bool
+		expectedPersist  string
+		expectedInterval time.Duration
+	}{
+		{`cache`, false, "", 0},
+		{`cache {
+				persist /var/lib/coredns/cache.json
+			}`, false, "/var/lib/coredns/cache.json", defaultPersistInterval},
+		{`cache {
+				persist /var/lib/coredns/cache.json 30s
+			}`, false, "/var/lib/coredns/cache.json", 30 * time.Second},
+		// fails
+		{`cache {
+				persist
+			}`, true, "", 0},
+		{`cache {
+				persist /var/lib/coredns/cache.json 0s
+			}`, true, "", 0},
+		{`cache {
+				persist /var/lib/coredns/cache.json foo
+			}`, true, "", 0},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr && err != nil {
+			continue
+		}
+		if ca.persist != test.expectedPersist {
+			t.Errorf("Test %v: Expected persist file %q but found: %q", i, test.expectedPersist, ca.persist)
+		}
+		if ca.persistInterval != test.expectedInterval {
+			t.Errorf("Test %v: Expected persist interval %s but found: %s", i, test.expectedInterval, ca.persistInterval)
+		}
+	}
+}
+
+func TestSetupAggressiveNSEC(t *testing.T) {
+	tests := []struct {
+		input      string
+		shouldErr  bool
+		aggressive bool
+	}{
+		{`cache`, false, false},
+		{`cache {
+				aggressive_nsec
+			}`, false, true},
+		// fails
+		{`cache {
+				aggressive_nsec 10
+			}`, true, false},
+	}
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		ca, err := cacheParse(c)
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %v: Expected error but found nil", i)
+			continue
+		} else if !test.shouldErr && err != nil {
+			t.Errorf("Test %v: Expected no error but found error: %v", i, err)
+			continue
+		}
+		if test.shouldErr && err != nil {
+			continue
+		}
+		if (ca.denial != nil) != test.aggressive {
+			t.Errorf("Test %v: Expected aggressive_nsec to be %t", i, test.aggressive)
+		}
+	}
+}
@@ -25,12 +25,12 @@ func init() {
 }
 
 func setup(c *caddy.Controller) error {
-	zones, keys, capacity, splitkeys, err := dnssecParse(c)
+	zones, keys, capacity, policy, splitkeys, err := dnssecParse(c)
 	if err != nil {
 		return plugin.Error("dnssec", err)
 	}
 
-	ca := cache.New(capacity)
+	ca := cache.NewWithPolicy(capacity, policy)
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		return New(zones, keys, splitkeys, next, ca)
 	})
@@ -43,17 +43,18 @@ func setup(c *caddy.Controller) error {
 	return nil
 }
 
-func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
+func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, cache.Policy, bool, error) {
 	zones := []string{}
 
 	keys := []*DNSKEY{}
 
 	capacity := defaultCap
+	policy := cache.Random
 
 	i := 0
 	for c.Next() {
 		if i > 0 {
-			return nil, nil, 0, false, plugin.ErrOnce
+			return nil, nil, 0, policy, false, plugin.ErrOnce
 		}
 		i++
 
@@ -71,21 +72,30 @@ func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
 			case "key":
 				k, e := keyParse(c)
 				if e != nil {
-					return nil, nil, 0, false, e
+					return nil, nil, 0, policy, false, e
 				}
 				keys = append(keys, k...)
 			case "cache_capacity":
 				if !c.NextArg() {
-					return nil, nil, 0, false, c.ArgErr()
+					return nil, nil, 0, policy, false, c.ArgErr()
 				}
 				value := c.Val()
 				cacheCap, err := strconv.Atoi(value)
 				if err != nil {
-					return nil, nil, 0, false, err
+					return nil, nil, 0, policy, false, err
 				}
 				capacity = cacheCap
+			case "cache_policy":
+				if !c.NextArg() {
+					return nil, nil, 0, policy, false, c.ArgErr()
+				}
+				p, err := cache.ParsePolicy(c.Val())
+				if err != nil {
+					return nil, nil, 0, policy, false, c.Errf("%s", err)
+				}
+				policy = p
 			default:
-				return nil, nil, 0, false, c.Errf("unknown property '%s'", x)
+				return nil, nil, 0, policy, false, c.Errf("unknown property '%s'", x)
 			}
 
 		}
@@ -116,11 +126,11 @@ func dnssecParse(c *caddy.Controller) ([]string, []*DNSKEY, int, bool, error) {
 			}
 		}
 		if !ok {
-			return zones, keys, capacity, splitkeys, fmt.Errorf("key %s (keyid: %d) can not sign any of the zones", string(kname), k.tag)
+			return zones, keys, capacity, policy, splitkeys, fmt.Errorf("key %s (keyid: %d) can not sign any of the zones", string(kname), k.tag)
 		}
 	}
 
-	return zones, keys, capacity, splitkeys, nil
+	return zones, keys, capacity, policy, splitkeys, nil
 }
 
 func keyParse(c *caddy.Controller) ([]*DNSKEY, error) {
@@ -6,6 +6,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/coredns/coredns/plugin/pkg/cache"
+
 	"github.com/caddyserver/caddy"
 )
 
@@ -82,7 +84,7 @@ func TestSetupDnssec(t *testing.T) {
 
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.input)
-		zones, keys, capacity, splitkeys, err := dnssecParse(c)
+		zones, keys, capacity, _, splitkeys, err := dnssecParse(c)
 
 		if test.shouldErr && err == nil {
 			t.Errorf("Test %d: Expected error but found %s for input %s", i, err, test.input)
@@ -159,3 +161,43 @@ Created: 20170901060531
 Publish: 20170901060531
 Activate: 20170901060531
 `
+
+func TestSetupDnssecPolicy(t *testing.T) {
+	tests := []struct {
+		input          string
+		shouldErr      bool
+		expectedPolicy cache.Policy
+	}{
+		{`dnssec`, false, cache.Random},
+		{`dnssec {
+			cache_policy lru
+		}`, false, cache.LRU},
+		{`dnssec {
+			cache_policy tinylfu
+		}`, false, cache.TinyLFU},
+		{`dnssec {
+			cache_policy
+		}`, true, cache.Random},
+		{`dnssec {
+			cache_policy mru
+		}`, true, cache.Random},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		_, _, _, policy, _, err := dnssecParse(c)
+
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error but found none for input %s", i, test.input)
+		}
+		if err != nil {
+			if !test.shouldErr {
+				t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+			}
+			continue
+		}
+		if policy != test.expectedPolicy {
+			t.Errorf("Test %d: Expected policy %s, got %s", i, test.expectedPolicy, policy)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package dnstapio
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/rotate"
+
+	tap "github.com/dnstap/golang-dnstap"
+	fs "github.com/farsightsec/golang-framestream"
+)
+
+// FileOptions tells how dnstap files are rotated.
+type FileOptions struct {
+	Size     int64         // rotate when the file is larger than Size bytes, 0 disables
+	Interval time.Duration // rotate when the file is older than Interval, 0 disables
+	Keep     int           // number of rotated files to keep, 0 keeps all
+	Compress bool          // gzip rotated files
+}
+
+// NewFile returns a new and initialized DnstapIO that writes to the file path in the Frame Streams
+// format. The file is rotated as specified by opts; rotated files get the time of rotation as suffix.
+func NewFile(path string, opts FileOptions) DnstapIO {
+	return &dnstapIO{
+		endpoint: path,
+		file:     &rotatingFile{path: path, opts: opts},
+		enc: newDnstapEncoder(&fs.EncoderOptions{
+			ContentType: []byte("protobuf:dnstap.Dnstap"),
+		}),
+		queue: make(chan tap.Dnstap, queueSize),
+		quit:  make(chan struct{}),
+	}
+}
+
+// rotatingFile is the file dnstap messages are written to. It keeps track of its size and age to
+// see when it needs to be rotated.
+type rotatingFile struct {
+	path string
+	opts FileOptions
+
+	f      *os.File
+	size   int64
+	opened time.Time
+
+	mu sync.Mutex // serializes the compressing and pruning of rotated files
+}
+
+// open opens the file for writing. An existing file, left behind by a previous run, is rotated first,
+// as each file must hold a single frame stream.
+func (r *rotatingFile) open() error {
+	if stat, err := os.Stat(r.path); err == nil && stat.Size() > 0 {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	r.f = f
+	r.size = 0
+	r.opened = time.Now()
+	return nil
+}
+
+// Write implements the io.Writer interface.
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close implements the io.Closer interface.
+func (r *rotatingFile) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+// due returns true if the file should be rotated.
+func (r *rotatingFile) due(now time.Time) bool {
+	if r.f == nil {
+		return false
+	}
+	if r.opts.Size > 0 && r.size >= r.opts.Size {
+		return true
+	}
+	return r.opts.Interval > 0 && now.Sub(r.opened) >= r.opts.Interval
+}
+
+// rotate renames the (closed) file. Compressing it and removing old files is done in the background.
+func (r *rotatingFile) rotate() error {
+	rotated, err := rotate.Rename(r.path)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if r.opts.Compress {
+			if err := compress(rotated); err != nil {
+				log.Warningf("Failed to compress %q: %s", rotated, err)
+			}
+		}
+		if r.opts.Keep > 0 {
+			if err := rotate.Prune(r.path, r.opts.Keep); err != nil {
+				log.Warningf("Failed to remove rotated files of %q: %s", r.path, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// compress gzips the file path to path.gz and removes path.
+func compress(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(out.Name())
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return os.Remove(path)
+}
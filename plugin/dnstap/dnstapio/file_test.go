@@ -0,0 +1,122 @@
+package dnstapio
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tap "github.com/dnstap/golang-dnstap"
+)
+
+// readFile returns the messages in the dnstap file path, it fails if the frame stream isn't complete.
+func readFile(path string) ([]*tap.Dnstap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	msgs := []*tap.Dnstap{}
+	for {
+		dt, err := r.Read()
+		if err == io.EOF {
+			return msgs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, dt)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dnstap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dnstap.fstrm")
+
+	dio := NewFile(path, FileOptions{})
+	dio.Connect()
+	for i := 0; i < 3; i++ {
+		dio.Dnstap(msg)
+	}
+	dio.Close()
+
+	// Closing is asynchronous, wait for the file to be complete.
+	var msgs []*tap.Dnstap
+	for i := 0; i < 50; i++ {
+		if msgs, err = readFile(path); err == nil && len(msgs) == 3 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("Expected 3 messages, got %d: %v", len(msgs), err)
+	}
+	if msgs[0].GetType() != tap.Dnstap_MESSAGE {
+		t.Errorf("Expected type %s, got %s", tap.Dnstap_MESSAGE, msgs[0].GetType())
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dnstap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "dnstap.fstrm")
+
+	r := &rotatingFile{path: path, opts: FileOptions{Size: 10, Keep: 2, Compress: true}}
+	for i := 0; i < 4; i++ {
+		if err := r.open(); err != nil {
+			t.Fatal(err)
+		}
+		if r.due(time.Now()) {
+			t.Errorf("Expected empty file not to be due for rotation")
+		}
+		r.Write([]byte("0123456789"))
+		if !r.due(time.Now()) {
+			t.Errorf("Expected file of 10 bytes to be due for rotation")
+		}
+		r.Close()
+		if err := r.rotate(); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Wait for the background compression and pruning.
+	var files, all []string
+	for i := 0; i < 50; i++ {
+		r.mu.Lock()
+		files, _ = filepath.Glob(path + ".*.gz")
+		all, _ = filepath.Glob(path + ".*")
+		r.mu.Unlock()
+		if len(files) == 2 && len(all) == 2 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if len(files) != 2 || len(all) != 2 {
+		t.Fatalf("Expected 2 compressed rotated files, got %v", all)
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	r := &rotatingFile{opts: FileOptions{Interval: time.Minute}, f: os.Stdout, opened: time.Now()}
+	if r.due(time.Now()) {
+		t.Errorf("Expected new file not to be due for rotation")
+	}
+	if !r.due(time.Now().Add(2 * time.Minute)) {
+		t.Errorf("Expected old file to be due for rotation")
+	}
+}
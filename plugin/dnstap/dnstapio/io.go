@@ -1,6 +1,7 @@
 package dnstapio
 
 import (
+	"io"
 	"net"
 	"sync/atomic"
 	"time"
@@ -23,7 +24,8 @@ const (
 type dnstapIO struct {
 	endpoint string
 	socket   bool
-	conn     net.Conn
+	file     *rotatingFile // when set, write to this file instead of dialing endpoint
+	conn     io.WriteCloser
 	enc      *dnstapEncoder
 	queue    chan tap.Dnstap
 	dropped  uint32
@@ -53,6 +55,13 @@ type DnstapIO interface {
 
 func (dio *dnstapIO) newConnect() error {
 	var err error
+	if dio.file != nil {
+		if err = dio.file.open(); err != nil {
+			return err
+		}
+		dio.conn = dio.file
+		return dio.enc.resetWriter(dio.conn)
+	}
 	if dio.socket {
 		if dio.conn, err = net.Dial("unix", dio.endpoint); err != nil {
 			return err
@@ -116,6 +125,18 @@ func (dio *dnstapIO) flushBuffer() {
 		} else {
 			log.Info("Reconnected to dnstap")
 		}
+		return
+	}
+
+	if dio.file != nil && dio.file.due(time.Now()) {
+		// Close the frame stream, so the rotated file is complete, and start a new one.
+		dio.closeConnection()
+		if err := dio.file.rotate(); err != nil {
+			log.Errorf("Cannot rotate dnstap file %q: %s", dio.file.path, err)
+		}
+		if err := dio.newConnect(); err != nil {
+			log.Errorf("Cannot open dnstap file %q: %s", dio.file.path, err)
+		}
 	}
 }
 
@@ -125,11 +146,24 @@ func (dio *dnstapIO) write(payload *tap.Dnstap) {
 	}
 }
 
+// drain writes the messages still in the queue.
+func (dio *dnstapIO) drain() {
+	for {
+		select {
+		case payload := <-dio.queue:
+			dio.write(&payload)
+		default:
+			return
+		}
+	}
+}
+
 func (dio *dnstapIO) serve() {
 	timeout := time.After(flushTimeout)
 	for {
 		select {
 		case <-dio.quit:
+			dio.drain()
 			dio.flushBuffer()
 			dio.closeConnection()
 			return
@@ -0,0 +1,136 @@
+package dnstapio
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net"
+	"time"
+
+	tap "github.com/dnstap/golang-dnstap"
+	fs "github.com/farsightsec/golang-framestream"
+	"github.com/golang/protobuf/proto"
+	"github.com/miekg/dns"
+)
+
+// Reader reads dnstap messages from a Frame Streams file, as written by NewFile.
+type Reader struct {
+	dec *fs.Decoder
+}
+
+// NewReader returns a Reader reading from r. Gzip compressed input, as created when rotating
+// files, is detected and decompressed.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+	} else {
+		r = br
+	}
+
+	dec, err := fs.NewDecoder(r, &fs.DecoderOptions{ContentType: []byte("protobuf:dnstap.Dnstap")})
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{dec: dec}, nil
+}
+
+// Read returns the next dnstap message. It returns io.EOF when there are no more messages.
+func (r *Reader) Read() (*tap.Dnstap, error) {
+	buf, err := r.dec.Decode()
+	if err != nil {
+		return nil, err
+	}
+	dt := &tap.Dnstap{}
+	if err := proto.Unmarshal(buf, dt); err != nil {
+		return nil, err
+	}
+	return dt, nil
+}
+
+// Text renders dt as a single line of text, in the same format as the dnstap command line tool.
+func Text(dt *tap.Dnstap) []byte {
+	out, _ := tap.TextFormat(dt)
+	return out
+}
+
+// JSON renders dt as a JSON object, the DNS messages are included in presentation format.
+func JSON(dt *tap.Dnstap) ([]byte, error) {
+	j := jsonDnstap{
+		Type:     dt.GetType().String(),
+		Identity: string(dt.GetIdentity()),
+		Version:  string(dt.GetVersion()),
+	}
+	if m := dt.GetMessage(); m != nil {
+		j.Message = &jsonMessage{
+			Type:            m.GetType().String(),
+			SocketFamily:    m.GetSocketFamily().String(),
+			SocketProtocol:  m.GetSocketProtocol().String(),
+			QueryAddress:    ipString(m.GetQueryAddress()),
+			QueryPort:       m.GetQueryPort(),
+			ResponseAddress: ipString(m.GetResponseAddress()),
+			ResponsePort:    m.GetResponsePort(),
+			QueryTime:       timeOf(m.QueryTimeSec, m.QueryTimeNsec),
+			ResponseTime:    timeOf(m.ResponseTimeSec, m.ResponseTimeNsec),
+			QueryMessage:    msgString(m.GetQueryMessage()),
+			ResponseMessage: msgString(m.GetResponseMessage()),
+		}
+	}
+	return json.Marshal(j)
+}
+
+type jsonDnstap struct {
+	Type     string       `json:"type"`
+	Identity string       `json:"identity,omitempty"`
+	Version  string       `json:"version,omitempty"`
+	Message  *jsonMessage `json:"message,omitempty"`
+}
+
+type jsonMessage struct {
+	Type            string     `json:"type"`
+	SocketFamily    string     `json:"socket_family"`
+	SocketProtocol  string     `json:"socket_protocol"`
+	QueryAddress    string     `json:"query_address,omitempty"`
+	QueryPort       uint32     `json:"query_port,omitempty"`
+	ResponseAddress string     `json:"response_address,omitempty"`
+	ResponsePort    uint32     `json:"response_port,omitempty"`
+	QueryTime       *time.Time `json:"query_time,omitempty"`
+	ResponseTime    *time.Time `json:"response_time,omitempty"`
+	QueryMessage    string     `json:"query_message,omitempty"`
+	ResponseMessage string     `json:"response_message,omitempty"`
+}
+
+func ipString(ip []byte) string {
+	if len(ip) == 0 {
+		return ""
+	}
+	return net.IP(ip).String()
+}
+
+func timeOf(sec *uint64, nsec *uint32) *time.Time {
+	if sec == nil {
+		return nil
+	}
+	n := uint32(0)
+	if nsec != nil {
+		n = *nsec
+	}
+	t := time.Unix(int64(*sec), int64(n)).UTC()
+	return &t
+}
+
+func msgString(buf []byte) string {
+	if len(buf) == 0 {
+		return ""
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return ""
+	}
+	return m.String()
+}
@@ -0,0 +1,60 @@
+package dnstapio
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	dtmsg "github.com/coredns/coredns/plugin/dnstap/msg"
+
+	tap "github.com/dnstap/golang-dnstap"
+	"github.com/miekg/dns"
+)
+
+func clientQuery(t *testing.T) *tap.Dnstap {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	addr := &net.UDPAddr{IP: net.ParseIP("10.240.0.1"), Port: 40212}
+	tm, err := dtmsg.New().Time(time.Unix(1500000000, 0)).Addr(addr).Msg(m).ToClientQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ := tap.Dnstap_MESSAGE
+	return &tap.Dnstap{Type: &typ, Message: tm}
+}
+
+func TestText(t *testing.T) {
+	out := string(Text(clientQuery(t)))
+	for _, want := range []string{"CQ", "10.240.0.1", "example.org.", "A"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in %q", want, out)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	out, err := JSON(clientQuery(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := jsonDnstap{}
+	if err := json.Unmarshal(out, &j); err != nil {
+		t.Fatalf("Expected valid JSON, got %s: %s", out, err)
+	}
+	if j.Type != "MESSAGE" || j.Message == nil {
+		t.Fatalf("Expected a MESSAGE, got %s", out)
+	}
+	if j.Message.Type != "CLIENT_QUERY" || j.Message.QueryAddress != "10.240.0.1" || j.Message.QueryPort != 40212 {
+		t.Errorf("Unexpected message %s", out)
+	}
+	if j.Message.QueryTime == nil || j.Message.QueryTime.Unix() != 1500000000 {
+		t.Errorf("Expected query time 1500000000, got %v", j.Message.QueryTime)
+	}
+	if !strings.Contains(j.Message.QueryMessage, "example.org.") {
+		t.Errorf("Expected query message to contain the question, got %q", j.Message.QueryMessage)
+	}
+}
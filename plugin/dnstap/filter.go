@@ -0,0 +1,101 @@
+package dnstap
+
+import (
+	"net"
+	"sync"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/request"
+
+	tap "github.com/dnstap/golang-dnstap"
+	"github.com/miekg/dns"
+)
+
+// filter selects the queries that are tapped. A query is tapped when it matches all configured
+// criteria; for each criterion it needs to match one of the values.
+type filter struct {
+	names  []string     // qname suffixes
+	rcodes []int        // rcodes of the response
+	nets   []*net.IPNet // client networks
+}
+
+// query returns true if the query in state matches the name and client criteria.
+func (f *filter) query(state request.Request) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.names) > 0 && plugin.Zones(f.names).Matches(state.Name()) == "" {
+		return false
+	}
+	if len(f.nets) > 0 {
+		ip := net.ParseIP(state.IP())
+		for _, n := range f.nets {
+			if n.Contains(ip) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// rcode returns true if rcode matches the rcode criterion.
+func (f *filter) rcode(rcode int) bool {
+	if f == nil || len(f.rcodes) == 0 {
+		return true
+	}
+	for _, r := range f.rcodes {
+		if r == rcode {
+			return true
+		}
+	}
+	return false
+}
+
+// pending holds the messages tapped for a query before the rcode of its response is known, i.e.
+// the messages of the queries forwarded to upstreams. They are sent once the rcode matches.
+type pending struct {
+	sync.Mutex
+	decided bool
+	send    bool
+	msgs    []*tap.Message
+}
+
+// hold returns true if m must not be sent now: it is held until the rcode is known, or the rcode
+// didn't match.
+func (p *pending) hold(m *tap.Message) bool {
+	p.Lock()
+	defer p.Unlock()
+	if !p.decided {
+		p.msgs = append(p.msgs, m)
+	}
+	return !p.decided || !p.send
+}
+
+// decide records if the messages should be sent and returns the messages held so far.
+func (p *pending) decide(send bool) []*tap.Message {
+	p.Lock()
+	defer p.Unlock()
+	p.decided, p.send = true, send
+	msgs := p.msgs
+	p.msgs = nil
+	if !send {
+		return nil
+	}
+	return msgs
+}
+
+// rcodeWriter records the rcode of the response that is written.
+type rcodeWriter struct {
+	dns.ResponseWriter
+	rcode   int
+	written bool
+}
+
+// WriteMsg implements the dns.ResponseWriter interface.
+func (w *rcodeWriter) WriteMsg(res *dns.Msg) error {
+	if res != nil {
+		w.rcode, w.written = res.Rcode, true
+	}
+	return w.ResponseWriter.WriteMsg(res)
+}
@@ -0,0 +1,124 @@
+package dnstap
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/metadata"
+	"github.com/coredns/coredns/plugin/pkg/replacer"
+	mwtest "github.com/coredns/coredns/plugin/test"
+
+	tap "github.com/dnstap/golang-dnstap"
+	"github.com/miekg/dns"
+)
+
+type recordIO struct {
+	taps []tap.Dnstap
+}
+
+func (r *recordIO) Dnstap(d tap.Dnstap) { r.taps = append(r.taps, d) }
+
+// forwardHandler taps an outside query and response, like forward does, and replies with rcode.
+func forwardHandler(rcode int) mwtest.HandlerFunc {
+	return func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if t := TapperFromContext(ctx); t != nil {
+			typ := tap.Message_FORWARDER_QUERY
+			t.TapMessage(&tap.Message{Type: &typ})
+			typ = tap.Message_FORWARDER_RESPONSE
+			t.TapMessage(&tap.Message{Type: &typ})
+		}
+		m := new(dns.Msg)
+		m.SetRcode(r, rcode)
+		w.WriteMsg(m)
+		return rcode, nil
+	}
+}
+
+func TestFilter(t *testing.T) {
+	_, n, _ := net.ParseCIDR("10.240.0.0/16")
+	_, other, _ := net.ParseCIDR("192.168.0.0/16")
+
+	tests := []struct {
+		filter *filter
+		qname  string
+		rcode  int
+		taps   int
+	}{
+		{nil, "example.org.", dns.RcodeSuccess, 4},
+		{&filter{names: []string{"example.org."}}, "www.example.org.", dns.RcodeSuccess, 4},
+		{&filter{names: []string{"example.org."}}, "example.net.", dns.RcodeSuccess, 0},
+		{&filter{nets: []*net.IPNet{n}}, "example.org.", dns.RcodeSuccess, 4},
+		{&filter{nets: []*net.IPNet{other}}, "example.org.", dns.RcodeSuccess, 0},
+		{&filter{rcodes: []int{dns.RcodeNameError}}, "example.org.", dns.RcodeNameError, 4},
+		{&filter{rcodes: []int{dns.RcodeNameError}}, "example.org.", dns.RcodeSuccess, 0},
+		{&filter{names: []string{"example.org."}, rcodes: []int{dns.RcodeServerFailure}}, "example.org.", dns.RcodeServerFailure, 4},
+	}
+
+	for i, tc := range tests {
+		io := &recordIO{}
+		h := Dnstap{Next: forwardHandler(tc.rcode), IO: io, filter: tc.filter}
+
+		q := new(dns.Msg)
+		q.SetQuestion(tc.qname, dns.TypeA)
+		h.ServeDNS(context.TODO(), &mwtest.ResponseWriter{}, q)
+
+		if len(io.taps) != tc.taps {
+			t.Errorf("Test %d: expected %d dnstap messages, got %d", i, tc.taps, len(io.taps))
+		}
+	}
+}
+
+func TestFilterNotWritten(t *testing.T) {
+	// The next plugin fails without writing a response, the server writes the SERVFAIL.
+	next := mwtest.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		if t := TapperFromContext(ctx); t != nil {
+			typ := tap.Message_FORWARDER_QUERY
+			t.TapMessage(&tap.Message{Type: &typ})
+		}
+		return dns.RcodeServerFailure, errors.New("no upstream")
+	})
+
+	io := &recordIO{}
+	h := Dnstap{Next: next, IO: io, filter: &filter{rcodes: []int{dns.RcodeServerFailure}}}
+
+	q := new(dns.Msg)
+	q.SetQuestion("example.org.", dns.TypeA)
+	h.ServeDNS(context.TODO(), &mwtest.ResponseWriter{}, q)
+
+	if len(io.taps) != 1 {
+		t.Errorf("Expected 1 dnstap message, got %d", len(io.taps))
+	}
+}
+
+func TestIdentityVersionExtra(t *testing.T) {
+	io := &recordIO{}
+	h := Dnstap{
+		Next:     forwardHandler(dns.RcodeSuccess),
+		IO:       io,
+		Identity: []byte("ns1"),
+		Version:  []byte("CoreDNS-1.6.2"),
+		Extra:    "{/test/namespace} {type}",
+		repl:     replacer.New(),
+	}
+
+	ctx := metadata.ContextWithMetadata(context.TODO())
+	metadata.SetValueFunc(ctx, "test/namespace", func() string { return "kube-system" })
+
+	q := new(dns.Msg)
+	q.SetQuestion("example.org.", dns.TypeA)
+	h.ServeDNS(ctx, &mwtest.ResponseWriter{}, q)
+
+	if len(io.taps) != 4 {
+		t.Fatalf("Expected 4 dnstap messages, got %d", len(io.taps))
+	}
+	for _, d := range io.taps {
+		if string(d.Identity) != "ns1" || string(d.Version) != "CoreDNS-1.6.2" {
+			t.Errorf("Expected identity ns1 and version CoreDNS-1.6.2, got %s and %s", d.Identity, d.Version)
+		}
+		if string(d.Extra) != "kube-system A" {
+			t.Errorf("Expected extra %q, got %q", "kube-system A", d.Extra)
+		}
+	}
+}
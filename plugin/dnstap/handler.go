@@ -6,6 +6,8 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/dnstap/taprw"
+	"github.com/coredns/coredns/plugin/pkg/replacer"
+	"github.com/coredns/coredns/request"
 
 	tap "github.com/dnstap/golang-dnstap"
 	"github.com/miekg/dns"
@@ -18,6 +20,19 @@ type Dnstap struct {
 
 	// Set to true to include the relevant raw DNS message into the dnstap messages.
 	JoinRawMessage bool
+
+	// Identity and Version are set in all dnstap messages.
+	Identity []byte
+	Version  []byte
+	// Extra is set in the dnstap messages after replacing its labels, e.g. {/kubernetes/client-namespace}.
+	Extra string
+
+	repl   replacer.Replacer
+	filter *filter
+
+	// Per query.
+	extra   []byte
+	pending *pending
 }
 
 type (
@@ -46,10 +61,20 @@ const (
 
 // TapMessage implements Tapper.
 func (h Dnstap) TapMessage(m *tap.Message) {
+	if h.pending != nil && h.pending.hold(m) {
+		return
+	}
+	h.send(m)
+}
+
+func (h Dnstap) send(m *tap.Message) {
 	t := tap.Dnstap_MESSAGE
 	h.IO.Dnstap(tap.Dnstap{
-		Type:    &t,
-		Message: m,
+		Type:     &t,
+		Identity: h.Identity,
+		Version:  h.Version,
+		Extra:    h.extra,
+		Message:  m,
 	})
 }
 
@@ -60,6 +85,19 @@ func (h Dnstap) Pack() bool {
 
 // ServeDNS logs the client query and response to dnstap and passes the dnstap Context.
 func (h Dnstap) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if !h.filter.query(state) {
+		return plugin.NextOrFailure(h.Name(), h.Next, ctx, w, r)
+	}
+	if h.Extra != "" {
+		h.extra = []byte(h.repl.Replace(ctx, state, nil, h.Extra))
+	}
+	var rw *rcodeWriter
+	if h.filter != nil && len(h.filter.rcodes) > 0 {
+		h.pending = new(pending)
+		rw = &rcodeWriter{ResponseWriter: w}
+		w = rw
+	}
 
 	// Add send option into context so other plugin can decide on which DNSTap
 	// message to be sent out
@@ -67,7 +105,7 @@ func (h Dnstap) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 	newCtx := context.WithValue(ctx, DnstapSendOption, &sendOption)
 	newCtx = ContextWithTapper(newCtx, h)
 
-	rw := &taprw.ResponseWriter{
+	tw := &taprw.ResponseWriter{
 		ResponseWriter: w,
 		Tapper:         &h,
 		Query:          r,
@@ -75,13 +113,23 @@ func (h Dnstap) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 		QueryEpoch:     time.Now(),
 	}
 
-	code, err := plugin.NextOrFailure(h.Name(), h.Next, newCtx, rw, r)
+	code, err := plugin.NextOrFailure(h.Name(), h.Next, newCtx, tw, r)
+	if rw != nil {
+		// When nothing is written, the server writes a response with the returned rcode.
+		rcode := code
+		if rw.written {
+			rcode = rw.rcode
+		}
+		for _, m := range h.pending.decide(h.filter.rcode(rcode)) {
+			h.send(m)
+		}
+	}
 	if err != nil {
 		// ignore dnstap errors
 		return code, err
 	}
 
-	if err = rw.DnstapError(); err != nil {
+	if err = tw.DnstapError(); err != nil {
 		return code, plugin.Error("dnstap", err)
 	}
 
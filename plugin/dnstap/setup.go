@@ -1,16 +1,25 @@
 package dnstap
 
 import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/dnstap/dnstapio"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/plugin/pkg/parse"
+	"github.com/coredns/coredns/plugin/pkg/replacer"
+	"github.com/coredns/coredns/plugin/pkg/rotate"
 
 	"github.com/caddyserver/caddy"
 	"github.com/caddyserver/caddy/caddyfile"
+	"github.com/miekg/dns"
 )
 
 var log = clog.NewWithPlugin("dnstap")
@@ -32,7 +41,15 @@ func wrapSetup(c *caddy.Controller) error {
 type config struct {
 	target string
 	socket bool
+	file   bool
 	full   bool
+
+	fileOpts dnstapio.FileOptions
+
+	identity string
+	version  string
+	extra    string
+	filter   *filter
 }
 
 func parseConfig(d *caddyfile.Dispenser) (c config, err error) {
@@ -42,7 +59,10 @@ func parseConfig(d *caddyfile.Dispenser) (c config, err error) {
 		return c, d.ArgErr()
 	}
 
-	if strings.HasPrefix(c.target, "tcp://") {
+	if strings.HasPrefix(c.target, "file://") {
+		c.target = c.target[7:]
+		c.file = true
+	} else if strings.HasPrefix(c.target, "tcp://") {
 		// remote IP endpoint
 		servers, err := parse.HostPortOrFile(c.target[6:])
 		if err != nil {
@@ -57,19 +77,159 @@ func parseConfig(d *caddyfile.Dispenser) (c config, err error) {
 		c.socket = true
 	}
 
-	c.full = d.NextArg() && d.Val() == "full"
+	args := d.RemainingArgs()
+	c.full = len(args) > 0 && args[0] == "full"
+
+	for d.NextBlock() {
+		switch x := d.Val(); x {
+		case "rotate_size", "rotate_interval", "keep", "compress":
+			if !c.file {
+				return c, d.Errf("'%s' is only supported for file targets", x)
+			}
+		}
+		switch d.Val() {
+		case "identity":
+			if !d.NextArg() {
+				return c, d.ArgErr()
+			}
+			c.identity = d.Val()
+		case "version":
+			if !d.NextArg() {
+				return c, d.ArgErr()
+			}
+			c.version = d.Val()
+		case "extra":
+			if !d.NextArg() {
+				return c, d.ArgErr()
+			}
+			c.extra = d.Val()
+		case "filter":
+			if c.filter == nil {
+				c.filter = &filter{}
+			}
+			if err := parseFilter(d, c.filter); err != nil {
+				return c, err
+			}
+		case "rotate_size":
+			if !d.NextArg() {
+				return c, d.ArgErr()
+			}
+			size, err := rotate.ParseSize(d.Val())
+			if err != nil {
+				return c, err
+			}
+			c.fileOpts.Size = size
+		case "rotate_interval":
+			if !d.NextArg() {
+				return c, d.ArgErr()
+			}
+			dur, err := time.ParseDuration(d.Val())
+			if err != nil {
+				return c, err
+			}
+			if dur <= 0 {
+				return c, fmt.Errorf("rotate_interval should be positive: %s", dur)
+			}
+			c.fileOpts.Interval = dur
+		case "keep":
+			if !d.NextArg() {
+				return c, d.ArgErr()
+			}
+			n, err := strconv.Atoi(d.Val())
+			if err != nil {
+				return c, err
+			}
+			if n < 0 {
+				return c, fmt.Errorf("keep can't be negative: %d", n)
+			}
+			c.fileOpts.Keep = n
+		case "compress":
+			if d.NextArg() {
+				return c, d.ArgErr()
+			}
+			c.fileOpts.Compress = true
+		default:
+			return c, d.Errf("unknown property '%s'", d.Val())
+		}
+	}
 
 	return
 }
 
+// parseFilter parses a filter line: filter name|rcode|client VALUES...
+func parseFilter(d *caddyfile.Dispenser, f *filter) error {
+	if !d.NextArg() {
+		return d.ArgErr()
+	}
+	criterion := d.Val()
+	args := d.RemainingArgs()
+	if len(args) == 0 {
+		return d.ArgErr()
+	}
+	switch criterion {
+	case "name":
+		for _, a := range args {
+			f.names = append(f.names, plugin.Host(a).Normalize())
+		}
+	case "rcode":
+		for _, a := range args {
+			rcode, ok := dns.StringToRcode[strings.ToUpper(a)]
+			if !ok {
+				return d.Errf("unknown rcode '%s'", a)
+			}
+			f.rcodes = append(f.rcodes, rcode)
+		}
+	case "client":
+		for _, a := range args {
+			if !strings.Contains(a, "/") {
+				if net.ParseIP(a).To4() != nil {
+					a += "/32"
+				} else {
+					a += "/128"
+				}
+			}
+			_, n, err := net.ParseCIDR(a)
+			if err != nil {
+				return err
+			}
+			f.nets = append(f.nets, n)
+		}
+	default:
+		return d.Errf("unknown filter '%s'", criterion)
+	}
+	return nil
+}
+
 func setup(c *caddy.Controller) error {
 	conf, err := parseConfig(&c.Dispenser)
 	if err != nil {
 		return err
 	}
 
-	dio := dnstapio.New(conf.target, conf.socket)
-	dnstap := Dnstap{IO: dio, JoinRawMessage: conf.full}
+	var dio dnstapio.DnstapIO
+	if conf.file {
+		if !filepath.IsAbs(conf.target) && dnsserver.GetConfig(c).Root != "" {
+			conf.target = filepath.Join(dnsserver.GetConfig(c).Root, conf.target)
+		}
+		dio = dnstapio.NewFile(conf.target, conf.fileOpts)
+	} else {
+		dio = dnstapio.New(conf.target, conf.socket)
+	}
+	if conf.identity == "" {
+		conf.identity, _ = os.Hostname()
+	}
+	if conf.version == "" {
+		conf.version = caddy.AppName + "-" + caddy.AppVersion
+	}
+	dnstap := Dnstap{
+		IO:             dio,
+		JoinRawMessage: conf.full,
+		Identity:       []byte(conf.identity),
+		Version:        []byte(conf.version),
+		Extra:          conf.extra,
+		repl:           replacer.New(),
+		filter:         conf.filter,
+	}
 
 	c.OnStartup(func() error {
 		dio.Connect()
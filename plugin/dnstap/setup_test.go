@@ -2,6 +2,9 @@ package dnstap
 
 import (
 	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/dnstap/dnstapio"
 
 	"github.com/caddyserver/caddy"
 )
@@ -31,3 +34,120 @@ func TestConfig(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigFile(t *testing.T) {
+	tests := []struct {
+		input string
+		path  string
+		opts  dnstapio.FileOptions
+		fail  bool
+	}{
+		{"dnstap file:///var/log/coredns.fstrm full", "/var/log/coredns.fstrm", dnstapio.FileOptions{}, false},
+		{`dnstap file:///var/log/coredns.fstrm {
+			rotate_size 100M
+			rotate_interval 1h
+			keep 5
+			compress
+		}`, "/var/log/coredns.fstrm", dnstapio.FileOptions{Size: 100 << 20, Interval: time.Hour, Keep: 5, Compress: true}, false},
+		{`dnstap file:///var/log/coredns.fstrm {
+			rotate_size 1000
+		}`, "/var/log/coredns.fstrm", dnstapio.FileOptions{Size: 1000}, false},
+		// fails
+		{`dnstap tcp://127.0.0.1:6000 {
+			compress
+		}`, "", dnstapio.FileOptions{}, true},
+		{`dnstap file:///var/log/coredns.fstrm {
+			rotate_size 0
+		}`, "", dnstapio.FileOptions{}, true},
+		{`dnstap file:///var/log/coredns.fstrm {
+			rotate_interval -1h
+		}`, "", dnstapio.FileOptions{}, true},
+		{`dnstap file:///var/log/coredns.fstrm {
+			keep
+		}`, "", dnstapio.FileOptions{}, true},
+		{`dnstap file:///var/log/coredns.fstrm {
+			rotate
+		}`, "", dnstapio.FileOptions{}, true},
+	}
+	for i, tc := range tests {
+		cad := caddy.NewTestController("dns", tc.input)
+		conf, err := parseConfig(&cad.Dispenser)
+		if tc.fail {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if !conf.file || conf.target != tc.path || conf.fileOpts != tc.opts {
+			t.Errorf("Test %d: expected file %s with %+v, got %+v", i, tc.path, tc.opts, conf)
+		}
+	}
+}
+
+func TestConfigOptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		identity string
+		version  string
+		extra    string
+		names    int
+		rcodes   int
+		nets     int
+		fail     bool
+	}{
+		{`dnstap /tmp/dnstap.sock {
+			identity ns1
+			version 1.0
+			extra {/kubernetes/client-namespace}
+		}`, "ns1", "1.0", "{/kubernetes/client-namespace}", 0, 0, 0, false},
+		{`dnstap tcp://127.0.0.1:6000 full {
+			filter name example.org example.net
+			filter rcode NXDOMAIN servfail
+			filter client 10.0.0.0/8 192.168.1.1 ::1
+		}`, "", "", "", 2, 2, 3, false},
+		// fails
+		{`dnstap /tmp/dnstap.sock {
+			identity
+		}`, "", "", "", 0, 0, 0, true},
+		{`dnstap /tmp/dnstap.sock {
+			filter rcode NOPE
+		}`, "", "", "", 0, 0, 0, true},
+		{`dnstap /tmp/dnstap.sock {
+			filter client 10.0.0.0/33
+		}`, "", "", "", 0, 0, 0, true},
+		{`dnstap /tmp/dnstap.sock {
+			filter type A
+		}`, "", "", "", 0, 0, 0, true},
+		{`dnstap /tmp/dnstap.sock {
+			filter name
+		}`, "", "", "", 0, 0, 0, true},
+	}
+	for i, tc := range tests {
+		cad := caddy.NewTestController("dns", tc.input)
+		conf, err := parseConfig(&cad.Dispenser)
+		if tc.fail {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if conf.identity != tc.identity || conf.version != tc.version || conf.extra != tc.extra {
+			t.Errorf("Test %d: expected %q %q %q, got %q %q %q", i, tc.identity, tc.version, tc.extra, conf.identity, conf.version, conf.extra)
+		}
+		f := conf.filter
+		if f == nil {
+			f = &filter{}
+		}
+		if len(f.names) != tc.names || len(f.rcodes) != tc.rcodes || len(f.nets) != tc.nets {
+			t.Errorf("Test %d: expected %d names, %d rcodes and %d nets in the filter, got %+v", i, tc.names, tc.rcodes, tc.nets, f)
+		}
+	}
+}
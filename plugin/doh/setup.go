@@ -0,0 +1,63 @@
+package doh
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("doh", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	err := parseDoH(c)
+	if err != nil {
+		return plugin.Error("doh", err)
+	}
+	return nil
+}
+
+func parseDoH(c *caddy.Controller) error {
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return plugin.ErrOnce
+		}
+		i++
+
+		if config.Transport != transport.HTTPS {
+			return c.Errf("can only be used in a %s:// server block", transport.HTTPS)
+		}
+
+		paths := c.RemainingArgs()
+		for _, p := range paths {
+			if !strings.HasPrefix(p, "/") {
+				return c.Errf("path must start with a '/': %s", p)
+			}
+		}
+		config.HTTPSPaths = paths
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "http3":
+				if c.NextArg() {
+					return c.ArgErr()
+				}
+				config.HTTP3 = true
+			default:
+				return c.Errf("unknown option '%s'", c.Val())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package doh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input         string
+		trans         string
+		shouldErr     bool
+		expectedPaths []string
+		expectedHTTP3 bool
+		expectedErr   string
+	}{
+		// positive
+		{"doh", transport.HTTPS, false, nil, false, ""},
+		{"doh /dns-query /resolve", transport.HTTPS, false, []string{"/dns-query", "/resolve"}, false, ""},
+		{"doh {\nhttp3\n}", transport.HTTPS, false, nil, true, ""},
+		// negative
+		{"doh", transport.DNS, true, nil, false, "https:// server block"},
+		{"doh resolve", transport.HTTPS, true, nil, false, "must start with"},
+		{"doh {\nhttp3 yes\n}", transport.HTTPS, true, nil, false, "Wrong argument"},
+		{"doh {\nbogus\n}", transport.HTTPS, true, nil, false, "unknown option"},
+		{"doh\ndoh", transport.HTTPS, true, nil, false, "used once"},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		cfg := dnsserver.GetConfig(c)
+		cfg.Transport = test.trans
+		err := setup(c)
+
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			} else if !strings.Contains(err.Error(), test.expectedErr) {
+				t.Errorf("Test %d: expected error to contain %q, got %q", i, test.expectedErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if strings.Join(cfg.HTTPSPaths, " ") != strings.Join(test.expectedPaths, " ") {
+			t.Errorf("Test %d: expected paths %v, got %v", i, test.expectedPaths, cfg.HTTPSPaths)
+		}
+		if cfg.HTTP3 != test.expectedHTTP3 {
+			t.Errorf("Test %d: expected http3 %t, got %t", i, test.expectedHTTP3, cfg.HTTP3)
+		}
+	}
+}
@@ -3,13 +3,19 @@ package errors
 
 import (
 	"context"
+	"fmt"
 	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 	"unsafe"
 
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/rcode"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -22,6 +28,16 @@ type pattern struct {
 	count   uint32
 	period  time.Duration
 	pattern *regexp.Regexp
+	level   string // level of the consolidated message, the default is error
+
+	mu      sync.Mutex
+	classes map[class]uint32
+}
+
+// class classifies an error by the plugin it originates from and the rcode returned with it.
+type class struct {
+	plugin string
+	rcode  int
 }
 
 func (p *pattern) timer() *time.Timer {
@@ -32,11 +48,52 @@ func (p *pattern) setTimer(t *time.Timer) {
 	atomic.StorePointer(&p.ptimer, unsafe.Pointer(t))
 }
 
+// classify counts an error of class c.
+func (p *pattern) classify(c class) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.classes == nil {
+		p.classes = map[class]uint32{}
+	}
+	p.classes[c]++
+}
+
+// breakdown returns the number of errors per class since the last call, the most frequent first, e.g.
+// "forward SERVFAIL: 2990, cache SERVFAIL: 10".
+func (p *pattern) breakdown() string {
+	p.mu.Lock()
+	classes := p.classes
+	p.classes = nil
+	p.mu.Unlock()
+
+	cs := make([]class, 0, len(classes))
+	for c := range classes {
+		cs = append(cs, c)
+	}
+	sort.Slice(cs, func(i, j int) bool {
+		if classes[cs[i]] != classes[cs[j]] {
+			return classes[cs[i]] > classes[cs[j]]
+		}
+		if cs[i].plugin != cs[j].plugin {
+			return cs[i].plugin < cs[j].plugin
+		}
+		return cs[i].rcode < cs[j].rcode
+	})
+
+	parts := make([]string, len(cs))
+	for i, c := range cs {
+		parts[i] = fmt.Sprintf("%s %s: %d", c.plugin, rcode.ToString(c.rcode), classes[c])
+	}
+	return strings.Join(parts, ", ")
+}
+
 // errorHandler handles DNS errors (and errors from other plugin).
 type errorHandler struct {
-	patterns []*pattern
-	stopFlag uint32
-	Next     plugin.Handler
+	patterns    []*pattern
+	stopFlag    uint32
+	sinkConfigs []sinkConfig
+	sinks       *dispatcher
+	Next        plugin.Handler
 }
 
 func newErrorHandler() *errorHandler {
@@ -46,8 +103,19 @@ func newErrorHandler() *errorHandler {
 func (h *errorHandler) logPattern(i int) {
 	cnt := atomic.SwapUint32(&h.patterns[i].count, 0)
 	if cnt > 0 {
-		log.Errorf("%d errors like '%s' occurred in last %s",
+		msg := fmt.Sprintf("%d errors like '%s' occurred in last %s",
 			cnt, h.patterns[i].pattern.String(), h.patterns[i].period)
+		if b := h.patterns[i].breakdown(); b != "" {
+			msg += " (" + b + ")"
+		}
+		logLevel(h.patterns[i].level, msg)
+		h.sinks.report(&report{
+			Level:   h.patterns[i].level,
+			Message: msg,
+			Pattern: h.patterns[i].pattern.String(),
+			Count:   cnt,
+			Period:  h.patterns[i].period.String(),
+		})
 	}
 }
 
@@ -80,24 +148,59 @@ func (h *errorHandler) stop() {
 
 // ServeDNS implements the plugin.Handler interface.
 func (h *errorHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
-	rcode, err := plugin.NextOrFailure(h.Name(), h.Next, ctx, w, r)
+	ctx = plugin.WithErrorOrigin(ctx)
+	rc, err := plugin.NextOrFailure(h.Name(), h.Next, ctx, w, r)
 
 	if err != nil {
+		c := class{plugin: plugin.ErrorOrigin(ctx), rcode: rc}
+		errorsCount.WithLabelValues(metrics.WithServer(ctx), c.plugin, rcode.ToString(rc)).Inc()
+
 		strErr := err.Error()
 		for i := range h.patterns {
 			if h.patterns[i].pattern.MatchString(strErr) {
 				if h.inc(i) {
-					return rcode, err
+					h.patterns[i].classify(c)
+					return rc, err
 				}
 				break
 			}
 		}
 		state := request.Request{W: w, Req: r}
-		log.Errorf("%d %s %s: %s", rcode, state.Name(), state.Type(), strErr)
+		log.Errorf("%d %s %s: %s", rc, state.Name(), state.Type(), strErr)
+		h.sinks.report(&report{
+			Level:   levelError,
+			Message: fmt.Sprintf("%d %s %s: %s", rc, state.Name(), state.Type(), strErr),
+			Plugin:  c.plugin,
+			Rcode:   rcode.ToString(rc),
+			Name:    state.Name(),
+			Type:    state.Type(),
+			Error:   strErr,
+		})
 	}
 
-	return rcode, err
+	return rc, err
 }
 
 // Name implements the plugin.Handler interface.
 func (h *errorHandler) Name() string { return "errors" }
+
+// logLevel logs msg on level.
+func logLevel(level, msg string) {
+	switch level {
+	case levelDebug:
+		log.Debug(msg)
+	case levelInfo:
+		log.Info(msg)
+	case levelWarning:
+		log.Warning(msg)
+	default:
+		log.Error(msg)
+	}
+}
+
+const (
+	levelError   = "error"
+	levelWarning = "warning"
+	levelInfo    = "info"
+	levelDebug   = "debug"
+)
@@ -20,6 +20,7 @@ import (
 	"github.com/coredns/coredns/plugin/test"
 
 	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestErrors(t *testing.T) {
@@ -385,3 +386,43 @@ func genErrorHandler(rcode int, err error) plugin.Handler {
 		return rcode, err
 	})
 }
+
+func TestClassify(t *testing.T) {
+	buf := bytes.Buffer{}
+	golog.SetOutput(&buf)
+
+	h := &errorHandler{
+		patterns: []*pattern{{
+			period:  time.Hour,
+			pattern: regexp.MustCompile("timeout$"),
+			level:   levelWarning,
+		}},
+		Next: plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			return plugin.NextOrFailure("cache", namedHandler("forward"), ctx, w, r)
+		}),
+	}
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	before := testutil.ToFloat64(errorsCount.WithLabelValues("", "forward", "SERVFAIL"))
+	for i := 0; i < 3; i++ {
+		h.ServeDNS(context.TODO(), &test.ResponseWriter{}, req)
+	}
+	if x := testutil.ToFloat64(errorsCount.WithLabelValues("", "forward", "SERVFAIL")) - before; x != 3 {
+		t.Errorf("Expected 3 errors to be counted, got %f", x)
+	}
+
+	h.stop()
+	expLog := "[WARNING] plugin/errors: 3 errors like 'timeout$' occurred in last 1h0m0s (forward SERVFAIL: 3)"
+	if log := buf.String(); !strings.Contains(log, expLog) {
+		t.Errorf("Expected log %q, but got %q", expLog, log)
+	}
+}
+
+type namedHandler string
+
+func (n namedHandler) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	return dns.RcodeServerFailure, errors.New("read udp 10.0.0.1:53: i/o timeout")
+}
+
+func (n namedHandler) Name() string { return string(n) }
@@ -0,0 +1,14 @@
+package errors
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// errorsCount counts the errors per server, the plugin they originate from and the rcode.
+var errorsCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: plugin.Namespace,
+	Name:      "errors_total",
+	Help:      "Counter of errors per plugin they originate from and rcode.",
+}, []string{"server", "plugin", "rcode"})
@@ -1,11 +1,16 @@
 package errors
 
 import (
+	"fmt"
+	"net/url"
+	"path/filepath"
 	"regexp"
+	"strings"
 	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
 
 	"github.com/caddyserver/caddy"
 )
@@ -23,8 +28,13 @@ func setup(c *caddy.Controller) error {
 		return plugin.Error("errors", err)
 	}
 
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, errorsCount)
+		return handler.startSinks()
+	})
 	c.OnShutdown(func() error {
 		handler.stop()
+		handler.stopSinks()
 		return nil
 	})
 
@@ -38,6 +48,7 @@ func setup(c *caddy.Controller) error {
 
 func errorsParse(c *caddy.Controller) (*errorHandler, error) {
 	handler := newErrorHandler()
+	root := dnsserver.GetConfig(c).Root
 
 	i := 0
 	for c.Next() {
@@ -58,7 +69,7 @@ func errorsParse(c *caddy.Controller) (*errorHandler, error) {
 		}
 
 		for c.NextBlock() {
-			if err := parseBlock(c, handler); err != nil {
+			if err := parseBlock(c, handler, root); err != nil {
 				return nil, err
 			}
 		}
@@ -66,13 +77,19 @@ func errorsParse(c *caddy.Controller) (*errorHandler, error) {
 	return handler, nil
 }
 
-func parseBlock(c *caddy.Controller, h *errorHandler) error {
-	if c.Val() != "consolidate" {
-		return c.SyntaxErr("consolidate")
+func parseBlock(c *caddy.Controller, h *errorHandler, root string) error {
+	switch c.Val() {
+	case "consolidate":
+		return parseConsolidate(c, h)
+	case "sink":
+		return parseSink(c, h, root)
 	}
+	return c.SyntaxErr("consolidate or sink")
+}
 
+func parseConsolidate(c *caddy.Controller, h *errorHandler) error {
 	args := c.RemainingArgs()
-	if len(args) != 2 {
+	if len(args) != 2 && len(args) != 3 {
 		return c.ArgErr()
 	}
 	p, err := time.ParseDuration(args[0])
@@ -83,7 +100,99 @@ func parseBlock(c *caddy.Controller, h *errorHandler) error {
 	if err != nil {
 		return c.Err(err.Error())
 	}
-	h.patterns = append(h.patterns, &pattern{period: p, pattern: re})
+	level := levelError
+	if len(args) == 3 {
+		level = strings.ToLower(args[2])
+		switch level {
+		case levelError, levelWarning, levelInfo, levelDebug:
+		default:
+			return c.Errf("unknown log level: %s", args[2])
+		}
+	}
+	h.patterns = append(h.patterns, &pattern{period: p, pattern: re, level: level})
+
+	return nil
+}
+
+func parseSink(c *caddy.Controller, h *errorHandler, root string) error {
+	args := c.RemainingArgs()
+	if len(args) == 0 {
+		return c.ArgErr()
+	}
+	sc := sinkConfig{kind: args[0]}
+	switch sc.kind {
+	case "syslog":
+		if len(args) > 2 {
+			return c.ArgErr()
+		}
+		if len(args) == 2 {
+			sc.target = args[1]
+		}
+	case "file":
+		if len(args) != 2 {
+			return c.ArgErr()
+		}
+		sc.target = args[1]
+		if !filepath.IsAbs(sc.target) && root != "" {
+			sc.target = filepath.Join(root, sc.target)
+		}
+	case "webhook":
+		if len(args) != 2 {
+			return c.ArgErr()
+		}
+		u, err := url.Parse(args[1])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return c.Errf("invalid webhook URL: %s", args[1])
+		}
+		sc.target = args[1]
+	default:
+		return c.Errf("unknown sink: %s", sc.kind)
+	}
+	h.sinkConfigs = append(h.sinkConfigs, sc)
+	return nil
+}
+
+// sinkConfig is a sink as configured, it is opened on startup.
+type sinkConfig struct {
+	kind   string // syslog, file or webhook
+	target string
+}
 
+// startSinks opens the sinks and starts sending reports to them.
+func (h *errorHandler) startSinks() error {
+	if len(h.sinkConfigs) == 0 {
+		return nil
+	}
+	var sinks []sink
+	for _, sc := range h.sinkConfigs {
+		var (
+			s   sink
+			err error
+		)
+		switch sc.kind {
+		case "syslog":
+			s, err = newSyslogSink(sc.target)
+		case "file":
+			s, err = newFileSink(sc.target)
+		case "webhook":
+			s = newWebhookSink(sc.target)
+		}
+		if err != nil {
+			for _, s := range sinks {
+				s.close()
+			}
+			return fmt.Errorf("failed to open %s sink: %s", sc.kind, err)
+		}
+		sinks = append(sinks, s)
+	}
+	h.sinks = newDispatcher(sinks)
+	h.sinks.start()
 	return nil
 }
+
+// stopSinks sends the remaining reports and closes the sinks.
+func (h *errorHandler) stopSinks() {
+	if h.sinks != nil {
+		h.sinks.stop()
+	}
+}
@@ -52,6 +52,30 @@ func TestErrorsParse(t *testing.T) {
 		    consolidate 1m error1
 		    consolidate 5s error2
 		  }`, false, 2},
+		{`errors {
+		    consolidate 1m error warning
+		  }`, false, 1},
+		{`errors {
+		    consolidate 1m error loud
+		  }`, true, 0},
+		{`errors {
+		    sink syslog
+		    sink syslog udp://127.0.0.1:514
+		    sink file errors.json
+		    sink webhook http://localhost:8080/errors
+		  }`, false, 0},
+		{`errors {
+		    sink
+		  }`, true, 0},
+		{`errors {
+		    sink file
+		  }`, true, 0},
+		{`errors {
+		    sink webhook localhost
+		  }`, true, 0},
+		{`errors {
+		    sink mail root@localhost
+		  }`, true, 0},
 	}
 	for i, test := range tests {
 		c := caddy.NewTestController("dns", test.inputErrorsRules)
@@ -0,0 +1,178 @@
+package errors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// report is an error, or a consolidated group of errors, as sent to the sinks.
+type report struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"message"`
+
+	// Set for a single error.
+	Plugin string `json:"plugin,omitempty"`
+	Rcode  string `json:"rcode,omitempty"`
+	Name   string `json:"name,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Error  string `json:"error,omitempty"`
+
+	// Set for consolidated errors.
+	Pattern string `json:"pattern,omitempty"`
+	Count   uint32 `json:"count,omitempty"`
+	Period  string `json:"period,omitempty"`
+}
+
+// sink is a destination for reports, besides the log.
+type sink interface {
+	send(r *report) error
+	close() error
+}
+
+// dispatcher sends the reports to the sinks in the background, so a slow sink doesn't slow down
+// the queries. When it can't keep up, reports are dropped.
+type dispatcher struct {
+	sinks []sink
+	queue chan *report
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+func newDispatcher(sinks []sink) *dispatcher {
+	return &dispatcher{
+		sinks: sinks,
+		queue: make(chan *report, queueSize),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+// report queues r for the sinks. It is a noop if d is nil.
+func (d *dispatcher) report(r *report) {
+	if d == nil {
+		return
+	}
+	r.Time = time.Now().UTC()
+	if r.Level == "" {
+		r.Level = levelError
+	}
+	select {
+	case d.queue <- r:
+	default:
+	}
+}
+
+func (d *dispatcher) start() {
+	go func() {
+		defer close(d.done)
+		for {
+			select {
+			case r := <-d.queue:
+				d.send(r)
+			case <-d.quit:
+				for {
+					select {
+					case r := <-d.queue:
+						d.send(r)
+					default:
+						for _, s := range d.sinks {
+							s.close()
+						}
+						return
+					}
+				}
+			}
+		}
+	}()
+}
+
+// stop sends the queued reports and closes the sinks.
+func (d *dispatcher) stop() {
+	close(d.quit)
+	<-d.done
+}
+
+func (d *dispatcher) send(r *report) {
+	for _, s := range d.sinks {
+		if err := s.send(r); err != nil {
+			log.Warningf("Failed to send error report: %s", err)
+		}
+	}
+}
+
+// fileSink appends the reports to a file, as JSON, one per line.
+type fileSink struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+func newFileSink(path string) (*fileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileSink{f: f}, nil
+}
+
+func (s *fileSink) send(r *report) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.f.Write(append(buf, '\n'))
+	return err
+}
+
+func (s *fileSink) close() error { return s.f.Close() }
+
+// webhookSink posts each report, as JSON, to a URL.
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func newWebhookSink(url string) *webhookSink {
+	return &webhookSink{url: url, client: &http.Client{Timeout: webhookTimeout}}
+}
+
+func (s *webhookSink) send(r *report) error {
+	buf, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status from %s: %s", s.url, resp.Status)
+	}
+	return nil
+}
+
+func (s *webhookSink) close() error { return nil }
+
+const (
+	queueSize      = 1000
+	webhookTimeout = 5 * time.Second
+)
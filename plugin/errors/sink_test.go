@@ -0,0 +1,74 @@
+package errors
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestSinks(t *testing.T) {
+	reports := make(chan report, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rep := report{}
+		if err := json.NewDecoder(r.Body).Decode(&rep); err == nil {
+			reports <- rep
+		}
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "coredns-errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "errors.json")
+
+	h := &errorHandler{
+		sinkConfigs: []sinkConfig{{kind: "file", target: path}, {kind: "webhook", target: ts.URL}},
+		Next:        namedHandler("forward"),
+	}
+	if err := h.startSinks(); err != nil {
+		t.Fatalf("Failed to start sinks: %s", err)
+	}
+
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	h.ServeDNS(context.TODO(), &test.ResponseWriter{}, req)
+	h.stopSinks()
+
+	var rep report
+	select {
+	case rep = <-reports:
+	default:
+		t.Fatal("Expected a report to be posted to the webhook")
+	}
+	if rep.Plugin != "forward" || rep.Rcode != "SERVFAIL" || rep.Name != "example.org." || rep.Level != levelError {
+		t.Errorf("Unexpected report: %+v", rep)
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(buf)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 report in the file, got %d", len(lines))
+	}
+	rep = report{}
+	if err := json.Unmarshal([]byte(lines[0]), &rep); err != nil {
+		t.Fatalf("Failed to parse report: %s", err)
+	}
+	if rep.Error != "read udp 10.0.0.1:53: i/o timeout" {
+		t.Errorf("Unexpected error in report: %q", rep.Error)
+	}
+}
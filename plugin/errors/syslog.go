@@ -0,0 +1,32 @@
+package errors
+
+import clog "github.com/coredns/coredns/plugin/pkg/log"
+
+// syslogSink sends the reports to syslog, the level of a report sets the severity.
+type syslogSink struct {
+	out clog.Output
+}
+
+// newSyslogSink returns a sink for the syslog at addr, a URL like unix:///dev/log or udp://host:514.
+// The local syslog is used when addr is empty.
+func newSyslogSink(addr string) (sink, error) {
+	out, err := clog.NewSyslog(addr, false)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{out: out}, nil
+}
+
+func (s *syslogSink) send(r *report) error {
+	return s.out.Write(clog.Entry{Time: r.Time, Level: entryLevels[r.Level], Msg: r.Message})
+}
+
+func (s *syslogSink) close() error { return s.out.Close() }
+
+// entryLevels maps the level of a report to the level of a log entry, unknown levels are errors.
+var entryLevels = map[string]string{
+	levelDebug:   " [DEBUG] ",
+	levelInfo:    " [INFO] ",
+	levelWarning: " [WARNING] ",
+	levelError:   " [ERROR] ",
+}
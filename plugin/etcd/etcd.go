@@ -36,6 +36,7 @@ type Etcd struct {
 	PathPrefix string
 	Upstream   *upstream.Upstream
 	Client     *etcdcv3.Client
+	TsigSecret map[string]string // TSIG keys that may sign dynamic updates, keyed by key name.
 
 	endpoints []string // Stored here as well, to aid in testing.
 }
@@ -19,6 +19,10 @@ func (e *Etcd) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 		return plugin.NextOrFailure(e.Name(), e.Next, ctx, w, r)
 	}
 
+	if r.Opcode == dns.OpcodeUpdate {
+		return e.update(ctx, w, r, zone)
+	}
+
 	var (
 		records, extra []dns.RR
 		err            error
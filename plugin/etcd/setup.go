@@ -2,6 +2,8 @@ package etcd
 
 import (
 	"crypto/tls"
+	"encoding/base64"
+	"strings"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
@@ -11,6 +13,7 @@ import (
 
 	"github.com/caddyserver/caddy"
 	etcdcv3 "github.com/coreos/etcd/clientv3"
+	"github.com/miekg/dns"
 )
 
 var log = clog.NewWithPlugin("etcd")
@@ -28,7 +31,15 @@ func setup(c *caddy.Controller) error {
 		return plugin.Error("etcd", err)
 	}
 
-	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+	config := dnsserver.GetConfig(c)
+	for name, secret := range e.TsigSecret {
+		if config.TsigSecret == nil {
+			config.TsigSecret = make(map[string]string)
+		}
+		config.TsigSecret[name] = secret
+	}
+
+	config.AddPlugin(func(next plugin.Handler) plugin.Handler {
 		e.Next = next
 		return e
 	})
@@ -95,6 +106,18 @@ func etcdParse(c *caddy.Controller) (*Etcd, error) {
 					return &Etcd{}, c.Errf("credentials requires 2 arguments, username and password")
 				}
 				username, password = args[0], args[1]
+			case "update":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return &Etcd{}, c.ArgErr()
+				}
+				if _, err := base64.StdEncoding.DecodeString(args[1]); err != nil {
+					return &Etcd{}, c.Errf("invalid TSIG secret for key '%s': %s", args[0], err)
+				}
+				if etc.TsigSecret == nil {
+					etc.TsigSecret = make(map[string]string)
+				}
+				etc.TsigSecret[dns.Fqdn(strings.ToLower(args[0]))] = args[1]
 			default:
 				if c.Val() != "}" {
 					return &Etcd{}, c.Errf("unknown property '%s'", c.Val())
@@ -116,3 +116,23 @@ func TestSetupEtcd(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupEtcdUpdate(t *testing.T) {
+	c := caddy.NewTestController("dns", `etcd {
+	update Key.Example.org. c2VjcmV0
+}`)
+	etcd, err := etcdParse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if x := etcd.TsigSecret["key.example.org."]; x != "c2VjcmV0" {
+		t.Errorf("Expected TSIG secret for %q, got %q", "key.example.org.", x)
+	}
+
+	c = caddy.NewTestController("dns", `etcd {
+	update key.example.org. not-base64
+}`)
+	if _, err := etcdParse(c); err == nil {
+		t.Errorf("Expected error for invalid TSIG secret, got none")
+	}
+}
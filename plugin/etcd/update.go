@@ -0,0 +1,498 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+
+	etcdcv3 "github.com/coreos/etcd/clientv3"
+	"github.com/miekg/dns"
+)
+
+// updateRetries is the number of times an update is retried when etcd was modified concurrently.
+const updateRetries = 3
+
+var errConcurrentUpdate = errors.New("etcd modified concurrently during update")
+
+// node is a single service as stored in etcd, together with the key it lives under.
+type node struct {
+	key  string
+	serv *msg.Service
+}
+
+// snapshot is what owned read from etcd: the revision it was read at, and the mod revision of every key.
+type snapshot struct {
+	rev  int64
+	keys map[string]int64
+}
+
+// update handles a dynamic update (RFC 2136) for zone. The reply is always written here.
+func (e *Etcd) update(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, zone string) (int, error) {
+	rcode, err := e.applyUpdate(ctx, w, r, zone)
+
+	m := new(dns.Msg)
+	m.SetRcode(r, rcode)
+	if t := r.IsTsig(); t != nil && w.TsigStatus() == nil {
+		m.SetTsig(t.Hdr.Name, t.Algorithm, t.Fudge, time.Now().Unix())
+	}
+	w.WriteMsg(m)
+	return dns.RcodeSuccess, err
+}
+
+func (e *Etcd) applyUpdate(ctx context.Context, w dns.ResponseWriter, r *dns.Msg, zone string) (int, error) {
+	if len(e.TsigSecret) == 0 {
+		return dns.RcodeRefused, nil
+	}
+	q := r.Question[0]
+	if q.Qtype != dns.TypeSOA || q.Qclass != dns.ClassINET {
+		return dns.RcodeFormatError, nil
+	}
+	if strings.ToLower(q.Name) != zone {
+		return dns.RcodeNotAuth, nil
+	}
+
+	t := r.IsTsig()
+	if t == nil {
+		return dns.RcodeRefused, nil
+	}
+	if _, ok := e.TsigSecret[strings.ToLower(t.Hdr.Name)]; !ok {
+		return dns.RcodeNotAuth, nil
+	}
+	if err := w.TsigStatus(); err != nil {
+		log.Warningf("Rejected update for %q signed with %q: %s", zone, t.Hdr.Name, err)
+		return dns.RcodeNotAuth, nil
+	}
+
+	names, rcode := updateNames(zone, r)
+	if rcode != dns.RcodeSuccess {
+		return rcode, nil
+	}
+
+	for i := 0; i < updateRetries; i++ {
+		state, snap, err := e.owned(ctx, names)
+		if err != nil {
+			return dns.RcodeServerFailure, err
+		}
+
+		u := newUpdater(e.PathPrefix, state)
+		if rcode := u.prerequisites(r.Answer); rcode != dns.RcodeSuccess {
+			return rcode, nil
+		}
+		u.apply(r.Ns)
+
+		err = e.commit(ctx, names, snap, u.ops())
+		if err == errConcurrentUpdate {
+			continue
+		}
+		if err != nil {
+			return dns.RcodeServerFailure, err
+		}
+		log.Infof("Applied update for %q signed with %q", zone, t.Hdr.Name)
+		return dns.RcodeSuccess, nil
+	}
+	return dns.RcodeServerFailure, errConcurrentUpdate
+}
+
+// updateNames returns the (lowercased) owner names from the prerequisite and update sections of r.
+// It also performs the update section prescan from RFC 2136, section 3.4.1.
+func updateNames(zone string, r *dns.Msg) ([]string, int) {
+	seen := make(map[string]struct{})
+	names := []string{}
+	add := func(rr dns.RR) int {
+		name := strings.ToLower(rr.Header().Name)
+		if !dns.IsSubDomain(zone, name) {
+			return dns.RcodeNotZone
+		}
+		if _, ok := seen[name]; !ok {
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+		return dns.RcodeSuccess
+	}
+
+	for _, rr := range r.Answer {
+		if rcode := add(rr); rcode != dns.RcodeSuccess {
+			return nil, rcode
+		}
+	}
+	for _, rr := range r.Ns {
+		if rcode := add(rr); rcode != dns.RcodeSuccess {
+			return nil, rcode
+		}
+		h := rr.Header()
+		switch h.Class {
+		case dns.ClassINET:
+			if !updateType(h.Rrtype) {
+				return nil, dns.RcodeRefused
+			}
+		case dns.ClassANY:
+			if h.Ttl != 0 || h.Rdlength != 0 {
+				return nil, dns.RcodeFormatError
+			}
+			if h.Rrtype != dns.TypeANY && !updateType(h.Rrtype) {
+				return nil, dns.RcodeRefused
+			}
+		case dns.ClassNONE:
+			if h.Ttl != 0 {
+				return nil, dns.RcodeFormatError
+			}
+			if !updateType(h.Rrtype) {
+				return nil, dns.RcodeRefused
+			}
+		default:
+			return nil, dns.RcodeFormatError
+		}
+	}
+	return names, dns.RcodeSuccess
+}
+
+// owned returns the services stored for each of the names. These are the services stored on the key
+// for the name itself and the ones directly below it. The etcd revision the data was read at, and
+// the mod revisions of the keys, are returned as well.
+func (e *Etcd) owned(ctx context.Context, names []string) (map[string][]node, snapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	defer cancel()
+
+	state := make(map[string][]node)
+	snap := snapshot{keys: make(map[string]int64)}
+	for _, name := range names {
+		path := msg.Path(name, e.PathPrefix)
+		opts := []etcdcv3.OpOption{etcdcv3.WithPrefix()}
+		if snap.rev > 0 {
+			opts = append(opts, etcdcv3.WithRev(snap.rev))
+		}
+		r, err := e.Client.Get(ctx, path, opts...)
+		if err != nil {
+			return nil, snapshot{}, err
+		}
+		if snap.rev == 0 {
+			snap.rev = r.Header.Revision
+		}
+		for _, kv := range r.Kvs {
+			key := string(kv.Key)
+			if key != path && (!strings.HasPrefix(key, path+"/") || strings.Contains(key[len(path)+1:], "/")) {
+				continue
+			}
+			serv := new(msg.Service)
+			if err := json.Unmarshal(kv.Value, serv); err != nil {
+				return nil, snapshot{}, fmt.Errorf("%s: %s", kv.Key, err)
+			}
+			serv.Key = key
+			state[name] = append(state[name], node{key: key, serv: serv})
+			snap.keys[key] = kv.ModRevision
+		}
+	}
+	return state, snap, nil
+}
+
+// commit executes ops in a single etcd transaction, that only succeeds if nothing was modified under
+// any of the names since snap was read.
+func (e *Etcd) commit(ctx context.Context, names []string, snap snapshot, ops []etcdcv3.Op) error {
+	if len(ops) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, etcdTimeout)
+	defer cancel()
+
+	r, err := e.Client.Txn(ctx).If(e.compares(names, snap)...).Then(ops...).Commit()
+	if err != nil {
+		return err
+	}
+	if !r.Succeeded {
+		return errConcurrentUpdate
+	}
+	return nil
+}
+
+// compares returns the conditions for the transaction of commit. Every key that was read must still
+// have the same mod revision, a deleted key has 0. Keys created since, for a name or below it, have a
+// mod revision after snap.rev.
+func (e *Etcd) compares(names []string, snap snapshot) []etcdcv3.Cmp {
+	cmps := make([]etcdcv3.Cmp, 0, len(snap.keys)+2*len(names))
+	for key, rev := range snap.keys {
+		cmps = append(cmps, etcdcv3.Compare(etcdcv3.ModRevision(key), "=", rev))
+	}
+	for _, name := range names {
+		path := msg.Path(name, e.PathPrefix)
+		cmps = append(cmps,
+			etcdcv3.Compare(etcdcv3.ModRevision(path), "<", snap.rev+1),
+			etcdcv3.Compare(etcdcv3.ModRevision(path+"/").WithPrefix(), "<", snap.rev+1),
+		)
+	}
+	return cmps
+}
+
+// updater applies prerequisites and updates on an in memory copy of the services.
+type updater struct {
+	prefix  string
+	state   map[string][]node
+	changes map[string]*msg.Service // keys to put, a nil service means the key is deleted
+}
+
+func newUpdater(prefix string, state map[string][]node) *updater {
+	return &updater{prefix: prefix, state: state, changes: make(map[string]*msg.Service)}
+}
+
+// prerequisites checks the prerequisite section as described in RFC 2136, section 3.2.
+func (u *updater) prerequisites(rrs []dns.RR) int {
+	// RRset exists (value dependent) prerequisites are collected and compared at the end.
+	type set struct {
+		name string
+		typ  uint16
+	}
+	want := make(map[set]map[string]struct{})
+
+	for _, rr := range rrs {
+		h := rr.Header()
+		if h.Ttl != 0 {
+			return dns.RcodeFormatError
+		}
+		name := strings.ToLower(h.Name)
+		nodes := u.state[name]
+
+		switch h.Class {
+		case dns.ClassANY:
+			if h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if h.Rrtype == dns.TypeANY {
+				if len(nodes) == 0 {
+					return dns.RcodeNameError
+				}
+				continue
+			}
+			if len(u.rrs(name, h.Rrtype)) == 0 {
+				return dns.RcodeNXRrset
+			}
+		case dns.ClassNONE:
+			if h.Rdlength != 0 {
+				return dns.RcodeFormatError
+			}
+			if h.Rrtype == dns.TypeANY {
+				if len(nodes) > 0 {
+					return dns.RcodeYXDomain
+				}
+				continue
+			}
+			if len(u.rrs(name, h.Rrtype)) > 0 {
+				return dns.RcodeYXRrset
+			}
+		case dns.ClassINET:
+			s := set{name, h.Rrtype}
+			if want[s] == nil {
+				want[s] = make(map[string]struct{})
+			}
+			want[s][rdata(rr)] = struct{}{}
+		default:
+			return dns.RcodeFormatError
+		}
+	}
+
+	for s, rdatas := range want {
+		have := make(map[string]struct{})
+		for _, rr := range u.rrs(s.name, s.typ) {
+			have[rdata(rr)] = struct{}{}
+		}
+		if len(have) != len(rdatas) {
+			return dns.RcodeNXRrset
+		}
+		for r := range rdatas {
+			if _, ok := have[r]; !ok {
+				return dns.RcodeNXRrset
+			}
+		}
+	}
+	return dns.RcodeSuccess
+}
+
+// apply applies the update section as described in RFC 2136, section 3.4.2. The section must have
+// passed the prescan done in updateNames.
+func (u *updater) apply(rrs []dns.RR) {
+	for _, rr := range rrs {
+		h := rr.Header()
+		name := strings.ToLower(h.Name)
+
+		switch h.Class {
+		case dns.ClassINET:
+			u.add(name, rr)
+		case dns.ClassANY:
+			u.remove(name, func(n node) bool { return h.Rrtype == dns.TypeANY || serviceType(n.serv) == h.Rrtype })
+		case dns.ClassNONE:
+			u.remove(name, func(n node) bool { return match(n.serv, rr) })
+		}
+	}
+}
+
+// add adds rr to name, unless that conflicts with a CNAME (RFC 2136, section 3.4.2.2). Adding an
+// existing record replaces its TTL and a CNAME replaces an existing CNAME.
+func (u *updater) add(name string, rr dns.RR) {
+	typ := rr.Header().Rrtype
+	for _, n := range u.state[name] {
+		t := serviceType(n.serv)
+		if (typ == dns.TypeCNAME) != (t == dns.TypeCNAME) {
+			return
+		}
+	}
+	if typ == dns.TypeCNAME {
+		u.remove(name, func(n node) bool { return true })
+	}
+	u.remove(name, func(n node) bool { return match(n.serv, rr) })
+
+	serv := toService(rr)
+	key := msg.Path(name, u.prefix) + "/" + recordID(rr)
+	serv.Key = key
+	u.changes[key] = serv
+	u.state[name] = append(u.state[name], node{key: key, serv: serv})
+}
+
+// remove removes all services for name for which f returns true.
+func (u *updater) remove(name string, f func(node) bool) {
+	keep := u.state[name][:0]
+	for _, n := range u.state[name] {
+		if !f(n) {
+			keep = append(keep, n)
+			continue
+		}
+		u.changes[n.key] = nil
+	}
+	u.state[name] = keep
+}
+
+// rrs returns the records of type typ stored for name.
+func (u *updater) rrs(name string, typ uint16) []dns.RR {
+	rrs := []dns.RR{}
+	for _, n := range u.state[name] {
+		if serviceType(n.serv) != typ {
+			continue
+		}
+		if rr := toRR(name, n.serv); rr != nil {
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+// ops returns the etcd operations needed to store the result of the update.
+func (u *updater) ops() []etcdcv3.Op {
+	ops := []etcdcv3.Op{}
+	for key, serv := range u.changes {
+		if serv == nil {
+			ops = append(ops, etcdcv3.OpDelete(key))
+			continue
+		}
+		b, _ := json.Marshal(serv)
+		ops = append(ops, etcdcv3.OpPut(key, string(b)))
+	}
+	return ops
+}
+
+// updateType returns true if records of type typ can be added or removed with an update.
+func updateType(typ uint16) bool {
+	switch typ {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeSRV, dns.TypeTXT, dns.TypeCNAME:
+		return true
+	}
+	return false
+}
+
+// serviceType returns the record type a service is treated as when updating. A service with only text
+// is a TXT record, one with an IP address an A or AAAA record. A service with a domain name as host is
+// a SRV record when it has a port and a CNAME otherwise.
+func serviceType(serv *msg.Service) uint16 {
+	if serv.Host == "" {
+		if serv.Text != "" {
+			return dns.TypeTXT
+		}
+		return dns.TypeNone
+	}
+	if ip := net.ParseIP(serv.Host); ip != nil {
+		if ip.To4() != nil {
+			return dns.TypeA
+		}
+		return dns.TypeAAAA
+	}
+	if serv.Port != 0 {
+		return dns.TypeSRV
+	}
+	return dns.TypeCNAME
+}
+
+// toService converts rr to the service that is stored in etcd.
+func toService(rr dns.RR) *msg.Service {
+	serv := &msg.Service{TTL: rr.Header().Ttl}
+	switch x := rr.(type) {
+	case *dns.A:
+		serv.Host = x.A.String()
+	case *dns.AAAA:
+		serv.Host = x.AAAA.String()
+	case *dns.CNAME:
+		serv.Host = x.Target
+	case *dns.SRV:
+		serv.Host = x.Target
+		serv.Port = int(x.Port)
+		serv.Priority = int(x.Priority)
+		serv.Weight = int(x.Weight)
+	case *dns.TXT:
+		serv.Text = strings.Join(x.Txt, "")
+	}
+	return serv
+}
+
+// toRR converts serv to a record for name, using serviceType to determine the record type.
+func toRR(name string, serv *msg.Service) dns.RR {
+	switch serviceType(serv) {
+	case dns.TypeA:
+		return serv.NewA(name, net.ParseIP(serv.Host))
+	case dns.TypeAAAA:
+		return serv.NewAAAA(name, net.ParseIP(serv.Host))
+	case dns.TypeCNAME:
+		return serv.NewCNAME(name, serv.Host)
+	case dns.TypeSRV:
+		return serv.NewSRV(name, uint16(serv.Weight))
+	case dns.TypeTXT:
+		return serv.NewTXT(name)
+	}
+	return nil
+}
+
+// match returns true if serv holds the same data as rr, the TTL is ignored.
+func match(serv *msg.Service, rr dns.RR) bool {
+	if serviceType(serv) != rr.Header().Rrtype {
+		return false
+	}
+	x := toRR(rr.Header().Name, serv)
+	if x == nil {
+		return false
+	}
+	return rdata(x) == rdata(rr)
+}
+
+// rdata returns the presentation format of the rdata of rr. Domain names are lowercased.
+func rdata(rr dns.RR) string {
+	switch x := rr.(type) {
+	case *dns.TXT:
+		// A TXT record is stored as a single string, compare the concatenation.
+		return strings.Join(x.Txt, "")
+	case *dns.CNAME:
+		return strings.ToLower(dns.Fqdn(x.Target))
+	case *dns.SRV:
+		return fmt.Sprintf("%d %d %d %s", x.Priority, x.Weight, x.Port, strings.ToLower(dns.Fqdn(x.Target)))
+	}
+	return strings.TrimPrefix(rr.String(), rr.Header().String())
+}
+
+// recordID returns the last path element of the key an added record is stored under. It is derived
+// from the record's data, so adding the same record twice ends up in the same key.
+func recordID(rr dns.RR) string {
+	h := fnv.New64a()
+	h.Write([]byte(dns.TypeToString[rr.Header().Rrtype]))
+	h.Write([]byte(rdata(rr)))
+	return fmt.Sprintf("%x", h.Sum64())
+}
@@ -0,0 +1,174 @@
+package etcd
+
+import (
+	"testing"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func updateState() map[string][]node {
+	return map[string][]node{
+		"a.skydns.test.": {
+			{key: "/skydns/test/skydns/a/x1", serv: &msg.Service{Host: "10.0.0.1"}},
+			{key: "/skydns/test/skydns/a/x2", serv: &msg.Service{Host: "10.0.0.2"}},
+			{key: "/skydns/test/skydns/a/x3", serv: &msg.Service{Text: "some text"}},
+		},
+		"cname.skydns.test.": {
+			{key: "/skydns/test/skydns/cname", serv: &msg.Service{Host: "a.skydns.test"}},
+		},
+	}
+}
+
+func TestUpdatePrerequisites(t *testing.T) {
+	rrset := func(rr dns.RR) dns.RR { m := new(dns.Msg); m.RRsetUsed([]dns.RR{rr}); return m.Answer[0] }
+	rrsetNot := func(rr dns.RR) dns.RR { m := new(dns.Msg); m.RRsetNotUsed([]dns.RR{rr}); return m.Answer[0] }
+	name := func(rr dns.RR) dns.RR { m := new(dns.Msg); m.NameUsed([]dns.RR{rr}); return m.Answer[0] }
+	nameNot := func(rr dns.RR) dns.RR { m := new(dns.Msg); m.NameNotUsed([]dns.RR{rr}); return m.Answer[0] }
+	value := func(s string) dns.RR { rr := test.A(s); rr.Header().Ttl = 0; return rr }
+
+	tests := []struct {
+		prereq []dns.RR
+		rcode  int
+	}{
+		{[]dns.RR{name(test.A("a.skydns.test. IN A 127.0.0.1"))}, dns.RcodeSuccess},
+		{[]dns.RR{name(test.A("b.skydns.test. IN A 127.0.0.1"))}, dns.RcodeNameError},
+		{[]dns.RR{nameNot(test.A("b.skydns.test. IN A 127.0.0.1"))}, dns.RcodeSuccess},
+		{[]dns.RR{nameNot(test.A("a.skydns.test. IN A 127.0.0.1"))}, dns.RcodeYXDomain},
+		{[]dns.RR{rrset(test.TXT(`a.skydns.test. IN TXT "x"`))}, dns.RcodeSuccess},
+		{[]dns.RR{rrset(test.AAAA("a.skydns.test. IN AAAA ::1"))}, dns.RcodeNXRrset},
+		{[]dns.RR{rrsetNot(test.AAAA("a.skydns.test. IN AAAA ::1"))}, dns.RcodeSuccess},
+		{[]dns.RR{rrsetNot(test.CNAME("cname.skydns.test. IN CNAME x.skydns.test."))}, dns.RcodeYXRrset},
+		{[]dns.RR{value("a.skydns.test. IN A 10.0.0.1"), value("a.skydns.test. IN A 10.0.0.2")}, dns.RcodeSuccess},
+		{[]dns.RR{value("a.skydns.test. IN A 10.0.0.1")}, dns.RcodeNXRrset},
+		{[]dns.RR{value("a.skydns.test. IN A 10.0.0.1"), value("a.skydns.test. IN A 10.0.0.3")}, dns.RcodeNXRrset},
+		{[]dns.RR{test.A("a.skydns.test. 300 IN A 10.0.0.1")}, dns.RcodeFormatError},
+	}
+
+	for i, tc := range tests {
+		u := newUpdater("skydns", updateState())
+		if rcode := u.prerequisites(tc.prereq); rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+		}
+	}
+}
+
+func TestUpdateApply(t *testing.T) {
+	tests := []struct {
+		update func(m *dns.Msg)
+		name   string
+		want   []string // rdata of all records stored for name after the update
+		keys   int      // number of changed keys
+	}{
+		{
+			func(m *dns.Msg) { m.Insert([]dns.RR{test.A("a.skydns.test. 60 IN A 10.0.0.3")}) },
+			"a.skydns.test.", []string{"10.0.0.1", "10.0.0.2", "some text", "10.0.0.3"}, 1,
+		},
+		{
+			func(m *dns.Msg) { m.Remove([]dns.RR{test.A("a.skydns.test. IN A 10.0.0.1")}) },
+			"a.skydns.test.", []string{"10.0.0.2", "some text"}, 1,
+		},
+		{
+			func(m *dns.Msg) { m.RemoveRRset([]dns.RR{test.A("a.skydns.test. IN A 10.0.0.1")}) },
+			"a.skydns.test.", []string{"some text"}, 2,
+		},
+		{
+			func(m *dns.Msg) { m.RemoveName([]dns.RR{test.A("a.skydns.test. IN A 10.0.0.1")}) },
+			"a.skydns.test.", []string{}, 3,
+		},
+		{
+			// CNAME can't be added to a name that has other data.
+			func(m *dns.Msg) { m.Insert([]dns.RR{test.CNAME("a.skydns.test. 60 IN CNAME b.skydns.test.")}) },
+			"a.skydns.test.", []string{"10.0.0.1", "10.0.0.2", "some text"}, 0,
+		},
+		{
+			// A CNAME replaces an existing one.
+			func(m *dns.Msg) { m.Insert([]dns.RR{test.CNAME("cname.skydns.test. 60 IN CNAME b.skydns.test.")}) },
+			"cname.skydns.test.", []string{"b.skydns.test."}, 2,
+		},
+		{
+			func(m *dns.Msg) {
+				m.Insert([]dns.RR{test.SRV("srv.skydns.test. 60 IN SRV 10 20 8080 a.skydns.test.")})
+			},
+			"srv.skydns.test.", []string{"10 20 8080 a.skydns.test."}, 1,
+		},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetUpdate("skydns.test.")
+		tc.update(m)
+
+		if _, rcode := updateNames("skydns.test.", m); rcode != dns.RcodeSuccess {
+			t.Fatalf("Test %d: expected update to pass the prescan, got %s", i, dns.RcodeToString[rcode])
+		}
+
+		u := newUpdater("skydns", updateState())
+		u.apply(m.Ns)
+
+		got := []string{}
+		for _, n := range u.state[tc.name] {
+			got = append(got, rdata(toRR(tc.name, n.serv)))
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("Test %d: expected %v, got %v", i, tc.want, got)
+			continue
+		}
+		for j := range got {
+			if got[j] != tc.want[j] {
+				t.Errorf("Test %d: expected %v, got %v", i, tc.want, got)
+				break
+			}
+		}
+		if x := len(u.ops()); x != tc.keys {
+			t.Errorf("Test %d: expected %d etcd operations, got %d", i, tc.keys, x)
+		}
+	}
+}
+
+func TestUpdateNames(t *testing.T) {
+	tests := []struct {
+		rr    dns.RR
+		rcode int
+	}{
+		{test.A("a.skydns.test. 60 IN A 10.0.0.1"), dns.RcodeSuccess},
+		{test.A("a.example.org. 60 IN A 10.0.0.1"), dns.RcodeNotZone},
+		{test.MX("a.skydns.test. 60 IN MX 10 mx.skydns.test."), dns.RcodeRefused},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetUpdate("skydns.test.")
+		m.Insert([]dns.RR{tc.rr})
+
+		if _, rcode := updateNames("skydns.test.", m); rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+		}
+	}
+}
+
+func TestUpdateCompares(t *testing.T) {
+	e := &Etcd{PathPrefix: "skydns"}
+	snap := snapshot{rev: 10, keys: map[string]int64{"/skydns/test/skydns/a/x1": 7}}
+	cmps := e.compares([]string{"a.skydns.test."}, snap)
+
+	found := map[string]bool{}
+	for _, c := range cmps {
+		key := string(c.KeyBytes())
+		if len(c.RangeEnd) > 0 {
+			key += "*"
+		}
+		found[key] = true
+	}
+	// The key that was read, the name itself, and anything below it, but not a sibling like a2.
+	for _, k := range []string{"/skydns/test/skydns/a/x1", "/skydns/test/skydns/a", "/skydns/test/skydns/a/*"} {
+		if !found[k] {
+			t.Errorf("Expected a compare for %s, got %v", k, found)
+		}
+	}
+	if found["/skydns/test/skydns/a*"] {
+		t.Errorf("Expected no compare on the prefix without a trailing slash")
+	}
+}
@@ -9,12 +9,19 @@ the federation label. For federation to work we do a normal k8s lookup
 *without* that label, if that comes back with NXDOMAIN or NODATA(??) we create
 a federation record and return that.
 
-Federation is only useful in conjunction with the kubernetes plugin, without it is a noop.
+Federations are read from the Corefile, a file or the federations.coredns.io custom resource in
+kubernetes, the latter two are updated while CoreDNS is running.
+
+Federation is only useful in conjunction with a backend plugin, without it is a noop. When the
+backend implements Federator (like the kubernetes plugin) it creates the federation records,
+otherwise the federation label is kept and the zone is replaced with the federation's domain.
 */
 package federation
 
 import (
 	"context"
+	"errors"
+	"sync"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
@@ -32,6 +39,13 @@ type Federation struct {
 	zones    []string
 	Upstream *upstream.Upstream
 
+	// sources holds the federations per source, f is the merge of all of them.
+	sources map[string]map[string]string
+	mu      sync.RWMutex
+
+	file   *fileSource
+	watchK bool // watch the federations.coredns.io custom resources
+
 	Next        plugin.Handler
 	Federations Func
 }
@@ -40,9 +54,46 @@ type Federation struct {
 // federation. Right now this is only the kubernetes plugin.
 type Func func(state request.Request, fname, fzone string) (msg.Service, error)
 
+// Federator is implemented by plugins that create their own federation records.
+type Federator interface {
+	Federations(state request.Request, fname, fzone string) (msg.Service, error)
+}
+
+// Sources of federations, later ones take precedence when a name is defined in multiple sources.
+const (
+	sourceCorefile   = "corefile"
+	sourceFile       = "file"
+	sourceKubernetes = "kubernetes"
+)
+
+var sourceOrder = []string{sourceCorefile, sourceFile, sourceKubernetes}
+
 // New returns a new federation.
 func New() *Federation {
-	return &Federation{f: make(map[string]string)}
+	return &Federation{f: make(map[string]string), sources: make(map[string]map[string]string)}
+}
+
+// Update replaces the federations of source with feds.
+func (f *Federation) Update(source string, feds map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sources[source] = feds
+	merged := make(map[string]string)
+	for _, s := range sourceOrder {
+		for name, zone := range f.sources[s] {
+			merged[name] = zone
+		}
+	}
+	f.f = merged
+}
+
+// federation returns the domain of the federation name.
+func (f *Federation) federation(name string) (string, bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	zone, ok := f.f[name]
+	return zone, ok
 }
 
 // ServeDNS implements the plugin.Handle interface.
@@ -81,7 +132,8 @@ func (f *Federation) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.
 		return ret, err
 	}
 
-	if m := nw.Msg; m.Rcode != dns.RcodeNameError {
+	m := nw.Msg
+	if m.Rcode != dns.RcodeNameError {
 		// If positive answer we need to substitute the original qname in the answer.
 		m.Question[0].Name = qname
 		for _, a := range m.Answer {
@@ -94,7 +146,15 @@ func (f *Federation) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.
 	}
 
 	// Still here, we've seen NXDOMAIN and need to perform federation.
-	service, err := f.Federations(state, label, f.f[label]) // state references Req which has updated qname
+	fzone, ok := f.federation(label)
+	if !ok {
+		// The federation was removed while we were busy.
+		r.Question[0].Name = qname
+		m.Question[0].Name = qname
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+	service, err := f.Federations(state, label, fzone) // state references Req which has updated qname
 	if err != nil {
 		r.Question[0].Name = qname
 		return dns.RcodeServerFailure, err
@@ -102,7 +162,7 @@ func (f *Federation) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.
 
 	r.Question[0].Name = qname
 
-	m := new(dns.Msg)
+	m = new(dns.Msg)
 	m.SetReply(r)
 	m.Authoritative = true
 
@@ -139,9 +199,28 @@ func (f *Federation) isNameFederation(name, zone string) (string, string) {
 
 	fed := labels[ll-2]
 
-	if _, ok := f.f[fed]; ok {
+	if _, ok := f.federation(fed); ok {
 		without := dnsutil.Join(labels[:ll-2]...) + labels[ll-1] + "." + zone
 		return without, fed
 	}
 	return "", ""
 }
+
+// Backend returns the federation record for backends that don't implement Federator. The
+// federation label is put back in the name and the zone is replaced with fzone, i.e.
+// "nginx.prod.region.example.org." in zone "example.org." becomes "nginx.prod.region.<fzone>".
+func Backend(state request.Request, fname, fzone string) (msg.Service, error) {
+	base, _ := dnsutil.TrimZone(state.Name(), state.Zone)
+
+	labels := dns.SplitDomainName(base)
+	ll := len(labels)
+	if ll < 1 {
+		return msg.Service{}, errNoFederation
+	}
+
+	name := append([]string{}, labels[:ll-1]...)
+	name = append(name, fname, labels[ll-1], fzone)
+	return msg.Service{Host: dnsutil.Join(name...)}, nil
+}
+
+var errNoFederation = errors.New("name can't be federated")
@@ -117,3 +117,80 @@ func TestFederationKubernetesMissingLabels(t *testing.T) {
 		}
 	}
 }
+
+func TestFederationBackend(t *testing.T) {
+	tests := []test.Case{
+		{
+			Qname: "web.prod.region1.skydns.test.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeSuccess,
+			Answer: []dns.RR{
+				test.CNAME("web.prod.region1.skydns.test.  303       IN      CNAME   web.prod.region1.federal.example."),
+			},
+		},
+		{
+			Qname: "web.staging.region1.skydns.test.", Qtype: dns.TypeA,
+			Rcode: dns.RcodeNameError,
+		},
+	}
+
+	fed := New()
+	fed.zones = []string{"skydns.test."}
+	fed.Federations = Backend
+	// Backend that doesn't know any name.
+	fed.Next = test.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeNameError)
+		w.WriteMsg(m)
+		return dns.RcodeNameError, nil
+	})
+	fed.Update(sourceCorefile, map[string]string{"prod": "federal.example."})
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := tc.Msg()
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		_, err := fed.ServeDNS(ctx, rec, m)
+		if err != nil {
+			t.Errorf("Test %d, expected no error, got %v", i, err)
+			return
+		}
+
+		resp := rec.Msg
+		if err := test.SortAndCheck(resp, tc); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestFederationUpdate(t *testing.T) {
+	fed := New()
+	fed.Update(sourceCorefile, map[string]string{"prod": "prod.example.org.", "staging": "staging.example.org."})
+	fed.Update(sourceKubernetes, map[string]string{"prod": "prod.example.net."})
+	fed.Update(sourceFile, map[string]string{"prod": "prod.example.com.", "dev": "dev.example.com."})
+
+	tests := []struct {
+		name string
+		zone string
+	}{
+		{"prod", "prod.example.net."},
+		{"staging", "staging.example.org."},
+		{"dev", "dev.example.com."},
+		{"test", ""},
+	}
+	for i, tc := range tests {
+		zone, _ := fed.federation(tc.name)
+		if zone != tc.zone {
+			t.Errorf("Test %d, expected zone %q for %s, got %q", i, tc.zone, tc.name, zone)
+		}
+	}
+
+	// Removing the file's federations leaves the others alone.
+	fed.Update(sourceFile, nil)
+	if _, ok := fed.federation("dev"); ok {
+		t.Errorf("Expected federation dev to be removed")
+	}
+	if zone, _ := fed.federation("staging"); zone != "staging.example.org." {
+		t.Errorf("Expected federation staging to be kept, got %q", zone)
+	}
+}
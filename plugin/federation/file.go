@@ -0,0 +1,102 @@
+package federation
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// fileSource is a file holding federations, one "NAME DOMAIN" pair per line.
+type fileSource struct {
+	path   string
+	reload time.Duration
+
+	// mtime and size are used to see if the file changed.
+	mtime time.Time
+	size  int64
+}
+
+// defaultReload is the default interval with which the file is checked for changes.
+const defaultReload = 5 * time.Second
+
+// readFile reads the file when it changed since the previous read and updates the federations.
+func (f *Federation) readFile() {
+	file, err := os.Open(f.file.path)
+	if err != nil {
+		// We already log a warning if the file doesn't exist or can't be opened on setup. No need to return the error here.
+		return
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return
+	}
+	if f.file.mtime.Equal(stat.ModTime()) && f.file.size == stat.Size() {
+		return
+	}
+
+	buf, err := ioutil.ReadAll(file)
+	if err != nil {
+		return
+	}
+	feds, err := parseFile(bytes.NewReader(buf))
+	if err != nil {
+		log.Warningf("Failed to parse %q: %s", f.file.path, err)
+		return
+	}
+
+	f.Update(sourceFile, feds)
+	f.file.mtime = stat.ModTime()
+	f.file.size = stat.Size()
+}
+
+// parseFile parses the federations in r. Empty lines and everything after a '#' are ignored.
+func parseFile(r io.Reader) (map[string]string, error) {
+	feds := make(map[string]string)
+
+	scanner := bufio.NewScanner(r)
+	i := 0
+	for scanner.Scan() {
+		i++
+		line := scanner.Text()
+		if j := strings.Index(line, "#"); j >= 0 {
+			line = line[:j]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("line %d: need two fields for federation, got %d", i, len(fields))
+		}
+		feds[fields[0]] = dns.Fqdn(fields[1])
+	}
+	return feds, scanner.Err()
+}
+
+// periodicFileUpdate rereads the file every reload interval until the returned channel is closed.
+func periodicFileUpdate(f *Federation) chan bool {
+	parseChan := make(chan bool)
+
+	go func() {
+		ticker := time.NewTicker(f.file.reload)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-parseChan:
+				return
+			case <-ticker.C:
+				f.readFile()
+			}
+		}
+	}()
+	return parseChan
+}
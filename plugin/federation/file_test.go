@@ -0,0 +1,79 @@
+package federation
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		expected  map[string]string
+	}{
+		{"prod prod.example.org\nstaging staging.example.org.\n", false,
+			map[string]string{"prod": "prod.example.org.", "staging": "staging.example.org."}},
+		{"# federations\n\nprod prod.example.org # production\n", false,
+			map[string]string{"prod": "prod.example.org."}},
+		{"prod\n", true, nil},
+		{"prod prod.example.org extra\n", true, nil},
+	}
+
+	for i, tc := range tests {
+		feds, err := parseFile(strings.NewReader(tc.input))
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if len(feds) != len(tc.expected) {
+			t.Errorf("Test %d: expected %d federations, got %d", i, len(tc.expected), len(feds))
+		}
+		for name, zone := range tc.expected {
+			if feds[name] != zone {
+				t.Errorf("Test %d: expected zone %q for %s, got %q", i, zone, name, feds[name])
+			}
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "federation")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "federations")
+	if err := ioutil.WriteFile(path, []byte("prod prod.example.org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fed := New()
+	fed.file = &fileSource{path: path, reload: defaultReload}
+	fed.readFile()
+
+	if zone, _ := fed.federation("prod"); zone != "prod.example.org." {
+		t.Errorf("Expected zone prod.example.org. for prod, got %q", zone)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("staging staging.example.org\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fed.readFile()
+
+	if _, ok := fed.federation("prod"); ok {
+		t.Errorf("Expected federation prod to be removed")
+	}
+	if zone, _ := fed.federation("staging"); zone != "staging.example.org." {
+		t.Errorf("Expected zone staging.example.org. for staging, got %q", zone)
+	}
+}
@@ -16,6 +16,8 @@ func (APIConnFederationTest) HasSynced() bool                           { return
 func (APIConnFederationTest) Run()                                      { return }
 func (APIConnFederationTest) Stop() error                               { return nil }
 func (APIConnFederationTest) SvcIndexReverse(string) []*object.Service  { return nil }
+func (APIConnFederationTest) IngressIndex(string) []*object.Ingress     { return nil }
+func (APIConnFederationTest) IngressList() []*object.Ingress            { return nil }
 func (APIConnFederationTest) EpIndexReverse(string) []*object.Endpoints { return nil }
 func (APIConnFederationTest) Modified() int64                           { return 0 }
 
@@ -2,16 +2,22 @@ package federation
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/kubernetes"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
 	"github.com/coredns/coredns/plugin/pkg/upstream"
 	"github.com/miekg/dns"
 
 	"github.com/caddyserver/caddy"
 )
 
+var log = clog.NewWithPlugin("federation")
+
 func init() {
 	caddy.RegisterPlugin("federation", caddy.Plugin{
 		ServerType: "dns",
@@ -25,18 +31,48 @@ func setup(c *caddy.Controller) error {
 		return plugin.Error("federation", err)
 	}
 
+	stop := make(chan struct{})
+
 	// Do this in OnStartup, so all plugin has been initialized.
 	c.OnStartup(func() error {
-		m := dnsserver.GetConfig(c).Handler("kubernetes")
-		if m == nil {
-			return nil
-		}
-		if x, ok := m.(*kubernetes.Kubernetes); ok {
-			fed.Federations = x.Federations
+		fed.Federations = federations(dnsserver.GetConfig(c).Handlers())
+
+		if fed.watchK {
+			m := dnsserver.GetConfig(c).Handler("kubernetes")
+			x, ok := m.(*kubernetes.Kubernetes)
+			if !ok {
+				return plugin.Error("federation", fmt.Errorf("watching federations needs the kubernetes plugin"))
+			}
+			update := func(feds map[string]string) { fed.Update(sourceKubernetes, feds) }
+			if err := x.WatchFederations(stop, update); err != nil {
+				return plugin.Error("federation", err)
+			}
 		}
 		return nil
 	})
 
+	if fed.file != nil {
+		var parseChan chan bool
+
+		// The file is only reread after the first read, so the reads never run concurrently.
+		c.OnStartup(func() error {
+			fed.readFile()
+			parseChan = periodicFileUpdate(fed)
+			return nil
+		})
+		c.OnShutdown(func() error {
+			if parseChan != nil {
+				close(parseChan)
+			}
+			return nil
+		})
+	}
+
+	c.OnShutdown(func() error {
+		close(stop)
+		return nil
+	})
+
 	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
 		fed.Next = next
 		return fed
@@ -45,9 +81,26 @@ func setup(c *caddy.Controller) error {
 	return nil
 }
 
+// federations returns the Func of the first handler implementing Federator. If there is none,
+// Backend is returned when there is a plugin.ServiceBackend, otherwise nil.
+func federations(handlers []plugin.Handler) Func {
+	var f Func
+	for _, h := range handlers {
+		if x, ok := h.(Federator); ok {
+			return x.Federations
+		}
+		if _, ok := h.(plugin.ServiceBackend); ok && f == nil {
+			f = Backend
+		}
+	}
+	return f
+}
+
 func federationParse(c *caddy.Controller) (*Federation, error) {
 	fed := New()
 	fed.Upstream = upstream.New()
+	config := dnsserver.GetConfig(c)
+	static := make(map[string]string)
 
 	for c.Next() {
 		// federation [zones..]
@@ -67,13 +120,45 @@ func federationParse(c *caddy.Controller) (*Federation, error) {
 			case "upstream":
 				// remove soon
 				c.RemainingArgs()
+			case "file":
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return fed, c.ArgErr()
+				}
+				path := args[0]
+				if !filepath.IsAbs(path) && config.Root != "" {
+					path = filepath.Join(config.Root, path)
+				}
+				if _, err := os.Stat(path); err != nil {
+					if os.IsNotExist(err) {
+						log.Warningf("File does not exist: %s", path)
+					} else {
+						return fed, c.Errf("unable to access federation file '%s': %v", path, err)
+					}
+				}
+				fed.file = &fileSource{path: path, reload: defaultReload}
+				if len(args) == 2 {
+					reload, err := time.ParseDuration(args[1])
+					if err != nil {
+						return fed, c.Errf("invalid duration for reload '%s'", args[1])
+					}
+					if reload <= 0 {
+						return fed, c.Errf("invalid non positive duration for reload '%s'", args[1])
+					}
+					fed.file.reload = reload
+				}
+			case "kubernetes":
+				if len(c.RemainingArgs()) != 0 {
+					return fed, c.ArgErr()
+				}
+				fed.watchK = true
 			default:
 				args := c.RemainingArgs()
 				if x := len(args); x != 1 {
 					return fed, fmt.Errorf("need two arguments for federation, got %d", x)
 				}
 
-				fed.f[x] = dns.Fqdn(args[0])
+				static[x] = dns.Fqdn(args[0])
 			}
 		}
 
@@ -83,9 +168,10 @@ func federationParse(c *caddy.Controller) (*Federation, error) {
 
 		fed.zones = origins
 
-		if len(fed.f) == 0 {
+		if len(static) == 0 && fed.file == nil && !fed.watchK {
 			return fed, fmt.Errorf("at least one name to zone federation expected")
 		}
+		fed.Update(sourceCorefile, static)
 
 		return fed, nil
 	}
@@ -30,9 +30,23 @@ func TestSetup(t *testing.T) {
 			staging staging.example.org
 			prod prod.example.org
 		}`, false, 2, []string{"staging", "staging.example.org."}},
+		{`federation {
+			prod prod.example.org
+			kubernetes
+		}`, false, 1, []string{"prod", "prod.example.org."}},
+		{`federation {
+			prod prod.example.org
+			file federations.txt 10s
+		}`, false, 1, []string{"prod", "prod.example.org."}},
 		// errors
 		{`federation {
 		}`, true, 0, []string{}},
+		{`federation {
+			file federations.txt -1s
+		}`, true, 0, []string{}},
+		{`federation {
+			kubernetes yes
+		}`, true, 0, []string{}},
 		{`federation {
 			staging
 		}`, true, 0, []string{}},
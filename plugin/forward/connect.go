@@ -73,6 +73,15 @@ func (t *Transport) Dial(proto string) (*dns.Conn, bool, error) {
 func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options) (*dns.Msg, error) {
 	start := time.Now()
 
+	if p.quic != nil {
+		ret, err := p.quic.exchange(ctx, state.Req)
+		if err != nil {
+			return nil, err
+		}
+		p.count(ret, start)
+		return ret, nil
+	}
+
 	proto := ""
 	switch {
 	case opts.forceTCP: // TCP flag has precedence over UDP flag
@@ -94,8 +103,11 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 		conn.UDPSize = 512
 	}
 
+	stop := cancelOnDone(ctx, conn)
+
 	conn.SetWriteDeadline(time.Now().Add(maxTimeout))
 	if err := conn.WriteMsg(state.Req); err != nil {
+		stop()
 		conn.Close() // not giving it back
 		if err == io.EOF && cached {
 			return nil, ErrCachedClosed
@@ -108,6 +120,7 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 	for {
 		ret, err = conn.ReadMsg()
 		if err != nil {
+			stop()
 			conn.Close() // not giving it back
 			if err == io.EOF && cached {
 				return nil, ErrCachedClosed
@@ -120,8 +133,15 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 		}
 	}
 
+	stop()
 	p.transport.Yield(conn)
 
+	p.count(ret, start)
+	return ret, nil
+}
+
+// count updates the metrics for the reply ret to the query sent at start.
+func (p *Proxy) count(ret *dns.Msg, start time.Time) {
 	rc, ok := dns.RcodeToString[ret.Rcode]
 	if !ok {
 		rc = strconv.Itoa(ret.Rcode)
@@ -130,8 +150,34 @@ func (p *Proxy) Connect(ctx context.Context, state request.Request, opts options
 	RequestCount.WithLabelValues(p.addr).Add(1)
 	RcodeCount.WithLabelValues(rc, p.addr).Add(1)
 	RequestDuration.WithLabelValues(p.addr).Observe(time.Since(start).Seconds())
+}
 
-	return ret, nil
+// cancelOnDone makes reading from and writing to conn fail when ctx is done, e.g. when another upstream
+// won the race. The returned function stops this; it must be called before conn is reused.
+func cancelOnDone(ctx context.Context, conn deadliner) func() {
+	done := ctx.Done()
+	if done == nil {
+		return func() {}
+	}
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-done:
+			conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// deadliner is a net.Conn, or a QUIC stream.
+type deadliner interface {
+	SetDeadline(time.Time) error
 }
 
 const cumulativeAvgWeight = 4
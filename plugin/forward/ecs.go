@@ -0,0 +1,48 @@
+package forward
+
+import (
+	"net"
+
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// EDNS0 Client Subnet modes.
+const (
+	ecsAdd     = "add"     // add the option when the request doesn't have one
+	ecsRewrite = "rewrite" // always set the option to the client's subnet
+)
+
+// subnet returns the request to forward for state. The EDNS0 Client Subnet option is set to the client's
+// address, masked to the configured source prefix length, in a copy of the request; the client's request
+// and its advertised buffer size are left alone.
+func (f *Forward) subnet(state request.Request) request.Request {
+	if f.ecs == ecsAdd && edns.Subnet(state.Req) != nil {
+		return state
+	}
+
+	ip := net.ParseIP(state.IP())
+	if ip == nil {
+		return state
+	}
+	r := state.Req.Copy()
+	if !edns.SetSubnet(r, ip, f.ecsV4, f.ecsV6) {
+		return state
+	}
+	return request.Request{W: state.W, Req: r, Zone: state.Zone}
+}
+
+// restoreSubnet puts the EDNS0 Client Subnet option of the client's request req back in the reply ret,
+// keeping the scope prefix length of the upstream. A client must drop a reply whose option doesn't
+// match its own (RFC 7871, section 7.3), and caches key on it.
+func restoreSubnet(req, ret *dns.Msg) {
+	e, r := edns.Subnet(req), edns.Subnet(ret)
+	if e == nil || r == nil {
+		return
+	}
+	r.Family = e.Family
+	r.SourceNetmask = e.SourceNetmask
+	r.Address = e.Address
+}
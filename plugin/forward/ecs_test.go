@@ -0,0 +1,116 @@
+package forward
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func TestForwardSubnet(t *testing.T) {
+	tests := []struct {
+		config  string
+		subnet  string // subnet in the client's request, if any
+		address string // expected address in the forwarded request
+		netmask uint8
+	}{
+		{"ecs add", "", "10.240.0.0", 24},
+		{"ecs add 16", "", "10.240.0.0", 16},
+		{"ecs add", "192.168.1.0", "192.168.1.0", 24},
+		{"ecs rewrite 32", "192.168.1.0", "10.240.0.1", 32},
+	}
+
+	for i, tc := range tests {
+		var got *dns.EDNS0_SUBNET
+		s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+			got = edns.Subnet(r)
+			ret := new(dns.Msg)
+			ret.SetReply(r)
+			if got != nil {
+				ret.SetEdns0(4096, false)
+				ret.IsEdns0().Option = []dns.EDNS0{&dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: got.Family,
+					SourceNetmask: got.SourceNetmask, SourceScope: 16, Address: got.Address}}
+			}
+			w.WriteMsg(ret)
+		})
+
+		c := caddy.NewTestController("dns", "forward . "+s.Addr+" {\n"+tc.config+"\n}\n")
+		f, err := parseForward(c)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create forwarder: %s", i, err)
+		}
+		f.OnStartup()
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if tc.subnet != "" {
+			edns.SetSubnet(m, net.ParseIP(tc.subnet), 24, 56)
+		}
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+		}
+		f.OnShutdown()
+		s.Close()
+
+		if got == nil {
+			t.Errorf("Test %d: expected subnet option in the forwarded request", i)
+			continue
+		}
+		if !got.Address.Equal(net.ParseIP(tc.address)) || got.SourceNetmask != tc.netmask {
+			t.Errorf("Test %d: expected %s/%d, got %s/%d", i, tc.address, tc.netmask, got.Address, got.SourceNetmask)
+		}
+		if tc.subnet == "" && m.IsEdns0() != nil {
+			t.Errorf("Test %d: expected the client's request to be left alone", i)
+		}
+		// The client gets its own subnet back, with the scope of the upstream.
+		if tc.subnet != "" {
+			e := edns.Subnet(rec.Msg)
+			if e == nil || !e.Address.Equal(net.ParseIP(tc.subnet)) || e.SourceNetmask != 24 || e.SourceScope != 16 {
+				t.Errorf("Test %d: expected %s/24/16 in the reply, got %v", i, tc.subnet, e)
+			}
+		}
+	}
+}
+
+func TestSetupSubnet(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		ecs       string
+		v4, v6    uint8
+	}{
+		{"forward . 127.0.0.1\n", false, "", 0, 0},
+		{"forward . 127.0.0.1 {\necs add\n}\n", false, ecsAdd, 24, 56},
+		{"forward . 127.0.0.1 {\necs rewrite 20 48\n}\n", false, ecsRewrite, 20, 48},
+		// negative
+		{"forward . 127.0.0.1 {\necs\n}\n", true, "", 0, 0},
+		{"forward . 127.0.0.1 {\necs replace\n}\n", true, "", 0, 0},
+		{"forward . 127.0.0.1 {\necs add 33\n}\n", true, "", 0, 0},
+		{"forward . 127.0.0.1 {\necs add 24 129\n}\n", true, "", 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if f.ecs != test.ecs || f.ecsV4 != test.v4 || f.ecsV6 != test.v6 {
+			t.Errorf("Test %d: expected ecs %q %d %d, got %q %d %d", i, test.ecs, test.v4, test.v6, f.ecs, f.ecsV4, f.ecsV6)
+		}
+	}
+}
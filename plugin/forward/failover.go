@@ -0,0 +1,24 @@
+package forward
+
+import (
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/rcode"
+
+	"github.com/miekg/dns"
+)
+
+// failover returns true if ret has one of the rcodes configured with failover, in which case the next
+// upstream is tried. The reply counts as a failure of proxy, like a failed health check. No health check
+// is started right away, as it would likely succeed and clear the fails; only when proxy goes down, the
+// health check that brings it back up is started after one health check interval.
+func (f *Forward) failover(proxy *Proxy, ret *dns.Msg) bool {
+	if ret == nil || !f.failRcodes[ret.Rcode] {
+		return false
+	}
+	FailoverCount.WithLabelValues(rcode.ToString(ret.Rcode), proxy.addr).Add(1)
+	if f.maxfails != 0 && proxy.fail("reply with rcode "+rcode.ToString(ret.Rcode)) {
+		time.AfterFunc(f.hcInterval, proxy.Healthcheck)
+	}
+	return true
+}
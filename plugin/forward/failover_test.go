@@ -0,0 +1,170 @@
+package forward
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newRcodeServers returns a server per rcode, that replies with that rcode. The servers share a handler,
+// as they all use the default ServeMux, which tells them apart by port.
+func newRcodeServers(rcodes ...int) []*dnstest.Server {
+	ports := map[string]int{}
+	var mu sync.Mutex
+	handler := func(w dns.ResponseWriter, r *dns.Msg) {
+		_, port, _ := net.SplitHostPort(w.LocalAddr().String())
+		mu.Lock()
+		i := ports[port]
+		mu.Unlock()
+		ret := new(dns.Msg)
+		ret.SetRcode(r, rcodes[i])
+		w.WriteMsg(ret)
+	}
+
+	servers := make([]*dnstest.Server, len(rcodes))
+	for i := range rcodes {
+		servers[i] = dnstest.NewServer(handler)
+		_, port, _ := net.SplitHostPort(servers[i].Addr)
+		mu.Lock()
+		ports[port] = i
+		mu.Unlock()
+	}
+	return servers
+}
+
+func TestFailover(t *testing.T) {
+	tests := []struct {
+		rcodes   []int
+		option   string
+		expected int
+	}{
+		{[]int{dns.RcodeServerFailure, dns.RcodeSuccess}, "", dns.RcodeSuccess},
+		{[]int{dns.RcodeRefused, dns.RcodeServerFailure, dns.RcodeSuccess}, "", dns.RcodeSuccess},
+		{[]int{dns.RcodeServerFailure, dns.RcodeRefused}, "", dns.RcodeRefused},
+		{[]int{dns.RcodeServerFailure, dns.RcodeNameError}, "", dns.RcodeNameError},
+		{[]int{dns.RcodeServerFailure, dns.RcodeSuccess}, "hedge 1s", dns.RcodeSuccess},
+		{[]int{dns.RcodeServerFailure, dns.RcodeSuccess}, "parallel 2", dns.RcodeSuccess},
+		{[]int{dns.RcodeServerFailure, dns.RcodeRefused}, "hedge 1s", dns.RcodeRefused},
+	}
+
+	for i, tc := range tests {
+		servers := newRcodeServers(tc.rcodes...)
+		to := ""
+		for _, s := range servers {
+			to += " " + s.Addr
+		}
+		c := caddy.NewTestController("dns", "forward ."+to+" {\npolicy sequential\nfailover SERVFAIL refused\n"+tc.option+"\n}\n")
+		f, err := parseForward(c)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create forwarder: %s", i, err)
+		}
+		f.OnStartup()
+
+		first := f.proxies[0].addr
+		before := testutil.ToFloat64(FailoverCount.WithLabelValues("SERVFAIL", first))
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Errorf("Test %d: expected a reply, but got %s", i, err)
+		} else if rec.Msg.Rcode != tc.expected {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.expected], dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if x := testutil.ToFloat64(FailoverCount.WithLabelValues("SERVFAIL", first)) - before; tc.rcodes[0] == dns.RcodeServerFailure && x != 1 {
+			t.Errorf("Test %d: expected 1 failover for %s, got %f", i, first, x)
+		}
+
+		f.OnShutdown()
+		for _, s := range servers {
+			s.Close()
+		}
+	}
+}
+
+func TestFailoverFails(t *testing.T) {
+	// Health checks succeed, but queries fail.
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		if r.Question[0].Qtype == dns.TypeNS {
+			ret.SetReply(r)
+		} else {
+			ret.SetRcode(r, dns.RcodeServerFailure)
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	c := caddy.NewTestController("dns", "forward . "+s.Addr+" {\nfailover SERVFAIL\nhealth_check 5s\n}\n")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	p := f.proxies[0]
+	before := testutil.ToFloat64(HealthTransitionCount.WithLabelValues(p.addr, "down"))
+	for i := 0; i <= int(f.maxfails); i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		f.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if !p.Down(f.maxfails) {
+		t.Errorf("Expected %s to be down after %d failed queries", p.addr, f.maxfails+1)
+	}
+	if x := testutil.ToFloat64(HealthTransitionCount.WithLabelValues(p.addr, "down")) - before; x != 1 {
+		t.Errorf("Expected 1 down transition for %s, got %f", p.addr, x)
+	}
+}
+
+func TestSetupFailover(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		rcodes    map[int]bool
+	}{
+		{"forward . 127.0.0.1\n", false, nil},
+		{"forward . 127.0.0.1 {\nfailover SERVFAIL\n}\n", false, map[int]bool{dns.RcodeServerFailure: true}},
+		{"forward . 127.0.0.1 {\nfailover servfail REFUSED\n}\n", false, map[int]bool{dns.RcodeServerFailure: true, dns.RcodeRefused: true}},
+		// negative
+		{"forward . 127.0.0.1 {\nfailover\n}\n", true, nil},
+		{"forward . 127.0.0.1 {\nfailover BLAH\n}\n", true, nil},
+		{"forward . 127.0.0.1 {\nfailover NOERROR\n}\n", true, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if len(f.failRcodes) != len(test.rcodes) {
+			t.Errorf("Test %d: expected rcodes %v, got %v", i, test.rcodes, f.failRcodes)
+			continue
+		}
+		for rc := range test.rcodes {
+			if !f.failRcodes[rc] {
+				t.Errorf("Test %d: expected rcodes %v, got %v", i, test.rcodes, f.failRcodes)
+			}
+		}
+	}
+}
@@ -8,15 +8,20 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/debug"
 	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/request"
 
+	"github.com/caddyserver/caddy/caddyfile"
 	"github.com/miekg/dns"
 	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
 )
 
 var log = clog.NewWithPlugin("forward")
@@ -24,7 +29,8 @@ var log = clog.NewWithPlugin("forward")
 // Forward represents a plugin instance that can proxy requests to another (DNS) server. It has a list
 // of proxies each representing one upstream proxy.
 type Forward struct {
-	proxies    []*Proxy
+	proxies    []*Proxy // replaced, never modified, when the upstreams change; guarded by proxyMu
+	proxyMu    sync.RWMutex
 	p          Policy
 	hcInterval time.Duration
 
@@ -36,6 +42,46 @@ type Forward struct {
 	maxfails      uint32
 	expire        time.Duration
 
+	// EDNS0 Client Subnet mode and source prefix lengths, see ecs.go.
+	ecs          string
+	ecsV4, ecsV6 uint8
+
+	// Health check query, and passive health checking settings, see passive.go.
+	hcOpts        hcOptions
+	passiveWindow time.Duration
+	passiveRatio  float64
+	passiveMin    int
+
+	// Rcodes that make us try the next upstream, see failover.go.
+	failRcodes map[int]bool
+
+	// Limit on the number of queries forwarded at the same time, and the rcode to answer with when
+	// it is reached. A limit of 0 means no limit.
+	maxConcurrent int64
+	concurrent    int64 // atomic
+	overloadRcode int
+
+	// Racing the upstreams, see race.go.
+	parallel        int
+	hedgeDelay      time.Duration
+	hedgePercentile float64
+	latency         *latency
+
+	// Where the upstreams come from when they can change while running, see upstreams.go.
+	to     []*source
+	reload time.Duration // how often the files in TO are checked for changes, 0 disables it
+	srv    *srvName      // nil if the upstreams aren't discovered with SRV records
+	stop   chan struct{}
+	wg     sync.WaitGroup
+
+	// The routing table, with an upstream group per domain, see route.go.
+	routes      []*Forward // guarded by routeMu
+	routeMu     sync.RWMutex
+	routeSpecs  [][]caddyfile.Token // the tokens of the route stanzas in the block
+	routeFile   *source             // nil if there is no routes file
+	routeReload time.Duration
+	group       bool // true for an upstream group in a routing table
+
 	opts options // also here for testing
 
 	Next plugin.Handler
@@ -43,18 +89,29 @@ type Forward struct {
 
 // New returns a new Forward.
 func New() *Forward {
-	f := &Forward{maxfails: 2, tlsConfig: new(tls.Config), expire: defaultExpire, p: new(random), from: ".", hcInterval: hcInterval}
+	f := &Forward{maxfails: 2, tlsConfig: new(tls.Config), expire: defaultExpire, p: new(random), from: ".", hcInterval: hcInterval,
+		hcOpts: hcOptions{domain: ".", qtype: dns.TypeNS}, overloadRcode: dns.RcodeRefused}
 	return f
 }
 
 // SetProxy appends p to the proxy list and starts healthchecking.
 func (f *Forward) SetProxy(p *Proxy) {
-	f.proxies = append(f.proxies, p)
+	p.maxfails = f.maxfails
+	f.proxyMu.Lock()
+	f.proxies = append(f.proxies[:len(f.proxies):len(f.proxies)], p)
+	f.proxyMu.Unlock()
 	p.start(f.hcInterval)
 }
 
 // Len returns the number of configured proxies.
-func (f *Forward) Len() int { return len(f.proxies) }
+func (f *Forward) Len() int { return len(f.upstreams()) }
+
+// upstreams returns the current proxies.
+func (f *Forward) upstreams() []*Proxy {
+	f.proxyMu.RLock()
+	defer f.proxyMu.RUnlock()
+	return f.proxies
+}
 
 // Name implements plugin.Handler.
 func (f *Forward) Name() string { return "forward" }
@@ -66,13 +123,45 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	if !f.match(state) {
 		return plugin.NextOrFailure(f.Name(), f.Next, ctx, w, r)
 	}
+	if f.maxConcurrent > 0 {
+		count := atomic.AddInt64(&f.concurrent, 1)
+		defer atomic.AddInt64(&f.concurrent, -1)
+		if count > f.maxConcurrent {
+			MaxConcurrentRejectCount.Add(1)
+			return f.overloadRcode, ErrLimitExceeded
+		}
+	}
+	if g := f.route(state); g != nil {
+		return g.ServeDNS(ctx, w, r)
+	}
+	if f.routeOnly() {
+		// No route for this name, and no upstreams of our own.
+		return plugin.NextOrFailure(f.Name(), f.Next, ctx, w, r)
+	}
+	client := state
+	if f.ecs != "" {
+		state = f.subnet(state)
+	}
+
+	list := f.List()
+	if len(list) == 0 {
+		return dns.RcodeServerFailure, ErrNoForward
+	}
 
-	fails := 0
+	if f.racing() {
+		res := f.race(ctx, state, f.candidates(list))
+		if res.err != nil {
+			return dns.RcodeServerFailure, res.err
+		}
+		return f.reply(w, client, res.ret, res.taperr)
+	}
+
+	fails, tries, failovers := 0, 0, 0
 	var span, child ot.Span
-	var upstreamErr error
+	var upstreamErr, failedTaperr error
+	var failed *dns.Msg // last reply with a failover rcode
 	span = ot.SpanFromContext(ctx)
 	i := 0
-	list := f.List()
 	deadline := time.Now().Add(defaultTimeout)
 	start := time.Now()
 	for time.Now().Before(deadline) {
@@ -86,44 +175,31 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 		i++
 		if proxy.Down(f.maxfails) {
 			fails++
-			if fails < len(f.proxies) {
+			if fails < len(list) {
 				continue
 			}
 			// All upstream proxies are dead, assume healtcheck is completely broken and randomly
 			// select an upstream to connect to.
 			r := new(random)
-			proxy = r.List(f.proxies)[0]
+			proxy = r.List(list)[0]
 
 			HealthcheckBrokenCount.Add(1)
 		}
 
 		if span != nil {
 			child = span.Tracer().StartSpan("connect", ot.ChildOf(span.Context()))
+			child.SetTag(trace.TagUpstream, proxy.addr)
+			span.SetTag(trace.TagRetries, tries)
 			ctx = ot.ContextWithSpan(ctx, child)
 		}
+		tries++
 
-		var (
-			ret *dns.Msg
-			err error
-		)
-		opts := f.opts
-		for {
-			ret, err = proxy.Connect(ctx, state, opts)
-			if err == nil {
-				break
-			}
-			if err == ErrCachedClosed { // Remote side closed conn, can only happen with TCP.
-				continue
-			}
-			// Retry with TCP if truncated and prefer_udp configured.
-			if ret != nil && ret.Truncated && !opts.forceTCP && f.opts.preferUDP {
-				opts.forceTCP = true
-				continue
-			}
-			break
-		}
+		ret, err := f.connect(ctx, proxy, state)
 
 		if child != nil {
+			if err != nil {
+				ext.Error.Set(child, true)
+			}
 			child.Finish()
 		}
 		taperr := toDnstap(ctx, proxy.addr, f, state, ret, start)
@@ -136,26 +212,27 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 				proxy.Healthcheck()
 			}
 
-			if fails < len(f.proxies) {
+			if fails < len(list) {
 				continue
 			}
 			break
 		}
 
-		// Check if the reply is correct; if not return FormErr.
-		if !state.Match(ret) {
-			debug.Hexdumpf(ret, "Wrong reply for id: %d, %s %d", ret.Id, state.QName(), state.QType())
-
-			formerr := new(dns.Msg)
-			formerr.SetRcode(state.Req, dns.RcodeFormatError)
-			w.WriteMsg(formerr)
-			return 0, taperr
+		if f.failover(proxy, ret) {
+			failed, failedTaperr = ret, taperr
+			// Give every upstream one try; when they all fail this way, the last reply is returned.
+			if failovers++; failovers < len(list) {
+				continue
+			}
+			break
 		}
 
-		w.WriteMsg(ret)
-		return 0, taperr
+		return f.reply(w, client, ret, taperr)
 	}
 
+	if failed != nil {
+		return f.reply(w, client, failed, failedTaperr)
+	}
 	if upstreamErr != nil {
 		return dns.RcodeServerFailure, upstreamErr
 	}
@@ -163,6 +240,59 @@ func (f *Forward) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg
 	return dns.RcodeServerFailure, ErrNoHealthy
 }
 
+// connect sends the query in state to proxy, and records the outcome for passive health checking
+// unless the query was canceled.
+func (f *Forward) connect(ctx context.Context, proxy *Proxy, state request.Request) (*dns.Msg, error) {
+	InflightGauge.WithLabelValues(proxy.addr).Inc()
+	ret, err := f.exchange(ctx, proxy, state)
+	InflightGauge.WithLabelValues(proxy.addr).Dec()
+	if ctx.Err() == nil {
+		proxy.observe(err)
+	}
+	return ret, err
+}
+
+// exchange sends the query in state to proxy. It retries when a cached connection was closed by the
+// upstream and, with prefer_udp, over TCP when the reply is truncated.
+func (f *Forward) exchange(ctx context.Context, proxy *Proxy, state request.Request) (*dns.Msg, error) {
+	opts := f.opts
+	for {
+		ret, err := proxy.Connect(ctx, state, opts)
+		if err == nil {
+			return ret, nil
+		}
+		if err == ErrCachedClosed { // Remote side closed conn, can only happen with TCP.
+			continue
+		}
+		// Retry with TCP if truncated and prefer_udp configured.
+		if ret != nil && ret.Truncated && !opts.forceTCP && f.opts.preferUDP {
+			opts.forceTCP = true
+			continue
+		}
+		return ret, err
+	}
+}
+
+// reply writes ret to the client, or FORMERR when ret is not a reply to the query in state. State is
+// the client's request, not the forwarded one.
+func (f *Forward) reply(w dns.ResponseWriter, state request.Request, ret *dns.Msg, taperr error) (int, error) {
+	// Check if the reply is correct; if not return FormErr.
+	if !state.Match(ret) {
+		debug.Hexdumpf(ret, "Wrong reply for id: %d, %s %d", ret.Id, state.QName(), state.QType())
+
+		formerr := new(dns.Msg)
+		formerr.SetRcode(state.Req, dns.RcodeFormatError)
+		w.WriteMsg(formerr)
+		return 0, taperr
+	}
+	if f.ecs == ecsRewrite {
+		restoreSubnet(state.Req, ret)
+	}
+
+	w.WriteMsg(ret)
+	return 0, taperr
+}
+
 func (f *Forward) match(state request.Request) bool {
 	if !plugin.Name(f.from).Matches(state.Name()) || !f.isAllowedDomain(state.Name()) {
 		return false
@@ -191,7 +321,13 @@ func (f *Forward) ForceTCP() bool { return f.opts.forceTCP }
 func (f *Forward) PreferUDP() bool { return f.opts.preferUDP }
 
 // List returns a set of proxies to be used for this client depending on the policy in f.
-func (f *Forward) List() []*Proxy { return f.p.List(f.proxies) }
+func (f *Forward) List() []*Proxy {
+	list := f.upstreams()
+	if len(list) == 0 {
+		return nil
+	}
+	return f.p.List(list)
+}
 
 var (
 	// ErrNoHealthy means no healthy proxies left.
@@ -200,6 +336,8 @@ var (
 	ErrNoForward = errors.New("no forwarder defined")
 	// ErrCachedClosed means cached connection was closed by peer.
 	ErrCachedClosed = errors.New("cached connection was closed by peer")
+	// ErrLimitExceeded means too many queries are being forwarded at the same time.
+	ErrLimitExceeded = errors.New("max_concurrent exceeded")
 )
 
 // policy tells forward what policy for selecting upstream it uses.
@@ -1,10 +1,13 @@
 package forward
 
 import (
+	"context"
 	"crypto/tls"
+	"fmt"
 	"sync/atomic"
 	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/rcode"
 	"github.com/coredns/coredns/plugin/pkg/transport"
 
 	"github.com/miekg/dns"
@@ -16,19 +19,31 @@ type HealthChecker interface {
 	SetTLSConfig(*tls.Config)
 }
 
-// dnsHc is a health checker for a DNS endpoint (DNS, and DoT).
-type dnsHc struct{ c *dns.Client }
+// hcOptions holds the settings of the health check query, see health_check in setup.go.
+type hcOptions struct {
+	domain string // name queried, the default is "."
+	qtype  uint16 // type queried, the default is NS
+	noRec  bool   // clear the RD bit
+	rcodes []int  // rcodes that make the upstream healthy, any rcode if empty
+	tcp    bool   // use TCP instead of UDP, DoT upstreams always use TLS
+}
+
+// dnsHc is a health checker for a DNS endpoint (DNS, DoT and DoQ).
+type dnsHc struct {
+	c    *dns.Client
+	opts hcOptions
+}
 
 // NewHealthChecker returns a new HealthChecker based on transport.
 func NewHealthChecker(trans string) HealthChecker {
 	switch trans {
-	case transport.DNS, transport.TLS:
+	case transport.DNS, transport.TLS, transport.QUIC:
 		c := new(dns.Client)
 		c.Net = "udp"
 		c.ReadTimeout = 1 * time.Second
 		c.WriteTimeout = 1 * time.Second
 
-		return &dnsHc{c: c}
+		return &dnsHc{c: c, opts: hcOptions{domain: ".", qtype: dns.TypeNS}}
 	}
 
 	log.Warningf("No healthchecker for transport %q", trans)
@@ -40,27 +55,50 @@ func (h *dnsHc) SetTLSConfig(cfg *tls.Config) {
 	h.c.TLSConfig = cfg
 }
 
-// For HC we send to . IN NS +norec message to the upstream. Dial timeouts and empty
-// replies are considered fails, basically anything else constitutes a healthy upstream.
+// setOptions sets the health check query. It must be called before SetTLSConfig.
+func (h *dnsHc) setOptions(opts hcOptions) {
+	h.opts = opts
+	if opts.tcp {
+		h.c.Net = "tcp"
+	}
+}
+
+// For HC we send to . IN NS message (or the query set with health_check) to the upstream. Dial
+// timeouts and empty replies are considered fails, basically anything else constitutes a healthy
+// upstream, unless the expected rcodes are set.
 
 // Check is used as the up.Func in the up.Probe.
 func (h *dnsHc) Check(p *Proxy) error {
-	err := h.send(p.addr)
+	err := h.send(p)
 	if err != nil {
 		HealthcheckFailureCount.WithLabelValues(p.addr).Add(1)
-		atomic.AddUint32(&p.fails, 1)
+		p.fail("health check failed: " + err.Error())
 		return err
 	}
 
-	atomic.StoreUint32(&p.fails, 0)
+	if fails := atomic.SwapUint32(&p.fails, 0); p.maxfails != 0 && fails > p.maxfails {
+		p.transition(false, "health check succeeded")
+	}
 	return nil
 }
 
-func (h *dnsHc) send(addr string) error {
+func (h *dnsHc) send(p *Proxy) error {
 	ping := new(dns.Msg)
-	ping.SetQuestion(".", dns.TypeNS)
+	ping.SetQuestion(h.opts.domain, h.opts.qtype)
+	ping.RecursionDesired = !h.opts.noRec
 
-	m, _, err := h.c.Exchange(ping, addr)
+	var (
+		m   *dns.Msg
+		err error
+	)
+	if p.quic != nil {
+		// DoQ upstreams are checked over the connection the queries use.
+		ctx, cancel := context.WithTimeout(context.Background(), h.c.ReadTimeout)
+		m, err = p.quic.exchange(ctx, ping)
+		cancel()
+	} else {
+		m, _, err = h.c.Exchange(ping, p.addr)
+	}
 	// If we got a header, we're alright, basically only care about I/O errors 'n stuff.
 	if err != nil && m != nil {
 		// Silly check, something sane came back.
@@ -68,6 +106,14 @@ func (h *dnsHc) send(addr string) error {
 			err = nil
 		}
 	}
+	if err != nil || len(h.opts.rcodes) == 0 {
+		return err
+	}
 
-	return err
+	for _, rc := range h.opts.rcodes {
+		if m.Rcode == rc {
+			return nil
+		}
+	}
+	return fmt.Errorf("unexpected rcode %s", rcode.ToString(m.Rcode))
 }
@@ -2,6 +2,7 @@ package forward
 
 import (
 	"context"
+	"errors"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -182,3 +183,101 @@ func TestHealthNoMaxFails(t *testing.T) {
 		t.Errorf("Expected number of health checks to be %d, got %d", expected, i1)
 	}
 }
+
+func TestHealthCheckQuery(t *testing.T) {
+	type query struct {
+		name  string
+		qtype uint16
+		rd    bool
+		proto string
+	}
+	queries := make(chan query, 1)
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		queries <- query{r.Question[0].Name, r.Question[0].Qtype, r.RecursionDesired, w.RemoteAddr().Network()}
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		if r.Question[0].Name == "." {
+			ret.Rcode = dns.RcodeRefused
+		}
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	tests := []struct {
+		opts      hcOptions
+		shouldErr bool
+		expected  query
+	}{
+		{hcOptions{domain: ".", qtype: dns.TypeNS}, false, query{".", dns.TypeNS, true, "udp"}},
+		{hcOptions{domain: ".", qtype: dns.TypeNS, rcodes: []int{dns.RcodeSuccess}}, true, query{".", dns.TypeNS, true, "udp"}},
+		{hcOptions{domain: "example.org.", qtype: dns.TypeA, noRec: true, rcodes: []int{dns.RcodeSuccess}, tcp: true}, false, query{"example.org.", dns.TypeA, false, "tcp"}},
+	}
+	for i, tc := range tests {
+		p := NewProxy(s.Addr, transport.DNS)
+		p.health.(*dnsHc).setOptions(tc.opts)
+		err := p.health.Check(p)
+		if tc.shouldErr != (err != nil) {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.shouldErr, err)
+		}
+		if x := <-queries; x != tc.expected {
+			t.Errorf("Test %d: expected health check query %v, got %v", i, tc.expected, x)
+		}
+	}
+}
+
+func TestPassiveHealth(t *testing.T) {
+	h := newPassiveHealth(10*time.Second, 0.5, 4)
+	now := time.Now()
+
+	// Too few queries to tell.
+	for i := 0; i < 3; i++ {
+		if down, _, _ := h.record(now, true); down {
+			t.Fatalf("Expected upstream to be up after %d queries", i+1)
+		}
+	}
+	// Failures that fell out of the window are forgotten.
+	now = now.Add(11 * time.Second)
+	h.record(now, false)
+	if down, _, _ := h.record(now, true); down {
+		t.Fatalf("Expected upstream to be up, with old failures forgotten")
+	}
+	h.record(now, false)
+	down, fails, total := h.record(now.Add(time.Second), true)
+	if !down || fails != 2 || total != 4 {
+		t.Fatalf("Expected upstream to be down with 2 of 4 queries failed, got %t, %d of %d", down, fails, total)
+	}
+
+	if down, _ := h.down(now.Add(5 * time.Second)); !down {
+		t.Errorf("Expected upstream to be down within the window")
+	}
+	if down, up := h.down(now.Add(12 * time.Second)); down || !up {
+		t.Errorf("Expected upstream to come back up after the window")
+	}
+	if down, up := h.down(now.Add(13 * time.Second)); down || up {
+		t.Errorf("Expected upstream to stay up")
+	}
+}
+
+func TestPassiveHealthDown(t *testing.T) {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	p := NewProxy(s.Addr, transport.DNS)
+	f := New()
+	f.SetProxy(p)
+	defer f.Close()
+	p.passive = newPassiveHealth(time.Minute, 0.5, 2)
+
+	p.observe(nil)
+	if p.Down(f.maxfails) {
+		t.Fatalf("Expected proxy to be up")
+	}
+	p.observe(errors.New("timeout"))
+	if !p.Down(f.maxfails) {
+		t.Errorf("Expected proxy to be down after half of its queries failed")
+	}
+}
@@ -2,7 +2,9 @@ package forward
 
 import (
 	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/rcode"
 
+	"github.com/miekg/dns"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
@@ -39,6 +41,48 @@ var (
 		Name:      "healthcheck_broken_count_total",
 		Help:      "Counter of the number of complete failures of the healtchecks.",
 	})
+	HealthTransitionCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "health_transition_count_total",
+		Help:      "Counter of upstreams going down or coming back up.",
+	}, []string{"to", "state"})
+	UpstreamDownGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "upstream_down",
+		Help:      "Gauge of upstreams that are down, 1 if the upstream is down.",
+	}, []string{"to"})
+	FailoverCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "failover_count_total",
+		Help:      "Counter of replies with a failover rcode per upstream.",
+	}, []string{"rcode", "to"})
+	RaceWinCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "race_wins_total",
+		Help:      "Counter of raced queries answered first by an upstream.",
+	}, []string{"to"})
+	RaceLossCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "race_losses_total",
+		Help:      "Counter of raced queries answered first by another upstream.",
+	}, []string{"to"})
+	InflightGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "inflight_requests",
+		Help:      "Gauge of requests waiting for an answer per upstream.",
+	}, []string{"to"})
+	MaxConcurrentRejectCount = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "forward",
+		Name:      "max_concurrent_reject_count_total",
+		Help:      "Counter of the number of queries rejected because the max_concurrent limit was reached.",
+	})
 	SocketGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: plugin.Namespace,
 		Subsystem: "forward",
@@ -46,3 +90,21 @@ var (
 		Help:      "Gauge of open sockets per upstream.",
 	}, []string{"to"})
 )
+
+// deleteMetrics removes the series of the upstream addr, it is called when the upstream is removed.
+// Series of rcodes without a name are kept.
+func deleteMetrics(addr string) {
+	type vec interface {
+		DeleteLabelValues(...string) bool
+	}
+	for _, v := range []vec{RequestCount, RequestDuration, HealthcheckFailureCount, UpstreamDownGauge, RaceWinCount,
+		RaceLossCount, InflightGauge, SocketGauge} {
+		v.DeleteLabelValues(addr)
+	}
+	HealthTransitionCount.DeleteLabelValues(addr, "down")
+	HealthTransitionCount.DeleteLabelValues(addr, "up")
+	for rc := range dns.RcodeToString {
+		RcodeCount.DeleteLabelValues(dns.RcodeToString[rc], addr)
+		FailoverCount.DeleteLabelValues(rcode.ToString(rc), addr)
+	}
+}
@@ -0,0 +1,85 @@
+package forward
+
+import (
+	"sync"
+	"time"
+)
+
+// passiveHealth tracks the outcome of the queries sent to an upstream in a sliding window. When the
+// ratio of failed queries gets too high, the upstream is considered down for the length of the window;
+// after that it gets queries again, with a clean slate.
+type passiveHealth struct {
+	window time.Duration
+	ratio  float64
+	min    int // minimum number of queries in the window before the ratio is considered
+
+	mu      sync.Mutex
+	buckets [passiveBuckets]bucket
+	until   time.Time // down until this time
+}
+
+// bucket counts the queries of one slice of the window.
+type bucket struct {
+	slice  int64 // the slice of time this bucket counts, see slice
+	total  int
+	failed int
+}
+
+func newPassiveHealth(window time.Duration, ratio float64, min int) *passiveHealth {
+	return &passiveHealth{window: window, ratio: ratio, min: min}
+}
+
+// slice returns the number of the slice of the window t falls in.
+func (h *passiveHealth) slice(t time.Time) int64 {
+	return t.UnixNano() / int64(h.window/passiveBuckets)
+}
+
+// record records the outcome of a query. It returns true, together with the number of failed and total
+// queries in the window, when that marks the upstream down.
+func (h *passiveHealth) record(now time.Time, failed bool) (down bool, fails, total int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	cur := h.slice(now)
+	b := &h.buckets[cur%passiveBuckets]
+	if b.slice != cur {
+		*b = bucket{slice: cur}
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+
+	if now.Before(h.until) || !failed {
+		return false, 0, 0
+	}
+	for _, b := range h.buckets {
+		if b.slice > cur-passiveBuckets {
+			total += b.total
+			fails += b.failed
+		}
+	}
+	if total < h.min || float64(fails) < h.ratio*float64(total) {
+		return false, 0, 0
+	}
+
+	h.until = now.Add(h.window)
+	h.buckets = [passiveBuckets]bucket{}
+	return true, fails, total
+}
+
+// down returns true if the upstream is down at now. When it comes back up, up is true once.
+func (h *passiveHealth) down(now time.Time) (down, up bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.until.IsZero() {
+		return false, false
+	}
+	if now.Before(h.until) {
+		return true, false
+	}
+	h.until = time.Time{}
+	return false, true
+}
+
+const passiveBuckets = 10
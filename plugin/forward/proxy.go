@@ -2,36 +2,46 @@ package forward
 
 import (
 	"crypto/tls"
+	"fmt"
 	"runtime"
 	"sync/atomic"
 	"time"
 
+	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/plugin/pkg/up"
 )
 
 // Proxy defines an upstream host.
 type Proxy struct {
-	fails uint32
+	fails    uint32
+	maxfails uint32 // as configured in the forward block, for passive health checking and reporting
 
-	addr string
+	addr  string
+	trans string
 
 	// Connection caching
 	expire    time.Duration
 	transport *Transport
+	quic      *quicConn // nil if the upstream isn't DNS-over-QUIC
 
 	// health checking
-	probe  *up.Probe
-	health HealthChecker
+	probe   *up.Probe
+	health  HealthChecker
+	passive *passiveHealth // nil if passive health checking is not enabled
 }
 
 // NewProxy returns a new proxy.
 func NewProxy(addr, trans string) *Proxy {
 	p := &Proxy{
 		addr:      addr,
+		trans:     trans,
 		fails:     0,
 		probe:     up.New(),
 		transport: newTransport(addr),
 	}
+	if trans == transport.QUIC {
+		p.quic = newQUICConn(addr)
+	}
 	p.health = NewHealthChecker(trans)
 	runtime.SetFinalizer(p, (*Proxy).finalizer)
 	return p
@@ -39,12 +49,20 @@ func NewProxy(addr, trans string) *Proxy {
 
 // SetTLSConfig sets the TLS config in the lower p.transport and in the healthchecking client.
 func (p *Proxy) SetTLSConfig(cfg *tls.Config) {
+	if p.quic != nil {
+		p.quic.setTLSConfig(cfg)
+	}
 	p.transport.SetTLSConfig(cfg)
 	p.health.SetTLSConfig(cfg)
 }
 
 // SetExpire sets the expire duration in the lower p.transport.
-func (p *Proxy) SetExpire(expire time.Duration) { p.transport.SetExpire(expire) }
+func (p *Proxy) SetExpire(expire time.Duration) {
+	p.transport.SetExpire(expire)
+	if p.quic != nil {
+		p.quic.setExpire(expire)
+	}
+}
 
 // Healthcheck kicks of a round of health checks for this proxy.
 func (p *Proxy) Healthcheck() {
@@ -58,19 +76,74 @@ func (p *Proxy) Healthcheck() {
 	})
 }
 
-// Down returns true if this proxy is down, i.e. has *more* fails than maxfails.
+// Down returns true if this proxy is down, i.e. has *more* fails than maxfails, or too many of the
+// queries sent to it failed recently.
 func (p *Proxy) Down(maxfails uint32) bool {
 	if maxfails == 0 {
 		return false
 	}
 
+	if p.passive != nil {
+		down, up := p.passive.down(time.Now())
+		if down {
+			return true
+		}
+		if up {
+			p.transition(false, "passive health check window passed")
+		}
+	}
+
 	fails := atomic.LoadUint32(&p.fails)
 	return fails > maxfails
 }
 
+// observe records the outcome of a query sent to p, for passive health checking.
+func (p *Proxy) observe(err error) {
+	if p.passive == nil || p.maxfails == 0 {
+		return
+	}
+	down, fails, total := p.passive.record(time.Now(), err != nil)
+	if down {
+		p.transition(true, fmt.Sprintf("%d of %d queries failed in the last %s", fails, total, p.passive.window))
+	}
+}
+
+// fail increases the fail counter of p, it returns true if p went down because of it. Failed health
+// checks and replies with a failover rcode are counted.
+func (p *Proxy) fail(reason string) bool {
+	fails := atomic.AddUint32(&p.fails, 1)
+	if p.maxfails == 0 || fails != p.maxfails+1 {
+		return false
+	}
+	p.transition(true, fmt.Sprintf("%d failures, last %s", fails, reason))
+	return true
+}
+
+// transition logs that p went down or came back up, and updates the metrics.
+func (p *Proxy) transition(down bool, reason string) {
+	if down {
+		log.Warningf("Upstream %s is down: %s", p.addr, reason)
+		HealthTransitionCount.WithLabelValues(p.addr, "down").Add(1)
+	} else {
+		log.Infof("Upstream %s is up: %s", p.addr, reason)
+		HealthTransitionCount.WithLabelValues(p.addr, "up").Add(1)
+	}
+	if p.Down(p.maxfails) {
+		UpstreamDownGauge.WithLabelValues(p.addr).Set(1)
+		return
+	}
+	UpstreamDownGauge.WithLabelValues(p.addr).Set(0)
+}
+
 // close stops the health checking goroutine.
-func (p *Proxy) close()     { p.probe.Stop() }
-func (p *Proxy) finalizer() { p.transport.Stop() }
+func (p *Proxy) close() { p.probe.Stop() }
+
+func (p *Proxy) finalizer() {
+	p.transport.Stop()
+	if p.quic != nil {
+		p.quic.close()
+	}
+}
 
 // start starts the proxy's healthchecking.
 func (p *Proxy) start(duration time.Duration) {
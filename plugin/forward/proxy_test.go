@@ -3,14 +3,19 @@ package forward
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/plugin/pkg/transport"
 	"github.com/coredns/coredns/plugin/test"
 	"github.com/coredns/coredns/request"
 
 	"github.com/caddyserver/caddy"
 	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestProxyClose(t *testing.T) {
@@ -121,3 +126,91 @@ func TestProtocolSelection(t *testing.T) {
 		}
 	}
 }
+
+func TestProxySpanTags(t *testing.T) {
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	c := caddy.NewTestController("dns", "forward . "+s.Addr)
+	f, err := parseForward(c)
+	if err != nil {
+		t.Errorf("Failed to create forwarder: %s", err)
+	}
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	tracer := mocktracer.New()
+	span := tracer.StartSpan("forward")
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	f.ServeDNS(ot.ContextWithSpan(context.TODO(), span), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	span.Finish()
+
+	spans := tracer.FinishedSpans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	if x := spans[0].Tag(trace.TagUpstream); x != s.Addr {
+		t.Errorf("Expected tag %s to be %q, got %v", trace.TagUpstream, s.Addr, x)
+	}
+	if x := spans[1].Tag(trace.TagRetries); x != 0 {
+		t.Errorf("Expected tag %s to be 0, got %v", trace.TagRetries, x)
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	s := dnstest.NewServer(func(w dns.ResponseWriter, r *dns.Msg) {
+		<-release
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		w.WriteMsg(ret)
+	})
+	defer s.Close()
+
+	c := caddy.NewTestController("dns", "forward . "+s.Addr+" {\nmax_concurrent 1 SERVFAIL\n}\n")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	done := make(chan error)
+	go func() {
+		_, err := f.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+		done <- err
+	}()
+	for i := 0; testutil.ToFloat64(InflightGauge.WithLabelValues(f.proxies[0].addr)) != 1; i++ {
+		if i == 100 {
+			t.Fatal("Expected a query to be in flight")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	rejects := testutil.ToFloat64(MaxConcurrentRejectCount)
+	rc, err := f.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	if rc != dns.RcodeServerFailure || err != ErrLimitExceeded {
+		t.Errorf("Expected SERVFAIL and %q, got %s and %v", ErrLimitExceeded, dns.RcodeToString[rc], err)
+	}
+	if x := testutil.ToFloat64(MaxConcurrentRejectCount) - rejects; x != 1 {
+		t.Errorf("Expected 1 rejected query, got %f", x)
+	}
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("Expected the first query to be answered, got %s", err)
+	}
+	if x := testutil.ToFloat64(InflightGauge.WithLabelValues(f.proxies[0].addr)); x != 0 {
+		t.Errorf("Expected no queries in flight, got %f", x)
+	}
+	if _, err := f.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m); err != nil {
+		t.Errorf("Expected a query to be answered after the first one finished, got %s", err)
+	}
+}
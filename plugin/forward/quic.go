@@ -0,0 +1,137 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/doq"
+
+	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go"
+)
+
+// quicConn is the DNS-over-QUIC (RFC 9250) connection to an upstream. The QUIC connection is shared
+// by all queries, each query is sent on a stream of its own. When it's closed, e.g. because it was
+// idle for too long, the next query dials a new one. TLS sessions are resumed, so that queries can be
+// sent in 0-RTT data.
+type quicConn struct {
+	addr string
+
+	mu        sync.Mutex
+	conn      quic.EarlyConnection // nil when not connected
+	tlsConfig *tls.Config
+	expire    time.Duration
+}
+
+func newQUICConn(addr string) *quicConn {
+	q := &quicConn{addr: addr, expire: defaultExpire}
+	q.setTLSConfig(new(tls.Config))
+	return q
+}
+
+// setTLSConfig sets the TLS config used for new connections.
+func (q *quicConn) setTLSConfig(cfg *tls.Config) {
+	cfg = cfg.Clone()
+	cfg.NextProtos = []string{doq.NextProto}
+	cfg.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+
+	q.mu.Lock()
+	q.tlsConfig = cfg
+	q.mu.Unlock()
+}
+
+// setExpire sets how long a connection may be idle before it's closed.
+func (q *quicConn) setExpire(expire time.Duration) {
+	q.mu.Lock()
+	q.expire = expire
+	q.mu.Unlock()
+}
+
+// connection returns the connection to the upstream, dialing it when there is none. The second return
+// value is true if the connection was already there.
+func (q *quicConn) connection(ctx context.Context) (quic.EarlyConnection, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.conn != nil {
+		select {
+		case <-q.conn.Context().Done():
+			q.conn = nil
+		default:
+			return q.conn, true, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, maxDialTimeout)
+	defer cancel()
+	conn, err := quic.DialAddrEarly(ctx, q.addr, q.tlsConfig, &quic.Config{MaxIdleTimeout: q.expire})
+	if err != nil {
+		return nil, false, err
+	}
+	q.conn = conn
+	return conn, false, nil
+}
+
+// drop forgets conn, when it's still the connection to the upstream.
+func (q *quicConn) drop(conn quic.EarlyConnection) {
+	q.mu.Lock()
+	if q.conn == conn {
+		q.conn = nil
+	}
+	q.mu.Unlock()
+	conn.CloseWithError(doq.NoError, "")
+}
+
+// exchange sends m on a new stream, and returns the reply.
+func (q *quicConn) exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	conn, cached, err := q.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// An error on a connection that's gone, e.g. closed by the upstream when it was idle, is
+	// returned as ErrCachedClosed so the query is retried on a new one.
+	fail := func(err error) (*dns.Msg, error) {
+		if conn.Context().Err() != nil {
+			q.drop(conn)
+			if cached {
+				return nil, ErrCachedClosed
+			}
+		}
+		return nil, err
+	}
+
+	stream, err := conn.OpenStreamSync(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	stream.SetDeadline(time.Now().Add(readTimeout))
+	stop := cancelOnDone(ctx, stream)
+	defer stop()
+
+	if err := doq.WriteMsg(stream, m); err != nil {
+		stream.CancelRead(doq.RequestCancelled)
+		return fail(err)
+	}
+	// The client closes its side of the stream after the query, see section 4.2 of RFC 9250.
+	stream.Close()
+
+	ret, err := doq.ReadMsg(stream)
+	if err != nil {
+		stream.CancelRead(doq.RequestCancelled)
+		return fail(err)
+	}
+	ret.Id = m.Id
+	return ret, nil
+}
+
+// close closes the connection.
+func (q *quicConn) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.conn != nil {
+		q.conn.CloseWithError(doq.NoError, "")
+		q.conn = nil
+	}
+}
@@ -0,0 +1,98 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+	"github.com/coredns/coredns/plugin/test"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+// quicServer returns a DNS-over-QUIC server that answers every A query with 127.0.0.1, and its address.
+func quicServer(t *testing.T) (*dnsserver.ServerQUIC, string) {
+	dir, rm, err := test.WritePEMFiles("")
+	if err != nil {
+		t.Fatalf("Could not write PEM files: %s", err)
+	}
+	defer rm()
+	tlsConfig, err := pkgtls.NewTLSConfig(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &dnsserver.Config{Zone: ".", Transport: transport.QUIC, TLSConfig: tlsConfig}
+	c.AddPlugin(func(next plugin.Handler) plugin.Handler {
+		return plugin.HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			ret := new(dns.Msg)
+			ret.SetReply(r)
+			ret.Answer = append(ret.Answer, test.A(r.Question[0].Name+" IN A 127.0.0.1"))
+			w.WriteMsg(ret)
+			return 0, nil
+		})
+	})
+	s, err := dnsserver.NewServerQUIC("quic://127.0.0.1:0", []*dnsserver.Config{c})
+	if err != nil {
+		t.Fatalf("Failed to create server: %s", err)
+	}
+	pc, err := s.ListenPacket()
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	go s.ServePacket(pc)
+	return s, pc.LocalAddr().String()
+}
+
+func TestQUIC(t *testing.T) {
+	s, addr := quicServer(t)
+	defer s.Stop()
+
+	p := NewProxy(addr, transport.QUIC)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	f := New()
+	f.SetProxy(p)
+	defer f.OnShutdown()
+
+	for _, name := range []string{"example.org.", "example.net."} {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Expected to receive reply for %s, got %s", name, err)
+		}
+		if rec.Msg.Id != m.Id {
+			t.Errorf("Expected the reply to have ID %d, got %d", m.Id, rec.Msg.Id)
+		}
+		if len(rec.Msg.Answer) != 1 || rec.Msg.Answer[0].Header().Name != name {
+			t.Errorf("Expected an answer for %s, got %v", name, rec.Msg.Answer)
+		}
+	}
+
+	// Once the connection is gone, the next query dials a new one.
+	p.quic.close()
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	if _, err := p.Connect(context.TODO(), request.Request{Req: m, W: &test.ResponseWriter{}}, options{}); err != nil {
+		t.Errorf("Expected to reconnect, got %s", err)
+	}
+}
+
+func TestQUICHealth(t *testing.T) {
+	s, addr := quicServer(t)
+	defer s.Stop()
+
+	p := NewProxy(addr, transport.QUIC)
+	p.SetTLSConfig(&tls.Config{InsecureSkipVerify: true})
+	defer p.quic.close()
+	if err := p.health.Check(p); err != nil {
+		t.Errorf("Expected the health check to succeed, got %s", err)
+	}
+}
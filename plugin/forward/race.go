@@ -0,0 +1,214 @@
+package forward
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/trace"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+)
+
+// Racing the upstreams: with parallel the query is sent to the first N upstreams at once, with hedge it
+// is sent to the next upstream as well when the upstreams queried so far haven't answered within a delay.
+// The first answer is used and the other queries are canceled.
+
+// racing returns true when queries are raced against several upstreams.
+func (f *Forward) racing() bool {
+	return f.parallel > 1 || f.hedgeDelay > 0 || f.hedgePercentile > 0
+}
+
+// hedgeAfter returns the delay after which the next upstream is queried, or 0 when no hedged queries
+// are to be sent.
+func (f *Forward) hedgeAfter() time.Duration {
+	if f.hedgePercentile > 0 {
+		return f.latency.percentile(f.hedgePercentile)
+	}
+	return f.hedgeDelay
+}
+
+// candidates returns the healthy upstreams in list, which is in the order of the policy. When all
+// upstreams are down it returns a random one.
+func (f *Forward) candidates(list []*Proxy) []*Proxy {
+	healthy := make([]*Proxy, 0, len(list))
+	for _, p := range list {
+		if !p.Down(f.maxfails) {
+			healthy = append(healthy, p)
+		}
+	}
+	if len(healthy) > 0 {
+		return healthy
+	}
+	// All upstream proxies are dead, assume healtcheck is completely broken and randomly
+	// select an upstream to connect to.
+	HealthcheckBrokenCount.Add(1)
+	return new(random).List(list)[:1]
+}
+
+// raceResult is the outcome of querying one upstream.
+type raceResult struct {
+	proxy  *Proxy
+	ret    *dns.Msg
+	err    error
+	taperr error
+	rtt    time.Duration
+}
+
+// race sends the query in state to the upstreams in list, as configured with parallel and hedge, and
+// returns the first answer. When an upstream fails, or replies with a failover rcode, the next one is
+// queried straight away. When none of them answers, the last failover reply or else the last error is
+// returned.
+func (f *Forward) race(ctx context.Context, state request.Request, list []*Proxy) raceResult {
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	span := ot.SpanFromContext(ctx)
+	results := make(chan raceResult, len(list))
+	started := 0
+	launch := func() {
+		proxy := list[started]
+		started++
+		go func() {
+			qctx := ctx
+			var child ot.Span
+			if span != nil {
+				child = span.Tracer().StartSpan("connect", ot.ChildOf(span.Context()))
+				child.SetTag(trace.TagUpstream, proxy.addr)
+				qctx = ot.ContextWithSpan(ctx, child)
+			}
+			start := time.Now()
+			ret, err := f.connect(qctx, proxy, state)
+			rtt := time.Since(start)
+			if child != nil {
+				if err != nil {
+					ext.Error.Set(child, true)
+				}
+				child.Finish()
+			}
+			taperr := toDnstap(qctx, proxy.addr, f, state, ret, start)
+
+			// Kick off health check to see if *our* upstream is broken, unless we canceled the query.
+			if err != nil && ctx.Err() == nil && f.maxfails != 0 {
+				proxy.Healthcheck()
+			}
+			results <- raceResult{proxy: proxy, ret: ret, err: err, taperr: taperr, rtt: rtt}
+		}()
+	}
+
+	parallel := f.parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	for started < parallel && started < len(list) {
+		launch()
+	}
+
+	var hedge <-chan time.Time
+	if delay := f.hedgeAfter(); delay > 0 && started < len(list) {
+		t := time.NewTicker(delay)
+		defer t.Stop()
+		hedge = t.C
+	}
+
+	var (
+		err    error
+		failed raceResult // last reply with a failover rcode
+	)
+	for done := 0; done < started; {
+		select {
+		case r := <-results:
+			done++
+			if r.err == nil && !f.failover(r.proxy, r.ret) {
+				f.won(r, list[:started])
+				if span != nil {
+					span.SetTag(trace.TagRetries, started-1)
+				}
+				return r
+			}
+			if r.err == nil {
+				failed = r
+			} else {
+				err = r.err
+			}
+			if started < len(list) {
+				launch()
+			}
+		case <-hedge:
+			if started < len(list) {
+				launch()
+			}
+		case <-ctx.Done():
+			if failed.ret != nil {
+				return failed
+			}
+			if err == nil {
+				err = ctx.Err()
+			}
+			return raceResult{err: err}
+		}
+	}
+	if failed.ret != nil {
+		return failed
+	}
+	return raceResult{err: err}
+}
+
+// won records that r is the first answer of the upstreams in raced.
+func (f *Forward) won(r raceResult, raced []*Proxy) {
+	if f.latency != nil {
+		f.latency.add(r.rtt)
+	}
+	if len(raced) < 2 {
+		return
+	}
+	for _, p := range raced {
+		if p == r.proxy {
+			RaceWinCount.WithLabelValues(p.addr).Add(1)
+			continue
+		}
+		RaceLossCount.WithLabelValues(p.addr).Add(1)
+	}
+}
+
+// latency keeps the response times of the last answers, to derive the hedge delay from.
+type latency struct {
+	sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (l *latency) add(d time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	if len(l.samples) < latencySamples {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencySamples
+}
+
+// percentile returns the p-th percentile of the response times. When there are too few samples to
+// tell, it returns 0.
+func (l *latency) percentile(p float64) time.Duration {
+	l.Lock()
+	if len(l.samples) < minLatencySamples {
+		l.Unlock()
+		return 0
+	}
+	s := append([]time.Duration(nil), l.samples...)
+	l.Unlock()
+
+	sort.Slice(s, func(i, j int) bool { return s[i] < s[j] })
+	return s[int(float64(len(s)-1)*p/100)]
+}
+
+const (
+	latencySamples    = 256
+	minLatencySamples = 20
+)
@@ -0,0 +1,186 @@
+package forward
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newRaceServers returns a server per delay. Server i answers with an A record holding 127.0.0.i+1 after
+// its delay, or not at all when the delay is negative. The servers share a handler, as they all use the
+// default ServeMux, which tells them apart by port.
+func newRaceServers(delays ...time.Duration) []*dnstest.Server {
+	ports := map[string]int{}
+	var mu sync.Mutex
+	handler := func(w dns.ResponseWriter, r *dns.Msg) {
+		_, port, _ := net.SplitHostPort(w.LocalAddr().String())
+		mu.Lock()
+		i, ok := ports[port]
+		mu.Unlock()
+		if !ok || delays[i] < 0 {
+			return
+		}
+		time.Sleep(delays[i])
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A(fmt.Sprintf("example.org. IN A 127.0.0.%d", i+1)))
+		w.WriteMsg(ret)
+	}
+
+	servers := make([]*dnstest.Server, len(delays))
+	for i := range delays {
+		servers[i] = dnstest.NewServer(handler)
+		_, port, _ := net.SplitHostPort(servers[i].Addr)
+		mu.Lock()
+		ports[port] = i
+		mu.Unlock()
+	}
+	return servers
+}
+
+func TestRace(t *testing.T) {
+	servers := newRaceServers(1*time.Second, 0)
+	for _, s := range servers {
+		defer s.Close()
+	}
+
+	tests := []struct {
+		option string
+	}{
+		{"parallel 2"},
+		{"hedge 50ms"},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", "forward . "+servers[0].Addr+" "+servers[1].Addr+" {\npolicy sequential\n"+tc.option+"\n}\n")
+		f, err := parseForward(c)
+		if err != nil {
+			t.Fatalf("Test %d: failed to create forwarder: %s", i, err)
+		}
+		f.OnStartup()
+
+		slow, fast := f.proxies[0].addr, f.proxies[1].addr
+		wins := testutil.ToFloat64(RaceWinCount.WithLabelValues(fast))
+		losses := testutil.ToFloat64(RaceLossCount.WithLabelValues(slow))
+
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		start := time.Now()
+		if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Test %d: expected to receive reply, but got %s", i, err)
+		}
+		if d := time.Since(start); d > 500*time.Millisecond {
+			t.Errorf("Test %d: expected the fast upstream to answer, took %s", i, d)
+		}
+		if x := rec.Msg.Answer[0].(*dns.A).A.String(); x != "127.0.0.2" {
+			t.Errorf("Test %d: expected answer %s, got %s", i, "127.0.0.2", x)
+		}
+		if x := testutil.ToFloat64(RaceWinCount.WithLabelValues(fast)) - wins; x != 1 {
+			t.Errorf("Test %d: expected 1 win for %s, got %f", i, fast, x)
+		}
+		if x := testutil.ToFloat64(RaceLossCount.WithLabelValues(slow)) - losses; x != 1 {
+			t.Errorf("Test %d: expected 1 loss for %s, got %f", i, slow, x)
+		}
+		f.OnShutdown()
+	}
+}
+
+func TestRaceFailover(t *testing.T) {
+	servers := newRaceServers(-1, 0)
+	for _, s := range servers {
+		defer s.Close()
+	}
+
+	c := caddy.NewTestController("dns", "forward . "+servers[0].Addr+" "+servers[1].Addr+" {\npolicy sequential\nmax_fails 0\nhedge 10s\n}\n")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected to receive reply, but got %s", err)
+	}
+	if x := rec.Msg.Answer[0].(*dns.A).A.String(); x != "127.0.0.2" {
+		t.Errorf("Expected answer %s, got %s", "127.0.0.2", x)
+	}
+}
+
+func TestLatencyPercentile(t *testing.T) {
+	l := new(latency)
+	for i := 1; i < minLatencySamples; i++ {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+	if x := l.percentile(90); x != 0 {
+		t.Errorf("Expected no percentile with too few samples, got %s", x)
+	}
+	for i := minLatencySamples; i <= latencySamples+100; i++ {
+		l.add(time.Duration(i) * time.Millisecond)
+	}
+	// The samples are now 101ms ... 356ms.
+	if x := l.percentile(50); x != 228*time.Millisecond {
+		t.Errorf("Expected %s, got %s", 228*time.Millisecond, x)
+	}
+	if x := l.percentile(99); x != 353*time.Millisecond {
+		t.Errorf("Expected %s, got %s", 353*time.Millisecond, x)
+	}
+}
+
+func TestSetupRace(t *testing.T) {
+	tests := []struct {
+		input      string
+		shouldErr  bool
+		parallel   int
+		delay      time.Duration
+		percentile float64
+	}{
+		{"forward . 127.0.0.1\n", false, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nparallel 2\n}\n", false, 2, 0, 0},
+		{"forward . 127.0.0.1 {\nhedge 100ms\n}\n", false, 0, 100 * time.Millisecond, 0},
+		{"forward . 127.0.0.1 {\nhedge p95\n}\n", false, 0, 0, 95},
+		{"forward . 127.0.0.1 {\nparallel 2\nhedge p99.9\n}\n", false, 2, 0, 99.9},
+		// negative
+		{"forward . 127.0.0.1 {\nparallel\n}\n", true, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nparallel 0\n}\n", true, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhedge\n}\n", true, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhedge 0s\n}\n", true, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhedge p100\n}\n", true, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhedge pxx\n}\n", true, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhedge 10ms 20ms\n}\n", true, 0, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if f.parallel != test.parallel || f.hedgeDelay != test.delay || f.hedgePercentile != test.percentile {
+			t.Errorf("Test %d: expected parallel %d, hedge %s p%g, got %d, %s p%g", i, test.parallel, test.delay, test.percentile, f.parallel, f.hedgeDelay, f.hedgePercentile)
+		}
+		if (test.percentile > 0) != (f.latency != nil) {
+			t.Errorf("Test %d: expected latencies to be tracked only for a hedge percentile", i)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package forward
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/coredns/coredns/request"
+
+	"github.com/caddyserver/caddy/caddyfile"
+)
+
+// table returns the upstream groups of the routing table.
+func (f *Forward) table() []*Forward {
+	f.routeMu.RLock()
+	defer f.routeMu.RUnlock()
+	return f.routes
+}
+
+// route returns the upstream group of the longest domain in the routing table that matches the query
+// in state, or nil when there is none.
+func (f *Forward) route(state request.Request) *Forward {
+	var group *Forward
+	for _, g := range f.table() {
+		if g.match(state) && (group == nil || len(g.from) > len(group.from)) {
+			group = g
+		}
+	}
+	return group
+}
+
+// routeOnly returns true if f only has a routing table, and no upstreams of its own.
+func (f *Forward) routeOnly() bool {
+	return len(f.to) == 0 && f.srv == nil && (len(f.routeSpecs) > 0 || f.routeFile != nil)
+}
+
+// routeTokens returns the tokens of the route stanza in the forward block that starts at the current
+// token, including its block. Nested blocks aren't supported by the dispenser, so the stanza is parsed
+// on its own later.
+func routeTokens(c *caddyfile.Dispenser) []caddyfile.Token {
+	token := func() caddyfile.Token { return caddyfile.Token{File: c.File(), Line: c.Line(), Text: c.Val()} }
+
+	tokens := []caddyfile.Token{token()}
+	for c.NextArg() {
+		tokens = append(tokens, token())
+		if c.Val() != "{" {
+			continue
+		}
+		for depth := 1; depth > 0 && c.Next(); {
+			tokens = append(tokens, token())
+			switch c.Val() {
+			case "{":
+				depth++
+			case "}":
+				depth--
+			}
+		}
+		break
+	}
+	return tokens
+}
+
+// parseRoutes returns the upstream groups for the route stanzas in the forward block, followed by those
+// in the routes file.
+func (f *Forward) parseRoutes() ([]*Forward, error) {
+	var groups []*Forward
+	for _, tokens := range f.routeSpecs {
+		c := caddyfile.NewDispenserTokens(tokens[0].File, tokens)
+		c.Next()
+		g := f.inherit()
+		if err := parseStanza(&c, g); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	if f.routeFile != nil {
+		fg, err := f.readRoutes(f.routeFile.file)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, fg...)
+	}
+	return groups, checkRoutes(groups)
+}
+
+// checkRoutes checks that the upstream groups don't have too many upstreams, and that no domain has
+// more than one.
+func checkRoutes(groups []*Forward) error {
+	seen := map[string]bool{}
+	for _, g := range groups {
+		if g.Len() > max {
+			return fmt.Errorf("more than %d TOs configured for route %s: %d", max, g.from, g.Len())
+		}
+		if seen[g.from] {
+			return fmt.Errorf("more than one route for %s", g.from)
+		}
+		seen[g.from] = true
+	}
+	return nil
+}
+
+// readRoutes reads the route stanzas in file, each has the syntax of a forward stanza and starts
+// with route instead of forward.
+func (f *Forward) readRoutes(file string) ([]*Forward, error) {
+	r, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if fi, err := r.Stat(); err == nil {
+		f.routeFile.mtime, f.routeFile.size = fi.ModTime(), fi.Size()
+	}
+
+	var groups []*Forward
+	c := caddyfile.NewDispenser(file, r)
+	for c.Next() {
+		if c.Val() != "route" {
+			return nil, c.Errf("unknown directive '%s'", c.Val())
+		}
+		g := f.inherit()
+		if err := parseStanza(&c, g); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// reloadRoutes reads the routes file again when it changed, and replaces the upstream groups from the
+// file with the new ones; the groups of the route stanzas in the block are kept as they are. When the
+// file can't be parsed the groups we have are kept.
+func (f *Forward) reloadRoutes() {
+	fi, err := os.Stat(f.routeFile.file)
+	if err != nil {
+		log.Warningf("Failed to check %s, keeping its routes: %s", f.routeFile.file, err)
+		return
+	}
+	if fi.ModTime().Equal(f.routeFile.mtime) && fi.Size() == f.routeFile.size {
+		return
+	}
+
+	// The groups of the block come first in the table, and only this goroutine replaces the table.
+	inline := f.table()[:len(f.routeSpecs)]
+	fg, err := f.readRoutes(f.routeFile.file)
+	if err == nil {
+		err = checkRoutes(append(append([]*Forward{}, inline...), fg...))
+	}
+	if err != nil {
+		log.Warningf("Failed to read %s, keeping its routes: %s", f.routeFile.file, err)
+		f.routeFile.mtime, f.routeFile.size = fi.ModTime(), fi.Size()
+		return
+	}
+	for _, g := range fg {
+		g.OnStartup()
+	}
+
+	f.routeMu.Lock()
+	old := f.routes[len(inline):]
+	f.routes = append(append([]*Forward{}, inline...), fg...)
+	f.routeMu.Unlock()
+
+	for _, g := range old {
+		g.OnShutdown()
+	}
+	log.Infof("Reloaded %d routes from %s", len(fg), f.routeFile.file)
+}
+
+// inherit returns a new upstream group for the routing table, with the settings of the forward block.
+func (f *Forward) inherit() *Forward {
+	g := New()
+	g.group = true
+
+	switch f.p.(type) {
+	case *roundRobin:
+		g.p = &roundRobin{}
+	case *sequential:
+		g.p = &sequential{}
+	}
+	g.hcInterval = f.hcInterval
+	g.tlsConfig = f.tlsConfig.Clone()
+	g.tlsServerName = f.tlsServerName
+	g.maxfails = f.maxfails
+	g.expire = f.expire
+	g.ecs, g.ecsV4, g.ecsV6 = f.ecs, f.ecsV4, f.ecsV6
+	g.hcOpts = f.hcOpts
+	g.hcOpts.rcodes = append([]int(nil), f.hcOpts.rcodes...)
+	g.passiveWindow, g.passiveRatio, g.passiveMin = f.passiveWindow, f.passiveRatio, f.passiveMin
+	g.failRcodes = f.failRcodes
+	g.parallel, g.hedgeDelay, g.hedgePercentile = f.parallel, f.hedgeDelay, f.hedgePercentile
+	if f.latency != nil {
+		g.latency = new(latency)
+	}
+	g.reload = f.reload
+	g.opts = f.opts
+	return g
+}
@@ -0,0 +1,271 @@
+package forward
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+// answerServer returns a UDP server that answers every A query with ip, and its address. Unlike
+// dnstest.NewServer it has its own handler, so several of them can answer differently.
+func answerServer(t *testing.T, ip string) (*dns.Server, string) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	started := make(chan struct{})
+	s := &dns.Server{PacketConn: pc, NotifyStartedFunc: func() { close(started) }}
+	s.Handler = dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		ret := new(dns.Msg)
+		ret.SetReply(r)
+		ret.Answer = append(ret.Answer, test.A(r.Question[0].Name+" IN A "+ip))
+		w.WriteMsg(ret)
+	})
+	go s.ActivateAndServe()
+	<-started
+	return s, pc.LocalAddr().String()
+}
+
+func answer(t *testing.T, f *Forward, name string) string {
+	m := new(dns.Msg)
+	m.SetQuestion(name, dns.TypeA)
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := f.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected to receive reply for %s, got %s", name, err)
+	}
+	if rec.Msg == nil || len(rec.Msg.Answer) == 0 {
+		return ""
+	}
+	return rec.Msg.Answer[0].(*dns.A).A.String()
+}
+
+func TestRoute(t *testing.T) {
+	def, defAddr := answerServer(t, "10.0.0.1")
+	org, orgAddr := answerServer(t, "10.0.0.2")
+	sub, subAddr := answerServer(t, "10.0.0.3")
+	defer def.Shutdown()
+	defer org.Shutdown()
+	defer sub.Shutdown()
+
+	c := caddy.NewTestController("dns", `forward . `+defAddr+` {
+		policy sequential
+		route example.org `+orgAddr+`
+		route sub.example.org `+subAddr+`
+	}`)
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"example.com.", "10.0.0.1"},
+		{"example.org.", "10.0.0.2"},
+		{"www.example.org.", "10.0.0.2"},
+		{"sub.example.org.", "10.0.0.3"},
+		{"www.sub.example.org.", "10.0.0.3"},
+		{"notsub.example.org.", "10.0.0.2"},
+	}
+	for i, tc := range tests {
+		if x := answer(t, f, tc.name); x != tc.expected {
+			t.Errorf("Test %d: expected %s to be answered by %s, got %s", i, tc.name, tc.expected, x)
+		}
+	}
+
+	for _, g := range f.table() {
+		if _, ok := g.p.(*sequential); !ok {
+			t.Errorf("Expected route %s to inherit the policy, got %s", g.from, g.p)
+		}
+	}
+}
+
+func TestRouteBlock(t *testing.T) {
+	c := caddy.NewTestController("dns", `forward . 10.0.0.1 {
+		route example.org 10.0.0.2 {
+			policy round_robin
+			max_fails 5
+		}
+		route example.net 10.0.0.3
+		max_fails 3
+	}`)
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+
+	groups := f.table()
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 routes, got %d", len(groups))
+	}
+	if _, ok := groups[0].p.(*roundRobin); !ok || groups[0].maxfails != 5 {
+		t.Errorf("Expected the settings of the route, got policy %s and max_fails %d", groups[0].p, groups[0].maxfails)
+	}
+	if _, ok := groups[1].p.(*random); !ok || groups[1].maxfails != 3 {
+		t.Errorf("Expected the settings of the forward block, got policy %s and max_fails %d", groups[1].p, groups[1].maxfails)
+	}
+}
+
+func TestRouteOnly(t *testing.T) {
+	org, orgAddr := answerServer(t, "10.0.0.2")
+	defer org.Shutdown()
+
+	c := caddy.NewTestController("dns", "forward . {\nroute example.org "+orgAddr+"\n}\n")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f.Next = test.NextHandler(dns.RcodeRefused, nil)
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	if x := answer(t, f, "www.example.org."); x != "10.0.0.2" {
+		t.Errorf("Expected the route to answer, got %q", x)
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	rcode, _ := f.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	if rcode != dns.RcodeRefused {
+		t.Errorf("Expected a name without a route to go to the next plugin, got rcode %d", rcode)
+	}
+}
+
+func TestRoutesFile(t *testing.T) {
+	def, defAddr := answerServer(t, "10.0.0.1")
+	org, orgAddr := answerServer(t, "10.0.0.2")
+	other, otherAddr := answerServer(t, "10.0.0.3")
+	defer def.Shutdown()
+	defer org.Shutdown()
+	defer other.Shutdown()
+
+	dir, err := ioutil.TempDir("", "forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	routes := filepath.Join(dir, "routes")
+	write := func(s string) {
+		if err := ioutil.WriteFile(routes, []byte(s), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`route example.org ` + orgAddr + ` {
+		policy round_robin
+		except skip.example.org
+		max_fails 5
+	}
+	route example.net ` + otherAddr)
+
+	c := caddy.NewTestController("dns", "forward . "+defAddr+" {\nmax_fails 3\nroute example.com "+otherAddr+"\nroutes "+routes+" 1h\n}\n")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	f.OnStartup()
+	defer f.OnShutdown()
+
+	groups := f.table()
+	if len(groups) != 3 {
+		t.Fatalf("Expected 3 routes, got %d", len(groups))
+	}
+	inline := groups[0]
+	groups = groups[1:]
+	if _, ok := groups[0].p.(*roundRobin); !ok || groups[0].maxfails != 5 {
+		t.Errorf("Expected the settings of the route, got policy %s and max_fails %d", groups[0].p, groups[0].maxfails)
+	}
+	if _, ok := groups[1].p.(*random); !ok || groups[1].maxfails != 3 {
+		t.Errorf("Expected the settings of the forward block, got policy %s and max_fails %d", groups[1].p, groups[1].maxfails)
+	}
+
+	for name, expected := range map[string]string{"www.example.org.": "10.0.0.2", "skip.example.org.": "10.0.0.1", "example.net.": "10.0.0.3"} {
+		if x := answer(t, f, name); x != expected {
+			t.Errorf("Expected %s to be answered by %s, got %s", name, expected, x)
+		}
+	}
+
+	// A broken file keeps the routes we have.
+	write("route example.org {\n}\n")
+	f.reloadRoutes()
+	if x := answer(t, f, "example.net."); x != "10.0.0.3" {
+		t.Errorf("Expected the routes to be kept, got %s", x)
+	}
+
+	// A route of the block can't be given again in the file.
+	write("route example.com " + orgAddr + "\n")
+	f.reloadRoutes()
+	if x := answer(t, f, "example.net."); x != "10.0.0.3" {
+		t.Errorf("Expected the routes to be kept, got %s", x)
+	}
+
+	write("route example.net " + orgAddr + "\n")
+	f.reloadRoutes()
+	if len(f.table()) != 2 {
+		t.Fatalf("Expected 2 routes after the reload, got %d", len(f.table()))
+	}
+	if f.table()[0] != inline {
+		t.Errorf("Expected the route of the block to be kept as it is")
+	}
+	if x := answer(t, f, "example.net."); x != "10.0.0.2" {
+		t.Errorf("Expected the new route to be used, got %s", x)
+	}
+	if x := answer(t, f, "example.org."); x != "10.0.0.1" {
+		t.Errorf("Expected the removed route to be gone, got %s", x)
+	}
+}
+
+func TestSetupRoutes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	nested := filepath.Join(dir, "nested")
+	if err := ioutil.WriteFile(nested, []byte("route example.org 10.0.0.1 {\nroute example.net 10.0.0.2\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unknown := filepath.Join(dir, "unknown")
+	if err := ioutil.WriteFile(unknown, []byte("forward example.org 10.0.0.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input       string
+		expectedErr string
+	}{
+		{"forward . 127.0.0.1 {\nroute example.org\n}\n", "Wrong argument count"},
+		{"forward . 127.0.0.1 {\nroute example.org 10.0.0.1\nroute Example.org 10.0.0.2\n}\n", "more than one route"},
+		{"forward . 127.0.0.1 {\nroute example.org a.b.c.d\n}\n", "not an IP"},
+		{"forward . 127.0.0.1 {\nroute example.org 10.0.0.1 {\nroute example.net 10.0.0.2\n}\n}\n", "can't be used in a route"},
+		{"forward . 127.0.0.1 {\nroute example.org 10.0.0.1 {\npolicy bogus\n}\n}\n", "unknown policy"},
+		{"forward . 127.0.0.1 {\nroutes " + nested + "\n}\n", "can't be used in a route"},
+		{"forward . 127.0.0.1 {\nroutes " + unknown + "\n}\n", "unknown directive"},
+		{"forward . 127.0.0.1 {\nroutes " + filepath.Join(dir, "missing") + "\n}\n", "no such file"},
+		{"forward . 127.0.0.1 {\nroutes " + nested + " -1s\n}\n", "can't be negative"},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		_, err := parseForward(c)
+		if err == nil {
+			t.Errorf("Test %d: expected error but found none for input %s", i, tc.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tc.expectedErr) {
+			t.Errorf("Test %d: expected error to contain %q, got %q", i, tc.expectedErr, err)
+		}
+	}
+}
@@ -3,17 +3,20 @@ package forward
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/metrics"
+	"github.com/coredns/coredns/plugin/pkg/edns"
 	"github.com/coredns/coredns/plugin/pkg/parse"
 	pkgtls "github.com/coredns/coredns/plugin/pkg/tls"
 	"github.com/coredns/coredns/plugin/pkg/transport"
 
 	"github.com/caddyserver/caddy"
 	"github.com/caddyserver/caddy/caddyfile"
+	"github.com/miekg/dns"
 )
 
 func init() {
@@ -38,7 +41,8 @@ func setup(c *caddy.Controller) error {
 	})
 
 	c.OnStartup(func() error {
-		metrics.MustRegister(c, RequestCount, RcodeCount, RequestDuration, HealthcheckFailureCount, SocketGauge)
+		metrics.MustRegister(c, RequestCount, RcodeCount, RequestDuration, HealthcheckFailureCount, HealthTransitionCount,
+			UpstreamDownGauge, FailoverCount, RaceWinCount, RaceLossCount, InflightGauge, MaxConcurrentRejectCount, SocketGauge)
 		return f.OnStartup()
 	})
 
@@ -49,19 +53,40 @@ func setup(c *caddy.Controller) error {
 	return nil
 }
 
-// OnStartup starts a goroutines for all proxies.
+// OnStartup starts a goroutines for all proxies, and one that keeps the upstreams up to date if they
+// can change.
 func (f *Forward) OnStartup() (err error) {
-	for _, p := range f.proxies {
+	for _, p := range f.upstreams() {
 		p.start(f.hcInterval)
 	}
+	for _, g := range f.table() {
+		g.OnStartup()
+	}
+	if !f.dynamic() {
+		return nil
+	}
+	if f.srv != nil && f.lookup() {
+		f.update()
+	}
+	f.stop = make(chan struct{})
+	f.wg.Add(1)
+	go f.watch()
 	return nil
 }
 
 // OnShutdown stops all configured proxies.
 func (f *Forward) OnShutdown() error {
-	for _, p := range f.proxies {
+	if f.stop != nil {
+		close(f.stop)
+		f.wg.Wait()
+		f.stop = nil
+	}
+	for _, p := range f.upstreams() {
 		p.close()
 	}
+	for _, g := range f.table() {
+		g.OnShutdown()
+	}
 	return nil
 }
 
@@ -90,47 +115,81 @@ func parseForward(c *caddy.Controller) (*Forward, error) {
 // ParseForwardStanza parses one forward stanza
 func ParseForwardStanza(c *caddyfile.Dispenser) (*Forward, error) {
 	f := New()
+	if err := parseStanza(c, f); err != nil {
+		return f, err
+	}
+	routes, err := f.parseRoutes()
+	if err != nil {
+		return f, err
+	}
+	f.routes = routes
+	return f, nil
+}
 
+// parseStanza parses a forward stanza, or a route stanza of the routing table, into f.
+func parseStanza(c *caddyfile.Dispenser, f *Forward) error {
 	if !c.Args(&f.from) {
-		return f, c.ArgErr()
+		return c.ArgErr()
 	}
 	f.from = plugin.Host(f.from).Normalize()
 
-	to := c.RemainingArgs()
-	if len(to) == 0 {
-		return f, c.ArgErr()
+	if err := f.addTo(c.RemainingArgs()); err != nil {
+		return err
 	}
 
-	toHosts, err := parse.HostPortOrFile(to...)
-	if err != nil {
-		return f, err
+	for c.NextBlock() {
+		if err := parseBlock(c, f); err != nil {
+			return err
+		}
 	}
-
-	transports := make([]string, len(toHosts))
-	for i, host := range toHosts {
-		trans, h := parse.Transport(host)
-		p := NewProxy(h, trans)
-		f.proxies = append(f.proxies, p)
-		transports[i] = trans
+	if len(f.to) == 0 && f.srv == nil && len(f.routeSpecs) == 0 && f.routeFile == nil {
+		return c.ArgErr()
 	}
 
-	for c.NextBlock() {
-		if err := parseBlock(c, f); err != nil {
-			return f, err
+	f.finish()
+	return nil
+}
+
+// addTo adds the upstreams in the TO arguments to f.
+func (f *Forward) addTo(to []string) error {
+	for _, t := range to {
+		s, err := newSource(t)
+		if err != nil {
+			return err
+		}
+		f.to = append(f.to, s)
+		for _, host := range s.addrs {
+			trans, h := parse.Transport(host)
+			f.proxies = append(f.proxies, NewProxy(h, trans))
 		}
 	}
+	return nil
+}
 
+// finish applies the settings in the forward block to the proxies.
+func (f *Forward) finish() {
 	if f.tlsServerName != "" {
 		f.tlsConfig.ServerName = f.tlsServerName
 	}
-	for i := range f.proxies {
-		// Only set this for proxies that need it.
-		if transports[i] == transport.TLS {
-			f.proxies[i].SetTLSConfig(f.tlsConfig)
-		}
-		f.proxies[i].SetExpire(f.expire)
+	for _, p := range f.proxies {
+		f.configure(p)
 	}
-	return f, nil
+}
+
+// configure applies the settings in the forward block to the new proxy p.
+func (f *Forward) configure(p *Proxy) {
+	p.maxfails = f.maxfails
+	if hc, ok := p.health.(*dnsHc); ok {
+		hc.setOptions(f.hcOpts)
+	}
+	if f.passiveWindow > 0 {
+		p.passive = newPassiveHealth(f.passiveWindow, f.passiveRatio, f.passiveMin)
+	}
+	// Only set this for proxies that need it.
+	if p.trans == transport.TLS || p.trans == transport.QUIC {
+		p.SetTLSConfig(f.tlsConfig)
+	}
+	p.SetExpire(f.expire)
 }
 
 func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
@@ -157,10 +216,11 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 		}
 		f.maxfails = uint32(n)
 	case "health_check":
-		if !c.NextArg() {
+		args := c.RemainingArgs()
+		if len(args) == 0 {
 			return c.ArgErr()
 		}
-		dur, err := time.ParseDuration(c.Val())
+		dur, err := time.ParseDuration(args[0])
 		if err != nil {
 			return err
 		}
@@ -168,6 +228,48 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 			return fmt.Errorf("health_check can't be negative: %d", dur)
 		}
 		f.hcInterval = dur
+		for i := 1; i < len(args); i++ {
+			switch args[i] {
+			case "no_rec":
+				f.hcOpts.noRec = true
+			case "tcp":
+				f.hcOpts.tcp = true
+			case "domain", "type", "rcode":
+				if i+1 == len(args) {
+					return c.ArgErr()
+				}
+				i++
+				if err := parseHealthQuery(c, f, args[i-1], args[i]); err != nil {
+					return err
+				}
+			default:
+				return c.Errf("unknown health_check option '%s'", args[i])
+			}
+		}
+	case "passive_health":
+		args := c.RemainingArgs()
+		if len(args) < 2 || len(args) > 3 {
+			return c.ArgErr()
+		}
+		dur, err := time.ParseDuration(args[0])
+		if err != nil {
+			return err
+		}
+		if dur <= 0 {
+			return fmt.Errorf("passive_health window must be positive: %s", dur)
+		}
+		ratio, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || ratio <= 0 || ratio > 1 {
+			return c.Errf("invalid passive_health ratio '%s'", args[1])
+		}
+		f.passiveWindow, f.passiveRatio, f.passiveMin = dur, ratio, defaultPassiveMin
+		if len(args) == 3 {
+			n, err := strconv.Atoi(args[2])
+			if err != nil || n < 1 {
+				return c.Errf("invalid passive_health minimum '%s'", args[2])
+			}
+			f.passiveMin = n
+		}
 	case "force_tcp":
 		if c.NextArg() {
 			return c.ArgErr()
@@ -220,6 +322,167 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 		default:
 			return c.Errf("unknown policy '%s'", x)
 		}
+	case "ecs":
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 3 {
+			return c.ArgErr()
+		}
+		switch args[0] {
+		case ecsAdd, ecsRewrite:
+			f.ecs = args[0]
+		default:
+			return c.Errf("unknown ecs mode '%s'", args[0])
+		}
+		f.ecsV4, f.ecsV6 = edns.DefaultSubnetV4, edns.DefaultSubnetV6
+		if len(args) > 1 {
+			n, err := strconv.ParseUint(args[1], 10, 8)
+			if err != nil || n > 32 {
+				return c.Errf("invalid IPv4 source prefix length '%s'", args[1])
+			}
+			f.ecsV4 = uint8(n)
+		}
+		if len(args) > 2 {
+			n, err := strconv.ParseUint(args[2], 10, 8)
+			if err != nil || n > 128 {
+				return c.Errf("invalid IPv6 source prefix length '%s'", args[2])
+			}
+			f.ecsV6 = uint8(n)
+		}
+	case "max_concurrent":
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 2 {
+			return c.ArgErr()
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return fmt.Errorf("max_concurrent can't be negative: %d", n)
+		}
+		f.maxConcurrent = int64(n)
+		if len(args) == 2 {
+			switch x := strings.ToUpper(args[1]); x {
+			case "REFUSED":
+				f.overloadRcode = dns.RcodeRefused
+			case "SERVFAIL":
+				f.overloadRcode = dns.RcodeServerFailure
+			default:
+				return c.Errf("invalid max_concurrent rcode '%s'", args[1])
+			}
+		}
+	case "failover":
+		rcodes := c.RemainingArgs()
+		if len(rcodes) == 0 {
+			return c.ArgErr()
+		}
+		f.failRcodes = make(map[int]bool)
+		for _, a := range rcodes {
+			rc, ok := dns.StringToRcode[strings.ToUpper(a)]
+			if !ok {
+				return c.Errf("unknown rcode '%s'", a)
+			}
+			if rc == dns.RcodeSuccess {
+				return c.Errf("can't failover on rcode '%s'", a)
+			}
+			f.failRcodes[rc] = true
+		}
+	case "parallel":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		n, err := strconv.Atoi(c.Val())
+		if err != nil {
+			return err
+		}
+		if n < 1 {
+			return fmt.Errorf("parallel must be at least 1: %d", n)
+		}
+		f.parallel = n
+	case "hedge":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		if x := c.Val(); strings.HasPrefix(x, "p") {
+			p, err := strconv.ParseFloat(x[1:], 64)
+			if err != nil || p <= 0 || p >= 100 {
+				return c.Errf("invalid hedge percentile '%s'", x)
+			}
+			f.hedgePercentile = p
+			f.latency = new(latency)
+		} else {
+			dur, err := time.ParseDuration(x)
+			if err != nil {
+				return err
+			}
+			if dur <= 0 {
+				return fmt.Errorf("hedge must be positive: %s", dur)
+			}
+			f.hedgeDelay = dur
+		}
+		if c.NextArg() {
+			return c.ArgErr()
+		}
+	case "reload":
+		if !c.NextArg() {
+			return c.ArgErr()
+		}
+		dur, err := time.ParseDuration(c.Val())
+		if err != nil {
+			return err
+		}
+		if dur < 0 {
+			return fmt.Errorf("reload can't be negative: %s", dur)
+		}
+		f.reload = dur
+	case "srv":
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 2 {
+			return c.ArgErr()
+		}
+		trans, name := parse.Transport(args[0])
+		switch trans {
+		case transport.DNS, transport.TLS, transport.QUIC:
+		default:
+			return c.Errf("unsupported srv transport '%s'", trans)
+		}
+		f.srv = &srvName{name: plugin.Name(name).Normalize(), trans: trans, interval: defaultSRVInterval}
+		if len(args) == 2 {
+			dur, err := time.ParseDuration(args[1])
+			if err != nil {
+				return err
+			}
+			if dur <= 0 {
+				return fmt.Errorf("srv interval must be positive: %s", dur)
+			}
+			f.srv.interval = dur
+		}
+	case "route":
+		if f.group {
+			return c.Errf("route can't be used in a route")
+		}
+		// The stanza, with its block, is parsed later, like a route in the routes file.
+		f.routeSpecs = append(f.routeSpecs, routeTokens(c))
+	case "routes":
+		if f.group {
+			return c.Errf("routes can't be used in a route")
+		}
+		args := c.RemainingArgs()
+		if len(args) == 0 || len(args) > 2 {
+			return c.ArgErr()
+		}
+		f.routeFile = &source{file: args[0]}
+		f.routeReload = defaultRoutesReload
+		if len(args) == 2 {
+			dur, err := time.ParseDuration(args[1])
+			if err != nil {
+				return err
+			}
+			if dur < 0 {
+				return fmt.Errorf("routes reload can't be negative: %s", dur)
+			}
+			f.routeReload = dur
+		}
 
 	default:
 		return c.Errf("unknown property '%s'", c.Val())
@@ -228,4 +491,29 @@ func parseBlock(c *caddyfile.Dispenser, f *Forward) error {
 	return nil
 }
 
-const max = 15 // Maximum number of upstreams.
+// parseHealthQuery parses the value of the domain, type or rcode option of health_check.
+func parseHealthQuery(c *caddyfile.Dispenser, f *Forward, opt, val string) error {
+	switch opt {
+	case "domain":
+		f.hcOpts.domain = plugin.Host(val).Normalize()
+	case "type":
+		qtype, ok := dns.StringToType[strings.ToUpper(val)]
+		if !ok {
+			return c.Errf("unknown type '%s'", val)
+		}
+		f.hcOpts.qtype = qtype
+	case "rcode":
+		rc, ok := dns.StringToRcode[strings.ToUpper(val)]
+		if !ok {
+			return c.Errf("unknown rcode '%s'", val)
+		}
+		f.hcOpts.rcodes = append(f.hcOpts.rcodes, rc)
+	}
+	return nil
+}
+
+const (
+	max                 = 15               // Maximum number of upstreams.
+	defaultPassiveMin   = 10               // Minimum number of queries in the window before passive health checking kicks in.
+	defaultRoutesReload = 30 * time.Second // How often the routes file is checked for changes.
+)
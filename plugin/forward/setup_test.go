@@ -6,8 +6,10 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
 )
 
 func TestSetup(t *testing.T) {
@@ -91,6 +93,9 @@ func TestSetupTLS(t *testing.T) {
 				tls
 			}`, false, "", ""},
 		{`forward . tls://127.0.0.1`, false, "", ""},
+		{`forward . quic://127.0.0.1 {
+				tls_servername dns
+			}`, false, "dns", ""},
 	}
 
 	for i, test := range tests {
@@ -172,3 +177,143 @@ nameserver 10.10.255.253`), 0666); err != nil {
 		}
 	}
 }
+
+func TestSetupHealthCheck(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		interval  time.Duration
+		opts      hcOptions
+		window    time.Duration
+		ratio     float64
+		min       int
+	}{
+		{"forward . 127.0.0.1\n", false, hcInterval, hcOptions{domain: ".", qtype: dns.TypeNS}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhealth_check 5s\n}\n", false, 5 * time.Second, hcOptions{domain: ".", qtype: dns.TypeNS}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhealth_check 1s no_rec domain example.org type a rcode NOERROR rcode nxdomain tcp\n}\n", false, time.Second,
+			hcOptions{domain: "example.org.", qtype: dns.TypeA, noRec: true, rcodes: []int{dns.RcodeSuccess, dns.RcodeNameError}, tcp: true}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\npassive_health 30s 0.5\n}\n", false, hcInterval, hcOptions{domain: ".", qtype: dns.TypeNS}, 30 * time.Second, 0.5, defaultPassiveMin},
+		{"forward . 127.0.0.1 {\npassive_health 1m 0.2 100\n}\n", false, hcInterval, hcOptions{domain: ".", qtype: dns.TypeNS}, time.Minute, 0.2, 100},
+		// negative
+		{"forward . 127.0.0.1 {\nhealth_check\n}\n", true, 0, hcOptions{}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhealth_check 1s domain\n}\n", true, 0, hcOptions{}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhealth_check 1s type AAAAA\n}\n", true, 0, hcOptions{}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhealth_check 1s rcode OK\n}\n", true, 0, hcOptions{}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\nhealth_check 1s udp\n}\n", true, 0, hcOptions{}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\npassive_health 30s\n}\n", true, 0, hcOptions{}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\npassive_health 0s 0.5\n}\n", true, 0, hcOptions{}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\npassive_health 30s 1.5\n}\n", true, 0, hcOptions{}, 0, 0, 0},
+		{"forward . 127.0.0.1 {\npassive_health 30s 0.5 0\n}\n", true, 0, hcOptions{}, 0, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if f.hcInterval != test.interval {
+			t.Errorf("Test %d: expected health check interval %s, got %s", i, test.interval, f.hcInterval)
+		}
+		if !reflect.DeepEqual(f.hcOpts, test.opts) {
+			t.Errorf("Test %d: expected health check %+v, got %+v", i, test.opts, f.hcOpts)
+		}
+		if !reflect.DeepEqual(f.proxies[0].health.(*dnsHc).opts, test.opts) {
+			t.Errorf("Test %d: expected the health check of the upstream to be set", i)
+		}
+		if f.passiveWindow != test.window || f.passiveRatio != test.ratio || f.passiveMin != test.min {
+			t.Errorf("Test %d: expected passive health %s %g %d, got %s %g %d", i, test.window, test.ratio, test.min, f.passiveWindow, f.passiveRatio, f.passiveMin)
+		}
+		if (test.window > 0) != (f.proxies[0].passive != nil) {
+			t.Errorf("Test %d: expected passive health checking of the upstream to be set", i)
+		}
+	}
+}
+
+func TestSetupMaxConcurrent(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		max       int64
+		rcode     int
+	}{
+		{"forward . 127.0.0.1\n", false, 0, dns.RcodeRefused},
+		{"forward . 127.0.0.1 {\nmax_concurrent 1000\n}\n", false, 1000, dns.RcodeRefused},
+		{"forward . 127.0.0.1 {\nmax_concurrent 1000 servfail\n}\n", false, 1000, dns.RcodeServerFailure},
+		// negative
+		{"forward . 127.0.0.1 {\nmax_concurrent\n}\n", true, 0, 0},
+		{"forward . 127.0.0.1 {\nmax_concurrent -1\n}\n", true, 0, 0},
+		{"forward . 127.0.0.1 {\nmax_concurrent 10 NXDOMAIN\n}\n", true, 0, 0},
+		{"forward . 127.0.0.1 {\nmax_concurrent 10 REFUSED 1\n}\n", true, 0, 0},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if f.maxConcurrent != test.max || f.overloadRcode != test.rcode {
+			t.Errorf("Test %d: expected max_concurrent %d %d, got %d %d", i, test.max, test.rcode, f.maxConcurrent, f.overloadRcode)
+		}
+	}
+}
+
+func TestSetupUpstreams(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		proxies   int
+		reload    time.Duration
+		srv       *srvName
+	}{
+		{"forward . 127.0.0.1 {\nreload 10s\n}\n", false, 1, 10 * time.Second, nil},
+		{"forward . {\nsrv _dns._udp.Example.org\n}\n", false, 0, 0, &srvName{name: "_dns._udp.example.org.", trans: "dns", interval: defaultSRVInterval}},
+		{"forward . 127.0.0.1 {\nsrv tls://_dns._tcp.example.org 1m\n}\n", false, 1, 0, &srvName{name: "_dns._tcp.example.org.", trans: "tls", interval: time.Minute}},
+		// negative
+		{"forward .\n", true, 0, 0, nil},
+		{"forward . 127.0.0.1 {\nreload\n}\n", true, 0, 0, nil},
+		{"forward . 127.0.0.1 {\nreload -1s\n}\n", true, 0, 0, nil},
+		{"forward . {\nsrv\n}\n", true, 0, 0, nil},
+		{"forward . {\nsrv _dns._udp.example.org 0s\n}\n", true, 0, 0, nil},
+		{"forward . {\nsrv grpc://_dns._tcp.example.org\n}\n", true, 0, 0, nil},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		f, err := parseForward(c)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error but found none for input %s", i, test.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error but found one for input %s, got: %v", i, test.input, err)
+			continue
+		}
+		if f.Len() != test.proxies {
+			t.Errorf("Test %d: expected %d proxies, got %d", i, test.proxies, f.Len())
+		}
+		if f.reload != test.reload {
+			t.Errorf("Test %d: expected reload %s, got %s", i, test.reload, f.reload)
+		}
+		if !reflect.DeepEqual(f.srv, test.srv) {
+			t.Errorf("Test %d: expected srv %+v, got %+v", i, test.srv, f.srv)
+		}
+	}
+}
@@ -0,0 +1,287 @@
+package forward
+
+import (
+	"context"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/parse"
+	"github.com/coredns/coredns/plugin/pkg/transport"
+)
+
+// source is one of the TO arguments: an address, or a resolv.conf-style file that is re-read when it
+// changes.
+type source struct {
+	file  string // empty for an address
+	mtime time.Time
+	size  int64
+	addrs []string // the address, or the addresses last read from the file
+}
+
+// srvName is the SRV name the upstreams are discovered with.
+type srvName struct {
+	name     string
+	trans    string
+	interval time.Duration
+	addrs    []string // the addresses found last
+}
+
+// lookupSRV returns the addresses of the targets of the SRV records of name. It's a variable so tests
+// can replace it.
+var lookupSRV = func(ctx context.Context, name string) ([]string, error) {
+	_, srvs, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the order stable between lookups, the weights shuffle the records within a priority.
+	sort.SliceStable(srvs, func(i, j int) bool {
+		if srvs[i].Priority != srvs[j].Priority {
+			return srvs[i].Priority < srvs[j].Priority
+		}
+		return srvs[i].Target < srvs[j].Target
+	})
+	var addrs []string
+	for _, s := range srvs {
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, s.Target)
+		if err != nil {
+			log.Warningf("Failed to resolve SRV target %s: %s", s.Target, err)
+			continue
+		}
+		for _, ip := range ips {
+			addrs = append(addrs, net.JoinHostPort(ip.String(), strconv.Itoa(int(s.Port))))
+		}
+	}
+	return addrs, nil
+}
+
+// newSource returns the source for the TO argument to.
+func newSource(to string) (*source, error) {
+	addrs, err := parse.HostPortOrFile(to)
+	if err != nil {
+		return nil, err
+	}
+	s := &source{addrs: addrs}
+	if _, host := parse.Transport(to); !isAddr(host) {
+		s.file = host
+		if fi, err := os.Stat(host); err == nil {
+			s.mtime, s.size = fi.ModTime(), fi.Size()
+		}
+	}
+	return s, nil
+}
+
+// isAddr returns true if host is an IP address, with or without a port.
+func isAddr(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return net.ParseIP(host) != nil
+}
+
+// dynamic returns true if the upstreams, or the routing table, can change while running.
+func (f *Forward) dynamic() bool {
+	return f.srv != nil || (f.reload > 0 && f.hasFiles()) || (f.routeFile != nil && f.routeReload > 0)
+}
+
+func (f *Forward) hasFiles() bool {
+	for _, s := range f.to {
+		if s.file != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// watch checks the files in TO and the routes file for changes, and looks up the SRV name, until
+// f.stop is closed.
+func (f *Forward) watch() {
+	defer f.wg.Done()
+
+	var reload, lookup, routes <-chan time.Time
+	if f.reload > 0 && f.hasFiles() {
+		t := time.NewTicker(f.reload)
+		defer t.Stop()
+		reload = t.C
+	}
+	if f.srv != nil {
+		t := time.NewTicker(f.srv.interval)
+		defer t.Stop()
+		lookup = t.C
+	}
+	if f.routeFile != nil && f.routeReload > 0 {
+		t := time.NewTicker(f.routeReload)
+		defer t.Stop()
+		routes = t.C
+	}
+
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-reload:
+			if f.reloadFiles() {
+				f.update()
+			}
+		case <-lookup:
+			if f.lookup() {
+				f.update()
+			}
+		case <-routes:
+			f.reloadRoutes()
+		}
+	}
+}
+
+// reloadFiles re-reads the files in TO that changed since they were last read. It returns true if the
+// addresses in any of them changed.
+func (f *Forward) reloadFiles() bool {
+	changed := false
+	for _, s := range f.to {
+		if s.file == "" {
+			continue
+		}
+		fi, err := os.Stat(s.file)
+		if err != nil {
+			log.Warningf("Failed to check %s, keeping its upstreams: %s", s.file, err)
+			continue
+		}
+		if fi.ModTime().Equal(s.mtime) && fi.Size() == s.size {
+			continue
+		}
+		s.mtime, s.size = fi.ModTime(), fi.Size()
+
+		addrs, err := parse.HostPortOrFile(s.file)
+		if err != nil {
+			log.Warningf("Failed to read %s, keeping its upstreams: %s", s.file, err)
+			continue
+		}
+		if len(addrs) == 0 {
+			log.Warningf("No nameservers in %s, keeping its upstreams", s.file)
+			continue
+		}
+		if !equal(addrs, s.addrs) {
+			s.addrs = addrs
+			changed = true
+		}
+	}
+	return changed
+}
+
+// lookup looks up the SRV name. It returns true if the addresses changed.
+func (f *Forward) lookup() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+
+	found, err := lookupSRV(ctx, f.srv.name)
+	if err != nil {
+		log.Warningf("Failed to look up %s, keeping its upstreams: %s", f.srv.name, err)
+		return false
+	}
+	if len(found) == 0 {
+		log.Warningf("No upstreams found for %s, keeping its upstreams", f.srv.name)
+		return false
+	}
+	addrs := make([]string, len(found))
+	for i, a := range found {
+		addrs[i] = a
+		if f.srv.trans != transport.DNS {
+			addrs[i] = f.srv.trans + "://" + a
+		}
+	}
+	if equal(addrs, f.srv.addrs) {
+		return false
+	}
+	f.srv.addrs = addrs
+	return true
+}
+
+// addresses returns the addresses of all upstreams, in the order of TO followed by those found with SRV
+// records.
+func (f *Forward) addresses() []string {
+	var addrs []string
+	for _, s := range f.to {
+		addrs = append(addrs, s.addrs...)
+	}
+	if f.srv != nil {
+		addrs = append(addrs, f.srv.addrs...)
+	}
+	return addrs
+}
+
+// update replaces the proxies with ones for the current addresses. The proxies for addresses that are
+// still there are kept as they are, with their health state and cached connections. New proxies are
+// started, and the removed ones are stopped.
+func (f *Forward) update() {
+	old := f.upstreams()
+	byKey := make(map[string]*Proxy, len(old))
+	for _, p := range old {
+		byKey[key(p.trans, p.addr)] = p
+	}
+
+	var (
+		list []*Proxy
+		seen = map[string]bool{}
+	)
+	for _, a := range f.addresses() {
+		trans, h := parse.Transport(a)
+		k := key(trans, h)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		if len(list) == max {
+			log.Warningf("More than %d upstreams, ignoring %s", max, h)
+			continue
+		}
+		if p, ok := byKey[k]; ok {
+			list = append(list, p)
+			continue
+		}
+		p := NewProxy(h, trans)
+		f.configure(p)
+		p.start(f.hcInterval)
+		log.Infof("Added upstream %s", h)
+		list = append(list, p)
+	}
+
+	f.proxyMu.Lock()
+	f.proxies = list
+	f.proxyMu.Unlock()
+
+	kept := make(map[*Proxy]bool, len(list))
+	for _, p := range list {
+		kept[p] = true
+	}
+	for _, p := range old {
+		if kept[p] {
+			continue
+		}
+		// Queries in flight may still use p, its transport is stopped by the finalizer.
+		p.close()
+		deleteMetrics(p.addr)
+		log.Infof("Removed upstream %s", p.addr)
+	}
+}
+
+// key returns the key identifying the upstream at addr, using trans.
+func key(trans, addr string) string { return trans + "://" + addr }
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	defaultSRVInterval = 30 * time.Second
+	lookupTimeout      = 5 * time.Second
+)
@@ -0,0 +1,161 @@
+package forward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func addrs(f *Forward) []string {
+	var a []string
+	for _, p := range f.upstreams() {
+		a = append(a, p.addr)
+	}
+	return a
+}
+
+func TestReloadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	resolv := filepath.Join(dir, "resolv.conf")
+	if err := ioutil.WriteFile(resolv, []byte("nameserver 10.0.0.1\nnameserver 10.0.0.2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := caddy.NewTestController("dns", "forward . 127.0.0.1 "+resolv+" {\nreload 1s\n}\n")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	defer f.OnShutdown()
+	if x := addrs(f); !equal(x, []string{"127.0.0.1:53", "10.0.0.1:53", "10.0.0.2:53"}) {
+		t.Fatalf("Expected the upstreams in TO, got %v", x)
+	}
+	kept := f.upstreams()[1]
+	InflightGauge.WithLabelValues("10.0.0.2:53").Set(1)
+
+	if f.reloadFiles() {
+		t.Errorf("Expected no change when the file didn't change")
+	}
+
+	if err := ioutil.WriteFile(resolv, []byte("nameserver 10.0.0.1\nnameserver 10.0.0.3\nnameserver 10.0.0.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !f.reloadFiles() {
+		t.Fatalf("Expected a change after the file was rewritten")
+	}
+	f.update()
+	if x := addrs(f); !equal(x, []string{"127.0.0.1:53", "10.0.0.1:53", "10.0.0.3:53", "10.0.0.4:53"}) {
+		t.Errorf("Expected the upstreams from the new file, got %v", x)
+	}
+	if f.upstreams()[1] != kept {
+		t.Errorf("Expected the proxy for an upstream that is still there to be kept")
+	}
+	if InflightGauge.DeleteLabelValues("10.0.0.2:53") {
+		t.Errorf("Expected the metrics of the removed upstream to be deleted")
+	}
+
+	// An empty file, as written halfway an update, keeps the upstreams we have.
+	if err := ioutil.WriteFile(resolv, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if f.reloadFiles() {
+		t.Errorf("Expected no change for an empty file")
+	}
+}
+
+func TestLookupSRV(t *testing.T) {
+	var (
+		found []string
+		err   error
+	)
+	defer func(l func(context.Context, string) ([]string, error)) { lookupSRV = l }(lookupSRV)
+	lookupSRV = func(ctx context.Context, name string) ([]string, error) {
+		if name != "_dns._udp.example.org." {
+			t.Errorf("Expected the SRV name to be looked up, got %s", name)
+		}
+		return found, err
+	}
+
+	found = []string{"10.0.0.1:53", "10.0.0.2:5353"}
+	c := caddy.NewTestController("dns", "forward . 127.0.0.1 {\nsrv _dns._udp.example.org 1h\n}\n")
+	f, e := parseForward(c)
+	if e != nil {
+		t.Fatalf("Failed to create forwarder: %s", e)
+	}
+	static := f.upstreams()[0]
+	if e := f.OnStartup(); e != nil {
+		t.Fatalf("Failed to start forwarder: %s", e)
+	}
+	defer f.OnShutdown()
+	if x := addrs(f); !equal(x, []string{"127.0.0.1:53", "10.0.0.1:53", "10.0.0.2:5353"}) {
+		t.Fatalf("Expected the upstreams found at startup, got %v", x)
+	}
+
+	found = []string{"10.0.0.2:5353", "10.0.0.3:53"}
+	if !f.lookup() {
+		t.Fatalf("Expected a change after the SRV records changed")
+	}
+	f.update()
+	if x := addrs(f); !equal(x, []string{"127.0.0.1:53", "10.0.0.2:5353", "10.0.0.3:53"}) {
+		t.Errorf("Expected the upstreams found, got %v", x)
+	}
+	if f.upstreams()[0] != static {
+		t.Errorf("Expected the proxy for the address in TO to be kept")
+	}
+
+	found, err = nil, errors.New("no such host")
+	if f.lookup() {
+		t.Errorf("Expected no change when the lookup fails")
+	}
+	if f.Len() != 3 {
+		t.Errorf("Expected the upstreams to be kept when the lookup fails, got %d", f.Len())
+	}
+}
+
+func TestUpdateMax(t *testing.T) {
+	f := New()
+	f.to = []*source{{}}
+	for i := 0; i < max+5; i++ {
+		f.to[0].addrs = append(f.to[0].addrs, fmt.Sprintf("10.0.%d.1:53", i))
+	}
+	f.update()
+	defer f.OnShutdown()
+	if f.Len() != max {
+		t.Errorf("Expected %d upstreams, got %d", max, f.Len())
+	}
+}
+
+func TestNoUpstreams(t *testing.T) {
+	defer func(l func(context.Context, string) ([]string, error)) { lookupSRV = l }(lookupSRV)
+	lookupSRV = func(ctx context.Context, name string) ([]string, error) { return nil, errors.New("no such host") }
+
+	c := caddy.NewTestController("dns", "forward . {\npolicy round_robin\nsrv _dns._udp.example.org\n}\n")
+	f, err := parseForward(c)
+	if err != nil {
+		t.Fatalf("Failed to create forwarder: %s", err)
+	}
+	if err := f.OnStartup(); err != nil {
+		t.Fatalf("Failed to start forwarder: %s", err)
+	}
+	defer f.OnShutdown()
+
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	rcode, err := f.ServeDNS(context.TODO(), &test.ResponseWriter{}, m)
+	if rcode != dns.RcodeServerFailure || err != ErrNoForward {
+		t.Errorf("Expected SERVFAIL and %q without upstreams, got %d and %v", ErrNoForward, rcode, err)
+	}
+}
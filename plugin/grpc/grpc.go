@@ -3,14 +3,18 @@ package grpc
 import (
 	"context"
 	"crypto/tls"
+	"strings"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/debug"
+	"github.com/coredns/coredns/plugin/pkg/trace"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
 	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc/metadata"
 )
 
 // GRPC represents a plugin instance that can proxy requests to another (DNS) server via gRPC protocol.
@@ -58,21 +62,26 @@ func (g *GRPC) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 		proxy := list[i]
 		i++
 
+		qctx := ctx
 		if span != nil {
-			child = span.Tracer().StartSpan("query", ot.ChildOf(span.Context()))
-			ctx = ot.ContextWithSpan(ctx, child)
+			child = span.Tracer().StartSpan("query", ot.ChildOf(span.Context()), ext.SpanKindRPCClient)
+			child.SetTag(trace.TagUpstream, proxy.addr)
+			span.SetTag(trace.TagRetries, i-1)
+			qctx = withSpanMetadata(ot.ContextWithSpan(ctx, child), child)
 		}
 
-		ret, err = proxy.query(ctx, r)
+		ret, err = proxy.query(qctx, r)
+		if child != nil {
+			if err != nil {
+				ext.Error.Set(child, true)
+			}
+			child.Finish()
+		}
 		if err != nil {
 			// Continue with the next proxy
 			continue
 		}
 
-		if child != nil {
-			child.Finish()
-		}
-
 		// Check if the reply is correct; if not return FormErr.
 		if !state.Match(ret) {
 			debug.Hexdumpf(ret, "Wrong reply for id: %d, %s %d", ret.Id, state.QName(), state.QType())
@@ -128,4 +137,28 @@ func (g *GRPC) isAllowedDomain(name string) bool {
 // List returns a set of proxies to be used for this client depending on the policy in p.
 func (g *GRPC) list() []*Proxy { return g.p.List(g.proxies) }
 
+// withSpanMetadata adds the context of span to the metadata of the outgoing gRPC request, so the
+// server continues the trace. With the otlp tracer these are the W3C traceparent and tracestate headers.
+func withSpanMetadata(ctx context.Context, span ot.Span) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	if err := span.Tracer().Inject(span.Context(), ot.HTTPHeaders, metadataWriter(md)); err != nil {
+		return ctx
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// metadataWriter implements ot.TextMapWriter for gRPC metadata.
+type metadataWriter metadata.MD
+
+// Set implements the ot.TextMapWriter interface.
+func (m metadataWriter) Set(key, val string) {
+	key = strings.ToLower(key)
+	m[key] = append(m[key], val)
+}
+
 const defaultTimeout = 5 * time.Second
@@ -10,6 +10,8 @@ import (
 	"github.com/coredns/coredns/plugin/test"
 
 	"github.com/miekg/dns"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"google.golang.org/grpc/metadata"
 )
 
 func TestGRPC(t *testing.T) {
@@ -73,3 +75,17 @@ func TestGRPC(t *testing.T) {
 		})
 	}
 }
+
+func TestGRPCSpanMetadata(t *testing.T) {
+	span := mocktracer.New().StartSpan("query")
+	ctx := metadata.NewOutgoingContext(context.TODO(), metadata.Pairs("x-client", "test"))
+	ctx = withSpanMetadata(ctx, span)
+
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if len(md.Get("x-client")) != 1 {
+		t.Errorf("Expected the existing metadata to be kept, got %v", md)
+	}
+	if len(md.Get("mockpfx-ids-traceid")) != 1 || len(md.Get("mockpfx-ids-spanid")) != 1 {
+		t.Errorf("Expected the span context in the metadata, got %v", md)
+	}
+}
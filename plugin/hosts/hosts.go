@@ -36,6 +36,17 @@ func (h Hosts) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (
 		}
 	}
 
+	if target := h.LookupStaticCNAME(qname); target != "" && state.QType() != dns.TypePTR {
+		answers = h.cname(qname, target, state.QType())
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Authoritative = true
+		m.Answer = answers
+
+		w.WriteMsg(m)
+		return dns.RcodeSuccess, nil
+	}
+
 	switch state.QType() {
 	case dns.TypePTR:
 		names := h.LookupStaticAddr(dnsutil.ExtractAddressFromReverse(qname))
@@ -95,6 +106,39 @@ func (h Hosts) otherRecordsExist(qtype uint16, qname string) bool {
 // Name implements the plugin.Handle interface.
 func (h Hosts) Name() string { return "hosts" }
 
+// cname returns the CNAME for qname pointing to target. For A and AAAA queries the target is
+// followed as long as it can be resolved from the hosts files, adding the records along the way.
+func (h Hosts) cname(qname, target string, qtype uint16) []dns.RR {
+	answers := []dns.RR{}
+	for i := 0; i < maxCNAMEChain; i++ {
+		r := new(dns.CNAME)
+		r.Hdr = dns.RR_Header{Name: qname, Rrtype: dns.TypeCNAME,
+			Class: dns.ClassINET, Ttl: h.options.ttl}
+		r.Target = target
+		answers = append(answers, r)
+
+		if qtype != dns.TypeA && qtype != dns.TypeAAAA {
+			return answers
+		}
+		if plugin.Zones(h.Origins).Matches(target) == "" {
+			return answers
+		}
+
+		next := h.LookupStaticCNAME(target)
+		if next == "" {
+			if qtype == dns.TypeA {
+				return append(answers, a(target, h.options.ttl, h.LookupStaticHostV4(target))...)
+			}
+			return append(answers, aaaa(target, h.options.ttl, h.LookupStaticHostV6(target))...)
+		}
+		qname, target = target, next
+	}
+	return answers
+}
+
+// maxCNAMEChain is the maximum number of aliases followed when answering a query for an alias.
+const maxCNAMEChain = 8
+
 // a takes a slice of net.IPs and returns a slice of A RRs.
 func a(zone string, ttl uint32, ips []net.IP) []dns.RR {
 	answers := []dns.RR{}
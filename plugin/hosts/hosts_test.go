@@ -102,3 +102,95 @@ const hostsExample = `
 reload 5s
 timeout 3600
 `
+
+func TestLookupAlias(t *testing.T) {
+	h := Hosts{
+		Next: test.ErrorHandler(),
+		Hostsfile: &Hostsfile{
+			Origins: []string{"."},
+			hmap:    newHostsMap(),
+			options: newOptions(),
+		},
+	}
+	h.parseReader(strings.NewReader(hostsAliasExample))
+
+	ctx := context.TODO()
+
+	for _, tc := range hostsAliasTestCases {
+		m := tc.Msg()
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		_, err := h.ServeDNS(ctx, rec, m)
+		if err != nil {
+			t.Errorf("Expected no error, got %v", err)
+			return
+		}
+
+		resp := rec.Msg
+		if err := test.SortAndCheck(resp, tc); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+var hostsAliasTestCases = []test.Case{
+	{
+		Qname: "a.dev.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("a.dev.local. 3600	IN	A 10.0.0.1"),
+		},
+	},
+	{
+		Qname: "b.a.dev.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("b.a.dev.local. 3600	IN	A 10.0.0.1"),
+		},
+	},
+	{
+		Qname: "exact.dev.local.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("exact.dev.local. 3600	IN	A 10.0.0.2"),
+		},
+	},
+	{
+		Qname: "www.example.org.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("example.org. 3600	IN	A 10.0.0.3"),
+			test.CNAME("www.example.org. 3600	IN	CNAME example.org."),
+		},
+	},
+	{
+		Qname: "www.example.org.", Qtype: dns.TypeAAAA,
+		Answer: []dns.RR{
+			test.CNAME("www.example.org. 3600	IN	CNAME example.org."),
+		},
+	},
+	{
+		Qname: "www.example.org.", Qtype: dns.TypeMX,
+		Answer: []dns.RR{
+			test.CNAME("www.example.org. 3600	IN	CNAME example.org."),
+		},
+	},
+	{
+		Qname: "x.svc.example.org.", Qtype: dns.TypeA,
+		Answer: []dns.RR{
+			test.A("example.org. 3600	IN	A 10.0.0.3"),
+			test.CNAME("www.example.org. 3600	IN	CNAME example.org."),
+			test.CNAME("x.svc.example.org. 3600	IN	CNAME www.example.org."),
+		},
+	},
+	{
+		Qname: "3.0.0.10.in-addr.arpa.", Qtype: dns.TypePTR,
+		Answer: []dns.RR{
+			test.PTR("3.0.0.10.in-addr.arpa. 3600 PTR example.org."),
+		},
+	},
+}
+
+const hostsAliasExample = `
+10.0.0.1 *.dev.local
+10.0.0.2 exact.dev.local
+10.0.0.3 example.org
+alias example.org www.example.org
+alias www.example.org *.svc.example.org
+`
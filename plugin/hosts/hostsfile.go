@@ -10,13 +10,17 @@ import (
 	"bufio"
 	"bytes"
 	"io"
+	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
 )
 
 func parseLiteralIP(addr string) net.IP {
@@ -60,6 +64,10 @@ type hostsMap struct {
 	byNameV4 map[string][]net.IP
 	byNameV6 map[string][]net.IP
 
+	// Key for the alias target must be a host name, it is the name
+	// that gets a CNAME to the target.
+	byNameCNAME map[string]string
+
 	// Key for the list of host names must be a literal IP address
 	// including IPv6 address with zone identifier.
 	// We don't support old-classful IP address notation.
@@ -73,14 +81,15 @@ const (
 
 func newHostsMap() *hostsMap {
 	return &hostsMap{
-		byNameV4: make(map[string][]net.IP),
-		byNameV6: make(map[string][]net.IP),
-		byAddr:   make(map[string][]string),
+		byNameV4:    make(map[string][]net.IP),
+		byNameV6:    make(map[string][]net.IP),
+		byNameCNAME: make(map[string]string),
+		byAddr:      make(map[string][]string),
 	}
 }
 
 // Len returns the total number of addresses in the hostmap, this includes
-// V4/V6 and any reverse addresses.
+// V4/V6, aliases and any reverse addresses.
 func (h *hostsMap) Len() int {
 	l := 0
 	for _, v4 := range h.byNameV4 {
@@ -92,7 +101,66 @@ func (h *hostsMap) Len() int {
 	for _, a := range h.byAddr {
 		l += len(a)
 	}
-	return l
+	return l + len(h.byNameCNAME)
+}
+
+// merge adds the entries of m to h. Aliases already in h take precedence.
+func (h *hostsMap) merge(m *hostsMap) {
+	for name, ips := range m.byNameV4 {
+		h.byNameV4[name] = append(h.byNameV4[name], ips...)
+	}
+	for name, ips := range m.byNameV6 {
+		h.byNameV6[name] = append(h.byNameV6[name], ips...)
+	}
+	for name, target := range m.byNameCNAME {
+		if _, ok := h.byNameCNAME[name]; !ok {
+			h.byNameCNAME[name] = target
+		}
+	}
+	for addr, names := range m.byAddr {
+		h.byAddr[addr] = append(h.byAddr[addr], names...)
+	}
+}
+
+// exists returns true if there are any entries for name.
+func (h *hostsMap) exists(name string) bool {
+	if _, ok := h.byNameV4[name]; ok {
+		return true
+	}
+	if _, ok := h.byNameV6[name]; ok {
+		return true
+	}
+	_, ok := h.byNameCNAME[name]
+	return ok
+}
+
+// owner returns the name under which the entries for name are stored. This is name itself
+// if it has entries, otherwise the closest wildcard (*.example.org.) that has them. If
+// nothing is found the empty string is returned.
+func (h *hostsMap) owner(name string) string {
+	if h.exists(name) {
+		return name
+	}
+	for off, end := 0, false; !end; {
+		off, end = dns.NextLabel(name, off)
+		wildcard := "*." + name[off:]
+		if end {
+			wildcard = "*."
+		}
+		if h.exists(wildcard) {
+			return wildcard
+		}
+	}
+	return ""
+}
+
+// source is a single hosts file that is read and reloaded independently.
+type source struct {
+	hmap *hostsMap
+
+	// mtime and size are used to detect if the file has changed
+	mtime time.Time
+	size  int64
 }
 
 // Hostsfile contains known host entries.
@@ -109,44 +177,113 @@ type Hostsfile struct {
 	// We need a copy here as we want to use it to initialize the maps for parse.
 	inline *hostsMap
 
-	// path to the hosts file
-	path string
+	// paths to the hosts files and the directories that contain hosts files
+	paths []string
 
-	// mtime and size are only read and modified by a single goroutine
-	mtime time.Time
-	size  int64
+	// sources holds the parsed hosts files keyed by their path. It is only
+	// read and modified by a single goroutine.
+	sources map[string]*source
 
 	options *options
 }
 
-// readHosts determines if the cached data needs to be updated based on the size and modification time of the hostsfile.
-func (h *Hostsfile) readHosts() {
-	file, err := os.Open(h.path)
-	if err != nil {
-		// We already log a warning if the file doesn't exist or can't be opened on setup. No need to return the error here.
-		return
+// files returns the paths of all hosts files: each configured file and each regular file in a
+// configured directory. Hidden files and backup files (ending in '~') in directories are skipped.
+func (h *Hostsfile) files() []string {
+	files := []string{}
+	for _, path := range h.paths {
+		stat, err := os.Stat(path)
+		if err != nil {
+			// We already log a warning if the file doesn't exist or can't be opened on setup. No need to return the error here.
+			continue
+		}
+		if !stat.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		infos, err := ioutil.ReadDir(path)
+		if err != nil {
+			continue
+		}
+		for _, info := range infos {
+			name := info.Name()
+			if !info.Mode().IsRegular() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+				continue
+			}
+			files = append(files, filepath.Join(path, name))
+		}
 	}
-	defer file.Close()
+	return files
+}
 
-	stat, err := file.Stat()
-	h.RLock()
-	size := h.size
-	h.RUnlock()
-	if err == nil && h.mtime.Equal(stat.ModTime()) && size == stat.Size() {
+// readHosts determines if the cached data needs to be updated based on the size and modification time of the hosts files.
+// Each file is only parsed again when it has changed; when any file changed, was added or was removed the lookup maps are rebuilt.
+func (h *Hostsfile) readHosts() {
+	files := h.files()
+
+	changed := len(files) != len(h.sources)
+	sources := make(map[string]*source, len(files))
+	for _, path := range files {
+		src, ok := h.sources[path]
+		if !ok {
+			src = &source{}
+			changed = true
+		}
+		updated, err := h.readSource(path, src)
+		if err != nil {
+			// Remember unreadable files, so they don't count as new on every read. Entries
+			// from when the file was still readable are dropped.
+			if src.hmap != nil {
+				src.hmap = nil
+				changed = true
+			}
+		}
+		changed = changed || updated
+		sources[path] = src
+	}
+	if !changed {
 		return
 	}
 
-	newMap := h.parse(file)
-	log.Debugf("Parsed hosts file into %d entries", newMap.Len())
+	newMap := newHostsMap()
+	if h.inline != nil {
+		newMap.merge(h.inline)
+	}
+	for _, path := range files {
+		if src := sources[path]; src.hmap != nil {
+			newMap.merge(src.hmap)
+		}
+	}
+	log.Debugf("Parsed %d hosts files into %d entries", len(sources), newMap.Len())
 
 	h.Lock()
-
 	h.hmap = newMap
-	// Update the data cache.
-	h.mtime = stat.ModTime()
-	h.size = stat.Size()
-
 	h.Unlock()
+
+	h.sources = sources
+}
+
+// readSource parses the file at path into src if it has changed since it was last read. It returns true
+// if the file was parsed.
+func (h *Hostsfile) readSource(path string, src *source) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	if src.hmap != nil && src.mtime.Equal(stat.ModTime()) && src.size == stat.Size() {
+		return false, nil
+	}
+
+	src.hmap = h.parse(file)
+	src.mtime = stat.ModTime()
+	src.size = stat.Size()
+	return true, nil
 }
 
 func (h *Hostsfile) initInline(inline []string) {
@@ -155,10 +292,13 @@ func (h *Hostsfile) initInline(inline []string) {
 	}
 
 	h.inline = h.parse(strings.NewReader(strings.Join(inline, "\n")))
-	*h.hmap = *h.inline
+	h.hmap = newHostsMap()
+	h.hmap.merge(h.inline)
 }
 
-// Parse reads the hostsfile and populates the byName and byAddr maps.
+// Parse reads the hostsfile and populates the byName and byAddr maps. Lines with "alias" as
+// their first field are of the form "alias TARGET NAMES..." and make each of NAMES a CNAME
+// for TARGET. Names may be wildcards, i.e. "*.example.org".
 func (h *Hostsfile) parse(r io.Reader) *hostsMap {
 	hmap := newHostsMap()
 
@@ -173,6 +313,10 @@ func (h *Hostsfile) parse(r io.Reader) *hostsMap {
 		if len(f) < 2 {
 			continue
 		}
+		if string(f[0]) == "alias" {
+			h.parseAlias(hmap, f[1:])
+			continue
+		}
 		addr := parseLiteralIP(string(f[0]))
 		if addr == nil {
 			continue
@@ -192,25 +336,36 @@ func (h *Hostsfile) parse(r io.Reader) *hostsMap {
 			default:
 				continue
 			}
-			if !h.options.autoReverse {
+			if !h.options.autoReverse || strings.HasPrefix(name, "*.") {
 				continue
 			}
 			hmap.byAddr[addr.String()] = append(hmap.byAddr[addr.String()], name)
 		}
 	}
 
-	for name := range h.hmap.byNameV4 {
-		hmap.byNameV4[name] = append(hmap.byNameV4[name], h.hmap.byNameV4[name]...)
+	return hmap
+}
+
+// parseAlias adds the aliases from f, which holds the fields of an alias line after "alias", to hmap.
+func (h *Hostsfile) parseAlias(hmap *hostsMap, f [][]byte) {
+	if len(f) < 2 {
+		return
 	}
-	for name := range h.hmap.byNameV4 {
-		hmap.byNameV6[name] = append(hmap.byNameV6[name], h.hmap.byNameV6[name]...)
+	target := absDomainName(string(f[0]))
+	if _, ok := dns.IsDomainName(target); !ok || strings.Contains(target, "*") {
+		return
 	}
-
-	for addr := range h.hmap.byAddr {
-		hmap.byAddr[addr] = append(hmap.byAddr[addr], h.hmap.byAddr[addr]...)
+	for i := 1; i < len(f); i++ {
+		name := absDomainName(string(f[i]))
+		if plugin.Zones(h.Origins).Matches(name) == "" || name == target {
+			continue
+		}
+		if _, ok := hmap.byNameCNAME[name]; ok {
+			// The first alias for a name wins.
+			continue
+		}
+		hmap.byNameCNAME[name] = target
 	}
-
-	return hmap
 }
 
 // ipVersion returns what IP version was used textually
@@ -229,17 +384,21 @@ func ipVersion(s string) int {
 }
 
 // LookupStaticHost looks up the IP addresses for the given host from the hosts file.
-func (h *Hostsfile) lookupStaticHost(hmapByName map[string][]net.IP, host string) []net.IP {
+func (h *Hostsfile) lookupStaticHost(v6 bool, host string) []net.IP {
 	fqhost := absDomainName(host)
 
 	h.RLock()
 	defer h.RUnlock()
 
+	hmapByName := h.hmap.byNameV4
+	if v6 {
+		hmapByName = h.hmap.byNameV6
+	}
 	if len(hmapByName) == 0 {
 		return nil
 	}
 
-	ips, ok := hmapByName[fqhost]
+	ips, ok := hmapByName[h.hmap.owner(fqhost)]
 	if !ok {
 		return nil
 	}
@@ -250,12 +409,23 @@ func (h *Hostsfile) lookupStaticHost(hmapByName map[string][]net.IP, host string
 
 // LookupStaticHostV4 looks up the IPv4 addresses for the given host from the hosts file.
 func (h *Hostsfile) LookupStaticHostV4(host string) []net.IP {
-	return h.lookupStaticHost(h.hmap.byNameV4, host)
+	return h.lookupStaticHost(false, host)
 }
 
 // LookupStaticHostV6 looks up the IPv6 addresses for the given host from the hosts file.
 func (h *Hostsfile) LookupStaticHostV6(host string) []net.IP {
-	return h.lookupStaticHost(h.hmap.byNameV6, host)
+	return h.lookupStaticHost(true, host)
+}
+
+// LookupStaticCNAME looks up the alias target for the given host from the hosts file. If host isn't
+// an alias the empty string is returned.
+func (h *Hostsfile) LookupStaticCNAME(host string) string {
+	fqhost := absDomainName(host)
+
+	h.RLock()
+	defer h.RUnlock()
+
+	return h.hmap.byNameCNAME[h.hmap.owner(fqhost)]
 }
 
 // LookupStaticAddr looks up the hosts for the given address from the hosts file.
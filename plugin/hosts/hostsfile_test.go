@@ -5,7 +5,10 @@
 package hosts
 
 import (
+	"io/ioutil"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -212,7 +215,7 @@ func testStaticAddr(t *testing.T, ent staticIPEntry, h *Hostsfile) {
 		ent.out[i] = absDomainName(ent.out[i])
 	}
 	if !reflect.DeepEqual(hosts, ent.out) {
-		t.Errorf("%s, lookupStaticAddr(%s) = %v; want %v", h.path, ent.in, hosts, h)
+		t.Errorf("%s, lookupStaticAddr(%s) = %v; want %v", h.paths, ent.in, hosts, h)
 	}
 }
 
@@ -240,3 +243,86 @@ func TestHostCacheModification(t *testing.T) {
 	}
 	testStaticAddr(t, entip, h)
 }
+
+func TestReadHostsFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	hostsd := filepath.Join(dir, "hosts.d")
+	if err := os.Mkdir(hostsd, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(path, content string) {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, "hosts"), "10.0.0.1 odin\n")
+	write(filepath.Join(hostsd, "a"), "10.0.0.2 thor\n")
+	write(filepath.Join(hostsd, ".hidden"), "10.0.0.3 loki\n")
+
+	h := &Hostsfile{
+		Origins: []string{"."},
+		hmap:    newHostsMap(),
+		inline:  newHostsMap(),
+		paths:   []string{filepath.Join(dir, "hosts"), hostsd},
+		sources: make(map[string]*source),
+		options: newOptions(),
+	}
+	h.inline.byNameV4["freya."] = []net.IP{net.ParseIP("10.0.0.4")}
+
+	h.readHosts()
+	for name, want := range map[string]int{"odin": 1, "thor": 1, "loki": 0, "freya": 1, "baldr": 0} {
+		if x := len(h.LookupStaticHostV4(name)); x != want {
+			t.Errorf("Expected %d addresses for %s, got %d", want, name, x)
+		}
+	}
+
+	// A new file in the directory is picked up, a removed one is dropped.
+	write(filepath.Join(hostsd, "b"), "10.0.0.5 baldr\n")
+	os.Remove(filepath.Join(dir, "hosts"))
+	h.readHosts()
+	for name, want := range map[string]int{"odin": 0, "thor": 1, "freya": 1, "baldr": 1} {
+		if x := len(h.LookupStaticHostV4(name)); x != want {
+			t.Errorf("Expected %d addresses for %s, got %d", want, name, x)
+		}
+	}
+	if len(h.sources) != 2 {
+		t.Errorf("Expected 2 hosts files to be read, got %d", len(h.sources))
+	}
+}
+
+func TestReadHostsUnreadable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A socket can be stat-ed, but not opened, even as root.
+	sock := filepath.Join(dir, "sock")
+	l, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	h := &Hostsfile{
+		Origins: []string{"."},
+		hmap:    newHostsMap(),
+		inline:  newHostsMap(),
+		paths:   []string{sock, filepath.Join(dir, "missing")},
+		sources: make(map[string]*source),
+		options: newOptions(),
+	}
+
+	h.readHosts()
+	hmap := h.hmap
+	h.readHosts()
+	if h.hmap != hmap {
+		t.Errorf("Expected hosts not to be rebuilt when no file changed")
+	}
+}
@@ -1,6 +1,7 @@
 package hosts
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -77,8 +78,8 @@ func hostsParse(c *caddy.Controller) (Hosts, error) {
 
 	h := Hosts{
 		Hostsfile: &Hostsfile{
-			path:    "/etc/hosts",
 			hmap:    newHostsMap(),
+			sources: make(map[string]*source),
 			options: options,
 		},
 	}
@@ -94,23 +95,12 @@ func hostsParse(c *caddy.Controller) (Hosts, error) {
 		args := c.RemainingArgs()
 
 		if len(args) >= 1 {
-			h.path = args[0]
-			args = args[1:]
-
-			if !filepath.IsAbs(h.path) && config.Root != "" {
-				h.path = filepath.Join(config.Root, h.path)
-			}
-			s, err := os.Stat(h.path)
+			path, err := hostsPath(config, args[0])
 			if err != nil {
-				if os.IsNotExist(err) {
-					log.Warningf("File does not exist: %s", h.path)
-				} else {
-					return h, c.Errf("unable to access hosts file '%s': %v", h.path, err)
-				}
-			}
-			if s != nil && s.IsDir() {
-				log.Warningf("Hosts file %q is a directory", h.path)
+				return h, c.Err(err.Error())
 			}
+			h.paths = append(h.paths, path)
+			args = args[1:]
 		}
 
 		origins := make([]string, len(c.ServerBlockKeys))
@@ -156,6 +146,18 @@ func hostsParse(c *caddy.Controller) (Hosts, error) {
 					return h, c.Errf("invalid negative duration for reload '%s'", remaining[0])
 				}
 				options.reload = reload
+			case "file":
+				remaining := c.RemainingArgs()
+				if len(remaining) == 0 {
+					return h, c.ArgErr()
+				}
+				for _, p := range remaining {
+					path, err := hostsPath(config, p)
+					if err != nil {
+						return h, c.Err(err.Error())
+					}
+					h.paths = append(h.paths, path)
+				}
 			default:
 				if len(h.Fall.Zones) == 0 {
 					line := strings.Join(append([]string{c.Val()}, c.RemainingArgs()...), " ")
@@ -167,7 +169,28 @@ func hostsParse(c *caddy.Controller) (Hosts, error) {
 		}
 	}
 
+	if len(h.paths) == 0 {
+		h.paths = []string{"/etc/hosts"}
+	}
+
 	h.initInline(inline)
 
 	return h, nil
 }
+
+// hostsPath returns the absolute path of the hosts file or directory p, relative paths are taken
+// from the root directive. A missing file only results in a warning, as it may be created later.
+func hostsPath(config *dnsserver.Config, p string) (string, error) {
+	if !filepath.IsAbs(p) && config.Root != "" {
+		p = filepath.Join(config.Root, p)
+	}
+	_, err := os.Stat(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			log.Warningf("File does not exist: %s", p)
+			return p, nil
+		}
+		return p, fmt.Errorf("unable to access hosts file '%s': %v", p, err)
+	}
+	return p, nil
+}
@@ -57,6 +57,18 @@ func TestHostsParse(t *testing.T) {
 			}`,
 			false, "/etc/hosts", []string{"miek.nl.", "10.in-addr.arpa."}, fall.Root,
 		},
+		{
+			`hosts {
+				file /tmp /etc/hosts
+			}`,
+			false, "/tmp", nil, fall.Zero,
+		},
+		{
+			`hosts /etc/hosts {
+				file
+			}`,
+			true, "/etc/hosts", nil, fall.Zero,
+		},
 		{
 			`hosts /etc/hosts {
 				fallthrough
@@ -77,8 +89,8 @@ func TestHostsParse(t *testing.T) {
 		} else if err != nil && !test.shouldErr {
 			t.Fatalf("Test %d expected no errors, but got '%v'", i, err)
 		} else if !test.shouldErr {
-			if h.path != test.expectedPath {
-				t.Fatalf("Test %d expected %v, got %v", i, test.expectedPath, h.path)
+			if h.paths[0] != test.expectedPath {
+				t.Fatalf("Test %d expected %v, got %v", i, test.expectedPath, h.paths[0])
 			}
 		} else {
 			if !h.Fall.Equal(test.expectedFallthrough) {
@@ -24,7 +24,14 @@ func (e *External) serveApex(state request.Request) (int, error) {
 			m.Extra = append(m.Extra, rr)
 		}
 	default:
-		m.Ns = []dns.RR{e.soa(state)}
+		for _, rr := range e.records[state.Zone] {
+			if rr.Header().Rrtype == state.QType() {
+				m.Answer = append(m.Answer, dns.Copy(rr))
+			}
+		}
+		if len(m.Answer) == 0 {
+			m.Ns = []dns.RR{e.soa(state)}
+		}
 	}
 
 	state.W.WriteMsg(m)
@@ -93,7 +100,7 @@ func (e *External) soa(state request.Request) *dns.SOA {
 	soa := &dns.SOA{Hdr: header,
 		Mbox:    dnsutil.Join(e.hostmaster, e.apex, state.Zone),
 		Ns:      dnsutil.Join("ns1", e.apex, state.Zone),
-		Serial:  12345, // Also dynamic?
+		Serial:  e.serial(state.Zone),
 		Refresh: 7200,
 		Retry:   1800,
 		Expire:  86400,
@@ -102,6 +109,20 @@ func (e *External) soa(state request.Request) *dns.SOA {
 	return soa
 }
 
+// serial returns the serial for zone's SOA record. Without a backend, or before it has seen any
+// data, a fixed serial is returned.
+func (e *External) serial(zone string) uint32 {
+	if e.externalSerialFunc == nil {
+		return defaultSerial
+	}
+	if s := e.externalSerialFunc(zone); s != 0 {
+		return s
+	}
+	return defaultSerial
+}
+
+const defaultSerial = 12345
+
 func (e *External) ns(state request.Request) *dns.NS {
 	header := dns.RR_Header{Name: state.Zone, Rrtype: dns.TypeNS, Ttl: e.ttl, Class: dns.ClassINET}
 	ns := &dns.NS{Hdr: header, Ns: dnsutil.Join("ns1", e.apex, state.Zone)}
@@ -1,9 +1,9 @@
 /*
 Package external implements external names for kubernetes clusters.
 
-This plugin only handles three qtypes (except the apex queries, because those are handled
-differently). We support A, AAAA and SRV request, for all other types we return NODATA or
-NXDOMAIN depending on the state of the cluster.
+This plugin only handles three qtypes (except the apex queries and zone transfers, because those
+are handled differently). We support A, AAAA and SRV request, for all other types we return NODATA
+or NXDOMAIN depending on the state of the cluster.
 
 A plugin willing to provide these services must implement the Externaler interface, although it
 likely only makes sense for the *kubernetes* plugin.
@@ -16,6 +16,7 @@ import (
 
 	"github.com/coredns/coredns/plugin"
 	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/pkg/fall"
 	"github.com/coredns/coredns/request"
 
 	"github.com/miekg/dns"
@@ -28,24 +29,35 @@ type Externaler interface {
 	External(request.Request) ([]msg.Service, int)
 	// ExternalAddress should return a string slice of addresses for the nameserving endpoint.
 	ExternalAddress(state request.Request) []dns.RR
+	// ExternalServices returns all services that should be included in a zone transfer of zone.
+	ExternalServices(zone string) []msg.Service
+	// ExternalSerial returns the serial of the zone's SOA record, it should change whenever
+	// the data returned by the other methods changes.
+	ExternalSerial(zone string) uint32
 }
 
 // External resolves Ingress and Loadbalance IPs from kubernetes clusters.
 type External struct {
 	Next  plugin.Handler
 	Zones []string
+	Fall  fall.F
 
 	hostmaster string
 	apex       string
 	ttl        uint32
 
-	externalFunc     func(request.Request) ([]msg.Service, int)
-	externalAddrFunc func(request.Request) []dns.RR
+	records    map[string][]dns.RR // extra records for the apex, keyed by zone
+	transferTo []string
+
+	externalFunc         func(request.Request) ([]msg.Service, int)
+	externalAddrFunc     func(request.Request) []dns.RR
+	externalServicesFunc func(string) []msg.Service
+	externalSerialFunc   func(string) uint32
 }
 
 // New returns a new and initialized *External.
 func New() *External {
-	e := &External{hostmaster: "hostmaster", ttl: 5, apex: "dns"}
+	e := &External{hostmaster: "hostmaster", ttl: 5, apex: "dns", records: make(map[string][]dns.RR)}
 	return e
 }
 
@@ -65,6 +77,9 @@ func (e *External) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Ms
 	state.Zone = zone
 	for _, z := range e.Zones {
 		// TODO(miek): save this in the External struct.
+		if state.Name() == z && (state.QType() == dns.TypeAXFR || state.QType() == dns.TypeIXFR) {
+			return e.transfer(ctx, state)
+		}
 		if state.Name() == z { // apex query
 			ret, err := e.serveApex(state)
 			return ret, err
@@ -77,6 +92,9 @@ func (e *External) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Ms
 	}
 
 	svc, rcode := e.externalFunc(state)
+	if len(svc) == 0 && rcode == dns.RcodeNameError && e.Fall.Through(state.Name()) {
+		return plugin.NextOrFailure(e.Name(), e.Next, ctx, w, r)
+	}
 
 	m := new(dns.Msg)
 	m.SetReply(state.Req)
@@ -147,6 +147,70 @@ var tests = []test.Case{
 			test.SOA("example.com.	5	IN	SOA	ns1.dns.example.com. hostmaster.example.com. 1499347823 7200 1800 86400 5"),
 		},
 	},
+	// Ingress
+	{
+		Qname: "www.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("www.example.com.	5	IN	A	1.2.3.5"),
+		},
+	},
+	{
+		Qname: "www.example.com.", Qtype: dns.TypeAAAA, Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.AAAA("www.example.com.	5	IN	AAAA	1:2::6"),
+		},
+	},
+	{
+		Qname: "www.example.com.", Qtype: dns.TypeSRV, Rcode: dns.RcodeSuccess,
+		Ns: []dns.RR{
+			test.SOA("example.com.	5	IN	SOA	ns1.dns.example.com. hostmaster.example.com. 1499347823 7200 1800 86400 5"),
+		},
+	},
+	// Ingress with a wildcard host
+	{
+		Qname: "shop.apps.example.com.", Qtype: dns.TypeA, Rcode: dns.RcodeSuccess,
+		Answer: []dns.RR{
+			test.A("shop.apps.example.com.	5	IN	A	1.2.3.6"),
+		},
+	},
+}
+
+func TestExternalFallthrough(t *testing.T) {
+	k := kubernetes.New([]string{"cluster.local."})
+	k.Namespaces = map[string]struct{}{"testns": {}}
+	k.APIConn = &external{}
+
+	e := New()
+	e.Zones = []string{"example.com."}
+	e.Fall.SetZonesFromArgs(nil)
+	e.Next = test.NextHandler(dns.RcodeRefused, nil)
+	e.externalFunc = k.External
+	e.externalAddrFunc = externalAddress // internal test function
+
+	tests := []struct {
+		qname string
+		rcode int
+	}{
+		{"www.example.com.", dns.RcodeSuccess},
+		{"svc1.testns.example.com.", dns.RcodeSuccess},
+		{"svc0.testns.example.com.", dns.RcodeRefused}, // NXDOMAIN is passed on to the next plugin.
+		{"no.www.example.com.", dns.RcodeRefused},
+	}
+
+	ctx := context.TODO()
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, dns.TypeA)
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+
+		rcode, _ := e.ServeDNS(ctx, w, m)
+		if rcode == dns.RcodeSuccess && w.Msg != nil {
+			rcode = w.Msg.Rcode
+		}
+		if rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rcode])
+		}
+	}
 }
 
 type external struct{}
@@ -154,6 +218,9 @@ type external struct{}
 func (external) HasSynced() bool                              { return true }
 func (external) Run()                                         { return }
 func (external) Stop() error                                  { return nil }
+func (external) IngressIndex(host string) []*object.Ingress {
+	return ingressIndexExternal[host]
+}
 func (external) EpIndexReverse(string) []*object.Endpoints    { return nil }
 func (external) SvcIndexReverse(string) []*object.Service     { return nil }
 func (external) Modified() int64                              { return 0 }
@@ -171,6 +238,15 @@ func (external) GetNamespaceByName(name string) (*api.Namespace, error) {
 	}, nil
 }
 
+var ingressIndexExternal = map[string][]*object.Ingress{
+	"www.example.com.": {
+		{Name: "ing1", Namespace: "testns", Hosts: []string{"www.example.com."}, IPs: []string{"1.2.3.5", "1:2::6"}},
+	},
+	"*.apps.example.com.": {
+		{Name: "ing2", Namespace: "testns", Hosts: []string{"*.apps.example.com."}, IPs: []string{"1.2.3.6"}},
+	},
+}
+
 var svcIndexExternal = map[string][]*object.Service{
 	"svc1.testns": {
 		{
@@ -194,6 +270,14 @@ var svcIndexExternal = map[string][]*object.Service{
 	},
 }
 
+func (external) IngressList() []*object.Ingress {
+	var ings []*object.Ingress
+	for _, ing := range ingressIndexExternal {
+		ings = append(ings, ing...)
+	}
+	return ings
+}
+
 func (external) ServiceList() []*object.Service {
 	var svcs []*object.Service
 	for _, svc := range svcIndexExternal {
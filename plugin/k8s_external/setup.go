@@ -1,14 +1,21 @@
 package external
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/coredns/coredns/core/dnsserver"
 	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	pkgparse "github.com/coredns/coredns/plugin/pkg/parse"
 
 	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
 )
 
+var log = clog.NewWithPlugin("k8s_external")
+
 func init() {
 	caddy.RegisterPlugin("k8s_external", caddy.Plugin{
 		ServerType: "dns",
@@ -31,6 +38,8 @@ func setup(c *caddy.Controller) error {
 		if x, ok := m.(Externaler); ok {
 			e.externalFunc = x.External
 			e.externalAddrFunc = x.ExternalAddress
+			e.externalServicesFunc = x.ExternalServices
+			e.externalSerialFunc = x.ExternalSerial
 		}
 		return nil
 	})
@@ -46,6 +55,7 @@ func setup(c *caddy.Controller) error {
 func parse(c *caddy.Controller) (*External, error) {
 	e := New()
 
+	records := []string{}
 	for c.Next() { // external
 		zones := c.RemainingArgs()
 		e.Zones = zones
@@ -77,10 +87,50 @@ func parse(c *caddy.Controller) (*External, error) {
 					return nil, c.ArgErr()
 				}
 				e.apex = args[0]
+			case "record":
+				args := c.RemainingArgs()
+				if len(args) < 2 {
+					return nil, c.ArgErr()
+				}
+				for i := range args {
+					// Quoted strings (i.e. TXT data) lost their quotes in the Corefile parser.
+					if strings.ContainsAny(args[i], " \t") {
+						args[i] = strconv.Quote(args[i])
+					}
+				}
+				records = append(records, strings.Join(args, " "))
+			case "transfer":
+				tos, froms, err := pkgparse.Transfer(c, false)
+				if err != nil {
+					return nil, err
+				}
+				if len(froms) != 0 {
+					return nil, c.Errf("transfer from is not supported with this plugin")
+				}
+				e.transferTo = tos
+			case "fallthrough":
+				e.Fall.SetZonesFromArgs(c.RemainingArgs())
 			default:
 				return nil, c.Errf("unknown property '%s'", c.Val())
 			}
 		}
 	}
+
+	for _, zone := range e.Zones {
+		for _, r := range records {
+			rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s", zone, e.ttl, r))
+			if err != nil {
+				return nil, c.Errf("invalid record '%s': %s", r, err)
+			}
+			if rr == nil {
+				return nil, c.Errf("invalid record '%s'", r)
+			}
+			switch rr.Header().Rrtype {
+			case dns.TypeSOA, dns.TypeNS, dns.TypeCNAME:
+				return nil, c.Errf("record of type %s can't be added to the apex", dns.TypeToString[rr.Header().Rrtype])
+			}
+			e.records[zone] = append(e.records[zone], rr)
+		}
+	}
 	return e, nil
 }
@@ -18,6 +18,21 @@ func TestSetup(t *testing.T) {
 		{`k8s_external example.org {
 			apex testdns
 }`, false, "example.org.", "testdns"},
+		{`k8s_external example.org {
+			record MX 10 mail.example.org.
+			record TXT "v=spf1 -all"
+			record CAA 0 issue "letsencrypt.org"
+			transfer to *
+}`, false, "example.org.", "dns"},
+		{`k8s_external example.org {
+			fallthrough
+}`, false, "example.org.", "dns"},
+		{`k8s_external example.org {
+			record NS ns2.example.org.
+}`, true, "example.org.", "dns"},
+		{`k8s_external example.org {
+			record MX mail.example.org.
+}`, true, "example.org.", "dns"},
 	}
 
 	for i, test := range tests {
@@ -46,3 +61,21 @@ func TestSetup(t *testing.T) {
 		}
 	}
 }
+
+func TestSetupRecords(t *testing.T) {
+	c := caddy.NewTestController("dns", `k8s_external example.org {
+	ttl 10
+	record TXT "v=spf1 -all"
+}`)
+	e, err := parse(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	rrs := e.records["example.org."]
+	if len(rrs) != 1 {
+		t.Fatalf("Expected 1 apex record, got %d", len(rrs))
+	}
+	if x := rrs[0].String(); x != "example.org.\t10\tIN\tTXT\t\"v=spf1 -all\"" {
+		t.Errorf("Expected TXT record, got %q", x)
+	}
+}
@@ -0,0 +1,115 @@
+package external
+
+import (
+	"context"
+	"net"
+
+	"github.com/coredns/coredns/plugin/etcd/msg"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+const transferLength = 2000
+
+// transfer performs a zone transfer of the external zone. The apex records, the records of the
+// nameserver and the records of all services with external IPs are transferred.
+func (e *External) transfer(ctx context.Context, state request.Request) (int, error) {
+	if !e.transferAllowed(state) || e.externalServicesFunc == nil {
+		return dns.RcodeRefused, nil
+	}
+
+	soa := e.soa(state)
+	records := []dns.RR{soa, e.ns(state)}
+	for _, rr := range e.records[state.Zone] {
+		records = append(records, dns.Copy(rr))
+	}
+
+	ns1 := dnsutil.Join("ns1", e.apex, state.Zone)
+	for _, rr := range e.externalAddrFunc(state) {
+		rr.Header().Ttl = e.ttl
+		rr.Header().Name = ns1
+		records = append(records, rr)
+	}
+
+	records = append(records, e.transferServices(e.externalServicesFunc(state.Zone))...)
+	records = append(records, soa)
+
+	ch := make(chan *dns.Envelope)
+	tr := new(dns.Transfer)
+
+	go func(ch chan *dns.Envelope) {
+		j, l := 0, 0
+		log.Infof("Outgoing transfer of %d records of zone %s to %s started", len(records), state.Zone, state.IP())
+		for i, r := range records {
+			l += dns.Len(r)
+			if l > transferLength {
+				ch <- &dns.Envelope{RR: records[j:i]}
+				l = 0
+				j = i
+			}
+		}
+		if j < len(records) {
+			ch <- &dns.Envelope{RR: records[j:]}
+		}
+		close(ch)
+	}(ch)
+
+	tr.Out(state.W, state.Req, ch)
+	// Defer closing to the client
+	state.W.Hijack()
+	return dns.RcodeSuccess, nil
+}
+
+// transferServices returns the address and SRV records for services.
+func (e *External) transferServices(services []msg.Service) []dns.RR {
+	dup := make(map[item]struct{})
+	records := []dns.RR{}
+	for _, s := range services {
+		what, ip := s.HostType()
+		if what != dns.TypeA && what != dns.TypeAAAA {
+			continue
+		}
+		s.TTL = e.ttl
+		name := msg.Domain(s.Key)
+
+		if s.TargetStrip == 0 && !isDuplicate(dup, name, s.Host, 0) {
+			if what == dns.TypeA {
+				records = append(records, s.NewA(name, ip))
+			} else {
+				records = append(records, s.NewAAAA(name, ip))
+			}
+		}
+
+		if s.Port == -1 {
+			continue
+		}
+		s.Host = name
+		srv := s.NewSRV(name, 100)
+		if !isDuplicate(dup, name+srv.Target, "", srv.Port) {
+			records = append(records, srv)
+		}
+	}
+	return records
+}
+
+// transferAllowed checks if incoming request for transferring the zone is allowed according to the ACLs.
+// Note: This is copied from zone.transferAllowed, but should eventually be factored into a common transfer pkg.
+func (e *External) transferAllowed(state request.Request) bool {
+	for _, t := range e.transferTo {
+		if t == "*" {
+			return true
+		}
+		// If remote IP matches we accept.
+		remote := state.IP()
+		to, _, err := net.SplitHostPort(t)
+		if err != nil {
+			continue
+		}
+		if to == remote {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package external
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/kubernetes"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestTransfer(t *testing.T) {
+	k := kubernetes.New([]string{"cluster.local."})
+	k.Namespaces = map[string]struct{}{"testns": {}}
+	k.APIConn = &external{}
+
+	e := New()
+	e.Zones = []string{"example.com."}
+	e.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	e.externalFunc = k.External
+	e.externalAddrFunc = externalAddress // internal test function
+	e.externalServicesFunc = k.ExternalServices
+	e.externalSerialFunc = func(string) uint32 { return 1570000000 }
+	e.records["example.com."] = []dns.RR{test.MX("example.com. 5 IN MX 10 mail.example.com.")}
+
+	ctx := context.TODO()
+	m := new(dns.Msg)
+	m.SetAxfr("example.com.")
+
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	if rcode, _ := e.ServeDNS(ctx, w, m); rcode != dns.RcodeRefused {
+		t.Fatalf("Expected transfer to be refused, got %s", dns.RcodeToString[rcode])
+	}
+
+	e.transferTo = []string{"*"}
+	w = dnstest.NewRecorder(&test.ResponseWriter{})
+	e.ServeDNS(ctx, w, m)
+	if w.Msg == nil {
+		t.Fatal("Expected transfer, got nothing")
+	}
+
+	tc := test.Case{
+		Qname: "example.com.", Qtype: dns.TypeAXFR,
+		Answer: []dns.RR{
+			test.SOA("example.com. 5 IN SOA ns1.dns.example.com. hostmaster.dns.example.com. 1570000000 7200 1800 86400 5"),
+			test.NS("example.com. 5 IN NS ns1.dns.example.com."),
+			test.MX("example.com. 5 IN MX 10 mail.example.com."),
+			test.A("ns1.dns.example.com. 5 IN A 127.0.0.1"),
+			test.A("svc1.testns.example.com. 5 IN A 1.2.3.4"),
+			test.SRV("svc1.testns.example.com. 5 IN SRV 0 100 80 svc1.testns.example.com."),
+			test.SRV("_http._tcp.svc1.testns.example.com. 5 IN SRV 0 100 80 svc1.testns.example.com."),
+			test.AAAA("svc6.testns.example.com. 5 IN AAAA 1:2::5"),
+			test.SRV("svc6.testns.example.com. 5 IN SRV 0 100 80 svc6.testns.example.com."),
+			test.SRV("_http._tcp.svc6.testns.example.com. 5 IN SRV 0 100 80 svc6.testns.example.com."),
+			test.A("www.example.com. 5 IN A 1.2.3.5"),
+			test.AAAA("www.example.com. 5 IN AAAA 1:2::6"),
+			test.A("*.apps.example.com. 5 IN A 1.2.3.6"),
+			test.SOA("example.com. 5 IN SOA ns1.dns.example.com. hostmaster.dns.example.com. 1570000000 7200 1800 86400 5"),
+		},
+	}
+	for _, i := range []int{0, len(w.Msg.Answer) - 1} {
+		soa, ok := w.Msg.Answer[i].(*dns.SOA)
+		if !ok {
+			t.Fatalf("Expected SOA as record %d, got %s", i, w.Msg.Answer[i])
+		}
+		if soa.Serial != 1570000000 {
+			t.Errorf("Expected serial %d, got %d", 1570000000, soa.Serial)
+		}
+	}
+
+	sort.Sort(test.RRSet(tc.Answer))
+	if err := test.SortAndCheck(w.Msg, tc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApexRecords(t *testing.T) {
+	k := kubernetes.New([]string{"cluster.local."})
+	k.Namespaces = map[string]struct{}{"testns": {}}
+	k.APIConn = &external{}
+
+	e := New()
+	e.Zones = []string{"example.com."}
+	e.Next = test.NextHandler(dns.RcodeSuccess, nil)
+	e.externalFunc = k.External
+	e.externalAddrFunc = externalAddress // internal test function
+	e.records["example.com."] = []dns.RR{
+		test.MX("example.com. 5 IN MX 10 mail.example.com."),
+		test.TXT(`example.com. 5 IN TXT "v=spf1 -all"`),
+	}
+
+	ctx := context.TODO()
+	tests := []test.Case{
+		{
+			Qname: "example.com.", Qtype: dns.TypeMX,
+			Answer: []dns.RR{test.MX("example.com. 5 IN MX 10 mail.example.com.")},
+		},
+		{
+			Qname: "example.com.", Qtype: dns.TypeTXT,
+			Answer: []dns.RR{test.TXT(`example.com. 5 IN TXT "v=spf1 -all"`)},
+		},
+		{
+			Qname: "example.com.", Qtype: dns.TypeCAA,
+			Ns: []dns.RR{test.SOA("example.com. 5 IN SOA ns1.dns.example.com. hostmaster.dns.example.com. 12345 7200 1800 86400 5")},
+		},
+	}
+	for i, tc := range tests {
+		w := dnstest.NewRecorder(&test.ResponseWriter{})
+		e.ServeDNS(ctx, w, tc.Msg())
+		if w.Msg == nil {
+			t.Fatalf("Test %d, got nil message for %q", i, tc.Qname)
+		}
+		if err := test.SortAndCheck(w.Msg, tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/coredns/coredns/plugin/kubernetes/object"
 
 	api "k8s.io/api/core/v1"
+	networking "k8s.io/api/networking/v1beta1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -23,6 +24,7 @@ const (
 	svcIPIndex            = "ServiceIP"
 	epNameNamespaceIndex  = "EndpointNameNamespace"
 	epIPIndex             = "EndpointsIP"
+	ingressHostIndex      = "IngressHost"
 )
 
 type dnsController interface {
@@ -33,6 +35,8 @@ type dnsController interface {
 	PodIndex(string) []*object.Pod
 	EpIndex(string) []*object.Endpoints
 	EpIndexReverse(string) []*object.Endpoints
+	IngressIndex(string) []*object.Ingress
+	IngressList() []*object.Ingress
 
 	GetNodeByName(string) (*api.Node, error)
 	GetNamespaceByName(string) (*api.Namespace, error)
@@ -60,11 +64,13 @@ type dnsControl struct {
 	podController cache.Controller
 	epController  cache.Controller
 	nsController  cache.Controller
+	ingController cache.Controller
 
 	svcLister cache.Indexer
 	podLister cache.Indexer
 	epLister  cache.Indexer
 	nsLister  cache.Store
+	ingLister cache.Indexer
 
 	// stopLock is used to enforce only a single call to Stop is active.
 	// Needed because we allow stopping through an http endpoint and
@@ -80,6 +86,7 @@ type dnsControl struct {
 type dnsControlOpts struct {
 	initPodCache       bool
 	initEndpointsCache bool
+	initIngressCache   bool
 	ignoreEmptyService bool
 
 	// Label handling.
@@ -139,6 +146,18 @@ func newdnsController(kubeClient kubernetes.Interface, opts dnsControlOpts) *dns
 			object.ToEndpoints)
 	}
 
+	if opts.initIngressCache {
+		dns.ingLister, dns.ingController = object.NewIndexerInformer(
+			&cache.ListWatch{
+				ListFunc:  ingressListFunc(dns.client, api.NamespaceAll, dns.selector),
+				WatchFunc: ingressWatchFunc(dns.client, api.NamespaceAll, dns.selector),
+			},
+			&networking.Ingress{},
+			cache.ResourceEventHandlerFuncs{AddFunc: dns.Add, UpdateFunc: dns.Update, DeleteFunc: dns.Delete},
+			cache.Indexers{ingressHostIndex: ingressHostIndexFunc},
+			object.ToIngress)
+	}
+
 	dns.nsLister, dns.nsController = cache.NewInformer(
 		&cache.ListWatch{
 			ListFunc:  namespaceListFunc(dns.client, dns.namespaceSelector),
@@ -195,6 +214,14 @@ func epIPIndexFunc(obj interface{}) ([]string, error) {
 	return ep.IndexIP, nil
 }
 
+func ingressHostIndexFunc(obj interface{}) ([]string, error) {
+	i, ok := obj.(*object.Ingress)
+	if !ok {
+		return nil, errObj
+	}
+	return i.Hosts, nil
+}
+
 func serviceListFunc(c kubernetes.Interface, ns string, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
 	return func(opts meta.ListOptions) (runtime.Object, error) {
 		if s != nil {
@@ -229,6 +256,16 @@ func endpointsListFunc(c kubernetes.Interface, ns string, s labels.Selector) fun
 	}
 }
 
+func ingressListFunc(c kubernetes.Interface, ns string, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
+	return func(opts meta.ListOptions) (runtime.Object, error) {
+		if s != nil {
+			opts.LabelSelector = s.String()
+		}
+		list, err := c.NetworkingV1beta1().Ingresses(ns).List(opts)
+		return list, err
+	}
+}
+
 func namespaceListFunc(c kubernetes.Interface, s labels.Selector) func(meta.ListOptions) (runtime.Object, error) {
 	return func(opts meta.ListOptions) (runtime.Object, error) {
 		if s != nil {
@@ -264,6 +301,9 @@ func (dns *dnsControl) Run() {
 	if dns.podController != nil {
 		go dns.podController.Run(dns.stopCh)
 	}
+	if dns.ingController != nil {
+		go dns.ingController.Run(dns.stopCh)
+	}
 	go dns.nsController.Run(dns.stopCh)
 	<-dns.stopCh
 }
@@ -280,7 +320,11 @@ func (dns *dnsControl) HasSynced() bool {
 		c = dns.podController.HasSynced()
 	}
 	d := dns.nsController.HasSynced()
-	return a && b && c && d
+	e := true
+	if dns.ingController != nil {
+		e = dns.ingController.HasSynced()
+	}
+	return a && b && c && d && e
 }
 
 func (dns *dnsControl) ServiceList() (svcs []*object.Service) {
@@ -383,6 +427,42 @@ func (dns *dnsControl) EpIndexReverse(ip string) (ep []*object.Endpoints) {
 	return ep
 }
 
+// IngressIndex returns the ingresses that have a rule for host. If the ingress cache
+// isn't initialized nil is returned.
+func (dns *dnsControl) IngressIndex(host string) (ings []*object.Ingress) {
+	if dns.ingLister == nil {
+		return nil
+	}
+	os, err := dns.ingLister.ByIndex(ingressHostIndex, host)
+	if err != nil {
+		return nil
+	}
+	for _, o := range os {
+		i, ok := o.(*object.Ingress)
+		if !ok {
+			continue
+		}
+		ings = append(ings, i)
+	}
+	return ings
+}
+
+// IngressList returns all ingresses. If the ingress cache isn't initialized nil is returned.
+func (dns *dnsControl) IngressList() (ings []*object.Ingress) {
+	if dns.ingLister == nil {
+		return nil
+	}
+	os := dns.ingLister.List()
+	for _, o := range os {
+		i, ok := o.(*object.Ingress)
+		if !ok {
+			continue
+		}
+		ings = append(ings, i)
+	}
+	return ings
+}
+
 // GetNodeByName return the node by name. If nothing is found an error is
 // returned. This query causes a roundtrip to the k8s API server, so use
 // sparingly. Currently this is only used for Federation.
@@ -436,6 +516,8 @@ func (dns *dnsControl) detectChanges(oldObj, newObj interface{}) {
 		dns.updateModifed()
 	case *object.Pod:
 		dns.updateModifed()
+	case *object.Ingress:
+		dns.updateModifed()
 	default:
 		log.Warningf("Updates for %T not supported.", ob)
 	}
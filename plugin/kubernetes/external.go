@@ -12,8 +12,13 @@ import (
 )
 
 // External implements the ExternalFunc call from the external plugin.
-// It returns any services matching in the services' ExternalIPs.
+// It returns any ingresses with a rule for the name, or else the services matching in
+// the services' ExternalIPs.
 func (k *Kubernetes) External(state request.Request) ([]msg.Service, int) {
+	if services := k.externalIngress(state); len(services) > 0 {
+		return services, dns.RcodeSuccess
+	}
+
 	base, _ := dnsutil.TrimZone(state.Name(), state.Zone)
 
 	segs := dns.SplitDomainName(base)
@@ -83,6 +88,38 @@ func (k *Kubernetes) External(state request.Request) ([]msg.Service, int) {
 	return services, rcode
 }
 
+// externalIngress returns the load balancer addresses of the ingresses that have a rule
+// for the name in state. If there is no rule for the name itself, a wildcard rule
+// for its parent is tried.
+func (k *Kubernetes) externalIngress(state request.Request) []msg.Service {
+	name := state.Name()
+	ingressList := k.APIConn.IngressIndex(name)
+	if len(ingressList) == 0 {
+		parent, end := dns.NextLabel(name, 0)
+		if end {
+			return nil
+		}
+		ingressList = k.APIConn.IngressIndex("*." + name[parent:])
+	}
+
+	services := []msg.Service{}
+	zonePath := msg.Path(state.Zone, coredns)
+
+	for _, ing := range ingressList {
+		if !k.namespaceExposed(ing.Namespace) {
+			continue
+		}
+		for _, ip := range ing.IPs {
+			// Ingresses have no ports we can use, Port -1 makes sure we don't return SRV records.
+			s := msg.Service{Host: ip, Port: -1, TTL: k.ttl}
+			s.Key = strings.Join([]string{zonePath, ing.Namespace, ing.Name}, "/")
+
+			services = append(services, s)
+		}
+	}
+	return services
+}
+
 // ExternalAddress returns the external service address(es) for the CoreDNS service.
 func (k *Kubernetes) ExternalAddress(state request.Request) []dns.RR {
 	// This is probably wrong, because of all the fallback behavior of k.nsAddr, i.e. can get
@@ -90,3 +127,52 @@ func (k *Kubernetes) ExternalAddress(state request.Request) []dns.RR {
 	rrs := []dns.RR{k.nsAddr()}
 	return rrs
 }
+
+// ExternalServices returns all services with external IPs in the exposed namespaces, keyed under zone.
+// For each named port an extra service is returned, keyed under the port and protocol, with TargetStrip
+// set so the SRV target becomes the name of the service itself. The load balancer addresses of the
+// ingresses are returned keyed under the hosts of their rules that fall in zone.
+func (k *Kubernetes) ExternalServices(zone string) []msg.Service {
+	zonePath := msg.Path(zone, coredns)
+	services := []msg.Service{}
+	for _, svc := range k.APIConn.ServiceList() {
+		if !k.namespaceExposed(svc.Namespace) {
+			continue
+		}
+		for _, ip := range svc.ExternalIPs {
+			for _, p := range svc.Ports {
+				s := msg.Service{Host: ip, Port: int(p.Port), TTL: k.ttl}
+				s.Key = strings.Join([]string{zonePath, svc.Namespace, svc.Name}, "/")
+				services = append(services, s)
+
+				// As per spec unnamed ports do not have a srv record
+				if p.Name == "" {
+					continue
+				}
+				s.Key = strings.Join([]string{zonePath, svc.Namespace, svc.Name, strings.ToLower("_" + string(p.Protocol)), strings.ToLower("_" + string(p.Name))}, "/")
+				s.TargetStrip = 2
+				services = append(services, s)
+			}
+		}
+	}
+	for _, ing := range k.APIConn.IngressList() {
+		if !k.namespaceExposed(ing.Namespace) {
+			continue
+		}
+		for _, host := range ing.Hosts {
+			if !dns.IsSubDomain(zone, host) {
+				continue
+			}
+			for _, ip := range ing.IPs {
+				// Ingresses have no ports we can use, Port -1 makes sure we don't return SRV records.
+				s := msg.Service{Host: ip, Port: -1, TTL: k.ttl}
+				s.Key = msg.Path(host, coredns)
+				services = append(services, s)
+			}
+		}
+	}
+	return services
+}
+
+// ExternalSerial returns the serial of the external zone, this is the time the cluster was last modified.
+func (k *Kubernetes) ExternalSerial(string) uint32 { return uint32(k.APIConn.Modified()) }
@@ -46,6 +46,24 @@ var extCases = []struct {
 	{
 		Qname: "svc0.svc-nons.example.com.", Rcode: dns.RcodeNameError,
 	},
+	{
+		Qname: "www.example.org.", Rcode: dns.RcodeSuccess,
+		Msg: []msg.Service{
+			{Host: "1.2.3.5", Port: -1, TTL: 5, Key: "/c/org/example/testns/ing1"},
+		},
+	},
+	{
+		Qname: "app.apps.example.org.", Rcode: dns.RcodeSuccess,
+		Msg: []msg.Service{
+			{Host: "1.2.3.6", Port: -1, TTL: 5, Key: "/c/org/example/testns/ing2"},
+		},
+	},
+	{
+		Qname: "a.app.apps.example.org.", Rcode: dns.RcodeNameError,
+	},
+	{
+		Qname: "hidden.example.org.", Rcode: dns.RcodeNameError,
+	},
 }
 
 func TestExternal(t *testing.T) {
@@ -98,6 +116,28 @@ func (external) GetNamespaceByName(name string) (*api.Namespace, error) {
 	}, nil
 }
 
+var ingressIndexExternal = map[string][]*object.Ingress{
+	"www.example.org.": {
+		{Name: "ing1", Namespace: "testns", Hosts: []string{"www.example.org."}, IPs: []string{"1.2.3.5"}},
+	},
+	"*.apps.example.org.": {
+		{Name: "ing2", Namespace: "testns", Hosts: []string{"*.apps.example.org."}, IPs: []string{"1.2.3.6"}},
+	},
+	"hidden.example.org.": {
+		{Name: "ing3", Namespace: "nons", Hosts: []string{"hidden.example.org."}, IPs: []string{"1.2.3.7"}},
+	},
+}
+
+func (external) IngressIndex(host string) []*object.Ingress { return ingressIndexExternal[host] }
+
+func (external) IngressList() []*object.Ingress {
+	var ings []*object.Ingress
+	for _, ing := range ingressIndexExternal {
+		ings = append(ings, ing...)
+	}
+	return ings
+}
+
 var svcIndexExternal = map[string][]*object.Service{
 	"svc1.testns": {
 		{
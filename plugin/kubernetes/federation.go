@@ -2,10 +2,21 @@ package kubernetes
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/coredns/coredns/plugin/etcd/msg"
 	"github.com/coredns/coredns/plugin/pkg/dnsutil"
 	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
 )
 
 // The federation node.Labels keys used.
@@ -49,3 +60,63 @@ func (k *Kubernetes) Federations(state request.Request, fname, fzone string) (ms
 
 	return msg.Service{Host: dnsutil.Join(r.endpoint, r.service, r.namespace, fname, r.podOrSvc, lz, lr, fzone)}, nil
 }
+
+// federationResource is the custom resource that holds federations, each object's name is the name of
+// the federation and its spec.domain the federation's domain.
+var federationResource = schema.GroupVersionResource{Group: "coredns.io", Version: "v1alpha1", Resource: "federations"}
+
+// WatchFederations watches the federations.coredns.io custom resources and calls update with the complete
+// mapping of federation names to domains each time they change. The watch runs until stop is closed.
+func (k *Kubernetes) WatchFederations(stop <-chan struct{}, update func(map[string]string)) error {
+	config, err := k.getClientConfig()
+	if err != nil {
+		return err
+	}
+	// Custom resources can't be encoded as protobuf.
+	config = rest.CopyConfig(config)
+	config.ContentType = ""
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes federation watch: %q", err)
+	}
+	resource := client.Resource(federationResource)
+
+	var store cache.Store
+	changed := func() { update(federationMap(store.List())) }
+	store, controller := cache.NewInformer(
+		&cache.ListWatch{
+			ListFunc:  func(opts meta.ListOptions) (runtime.Object, error) { return resource.List(opts) },
+			WatchFunc: func(opts meta.ListOptions) (watch.Interface, error) { return resource.Watch(opts) },
+		},
+		&unstructured.Unstructured{},
+		defaultResyncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(interface{}) { changed() },
+			UpdateFunc: func(interface{}, interface{}) { changed() },
+			DeleteFunc: func(interface{}) { changed() },
+		},
+	)
+
+	go controller.Run(stop)
+	return nil
+}
+
+// federationMap returns the federation name to domain mapping held in objs. Objects without a domain
+// are skipped.
+func federationMap(objs []interface{}) map[string]string {
+	feds := make(map[string]string)
+	for _, o := range objs {
+		u, ok := o.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		domain, found, err := unstructured.NestedString(u.Object, "spec", "domain")
+		if err != nil || !found || domain == "" {
+			log.Warningf("Federation %q has no domain, skipping", u.GetName())
+			continue
+		}
+		feds[u.GetName()] = dns.Fqdn(domain)
+	}
+	return feds
+}
@@ -0,0 +1,34 @@
+package kubernetes
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestFederationMap(t *testing.T) {
+	fed := func(name, domain string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{}}
+		u.SetName(name)
+		if domain != "" {
+			unstructured.SetNestedField(u.Object, domain, "spec", "domain")
+		}
+		return u
+	}
+
+	feds := federationMap([]interface{}{
+		fed("prod", "prod.example.org"),
+		fed("staging", "staging.example.org."),
+		fed("broken", ""),
+	})
+
+	if len(feds) != 2 {
+		t.Errorf("Expected 2 federations, got %d", len(feds))
+	}
+	if x := feds["prod"]; x != "prod.example.org." {
+		t.Errorf("Expected domain prod.example.org. for prod, got %q", x)
+	}
+	if x := feds["staging"]; x != "staging.example.org." {
+		t.Errorf("Expected domain staging.example.org. for staging, got %q", x)
+	}
+}
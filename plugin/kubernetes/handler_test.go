@@ -491,6 +491,8 @@ type APIConnServeTest struct {
 func (a APIConnServeTest) HasSynced() bool                         { return !a.notSynced }
 func (APIConnServeTest) Run()                                      { return }
 func (APIConnServeTest) Stop() error                               { return nil }
+func (APIConnServeTest) IngressIndex(string) []*object.Ingress     { return nil }
+func (APIConnServeTest) IngressList() []*object.Ingress            { return nil }
 func (APIConnServeTest) EpIndexReverse(string) []*object.Endpoints { return nil }
 func (APIConnServeTest) SvcIndexReverse(string) []*object.Service  { return nil }
 func (APIConnServeTest) Modified() int64                           { return time.Now().Unix() }
@@ -65,6 +65,8 @@ func (APIConnServiceTest) Run()                                      { return }
 func (APIConnServiceTest) Stop() error                               { return nil }
 func (APIConnServiceTest) PodIndex(string) []*object.Pod             { return nil }
 func (APIConnServiceTest) SvcIndexReverse(string) []*object.Service  { return nil }
+func (APIConnServiceTest) IngressIndex(string) []*object.Ingress     { return nil }
+func (APIConnServiceTest) IngressList() []*object.Ingress            { return nil }
 func (APIConnServiceTest) EpIndexReverse(string) []*object.Endpoints { return nil }
 func (APIConnServiceTest) Modified() int64                           { return 0 }
 
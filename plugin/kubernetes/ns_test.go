@@ -31,6 +31,9 @@ func (APIConnTest) ServiceList() []*object.Service {
 	return svcs
 }
 
+func (APIConnTest) IngressIndex(string) []*object.Ingress { return nil }
+func (APIConnTest) IngressList() []*object.Ingress        { return nil }
+
 func (APIConnTest) EpIndexReverse(string) []*object.Endpoints {
 	eps := []*object.Endpoints{
 		{
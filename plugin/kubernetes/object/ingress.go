@@ -0,0 +1,89 @@
+package object
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+	api "k8s.io/api/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Ingress is a stripped down api.Ingress with only the items we need for CoreDNS.
+type Ingress struct {
+	Version   string
+	Name      string
+	Namespace string
+
+	// Hosts are the fully qualified, lower cased, hosts of the rules of the ingress.
+	Hosts []string
+	// IPs are the load balancer addresses from the ingress' status.
+	IPs []string
+
+	*Empty
+}
+
+// ToIngress converts an api.Ingress to a *Ingress.
+func ToIngress(obj interface{}) interface{} {
+	ing, ok := obj.(*api.Ingress)
+	if !ok {
+		return nil
+	}
+
+	i := &Ingress{
+		Version:   ing.GetResourceVersion(),
+		Name:      ing.GetName(),
+		Namespace: ing.GetNamespace(),
+	}
+
+	for _, r := range ing.Spec.Rules {
+		if r.Host == "" {
+			continue
+		}
+		i.Hosts = append(i.Hosts, dns.Fqdn(strings.ToLower(r.Host)))
+	}
+	for _, lb := range ing.Status.LoadBalancer.Ingress {
+		// Load balancers that only have a hostname are skipped.
+		if lb.IP == "" {
+			continue
+		}
+		i.IPs = append(i.IPs, lb.IP)
+	}
+
+	*ing = api.Ingress{}
+
+	return i
+}
+
+var _ runtime.Object = &Ingress{}
+
+// DeepCopyObject implements the ObjectKind interface.
+func (i *Ingress) DeepCopyObject() runtime.Object {
+	i1 := &Ingress{
+		Version:   i.Version,
+		Name:      i.Name,
+		Namespace: i.Namespace,
+		Hosts:     make([]string, len(i.Hosts)),
+		IPs:       make([]string, len(i.IPs)),
+	}
+	copy(i1.Hosts, i.Hosts)
+	copy(i1.IPs, i.IPs)
+	return i1
+}
+
+// GetNamespace implements the metav1.Object interface.
+func (i *Ingress) GetNamespace() string { return i.Namespace }
+
+// SetNamespace implements the metav1.Object interface.
+func (i *Ingress) SetNamespace(namespace string) {}
+
+// GetName implements the metav1.Object interface.
+func (i *Ingress) GetName() string { return i.Name }
+
+// SetName implements the metav1.Object interface.
+func (i *Ingress) SetName(name string) {}
+
+// GetResourceVersion implements the metav1.Object interface.
+func (i *Ingress) GetResourceVersion() string { return i.Version }
+
+// SetResourceVersion implements the metav1.Object interface.
+func (i *Ingress) SetResourceVersion(version string) {}
@@ -55,6 +55,9 @@ func (APIConnReverseTest) SvcIndexReverse(ip string) []*object.Service {
 	return svcs
 }
 
+func (APIConnReverseTest) IngressIndex(string) []*object.Ingress { return nil }
+func (APIConnReverseTest) IngressList() []*object.Ingress        { return nil }
+
 func (APIConnReverseTest) EpIndexReverse(ip string) []*object.Endpoints {
 	switch ip {
 	case "10.0.0.100":
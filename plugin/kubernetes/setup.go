@@ -270,6 +270,11 @@ func ParseStanza(c *caddy.Controller) (*Kubernetes, error) {
 				return nil, c.ArgErr()
 			}
 			k8s.opts.initEndpointsCache = false
+		case "ingress":
+			if len(c.RemainingArgs()) != 0 {
+				return nil, c.ArgErr()
+			}
+			k8s.opts.initIngressCache = true
 		case "ignore":
 			args := c.RemainingArgs()
 			if len(args) > 0 {
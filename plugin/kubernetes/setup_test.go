@@ -528,6 +528,68 @@ func TestKubernetesParseNoEndpoints(t *testing.T) {
 	}
 }
 
+func TestKubernetesParseIngress(t *testing.T) {
+	tests := []struct {
+		input               string // Corefile data as string
+		shouldErr           bool   // true if test case is expected to produce an error.
+		expectedErrContent  string // substring from the expected error. Empty for positive cases.
+		expectedIngressInit bool
+	}{
+		// valid
+		{
+			`kubernetes coredns.local {
+	ingress
+}`,
+			false,
+			"",
+			true,
+		},
+		// invalid
+		{
+			`kubernetes coredns.local {
+	ingress all
+}`,
+			true,
+			"rong argument count or unexpected",
+			false,
+		},
+		// not set
+		{
+			`kubernetes coredns.local {
+}`,
+			false,
+			"",
+			false,
+		},
+	}
+
+	for i, test := range tests {
+		c := caddy.NewTestController("dns", test.input)
+		k8sController, err := kubernetesParse(c)
+
+		if test.shouldErr && err == nil {
+			t.Errorf("Test %d: Expected error, but did not find error for input '%s'. Error was: '%v'", i, test.input, err)
+		}
+
+		if err != nil {
+			if !test.shouldErr {
+				t.Errorf("Test %d: Expected no error but found one for input %s. Error was: %v", i, test.input, err)
+				continue
+			}
+
+			if !strings.Contains(err.Error(), test.expectedErrContent) {
+				t.Errorf("Test %d: Expected error to contain: %v, found error: %v, input: %s", i, test.expectedErrContent, err, test.input)
+			}
+			continue
+		}
+
+		foundIngressInit := k8sController.opts.initIngressCache
+		if foundIngressInit != test.expectedIngressInit {
+			t.Errorf("Test %d: Expected kubernetes controller to be initialized with ingress watch '%v'. Instead found ingress watch '%v' for input '%s'", i, test.expectedIngressInit, foundIngressInit, test.input)
+		}
+	}
+}
+
 func TestKubernetesParseIgnoreEmptyService(t *testing.T) {
 	tests := []struct {
 		input                 string // Corefile data as string
@@ -41,6 +41,16 @@ func endpointsWatchFunc(c kubernetes.Interface, ns string, s labels.Selector) fu
 	}
 }
 
+func ingressWatchFunc(c kubernetes.Interface, ns string, s labels.Selector) func(options meta.ListOptions) (watch.Interface, error) {
+	return func(options meta.ListOptions) (watch.Interface, error) {
+		if s != nil {
+			options.LabelSelector = s.String()
+		}
+		w, err := c.NetworkingV1beta1().Ingresses(ns).Watch(options)
+		return w, err
+	}
+}
+
 func namespaceWatchFunc(c kubernetes.Interface, s labels.Selector) func(options meta.ListOptions) (watch.Interface, error) {
 	return func(options meta.ListOptions) (watch.Interface, error) {
 		if s != nil {
@@ -0,0 +1,155 @@
+// Package logging implements a plugin that sets where, and in which format, CoreDNS logs.
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/rotate"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("logging", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	conf, err := parse(c)
+	if err != nil {
+		return plugin.Error("logging", err)
+	}
+
+	var out clog.Output
+	c.OnStartup(func() error {
+		o, err := conf.open()
+		if err != nil {
+			return plugin.Error("logging", err)
+		}
+		out = o
+		clog.SetOutput(out)
+		return nil
+	})
+	c.OnShutdown(func() error {
+		if out != nil {
+			clog.RemoveOutput(out)
+		}
+		return nil
+	})
+
+	return nil
+}
+
+// config is the output as configured in the Corefile.
+type config struct {
+	target string // stdout, syslog or file
+	addr   string // address of syslog or path of the file
+	json   bool
+	size   int64
+	keep   int
+}
+
+func parse(c *caddy.Controller) (*config, error) {
+	conf := &config{target: "stdout"}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) > 0 {
+			conf.target = args[0]
+		}
+		switch conf.target {
+		case "stdout":
+			if len(args) > 1 {
+				return nil, c.ArgErr()
+			}
+		case "syslog":
+			if len(args) > 2 {
+				return nil, c.ArgErr()
+			}
+			if len(args) == 2 {
+				conf.addr = args[1]
+			}
+		case "file":
+			if len(args) != 2 {
+				return nil, c.ArgErr()
+			}
+			conf.addr = args[1]
+			if root := dnsserver.GetConfig(c).Root; !filepath.IsAbs(conf.addr) && root != "" {
+				conf.addr = filepath.Join(root, conf.addr)
+			}
+		default:
+			return nil, c.Errf("unknown target: %s", conf.target)
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "format":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case "text":
+					conf.json = false
+				case "json":
+					conf.json = true
+				default:
+					return nil, c.Errf("unknown format: %s", args[0])
+				}
+			case "rotate_size":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				size, err := rotate.ParseSize(args[0])
+				if err != nil {
+					return nil, c.Err(err.Error())
+				}
+				conf.size = size
+			case "keep":
+				args := c.RemainingArgs()
+				if len(args) != 1 {
+					return nil, c.ArgErr()
+				}
+				keep, err := strconv.Atoi(args[0])
+				if err != nil {
+					return nil, c.Err(err.Error())
+				}
+				if keep <= 0 {
+					return nil, c.Errf("keep should be positive: %d", keep)
+				}
+				conf.keep = keep
+			default:
+				return nil, c.Errf("unknown property: %s", c.Val())
+			}
+		}
+		if conf.target != "file" && (conf.size > 0 || conf.keep > 0) {
+			return nil, c.Err("rotate_size and keep are only valid for a file")
+		}
+	}
+	return conf, nil
+}
+
+// open returns the output for conf.
+func (conf *config) open() (clog.Output, error) {
+	switch conf.target {
+	case "syslog":
+		return clog.NewSyslog(conf.addr, conf.json)
+	case "file":
+		return clog.NewFile(conf.addr, clog.FileOptions{Size: conf.size, Keep: conf.keep, JSON: conf.json})
+	}
+	return clog.NewWriter(os.Stdout, conf.json), nil
+}
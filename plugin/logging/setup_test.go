@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		exp       config
+	}{
+		{`logging`, false, config{target: "stdout"}},
+		{"logging {\nformat json\n}", false, config{target: "stdout", json: true}},
+		{`logging syslog`, false, config{target: "syslog"}},
+		{`logging syslog udp://127.0.0.1:514`, false, config{target: "syslog", addr: "udp://127.0.0.1:514"}},
+		{"logging file /var/log/coredns.log {\nformat text\nrotate_size 10M\nkeep 3\n}", false, config{target: "file", addr: "/var/log/coredns.log", size: 10 << 20, keep: 3}},
+		// fails
+		{`logging file`, true, config{}},
+		{`logging stdout extra`, true, config{}},
+		{`logging kafka`, true, config{}},
+		{"logging {\nformat xml\n}", true, config{}},
+		{"logging syslog {\nrotate_size 10M\n}", true, config{}},
+		{"logging file /tmp/log {\nrotate_size -1\n}", true, config{}},
+		{"logging file /tmp/log {\nkeep 0\n}", true, config{}},
+		{"logging file /tmp/log {\ncolor\n}", true, config{}},
+		{"logging\nlogging", true, config{}},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		conf, err := parse(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if *conf != tc.exp {
+			t.Errorf("Test %d: expected %+v, got %+v", i, tc.exp, *conf)
+		}
+	}
+}
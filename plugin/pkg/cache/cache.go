@@ -1,6 +1,6 @@
 // Package cache implements a cache. The cache hold 256 shards, each shard
-// holds a cache: a map with a mutex. There is no fancy expunge algorithm, it
-// just randomly evicts elements when it gets full.
+// holds a cache: a map with a mutex. When a shard gets full an element is
+// evicted according to the cache's Policy, by default a random one.
 package cache
 
 import (
@@ -20,16 +20,20 @@ type Cache struct {
 	shards [shardSize]*shard
 }
 
-// shard is a cache with random eviction.
+// shard is a cache with random eviction, unless policy is set.
 type shard struct {
-	items map[uint64]interface{}
-	size  int
+	items  map[uint64]interface{}
+	size   int
+	policy policy
 
 	sync.RWMutex
 }
 
-// New returns a new cache.
-func New(size int) *Cache {
+// New returns a new cache that evicts random elements.
+func New(size int) *Cache { return NewWithPolicy(size, Random) }
+
+// NewWithPolicy returns a new cache that evicts elements according to p.
+func NewWithPolicy(size int, p Policy) *Cache {
 	ssize := size / shardSize
 	if ssize < 4 {
 		ssize = 4
@@ -39,15 +43,17 @@ func New(size int) *Cache {
 
 	// Initialize all the shards
 	for i := 0; i < shardSize; i++ {
-		c.shards[i] = newShard(ssize)
+		c.shards[i] = newShardWithPolicy(ssize, p)
 	}
 	return c
 }
 
 // Add adds a new element to the cache. If the element already exists it is overwritten.
-func (c *Cache) Add(key uint64, el interface{}) {
+// Add returns true if another element was evicted to make room for el, or when el itself
+// wasn't added because the policy didn't admit it.
+func (c *Cache) Add(key uint64, el interface{}) bool {
 	shard := key & (shardSize - 1)
-	c.shards[shard].Add(key, el)
+	return c.shards[shard].Add(key, el)
 }
 
 // Get looks up element index under key.
@@ -71,30 +77,96 @@ func (c *Cache) Len() int {
 	return l
 }
 
+// Walk calls f for each element in the cache, until f returns false. The elements are visited with
+// the lock of their shard held, so f must not call other methods of the cache.
+func (c *Cache) Walk(f func(key uint64, el interface{}) bool) {
+	for _, s := range c.shards {
+		if !s.Walk(f) {
+			return
+		}
+	}
+}
+
 // newShard returns a new shard with size.
 func newShard(size int) *shard { return &shard{items: make(map[uint64]interface{}), size: size} }
 
+// newShardWithPolicy returns a new shard with size that evicts according to p.
+func newShardWithPolicy(size int, p Policy) *shard {
+	s := newShard(size)
+	switch p {
+	case LRU:
+		s.policy = newLRU()
+	case TinyLFU:
+		s.policy = newTinyLFU(size)
+	}
+	return s
+}
+
 // Add adds element indexed by key into the cache. Any existing element is overwritten
-func (s *shard) Add(key uint64, el interface{}) {
-	l := s.Len()
-	if l+1 > s.size {
+func (s *shard) Add(key uint64, el interface{}) bool {
+	if s.policy != nil {
+		return s.addPolicy(key, el)
+	}
+
+	evicted := false
+	s.RLock()
+	_, exists := s.items[key]
+	l := len(s.items)
+	s.RUnlock()
+	if !exists && l+1 > s.size {
 		s.Evict()
+		evicted = true
 	}
 
 	s.Lock()
 	s.items[key] = el
 	s.Unlock()
+	return evicted
+}
+
+// addPolicy adds el while letting the policy pick the element to evict.
+func (s *shard) addPolicy(key uint64, el interface{}) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	evicted := false
+	if _, exists := s.items[key]; !exists && len(s.items)+1 > s.size {
+		victim, ok := s.policy.victim()
+		if ok && !s.policy.admit(key, victim) {
+			return true
+		}
+		delete(s.items, victim)
+		s.policy.remove(victim)
+		evicted = true
+	}
+
+	s.items[key] = el
+	s.policy.add(key)
+	return evicted
 }
 
 // Remove removes the element indexed by key from the cache.
 func (s *shard) Remove(key uint64) {
 	s.Lock()
 	delete(s.items, key)
+	if s.policy != nil {
+		s.policy.remove(key)
+	}
 	s.Unlock()
 }
 
-// Evict removes a random element from the cache.
+// Evict removes an element from the cache, either a random one or the one chosen by the policy.
 func (s *shard) Evict() {
+	if s.policy != nil {
+		s.Lock()
+		if victim, ok := s.policy.victim(); ok {
+			delete(s.items, victim)
+			s.policy.remove(victim)
+		}
+		s.Unlock()
+		return
+	}
+
 	hasKey := false
 	var key uint64
 
@@ -117,12 +189,33 @@ func (s *shard) Evict() {
 
 // Get looks up the element indexed under key.
 func (s *shard) Get(key uint64) (interface{}, bool) {
+	if s.policy != nil {
+		// The policy is updated on each lookup, so we need the write lock.
+		s.Lock()
+		el, found := s.items[key]
+		s.policy.get(key, found)
+		s.Unlock()
+		return el, found
+	}
+
 	s.RLock()
 	el, found := s.items[key]
 	s.RUnlock()
 	return el, found
 }
 
+// Walk calls f for each element in the shard and returns false if f did.
+func (s *shard) Walk(f func(key uint64, el interface{}) bool) bool {
+	s.RLock()
+	defer s.RUnlock()
+	for k, el := range s.items {
+		if !f(k, el) {
+			return false
+		}
+	}
+	return true
+}
+
 // Len returns the current length of the cache.
 func (s *shard) Len() int {
 	s.RLock()
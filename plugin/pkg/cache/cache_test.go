@@ -39,3 +39,28 @@ func BenchmarkCache(b *testing.B) {
 		c.Get(1)
 	}
 }
+
+func TestCacheWalk(t *testing.T) {
+	c := New(4)
+	c.Add(1, 1)
+	c.Add(2, 2)
+	c.Add(3, 3)
+
+	sum := 0
+	c.Walk(func(key uint64, el interface{}) bool {
+		sum += el.(int)
+		return true
+	})
+	if sum != 6 {
+		t.Fatalf("Expected sum of elements to be %d, got %d", 6, sum)
+	}
+
+	n := 0
+	c.Walk(func(key uint64, el interface{}) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Fatalf("Expected walk to stop after %d element, got %d", 1, n)
+	}
+}
@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+)
+
+// Policy is the eviction policy of a cache.
+type Policy int
+
+const (
+	// Random evicts a random element.
+	Random Policy = iota
+	// LRU evicts the least recently used element.
+	LRU
+	// TinyLFU evicts the least recently used element, but only when the new element is estimated
+	// to be used more often than that one; otherwise the new element is not added. This keeps one-off
+	// elements from pushing out popular ones.
+	TinyLFU
+)
+
+var policyNames = map[Policy]string{Random: "random", LRU: "lru", TinyLFU: "tinylfu"}
+
+// String implements the fmt.Stringer interface.
+func (p Policy) String() string { return policyNames[p] }
+
+// ParsePolicy returns the policy with name s.
+func ParsePolicy(s string) (Policy, error) {
+	for p, name := range policyNames {
+		if strings.ToLower(s) == name {
+			return p, nil
+		}
+	}
+	return Random, fmt.Errorf("unknown eviction policy: %q", s)
+}
+
+// policy keeps track of the usage of the elements in a shard and selects the elements to evict. Methods
+// are called with the shard's lock held.
+type policy interface {
+	// get is called for each lookup of key, found tells if key was in the shard.
+	get(key uint64, found bool)
+	// add is called when key is added to, or overwritten in, the shard.
+	add(key uint64)
+	// remove is called when key is removed from the shard.
+	remove(key uint64)
+	// victim returns the element that should be evicted next.
+	victim() (uint64, bool)
+	// admit returns true if key may replace victim when the shard is full.
+	admit(key, victim uint64) bool
+}
+
+// lru is a least recently used policy.
+type lru struct {
+	ll    *list.List
+	elems map[uint64]*list.Element
+}
+
+func newLRU() *lru { return &lru{ll: list.New(), elems: make(map[uint64]*list.Element)} }
+
+func (l *lru) get(key uint64, found bool) {
+	if e, ok := l.elems[key]; ok {
+		l.ll.MoveToFront(e)
+	}
+}
+
+func (l *lru) add(key uint64) {
+	if e, ok := l.elems[key]; ok {
+		l.ll.MoveToFront(e)
+		return
+	}
+	l.elems[key] = l.ll.PushFront(key)
+}
+
+func (l *lru) remove(key uint64) {
+	if e, ok := l.elems[key]; ok {
+		l.ll.Remove(e)
+		delete(l.elems, key)
+	}
+}
+
+func (l *lru) victim() (uint64, bool) {
+	e := l.ll.Back()
+	if e == nil {
+		return 0, false
+	}
+	return e.Value.(uint64), true
+}
+
+func (l *lru) admit(key, victim uint64) bool { return true }
+
+// tinyLFU is an lru policy with an admission filter: a new element only replaces the least recently
+// used one when it is estimated to be more popular. Popularity is estimated with a count-min sketch of
+// all lookups, which is periodically aged so that elements that were popular long ago fade out.
+type tinyLFU struct {
+	*lru
+	sketch *sketch
+}
+
+func newTinyLFU(size int) *tinyLFU { return &tinyLFU{lru: newLRU(), sketch: newSketch(size)} }
+
+func (t *tinyLFU) get(key uint64, found bool) {
+	t.sketch.increment(key)
+	t.lru.get(key, found)
+}
+
+func (t *tinyLFU) admit(key, victim uint64) bool {
+	return t.sketch.estimate(key) > t.sketch.estimate(victim)
+}
+
+// sketch is a count-min sketch with 4 rows of counters that saturate at 15.
+type sketch struct {
+	rows [sketchDepth][]uint8
+	mask uint64
+
+	additions int
+	resetAt   int
+}
+
+const (
+	sketchDepth = 4
+	sketchMax   = 15
+)
+
+var sketchSeeds = [sketchDepth]uint64{0xc3a5c85c97cb3127, 0xb492b66fbe98f273, 0x9ae16a3b2f90404f, 0xcbf29ce484222325}
+
+// newSketch returns a sketch for tracking a shard with size elements.
+func newSketch(size int) *sketch {
+	width := 16
+	for width < size*4 {
+		width <<= 1
+	}
+	s := &sketch{mask: uint64(width - 1), resetAt: size * 10}
+	for i := range s.rows {
+		s.rows[i] = make([]uint8, width)
+	}
+	return s
+}
+
+func (s *sketch) index(key uint64, row int) uint64 {
+	h := (key ^ sketchSeeds[row]) * 0x9e3779b97f4a7c15
+	return (h >> 32) & s.mask
+}
+
+func (s *sketch) increment(key uint64) {
+	for i := range s.rows {
+		j := s.index(key, i)
+		if s.rows[i][j] < sketchMax {
+			s.rows[i][j]++
+		}
+	}
+	s.additions++
+	if s.additions >= s.resetAt {
+		s.reset()
+	}
+}
+
+func (s *sketch) estimate(key uint64) uint8 {
+	min := uint8(sketchMax)
+	for i := range s.rows {
+		if x := s.rows[i][s.index(key, i)]; x < min {
+			min = x
+		}
+	}
+	return min
+}
+
+// reset halves all counters.
+func (s *sketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+	s.additions /= 2
+}
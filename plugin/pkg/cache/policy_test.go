@@ -0,0 +1,130 @@
+package cache
+
+import "testing"
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		expected  Policy
+		shouldErr bool
+	}{
+		{"random", Random, false},
+		{"LRU", LRU, false},
+		{"tinylfu", TinyLFU, false},
+		{"arc", Random, true},
+	}
+	for i, tc := range tests {
+		p, err := ParsePolicy(tc.name)
+		if tc.shouldErr != (err != nil) {
+			t.Errorf("Test %d: expected error %t, got %v", i, tc.shouldErr, err)
+		}
+		if p != tc.expected {
+			t.Errorf("Test %d: expected policy %s, got %s", i, tc.expected, p)
+		}
+	}
+}
+
+func TestShardEvictLRU(t *testing.T) {
+	s := newShardWithPolicy(2, LRU)
+	s.Add(1, 1)
+	s.Add(2, 2)
+	s.Get(1) // 2 is now the least recently used
+
+	if evicted := s.Add(3, 3); !evicted {
+		t.Fatal("Expected an element to be evicted")
+	}
+	if _, found := s.Get(2); found {
+		t.Fatal("Found item that should have been evicted")
+	}
+	if _, found := s.Get(1); !found {
+		t.Fatal("Failed to find recently used item")
+	}
+
+	// Overwriting doesn't evict.
+	if evicted := s.Add(3, 4); evicted {
+		t.Fatal("Expected no element to be evicted")
+	}
+	if l := s.Len(); l != 2 {
+		t.Fatalf("Shard size should %d, got %d", 2, l)
+	}
+}
+
+func TestShardEvictTinyLFU(t *testing.T) {
+	s := newShardWithPolicy(2, TinyLFU)
+	for i := 0; i < 5; i++ {
+		s.Get(1)
+		s.Get(2)
+	}
+	s.Add(1, 1)
+	s.Add(2, 2)
+
+	// A one-off element isn't admitted.
+	s.Get(3)
+	if evicted := s.Add(3, 3); !evicted {
+		t.Fatal("Expected the element to be rejected")
+	}
+	if _, found := s.Get(3); found {
+		t.Fatal("Found item that should not have been admitted")
+	}
+	if l := s.Len(); l != 2 {
+		t.Fatalf("Shard size should %d, got %d", 2, l)
+	}
+
+	// A popular element replaces the least recently used one.
+	for i := 0; i < 10; i++ {
+		s.Get(4)
+	}
+	s.Get(2)
+	s.Add(4, 4)
+	if _, found := s.Get(4); !found {
+		t.Fatal("Failed to find admitted item")
+	}
+	if _, found := s.Get(1); found {
+		t.Fatal("Found item that should have been evicted")
+	}
+}
+
+func TestShardRemovePolicy(t *testing.T) {
+	s := newShardWithPolicy(2, LRU)
+	s.Add(1, 1)
+	s.Add(2, 2)
+	s.Remove(1)
+	s.Add(3, 3)
+
+	if _, found := s.Get(2); !found {
+		t.Fatal("Failed to find inserted record")
+	}
+	if l := s.Len(); l != 2 {
+		t.Fatalf("Shard size should %d, got %d", 2, l)
+	}
+}
+
+func TestSketch(t *testing.T) {
+	s := newSketch(4)
+	for i := 0; i < 6; i++ {
+		s.increment(1)
+	}
+	s.increment(2)
+
+	if x := s.estimate(1); x < 6 {
+		t.Errorf("Expected estimate of at least 6, got %d", x)
+	}
+	if s.estimate(1) <= s.estimate(2) {
+		t.Errorf("Expected 1 to be estimated more popular than 2")
+	}
+
+	s.reset()
+	if x := s.estimate(1); x < 3 {
+		t.Errorf("Expected estimate of at least 3 after reset, got %d", x)
+	}
+}
+
+func BenchmarkCacheTinyLFU(b *testing.B) {
+	b.ReportAllocs()
+
+	c := NewWithPolicy(4, TinyLFU)
+	for n := 0; n < b.N; n++ {
+		c.Add(1, 1)
+		c.Get(1)
+	}
+}
@@ -0,0 +1,73 @@
+package dnsutil
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Parent returns the parent of name, or the empty string if name is the root.
+func Parent(name string) string {
+	if name == "." {
+		return ""
+	}
+	off, end := dns.NextLabel(name, 0)
+	if end {
+		return "."
+	}
+	return name[off:]
+}
+
+// Below returns the name one label longer than zone, on the way to name. If name isn't longer than
+// that, name is returned.
+func Below(name, zone string) string {
+	labels := dns.SplitDomainName(name)
+	n := dns.CountLabel(zone) + 1
+	if n >= len(labels) {
+		return name
+	}
+	return dns.Fqdn(strings.Join(labels[len(labels)-n:], "."))
+}
+
+// ClosestEncloser returns the longest ancestor of name that is also an ancestor of owner or next. For
+// the owner and next name of the NSEC record that covers name, this is name's closest encloser.
+func ClosestEncloser(name, owner, next string) string {
+	n := dns.CompareDomainName(name, owner)
+	if x := dns.CompareDomainName(name, next); x > n {
+		n = x
+	}
+	if n == 0 {
+		return "."
+	}
+	labels := dns.SplitDomainName(name)
+	return dns.Fqdn(strings.Join(labels[len(labels)-n:], "."))
+}
+
+// CanonicalCompare compares a and b in canonical DNS name order (RFC 4034, section 6.1), both must be
+// lower cased. The result is -1, 0 or 1, like strings.Compare.
+func CanonicalCompare(a, b string) int {
+	la := dns.SplitDomainName(a)
+	lb := dns.SplitDomainName(b)
+	for i := 1; i <= len(la) && i <= len(lb); i++ {
+		if c := strings.Compare(la[len(la)-i], lb[len(lb)-i]); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(la) < len(lb):
+		return -1
+	case len(la) > len(lb):
+		return 1
+	}
+	return 0
+}
+
+// HasType returns true if the type bitmap of an NSEC or NSEC3 record holds t.
+func HasType(bitmap []uint16, t uint16) bool {
+	for _, b := range bitmap {
+		if b == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package dnsutil
+
+import "testing"
+
+func TestParent(t *testing.T) {
+	tests := []struct {
+		name, expected string
+	}{
+		{"www.example.org.", "example.org."},
+		{"org.", "."},
+		{".", ""},
+	}
+	for i, tc := range tests {
+		if x := Parent(tc.name); x != tc.expected {
+			t.Errorf("Test %d: expected %q, got %q", i, tc.expected, x)
+		}
+	}
+}
+
+func TestBelow(t *testing.T) {
+	tests := []struct {
+		name, zone, expected string
+	}{
+		{"www.example.org.", ".", "org."},
+		{"www.example.org.", "org.", "example.org."},
+		{"www.example.org.", "example.org.", "www.example.org."},
+		{"www.example.org.", "www.example.org.", "www.example.org."},
+		{"org.", ".", "org."},
+	}
+	for i, tc := range tests {
+		if x := Below(tc.name, tc.zone); x != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, x)
+		}
+	}
+}
+
+func TestClosestEncloser(t *testing.T) {
+	tests := []struct {
+		name, owner, next, expected string
+	}{
+		{"b.a.example.org.", "a.example.org.", "c.example.org.", "a.example.org."},
+		{"b.example.org.", "a.example.org.", "c.example.org.", "example.org."},
+		{"b.example.org.", "a.example.net.", "c.example.net.", "."},
+	}
+	for i, tc := range tests {
+		if x := ClosestEncloser(tc.name, tc.owner, tc.next); x != tc.expected {
+			t.Errorf("Test %d: expected %s, got %s", i, tc.expected, x)
+		}
+	}
+}
+
+func TestCanonicalCompare(t *testing.T) {
+	// Canonical order from RFC 4034, section 6.1.
+	names := []string{"example.", "a.example.", "yljkjljk.a.example.", "z.a.example.", "zabc.a.example.", "z.example.", "*.z.example."}
+	for i := 1; i < len(names); i++ {
+		if CanonicalCompare(names[i-1], names[i]) != -1 {
+			t.Errorf("Expected %s to sort before %s", names[i-1], names[i])
+		}
+		if CanonicalCompare(names[i], names[i-1]) != 1 {
+			t.Errorf("Expected %s to sort after %s", names[i], names[i-1])
+		}
+	}
+	if CanonicalCompare("example.", "example.") != 0 {
+		t.Errorf("Expected equal names to compare as 0")
+	}
+}
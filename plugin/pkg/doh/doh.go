@@ -95,7 +95,7 @@ func requestToMsgGet(req *http.Request) (*dns.Msg, error) {
 }
 
 func toMsg(r io.ReadCloser) (*dns.Msg, error) {
-	buf, err := ioutil.ReadAll(r)
+	buf, err := ioutil.ReadAll(io.LimitReader(r, dns.MaxMsgSize))
 	if err != nil {
 		return nil, err
 	}
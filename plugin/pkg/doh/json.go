@@ -0,0 +1,110 @@
+package doh
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// JSONMimeType is the mimetype of the JSON API, as used by browsers and tools like curl.
+const JSONMimeType = "application/dns-json"
+
+// jsonMsg is a DNS message in the JSON API's format.
+type jsonMsg struct {
+	Status     int
+	TC         bool
+	RD         bool
+	RA         bool
+	AD         bool
+	CD         bool
+	Question   []jsonQuestion
+	Answer     []jsonRR `json:",omitempty"`
+	Authority  []jsonRR `json:",omitempty"`
+	Additional []jsonRR `json:",omitempty"`
+}
+
+type jsonQuestion struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+}
+
+type jsonRR struct {
+	Name string `json:"name"`
+	Type uint16 `json:"type"`
+	TTL  uint32 `json:"TTL"`
+	Data string `json:"data"`
+}
+
+// IsJSON returns true if req is a query for the JSON API: a GET request with a 'name' query parameter.
+func IsJSON(req *http.Request) bool {
+	return req.Method == http.MethodGet && req.URL.Query().Get("name") != ""
+}
+
+// JSONRequestToMsg converts a JSON API request to a dns message. The query parameters are 'name', 'type'
+// (a number or a mnemonic, A when not given), and 'do' and 'cd' to set these bits.
+func JSONRequestToMsg(req *http.Request) (*dns.Msg, error) {
+	values := req.URL.Query()
+	name := values.Get("name")
+	if _, ok := dns.IsDomainName(name); !ok {
+		return nil, fmt.Errorf("invalid 'name' query parameter: %s", name)
+	}
+
+	qtype := dns.TypeA
+	if t := values.Get("type"); t != "" {
+		if n, err := strconv.ParseUint(t, 10, 16); err == nil {
+			qtype = uint16(n)
+		} else if x, ok := dns.StringToType[strings.ToUpper(t)]; ok {
+			qtype = x
+		} else {
+			return nil, fmt.Errorf("invalid 'type' query parameter: %s", t)
+		}
+	}
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	m.CheckingDisabled = isTrue(values.Get("cd"))
+	if isTrue(values.Get("do")) {
+		m.SetEdns0(4096, true)
+	}
+	return m, nil
+}
+
+// MsgToJSON converts m to the JSON API's format.
+func MsgToJSON(m *dns.Msg) ([]byte, error) {
+	j := jsonMsg{
+		Status: m.Rcode,
+		TC:     m.Truncated,
+		RD:     m.RecursionDesired,
+		RA:     m.RecursionAvailable,
+		AD:     m.AuthenticatedData,
+		CD:     m.CheckingDisabled,
+	}
+	for _, q := range m.Question {
+		j.Question = append(j.Question, jsonQuestion{Name: q.Name, Type: q.Qtype})
+	}
+	j.Answer = toJSON(m.Answer)
+	j.Authority = toJSON(m.Ns)
+	j.Additional = toJSON(m.Extra)
+	return json.Marshal(j)
+}
+
+// toJSON converts rrs, leaving out the OPT record.
+func toJSON(rrs []dns.RR) []jsonRR {
+	var j []jsonRR
+	for _, rr := range rrs {
+		h := rr.Header()
+		if h.Rrtype == dns.TypeOPT {
+			continue
+		}
+		// The data is the presentation format of the RR without its header.
+		data := strings.TrimPrefix(rr.String(), h.String())
+		j = append(j, jsonRR{Name: h.Name, Type: h.Rrtype, TTL: h.Ttl, Data: data})
+	}
+	return j
+}
+
+func isTrue(s string) bool { return s == "1" || s == "true" }
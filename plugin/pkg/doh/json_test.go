@@ -0,0 +1,84 @@
+package doh
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+func TestJSONRequestToMsg(t *testing.T) {
+	tests := []struct {
+		url       string
+		qtype     uint16
+		do, cd    bool
+		shouldErr bool
+	}{
+		{"https://example.org/dns-query?name=example.org", dns.TypeA, false, false, false},
+		{"https://example.org/dns-query?name=example.org.&type=aaaa", dns.TypeAAAA, false, false, false},
+		{"https://example.org/dns-query?name=example.org&type=48&do=1&cd=true", dns.TypeDNSKEY, true, true, false},
+		{"https://example.org/dns-query?name=example.org&type=bogus", 0, false, false, true},
+		{"https://example.org/dns-query?name=example..org", 0, false, false, true},
+	}
+	for i, tc := range tests {
+		req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+		if !IsJSON(req) {
+			t.Errorf("Test %d: expected a JSON request", i)
+		}
+		m, err := JSONRequestToMsg(req)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if x := m.Question[0].Name; x != "example.org." {
+			t.Errorf("Test %d: expected qname example.org., got %s", i, x)
+		}
+		if x := m.Question[0].Qtype; x != tc.qtype {
+			t.Errorf("Test %d: expected qtype %d, got %d", i, tc.qtype, x)
+		}
+		do := m.IsEdns0() != nil && m.IsEdns0().Do()
+		if do != tc.do || m.CheckingDisabled != tc.cd {
+			t.Errorf("Test %d: expected do %t and cd %t, got %t and %t", i, tc.do, tc.cd, do, m.CheckingDisabled)
+		}
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "https://example.org/dns-query?dns=AAABAAABAAAAAAAAB2V4YW1wbGUDb3JnAAABAAE", nil)
+	if IsJSON(req) {
+		t.Errorf("Expected a wire format request not to be a JSON request")
+	}
+}
+
+func TestMsgToJSON(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	m.Response, m.RecursionAvailable = true, true
+	m.Answer = []dns.RR{test.A("example.org. 300 IN A 127.0.0.1")}
+	m.SetEdns0(4096, false)
+
+	buf, err := MsgToJSON(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var j jsonMsg
+	if err := json.Unmarshal(buf, &j); err != nil {
+		t.Fatal(err)
+	}
+	if !j.RA || j.Status != dns.RcodeSuccess || len(j.Question) != 1 || j.Question[0].Type != dns.TypeA {
+		t.Errorf("Expected the header and question of the message, got %s", buf)
+	}
+	if len(j.Answer) != 1 || j.Answer[0].Data != "127.0.0.1" || j.Answer[0].TTL != 300 {
+		t.Errorf("Expected the answer 127.0.0.1 with TTL 300, got %s", buf)
+	}
+	if len(j.Additional) != 0 {
+		t.Errorf("Expected the OPT record to be left out, got %s", buf)
+	}
+}
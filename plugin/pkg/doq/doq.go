@@ -0,0 +1,59 @@
+// Package doq has the framing of DNS messages used by DNS-over-QUIC (RFC 9250).
+package doq
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/miekg/dns"
+)
+
+// NextProto is the ALPN token of DNS-over-QUIC, it must be negotiated in the TLS handshake.
+const NextProto = "doq"
+
+// The application error codes used when closing a connection or a stream, see section 4.3 of RFC 9250.
+const (
+	NoError          = 0x0
+	InternalError    = 0x1
+	ProtocolError    = 0x2
+	RequestCancelled = 0x3
+)
+
+// ErrID is returned by ReadMsg when the message ID isn't 0.
+var ErrID = errors.New("DNS-over-QUIC message ID is not 0")
+
+// ReadMsg reads a DNS message, prefixed with its length, from r. The message ID must be 0.
+func ReadMsg(r io.Reader) (*dns.Msg, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(buf); err != nil {
+		return nil, err
+	}
+	if m.Id != 0 {
+		return nil, ErrID
+	}
+	return m, nil
+}
+
+// WriteMsg writes m, prefixed with its length, to w. The message ID is written as 0, whatever it is in
+// m, as section 4.2.1 of RFC 9250 requires.
+func WriteMsg(w io.Writer, m *dns.Msg) error {
+	buf, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	b := make([]byte, 2+len(buf))
+	binary.BigEndian.PutUint16(b, uint16(len(buf)))
+	copy(b[2:], buf)
+	b[2], b[3] = 0, 0
+	_, err = w.Write(b)
+	return err
+}
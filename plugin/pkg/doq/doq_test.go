@@ -0,0 +1,53 @@
+package doq
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestWriteReadMsg(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+
+	buf := new(bytes.Buffer)
+	if err := WriteMsg(buf, m); err != nil {
+		t.Fatalf("Failed to write message: %s", err)
+	}
+	if m.Id == 0 {
+		t.Fatalf("Expected the ID of the message to be kept")
+	}
+	if l := int(buf.Bytes()[0])<<8 | int(buf.Bytes()[1]); l != buf.Len()-2 {
+		t.Errorf("Expected length %d, got %d", buf.Len()-2, l)
+	}
+
+	r, err := ReadMsg(buf)
+	if err != nil {
+		t.Fatalf("Failed to read message: %s", err)
+	}
+	if r.Id != 0 {
+		t.Errorf("Expected ID 0, got %d", r.Id)
+	}
+	if x := r.Question[0].Name; x != "example.org." {
+		t.Errorf("Expected %s, got %s", "example.org.", x)
+	}
+}
+
+func TestReadMsgID(t *testing.T) {
+	m := new(dns.Msg)
+	m.SetQuestion("example.org.", dns.TypeA)
+	b, _ := m.Pack()
+	buf := bytes.NewBuffer([]byte{byte(len(b) >> 8), byte(len(b))})
+	buf.Write(b)
+
+	if _, err := ReadMsg(buf); err != ErrID {
+		t.Errorf("Expected %q, got %v", ErrID, err)
+	}
+}
+
+func TestReadMsgShort(t *testing.T) {
+	if _, err := ReadMsg(bytes.NewBuffer([]byte{0, 20, 1, 2})); err == nil {
+		t.Errorf("Expected error for a truncated message")
+	}
+}
@@ -0,0 +1,95 @@
+package edns
+
+import (
+	"net"
+
+	"github.com/miekg/dns"
+)
+
+// Default source prefix lengths for the EDNS0 Client Subnet option, as recommended in RFC 7871, section 11.1.
+const (
+	DefaultSubnetV4 = 24
+	DefaultSubnetV6 = 56
+)
+
+// Subnet returns the EDNS0 Client Subnet option from m, or nil if m doesn't have one.
+func Subnet(m *dns.Msg) *dns.EDNS0_SUBNET {
+	o := m.IsEdns0()
+	if o == nil {
+		return nil
+	}
+	for _, s := range o.Option {
+		if e, ok := s.(*dns.EDNS0_SUBNET); ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// SetSubnet sets the EDNS0 Client Subnet option of m to ip, masked to v4 or v6 bits depending on the
+// family of ip. An existing option is overwritten. If m doesn't have an OPT RR one is added, advertising
+// a buffer size of 512 bytes. It returns false if ip is neither an IPv4 nor an IPv6 address.
+func SetSubnet(m *dns.Msg, ip net.IP, v4, v6 uint8) bool {
+	family, bits := uint16(1), v4
+	if ip.To4() == nil {
+		if ip.To16() == nil {
+			return false
+		}
+		family, bits = 2, v6
+	}
+
+	o := m.IsEdns0()
+	if o == nil {
+		m.SetEdns0(dns.MinMsgSize, false)
+		o = m.IsEdns0()
+	}
+	e := Subnet(m)
+	if e == nil {
+		e = &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET}
+		o.Option = append(o.Option, e)
+	}
+	e.Family = family
+	e.SourceNetmask = bits
+	e.SourceScope = 0
+	e.Address = MaskSubnet(family, ip, bits)
+	return true
+}
+
+// RemoveSubnet removes the EDNS0 Client Subnet option from m.
+func RemoveSubnet(m *dns.Msg) {
+	o := m.IsEdns0()
+	if o == nil {
+		return
+	}
+	opts := o.Option[:0]
+	for _, s := range o.Option {
+		if _, ok := s.(*dns.EDNS0_SUBNET); !ok {
+			opts = append(opts, s)
+		}
+	}
+	o.Option = opts
+}
+
+// MaskSubnet returns ip masked to bits. The family, 1 for IPv4 and 2 for IPv6, determines the length
+// of the returned address. Nil is returned for an unknown family.
+func MaskSubnet(family uint16, ip net.IP, bits uint8) net.IP {
+	switch family {
+	case 1:
+		if ip = ip.To4(); ip == nil {
+			return nil
+		}
+		if bits > net.IPv4len*8 {
+			bits = net.IPv4len * 8
+		}
+		return ip.Mask(net.CIDRMask(int(bits), net.IPv4len*8))
+	case 2:
+		if ip = ip.To16(); ip == nil {
+			return nil
+		}
+		if bits > net.IPv6len*8 {
+			bits = net.IPv6len * 8
+		}
+		return ip.Mask(net.CIDRMask(int(bits), net.IPv6len*8))
+	}
+	return nil
+}
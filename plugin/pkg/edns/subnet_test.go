@@ -0,0 +1,72 @@
+package edns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestSetSubnet(t *testing.T) {
+	tests := []struct {
+		ip      string
+		family  uint16
+		netmask uint8
+		address string
+	}{
+		{"10.240.0.1", 1, 24, "10.240.0.0"},
+		{"2001:db8:1234:5678::1", 2, 56, "2001:db8:1234:5600::"},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		if !SetSubnet(m, net.ParseIP(tc.ip), DefaultSubnetV4, DefaultSubnetV6) {
+			t.Fatalf("Test %d: expected subnet to be set", i)
+		}
+		if o := m.IsEdns0(); o == nil || o.UDPSize() != dns.MinMsgSize {
+			t.Errorf("Test %d: expected OPT RR with buffer size %d", i, dns.MinMsgSize)
+		}
+		e := Subnet(m)
+		if e == nil {
+			t.Fatalf("Test %d: expected subnet option, got none", i)
+		}
+		if e.Family != tc.family || e.SourceNetmask != tc.netmask || e.SourceScope != 0 {
+			t.Errorf("Test %d: expected family %d and netmask %d, got %d and %d", i, tc.family, tc.netmask, e.Family, e.SourceNetmask)
+		}
+		if !e.Address.Equal(net.ParseIP(tc.address)) {
+			t.Errorf("Test %d: expected address %s, got %s", i, tc.address, e.Address)
+		}
+	}
+}
+
+func TestSetSubnetOverwrite(t *testing.T) {
+	m := ednsMsg()
+	SetSubnet(m, net.ParseIP("10.0.0.1"), 8, 0)
+	SetSubnet(m, net.ParseIP("192.168.1.1"), 16, 0)
+
+	o := m.IsEdns0()
+	if len(o.Option) != 1 {
+		t.Fatalf("Expected 1 option, got %d", len(o.Option))
+	}
+	if e := Subnet(m); !e.Address.Equal(net.ParseIP("192.168.0.0")) || e.SourceNetmask != 16 {
+		t.Errorf("Expected 192.168.0.0/16, got %s/%d", e.Address, e.SourceNetmask)
+	}
+
+	RemoveSubnet(m)
+	if Subnet(m) != nil {
+		t.Errorf("Expected subnet option to be removed")
+	}
+}
+
+func TestMaskSubnet(t *testing.T) {
+	if ip := MaskSubnet(1, net.ParseIP("10.1.2.3"), 40); !ip.Equal(net.ParseIP("10.1.2.3")) {
+		t.Errorf("Expected 10.1.2.3, got %s", ip)
+	}
+	if ip := MaskSubnet(1, net.ParseIP("::1"), 24); ip != nil {
+		t.Errorf("Expected nil for an IPv6 address in family 1, got %s", ip)
+	}
+	if ip := MaskSubnet(3, net.ParseIP("10.1.2.3"), 24); ip != nil {
+		t.Errorf("Expected nil for unknown family, got %s", ip)
+	}
+}
@@ -0,0 +1,94 @@
+package log
+
+import (
+	"os"
+	"sync"
+
+	"github.com/coredns/coredns/plugin/pkg/rotate"
+)
+
+// FileOptions tells how log messages are written to a file.
+type FileOptions struct {
+	Size int64 // rotate when the file is larger than Size bytes, 0 disables
+	Keep int   // number of rotated files to keep, 0 keeps all
+	JSON bool  // write the messages as JSON instead of text
+}
+
+// NewFile returns an Output that appends to the file path. When the file gets larger than opts.Size
+// it is rotated: renamed to path with the time of rotation (in UTC) as suffix, and a new file is started.
+func NewFile(path string, opts FileOptions) (Output, error) {
+	f := &file{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+type file struct {
+	path string
+	opts FileOptions
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+func (f *file) open() error {
+	fh, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return err
+	}
+	f.f = fh
+	f.size = stat.Size()
+	return nil
+}
+
+func (f *file) Write(e Entry) error {
+	line := e.Text()
+	if f.opts.JSON {
+		line = e.JSON()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return os.ErrClosed
+	}
+	n, err := f.f.WriteString(line + "\n")
+	f.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if f.opts.Size > 0 && f.size >= f.opts.Size {
+		f.rotate()
+	}
+	return nil
+}
+
+// rotate renames the file and opens a new one, f.mu must be held. If the rename fails, the file is
+// reopened and rotation is tried again after the next write. If opening fails, the messages are logged
+// to standard output.
+func (f *file) rotate() {
+	f.f.Close()
+	f.f = nil
+	if _, err := rotate.Rename(f.path); err == nil && f.opts.Keep > 0 {
+		rotate.Prune(f.path, f.opts.Keep)
+	}
+	f.open()
+}
+
+func (f *file) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.f == nil {
+		return nil
+	}
+	err := f.f.Close()
+	f.f = nil
+	return err
+}
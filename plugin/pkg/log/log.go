@@ -46,12 +46,27 @@ func clock() string { return time.Now().Format("2006-01-02T15:04:05.000Z07:00")
 
 // logf calls log.Printf prefixed with level.
 func logf(level, format string, v ...interface{}) {
-	golog.Print(clock(), level, fmt.Sprintf(format, v...))
+	write(level, "", fmt.Sprintf(format, v...))
 }
 
 // log calls log.Print prefixed with level.
 func log(level string, v ...interface{}) {
-	golog.Print(clock(), level, fmt.Sprint(v...))
+	write(level, "", fmt.Sprint(v...))
+}
+
+// write writes the message msg of level, logged by plugin, to the output set with SetOutput, or to the
+// standard library's logger if there is none.
+func write(level, plugin, msg string) {
+	outMu.RLock()
+	o := out
+	outMu.RUnlock()
+	if o == nil {
+		golog.Print(clock(), level, plugin, msg)
+		return
+	}
+	if e := o.Write(Entry{Time: time.Now(), Level: level, Plugin: plugin, Msg: msg}); e != nil {
+		golog.Print(clock(), level, plugin, msg)
+	}
 }
 
 // Debug is equivalent to log.Print(), but prefixed with "[DEBUG] ". It only outputs something
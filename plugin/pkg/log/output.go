@@ -0,0 +1,101 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry is a log message as it is written to an Output.
+type Entry struct {
+	Time   time.Time
+	Level  string // as it is prefixed to the message, e.g. " [INFO] "
+	Plugin string // as it is prefixed to the message, e.g. "plugin/forward: ", empty if not logged by a plugin
+	Msg    string
+}
+
+// Text returns e formatted as it is logged to standard output, without a trailing newline.
+func (e Entry) Text() string {
+	return e.Time.Format("2006-01-02T15:04:05.000Z07:00") + e.Level + e.Plugin + e.Msg
+}
+
+// JSON returns e formatted as a JSON object, with the fields time, level, plugin (if set) and msg.
+func (e Entry) JSON() string {
+	j := struct {
+		Time   string `json:"time"`
+		Level  string `json:"level"`
+		Plugin string `json:"plugin,omitempty"`
+		Msg    string `json:"msg"`
+	}{
+		Time:   e.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+		Level:  e.level(),
+		Plugin: strings.TrimSuffix(strings.TrimPrefix(e.Plugin, "plugin/"), ": "),
+		Msg:    e.Msg,
+	}
+	buf, _ := json.Marshal(j)
+	return string(buf)
+}
+
+// level returns the level of e without decoration, e.g. "INFO".
+func (e Entry) level() string { return strings.Trim(e.Level, " []") }
+
+// Output is a destination for log messages, other than standard output.
+type Output interface {
+	// Write writes e. When it returns an error, e is logged to standard output instead.
+	Write(e Entry) error
+	Close() error
+}
+
+var (
+	outMu sync.RWMutex
+	out   Output
+)
+
+// SetOutput makes all logging, including the debug logging, go to o. The previous output, if any,
+// is closed.
+func SetOutput(o Output) {
+	outMu.Lock()
+	prev := out
+	out = o
+	outMu.Unlock()
+	if prev != nil && prev != o {
+		prev.Close()
+	}
+}
+
+// RemoveOutput makes the logging go to standard output again and closes o, if o is the current output.
+// If o was replaced by another output, and thus closed, it does nothing.
+func RemoveOutput(o Output) {
+	outMu.Lock()
+	if out != o {
+		outMu.Unlock()
+		return
+	}
+	out = nil
+	outMu.Unlock()
+	o.Close()
+}
+
+// NewWriter returns an Output that writes to w, as text or as JSON. Use it to log JSON to standard output.
+func NewWriter(w io.Writer, json bool) Output { return &writer{w: w, json: json} }
+
+type writer struct {
+	mu   sync.Mutex
+	w    io.Writer
+	json bool
+}
+
+func (w *writer) Write(e Entry) error {
+	line := e.Text()
+	if w.json {
+		line = e.JSON()
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := io.WriteString(w.w, line+"\n")
+	return err
+}
+
+func (w *writer) Close() error { return nil }
@@ -0,0 +1,81 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOutput(t *testing.T) {
+	var f bytes.Buffer
+	o := NewWriter(&f, true)
+	SetOutput(o)
+	defer RemoveOutput(o)
+
+	NewWithPlugin("testplugin").Warningf("%s", "test")
+	j := map[string]string{}
+	if err := json.Unmarshal(f.Bytes(), &j); err != nil {
+		t.Fatalf("Expected a JSON object, got %q", f.String())
+	}
+	if j["level"] != "WARNING" || j["plugin"] != "testplugin" || j["msg"] != "test" || j["time"] == "" {
+		t.Errorf("Unexpected JSON object: %v", j)
+	}
+
+	f.Reset()
+	Info("test")
+	if x := f.String(); !strings.Contains(x, `"level":"INFO"`) || strings.Contains(x, `"plugin"`) {
+		t.Errorf("Unexpected JSON object: %s", x)
+	}
+}
+
+func TestRemoveOutput(t *testing.T) {
+	var f1, f2 bytes.Buffer
+	o1, o2 := NewWriter(&f1, false), NewWriter(&f2, false)
+	SetOutput(o1)
+	SetOutput(o2)
+	// Removing the replaced output leaves the current one.
+	RemoveOutput(o1)
+	Info("test")
+	RemoveOutput(o2)
+
+	if f1.Len() != 0 {
+		t.Errorf("Expected no log in the replaced output, got %q", f1.String())
+	}
+	if x := f2.String(); !strings.Contains(x, info+"test") {
+		t.Errorf("Expected log to be %s, got %s", info+"test", x)
+	}
+}
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "coredns-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "coredns.log")
+
+	o, err := NewFile(path, FileOptions{Size: 100, Keep: 2})
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err)
+	}
+	SetOutput(o)
+	for i := 0; i < 20; i++ {
+		Info(strings.Repeat("x", 40))
+	}
+	RemoveOutput(o)
+
+	rotated, _ := filepath.Glob(path + ".*")
+	if len(rotated) != 2 {
+		t.Errorf("Expected 2 rotated files, got %d", len(rotated))
+	}
+	for _, r := range rotated {
+		buf, _ := ioutil.ReadFile(r)
+		if lines := strings.Count(string(buf), "\n"); lines != 2 {
+			t.Errorf("Expected 2 lines in %s, got %d", r, lines)
+		}
+	}
+}
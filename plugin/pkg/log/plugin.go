@@ -15,11 +15,11 @@ type P struct {
 func NewWithPlugin(name string) P { return P{"plugin/" + name + ": "} }
 
 func (p P) logf(level, format string, v ...interface{}) {
-	log(level, p.plugin, fmt.Sprintf(format, v...))
+	write(level, p.plugin, fmt.Sprintf(format, v...))
 }
 
 func (p P) log(level string, v ...interface{}) {
-	log(level+p.plugin, v...)
+	write(level, p.plugin, fmt.Sprint(v...))
 }
 
 // Debug logs as log.Debug.
@@ -0,0 +1,55 @@
+// +build !windows,!plan9,!nacl
+
+package log
+
+import (
+	"log/syslog"
+	"net/url"
+)
+
+// NewSyslog returns an Output that sends the log messages to syslog, with the level of a message as its
+// severity. The address addr is a URL like unix:///dev/log or udp://host:514, if it is empty the local
+// syslog is used. The messages are sent as text, without the time and level, or as JSON.
+func NewSyslog(addr string, json bool) (Output, error) {
+	network, raddr := "", ""
+	if addr != "" {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		network, raddr = u.Scheme, u.Host
+		if network == "unix" || network == "unixgram" {
+			raddr = u.Path
+		}
+	}
+	w, err := syslog.Dial(network, raddr, syslog.LOG_DAEMON|syslog.LOG_INFO, "coredns")
+	if err != nil {
+		return nil, err
+	}
+	return &syslogOutput{w: w, json: json}, nil
+}
+
+type syslogOutput struct {
+	w    *syslog.Writer
+	json bool
+}
+
+func (s *syslogOutput) Write(e Entry) error {
+	msg := e.Plugin + e.Msg
+	if s.json {
+		msg = e.JSON()
+	}
+	switch e.Level {
+	case debug:
+		return s.w.Debug(msg)
+	case info:
+		return s.w.Info(msg)
+	case warning:
+		return s.w.Warning(msg)
+	case fatal:
+		return s.w.Crit(msg)
+	}
+	return s.w.Err(msg)
+}
+
+func (s *syslogOutput) Close() error { return s.w.Close() }
@@ -0,0 +1,10 @@
+// +build windows plan9 nacl
+
+package log
+
+import "errors"
+
+// NewSyslog returns an error, syslog is not supported on this platform.
+func NewSyslog(addr string, json bool) (Output, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,73 @@
+// Package rotate has the helpers to rotate files that are written to, like logs and dnstap files.
+package rotate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is the time format of the suffix of rotated files, it sorts in chronological order.
+const Format = "20060102T150405.000000000"
+
+// Rename renames the file path to path with the current time (in UTC) as suffix, and returns the new name.
+func Rename(path string) (string, error) {
+	rotated := path + "." + time.Now().UTC().Format(Format)
+	return rotated, os.Rename(path, rotated)
+}
+
+// Prune removes the oldest rotated files of path, so only keep are left. Rotated files are the files
+// named path with a suffix in Format, optionally followed by ".gz"; other files are left alone. It returns
+// the first error removing a file, the others are still removed.
+func Prune(path string, keep int) error {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return err
+	}
+	files := []string{}
+	for _, f := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(f, path+"."), ".gz")
+		if _, err := time.Parse(Format, suffix); err != nil {
+			continue
+		}
+		files = append(files, f)
+	}
+	// The suffixes are timestamps, so this sorts from old to new.
+	sort.Strings(files)
+	var first error
+	for len(files) > keep {
+		if err := os.Remove(files[0]); err != nil && first == nil {
+			first = err
+		}
+		files = files[1:]
+	}
+	return first
+}
+
+// ParseSize parses a size in bytes, with an optional K, M or G suffix.
+func ParseSize(s string) (int64, error) {
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("size should be positive: %d", n)
+	}
+	return n * mult, nil
+}
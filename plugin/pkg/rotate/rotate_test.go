@@ -0,0 +1,96 @@
+package rotate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenamePrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log")
+
+	// Siblings that aren't rotated files of path must survive pruning.
+	siblings := []string{path + ".conf", path + ".sock", path + ".20190901.gz"}
+	for _, s := range siblings {
+		if err := ioutil.WriteFile(s, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rotated []string
+	for i := 0; i < 3; i++ {
+		if err := ioutil.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		r, err := Rename(path)
+		if err != nil {
+			t.Fatalf("Expected no error renaming, got %s", err)
+		}
+		rotated = append(rotated, r)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected %s to be renamed", path)
+	}
+
+	if err := Prune(path, 2); err != nil {
+		t.Fatalf("Expected no error pruning, got %s", err)
+	}
+	if _, err := os.Stat(rotated[0]); !os.IsNotExist(err) {
+		t.Errorf("Expected the oldest file %s to be removed", rotated[0])
+	}
+	for _, r := range append(rotated[1:], siblings...) {
+		if _, err := os.Stat(r); err != nil {
+			t.Errorf("Expected %s to be kept, got %s", r, err)
+		}
+	}
+
+	// Compressed rotated files are pruned too.
+	if err := os.Rename(rotated[1], rotated[1]+".gz"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Prune(path, 1); err != nil {
+		t.Fatalf("Expected no error pruning, got %s", err)
+	}
+	if _, err := os.Stat(rotated[1] + ".gz"); !os.IsNotExist(err) {
+		t.Errorf("Expected the compressed file %s.gz to be removed", rotated[1])
+	}
+	for _, r := range append(rotated[2:], siblings...) {
+		if _, err := os.Stat(r); err != nil {
+			t.Errorf("Expected %s to be kept, got %s", r, err)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		size      string
+		expected  int64
+		shouldErr bool
+	}{
+		{"100", 100, false},
+		{"10K", 10 << 10, false},
+		{"10M", 10 << 20, false},
+		{"1G", 1 << 30, false},
+		{"0", 0, true},
+		{"-1M", 0, true},
+		{"ten", 0, true},
+	}
+	for i, tc := range tests {
+		n, err := ParseSize(tc.size)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error for %s, got none", i, tc.size)
+			}
+			continue
+		}
+		if err != nil || n != tc.expected {
+			t.Errorf("Test %d: expected %d, got %d (%v)", i, tc.expected, n, err)
+		}
+	}
+}
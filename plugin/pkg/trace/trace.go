@@ -10,3 +10,13 @@ type Trace interface {
 	plugin.Handler
 	Tracer() ot.Tracer
 }
+
+// Tags plugins set on their spans, to show where the time of a query is spent.
+const (
+	// TagUpstream is the address of the upstream a query was sent to.
+	TagUpstream = "coredns.io/upstream"
+	// TagRetries is the number of upstreams that were tried before the one that answered.
+	TagRetries = "coredns.io/retries"
+	// TagCache tells how the cache handled a query: "hit", "miss" or "synthesized".
+	TagCache = "coredns.io/cache"
+)
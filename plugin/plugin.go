@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/miekg/dns"
 	ot "github.com/opentracing/opentracing-go"
@@ -77,12 +78,51 @@ func NextOrFailure(name string, next Handler, ctx context.Context, w dns.Respons
 			defer child.Finish()
 			ctx = ot.ContextWithSpan(ctx, child)
 		}
-		return next.ServeDNS(ctx, w, r)
+		rcode, err := next.ServeDNS(ctx, w, r)
+		if err != nil {
+			if o, ok := ctx.Value(originKey{}).(*origin); ok {
+				o.set(next.Name())
+			}
+		}
+		return rcode, err
 	}
 
 	return dns.RcodeServerFailure, Error(name, errors.New("no next plugin found"))
 }
 
+// WithErrorOrigin returns a copy of ctx in which NextOrFailure records the plugin an error originates from,
+// this is the first plugin that returned it. Use ErrorOrigin to retrieve its name.
+func WithErrorOrigin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, originKey{}, &origin{})
+}
+
+// ErrorOrigin returns the name of the plugin the error returned in ctx originates from. It returns the
+// empty string if no error was recorded, or ctx wasn't created with WithErrorOrigin.
+func ErrorOrigin(ctx context.Context) string {
+	o, ok := ctx.Value(originKey{}).(*origin)
+	if !ok {
+		return ""
+	}
+	o.Lock()
+	defer o.Unlock()
+	return o.name
+}
+
+type originKey struct{}
+
+type origin struct {
+	sync.Mutex
+	name string
+}
+
+func (o *origin) set(name string) {
+	o.Lock()
+	if o.name == "" {
+		o.name = name
+	}
+	o.Unlock()
+}
+
 // ClientWrite returns true if the response has been written to the client.
 // Each plugin to adhire to this protocol.
 func ClientWrite(rcode int) bool {
@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestErrorOrigin(t *testing.T) {
+	failing := namedHandler{"failing", HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		return dns.RcodeServerFailure, errors.New("failed")
+	})}
+	passing := namedHandler{"passing", HandlerFunc(func(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+		return NextOrFailure("passing", failing, ctx, w, r)
+	})}
+
+	if x := ErrorOrigin(context.TODO()); x != "" {
+		t.Errorf("Expected no origin, got %q", x)
+	}
+
+	ctx := WithErrorOrigin(context.TODO())
+	NextOrFailure("first", passing, ctx, nil, nil)
+	if x := ErrorOrigin(ctx); x != "failing" {
+		t.Errorf("Expected origin %q, got %q", "failing", x)
+	}
+
+	ctx = WithErrorOrigin(context.TODO())
+	NextOrFailure("first", nil, ctx, nil, nil)
+	if x := ErrorOrigin(ctx); x != "" {
+		t.Errorf("Expected no origin, got %q", x)
+	}
+}
+
+type namedHandler struct {
+	name string
+	Handler
+}
+
+func (n namedHandler) Name() string { return n.name }
@@ -0,0 +1,70 @@
+package recursor
+
+import (
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/cache"
+)
+
+// delegation is a zone cut: a zone and its name servers, with the addresses of the name servers that
+// came as glue.
+type delegation struct {
+	zone   string
+	ns     []string
+	addrs  map[string][]string
+	expire time.Time // zero for the root hints
+}
+
+// hostAddrs are the addresses of a name server that had to be resolved, because there was no glue.
+type hostAddrs struct {
+	name   string
+	addrs  []string
+	expire time.Time
+}
+
+// infra caches delegations and the addresses of name servers, so that not every query starts at the
+// root servers.
+type infra struct {
+	delegations *cache.Cache
+	hosts       *cache.Cache
+}
+
+func newInfra(size int) *infra {
+	return &infra{delegations: cache.New(size), hosts: cache.New(size)}
+}
+
+// addDelegation caches d until it expires.
+func (i *infra) addDelegation(d *delegation) {
+	i.delegations.Add(cache.Hash([]byte(d.zone)), d)
+}
+
+// delegation returns the cached delegation to zone, or nil when there is none or it expired.
+func (i *infra) delegation(zone string, now time.Time) *delegation {
+	el, ok := i.delegations.Get(cache.Hash([]byte(zone)))
+	if !ok {
+		return nil
+	}
+	d := el.(*delegation)
+	if d.zone != zone || now.After(d.expire) {
+		return nil
+	}
+	return d
+}
+
+// addHost caches the addresses of the name server name until expire.
+func (i *infra) addHost(name string, addrs []string, expire time.Time) {
+	i.hosts.Add(cache.Hash([]byte(name)), &hostAddrs{name: name, addrs: addrs, expire: expire})
+}
+
+// host returns the cached addresses of the name server name, or nil when there are none or they expired.
+func (i *infra) host(name string, now time.Time) []string {
+	el, ok := i.hosts.Get(cache.Hash([]byte(name)))
+	if !ok {
+		return nil
+	}
+	h := el.(*hostAddrs)
+	if h.name != name || now.After(h.expire) {
+		return nil
+	}
+	return h.addrs
+}
@@ -0,0 +1,23 @@
+package recursor
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Variables declared for monitoring.
+var (
+	OutgoingCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "recursor",
+		Name:      "outgoing_request_count_total",
+		Help:      "Counter of queries sent to authoritative servers.",
+	}, []string{"proto"})
+	OutgoingErrorCount = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "recursor",
+		Name:      "outgoing_error_count_total",
+		Help:      "Counter of queries to authoritative servers that failed or got an unusable reply.",
+	})
+)
@@ -0,0 +1,135 @@
+// Package recursor implements a recursive resolver. It resolves queries iteratively, following the
+// referrals from the root servers down to the authoritative servers.
+package recursor
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("recursor")
+
+// Recursor is a plugin that resolves queries itself, starting at the root servers.
+type Recursor struct {
+	from    string
+	ignored []string
+
+	root  *delegation // the root servers, from the root hints
+	infra *infra      // cached delegations and name server addresses
+
+	qmin    bool          // QNAME minimisation, RFC 7816
+	random  bool          // 0x20 case randomisation of the queries
+	timeout time.Duration // timeout of a query to an authoritative server
+	port    string        // port of the authoritative servers, only changed for testing
+
+	Next plugin.Handler
+}
+
+// New returns a new Recursor that uses the built-in root hints.
+func New() *Recursor {
+	return &Recursor{
+		from:    ".",
+		root:    defaultHints(),
+		infra:   newInfra(infraSize),
+		qmin:    true,
+		random:  true,
+		timeout: defaultTimeout,
+		port:    "53",
+	}
+}
+
+// ServeDNS implements plugin.Handler.
+func (r *Recursor) ServeDNS(ctx context.Context, w dns.ResponseWriter, req *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: req}
+	if !r.match(state) {
+		return plugin.NextOrFailure(r.Name(), r.Next, ctx, w, req)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	m, err := r.resolve(ctx, state.Name(), state.QType(), 0)
+	if err != nil {
+		return dns.RcodeServerFailure, err
+	}
+
+	ret := new(dns.Msg)
+	ret.SetReply(req)
+	ret.RecursionAvailable = true
+	ret.Rcode = m.Rcode
+	ret.Answer = m.Answer
+	// Only negative answers need the authority section, it holds the SOA for negative caching. The
+	// additional section is from servers we didn't ask about it, so isn't passed on.
+	if len(m.Answer) == 0 {
+		ret.Ns = m.Ns
+	} else if state.Do() {
+		// An answer expanded from a wildcard comes with the proof the name itself doesn't exist.
+		ret.Ns = denial(m.Ns)
+	}
+	if !state.Do() {
+		ret.Answer = stripDNSSEC(ret.Answer, state.QType())
+		ret.Ns = stripDNSSEC(ret.Ns, state.QType())
+	}
+	w.WriteMsg(ret)
+	return dns.RcodeSuccess, nil
+}
+
+// Name implements plugin.Handler.
+func (r *Recursor) Name() string { return "recursor" }
+
+// denial returns the NSEC and NSEC3 records in rrs, with their signatures.
+func denial(rrs []dns.RR) []dns.RR {
+	var ret []dns.RR
+	for _, rr := range rrs {
+		switch x := rr.(type) {
+		case *dns.NSEC, *dns.NSEC3:
+			ret = append(ret, rr)
+		case *dns.RRSIG:
+			if x.TypeCovered == dns.TypeNSEC || x.TypeCovered == dns.TypeNSEC3 {
+				ret = append(ret, rr)
+			}
+		}
+	}
+	return ret
+}
+
+// stripDNSSEC removes the DNSSEC records, other than those of qtype, from rrs for clients that didn't
+// ask for them.
+func stripDNSSEC(rrs []dns.RR, qtype uint16) []dns.RR {
+	j := 0
+	for _, rr := range rrs {
+		switch t := rr.Header().Rrtype; t {
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+			if t != qtype {
+				continue
+			}
+		}
+		rrs[j] = rr
+		j++
+	}
+	return rrs[:j]
+}
+
+func (r *Recursor) match(state request.Request) bool {
+	if !plugin.Name(r.from).Matches(state.Name()) {
+		return false
+	}
+	for _, ignore := range r.ignored {
+		if plugin.Name(ignore).Matches(state.Name()) {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	defaultTimeout = 2 * time.Second // per query to an authoritative server
+	resolveTimeout = 10 * time.Second
+	infraSize      = 10000
+)
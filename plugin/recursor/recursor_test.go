@@ -0,0 +1,271 @@
+package recursor
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// The fake hierarchy: each zone is served by its own server, on its own loopback address.
+var hierarchy = []struct {
+	addr   string
+	origin string
+	zone   string
+}{
+	{"127.0.0.1", ".", `
+.                    3600 IN SOA a.root-servers.test. hostmaster.root-servers.test. 1 7200 3600 1209600 3600
+.                    3600 IN NS  a.root-servers.test.
+a.root-servers.test. 3600 IN A   127.0.0.1
+org.                 3600 IN NS  ns1.org.
+ns1.org.             3600 IN A   127.0.0.2
+`},
+	{"127.0.0.2", "org.", `
+org.                 3600 IN SOA ns1.org. hostmaster.org. 1 7200 3600 1209600 3600
+org.                 3600 IN NS  ns1.org.
+ns1.org.             3600 IN A   127.0.0.2
+example.org.         3600 IN NS  ns1.example.org.
+ns1.example.org.     3600 IN A   127.0.0.3
+glueless.org.        3600 IN NS  ns.example.org.
+`},
+	{"127.0.0.3", "example.org.", `
+example.org.         3600 IN SOA ns1.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600
+example.org.         3600 IN NS  ns1.example.org.
+ns1.example.org.     3600 IN A   127.0.0.3
+ns.example.org.      3600 IN A   127.0.0.4
+www.example.org.     3600 IN A   192.0.2.1
+alias.example.org.   3600 IN CNAME www.glueless.org.
+tcp.example.org.     3600 IN A   192.0.2.3
+`},
+	{"127.0.0.4", "glueless.org.", `
+glueless.org.        3600 IN SOA ns.example.org. hostmaster.glueless.org. 1 7200 3600 1209600 3600
+glueless.org.        3600 IN NS  ns.example.org.
+www.glueless.org.    3600 IN A   192.0.2.2
+`},
+}
+
+// fakeHierarchy is a running fake hierarchy, it records the queries each server gets.
+type fakeHierarchy struct {
+	port    string
+	servers []*dns.Server
+
+	mu      sync.Mutex
+	queries map[string][]dns.Question // per server address
+}
+
+// newFakeHierarchy starts the servers of the hierarchy, all on the same port. Over UDP the server for
+// example.org. replies to queries for tcp.example.org. with the TC bit set.
+func newFakeHierarchy(t *testing.T) *fakeHierarchy {
+	for attempt := 0; attempt < 5; attempt++ {
+		h := &fakeHierarchy{queries: map[string][]dns.Question{}}
+		if err := h.start(); err != nil {
+			h.stop()
+			continue
+		}
+		return h
+	}
+	t.Fatal("Failed to start the fake hierarchy")
+	return nil
+}
+
+func (h *fakeHierarchy) start() error {
+	for _, s := range hierarchy {
+		z, err := file.Parse(strings.NewReader(s.zone), s.origin, "stdin", 0)
+		if err != nil {
+			return err
+		}
+		f := file.File{Zones: file.Zones{Z: map[string]*file.Zone{s.origin: z}, Names: []string{s.origin}}}
+		addr := s.addr
+		handler := dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+			h.mu.Lock()
+			h.queries[addr] = append(h.queries[addr], r.Question[0])
+			h.mu.Unlock()
+			if addr == "127.0.0.3" && strings.EqualFold(r.Question[0].Name, "poison.example.org.") {
+				// Out of bailiwick data for the CNAME target.
+				m := new(dns.Msg)
+				m.SetReply(r)
+				m.Authoritative = true
+				m.Answer = []dns.RR{
+					test.CNAME("poison.example.org. 3600 IN CNAME www.glueless.org."),
+					test.A("www.glueless.org. 3600 IN A 203.0.113.66"),
+				}
+				w.WriteMsg(m)
+				return
+			}
+			if _, udp := w.RemoteAddr().(*net.UDPAddr); udp && strings.EqualFold(r.Question[0].Name, "tcp.example.org.") {
+				m := new(dns.Msg)
+				m.SetReply(r)
+				m.Truncated = true
+				w.WriteMsg(m)
+				return
+			}
+			f.ServeDNS(context.TODO(), w, r)
+		})
+
+		port := h.port
+		if port == "" {
+			port = "0"
+		}
+		pc, err := net.ListenPacket("udp", net.JoinHostPort(addr, port))
+		if err != nil {
+			return err
+		}
+		_, h.port, _ = net.SplitHostPort(pc.LocalAddr().String())
+		l, err := net.Listen("tcp", net.JoinHostPort(addr, h.port))
+		if err != nil {
+			pc.Close()
+			return err
+		}
+		for _, srv := range []*dns.Server{{PacketConn: pc, Handler: handler}, {Listener: l, Handler: handler}} {
+			started := make(chan struct{})
+			srv.NotifyStartedFunc = func() { close(started) }
+			go srv.ActivateAndServe()
+			<-started
+			h.servers = append(h.servers, srv)
+		}
+	}
+	return nil
+}
+
+func (h *fakeHierarchy) stop() {
+	for _, s := range h.servers {
+		s.Shutdown()
+	}
+}
+
+// asked returns the queries the server at addr got.
+func (h *fakeHierarchy) asked(addr string) []dns.Question {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]dns.Question(nil), h.queries[addr]...)
+}
+
+// newTestRecursor returns a Recursor that uses h's root server.
+func newTestRecursor(h *fakeHierarchy) *Recursor {
+	r := New()
+	r.root = &delegation{zone: ".", ns: []string{"a.root-servers.test."}, addrs: map[string][]string{"a.root-servers.test.": {"127.0.0.1"}}}
+	r.port = h.port
+	return r
+}
+
+func TestRecursor(t *testing.T) {
+	h := newFakeHierarchy(t)
+	defer h.stop()
+	r := newTestRecursor(h)
+
+	tests := []test.Case{
+		{
+			Qname: "www.example.org.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("www.example.org. 3600 IN A 192.0.2.1")},
+		},
+		{
+			// CNAME to a zone with a glueless delegation.
+			Qname: "alias.example.org.", Qtype: dns.TypeA,
+			Answer: []dns.RR{
+				test.CNAME("alias.example.org. 3600 IN CNAME www.glueless.org."),
+				test.A("www.glueless.org. 3600 IN A 192.0.2.2"),
+			},
+		},
+		{
+			// The A record for the target isn't accepted from the server for example.org.
+			Qname: "poison.example.org.", Qtype: dns.TypeA,
+			Answer: []dns.RR{
+				test.CNAME("poison.example.org. 3600 IN CNAME www.glueless.org."),
+				test.A("www.glueless.org. 3600 IN A 192.0.2.2"),
+			},
+		},
+		{
+			Qname: "www.example.org.", Qtype: dns.TypeMX,
+			Ns: []dns.RR{test.SOA("example.org. 3600 IN SOA ns1.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600")},
+		},
+		{
+			Qname: "a.b.nope.example.org.", Qtype: dns.TypeA, Rcode: dns.RcodeNameError,
+			Ns: []dns.RR{test.SOA("example.org. 3600 IN SOA ns1.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600")},
+		},
+		{
+			// Truncated over UDP.
+			Qname: "tcp.example.org.", Qtype: dns.TypeA,
+			Answer: []dns.RR{test.A("tcp.example.org. 3600 IN A 192.0.2.3")},
+		},
+	}
+
+	for i, tc := range tests {
+		m := tc.Msg()
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := r.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if !rec.Msg.RecursionAvailable {
+			t.Errorf("Test %d: expected RA to be set", i)
+		}
+		if err := test.SortAndCheck(rec.Msg, tc); err != nil {
+			t.Errorf("Test %d: %s", i, err)
+		}
+	}
+}
+
+func TestRecursorQnameMinimisation(t *testing.T) {
+	h := newFakeHierarchy(t)
+	defer h.stop()
+	r := newTestRecursor(h)
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	if _, err := r.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+
+	root := h.asked("127.0.0.1")
+	if len(root) != 1 || !strings.EqualFold(root[0].Name, "org.") || root[0].Qtype != dns.TypeNS {
+		t.Errorf("Expected the root server to be asked for org. NS only, got %v", root)
+	}
+	tld := h.asked("127.0.0.2")
+	if len(tld) != 1 || !strings.EqualFold(tld[0].Name, "example.org.") || tld[0].Qtype != dns.TypeNS {
+		t.Errorf("Expected the org. server to be asked for example.org. NS only, got %v", tld)
+	}
+
+	// The delegations are cached, the next query goes straight to the example.org. server.
+	m.SetQuestion("ns1.example.org.", dns.TypeA)
+	if _, err := r.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if x := len(h.asked("127.0.0.1")) + len(h.asked("127.0.0.2")); x != 2 {
+		t.Errorf("Expected the root and org. servers not to be asked again, got %d queries", x)
+	}
+}
+
+func TestRecursorCaseRandomisation(t *testing.T) {
+	h := newFakeHierarchy(t)
+	defer h.stop()
+	r := newTestRecursor(h)
+
+	for i := 0; i < 4; i++ {
+		m := new(dns.Msg)
+		m.SetQuestion("www"+strconv.Itoa(i)+".example.org.", dns.TypeA)
+		r.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	}
+	for _, q := range h.asked("127.0.0.3") {
+		if q.Name != strings.ToLower(q.Name) {
+			return
+		}
+	}
+	t.Errorf("Expected the case of the queries to be randomised")
+}
+
+func TestRandomCase(t *testing.T) {
+	name := "www.example.org."
+	for i := 0; i < 10; i++ {
+		if x := randomCase(name); !strings.EqualFold(x, name) {
+			t.Errorf("Expected %q to differ from %q in case only", x, name)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package recursor
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/plugin/pkg/rcode"
+
+	"github.com/miekg/dns"
+)
+
+// resolve resolves qname and qtype and returns the reply of the authoritative server. Records in the
+// answer that are outside the zone of that server are dropped. CNAMEs that point to names the reply
+// doesn't hold the records for are followed; the CNAME records are prepended to the answer. Depth counts
+// the name servers we're resolving the address of, to resolve qname.
+func (r *Recursor) resolve(ctx context.Context, qname string, qtype uint16, depth int) (*dns.Msg, error) {
+	if depth > maxDepth {
+		return nil, errMaxDepth
+	}
+	var chain []dns.RR
+	name := qname
+	for i := 0; i <= maxCNAMEs; i++ {
+		m, zone, err := r.lookup(ctx, name, qtype, depth)
+		if err != nil {
+			return nil, err
+		}
+		m.Answer = inZone(m.Answer, zone)
+		target := follow(m.Answer, name, qtype)
+		if target == "" || m.Rcode != dns.RcodeSuccess {
+			m.Answer = append(chain, m.Answer...)
+			return m, nil
+		}
+		chain = append(chain, m.Answer...)
+		name = target
+	}
+	return nil, errMaxCNAMEs
+}
+
+// follow returns the target of the CNAME chain that starts at name in answer, when answer doesn't hold
+// the records of qtype for that target. Otherwise it returns "".
+func follow(answer []dns.RR, name string, qtype uint16) string {
+	if qtype == dns.TypeCNAME || qtype == dns.TypeANY {
+		return ""
+	}
+	target := ""
+	for i := 0; i <= len(answer); i++ {
+		next := ""
+		for _, rr := range answer {
+			if !strings.EqualFold(rr.Header().Name, name) {
+				continue
+			}
+			if rr.Header().Rrtype == qtype {
+				return ""
+			}
+			if c, ok := rr.(*dns.CNAME); ok {
+				next = strings.ToLower(c.Target)
+			}
+		}
+		if next == "" {
+			return target
+		}
+		target, name = next, next
+	}
+	return "" // CNAME loop, return what we have
+}
+
+// inZone returns the records in rrs that are in zone, the others are out of bailiwick for the server
+// of zone.
+func inZone(rrs []dns.RR, zone string) []dns.RR {
+	j := 0
+	for _, rr := range rrs {
+		if dns.IsSubDomain(zone, strings.ToLower(rr.Header().Name)) {
+			rrs[j] = rr
+			j++
+		}
+	}
+	return rrs[:j]
+}
+
+// lookup resolves qname and qtype iteratively, starting at the closest delegation we know of, and
+// returns the reply of the authoritative server and the zone of that server.
+func (r *Recursor) lookup(ctx context.Context, qname string, qtype uint16, depth int) (*dns.Msg, string, error) {
+	d := r.closest(qname, qtype)
+	known := d.zone // with QNAME minimisation, the name we've asked about so far
+	minimise := r.qmin
+	for i := 0; i < maxSteps; i++ {
+		name, qt := qname, qtype
+		if minimise {
+			if name = dnsutil.Below(qname, known); name != qname {
+				qt = dns.TypeNS
+			}
+		}
+
+		m, err := r.ask(ctx, d, name, qt, depth)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if zone := referral(m, d.zone, name); zone != "" {
+			d = r.delegate(m, zone, d.zone)
+			known = zone
+			continue
+		}
+		if name != qname {
+			if m.Rcode != dns.RcodeSuccess {
+				// Some servers get empty non-terminals wrong, ask for the full name instead.
+				minimise = false
+				continue
+			}
+			// Not a zone cut, or one served by the same servers: ask about one more label.
+			known = name
+			continue
+		}
+		return m, d.zone, nil
+	}
+	return nil, "", errMaxSteps
+}
+
+// closest returns the closest delegation to qname we know of. For DS, which lives at the parent side
+// of a zone cut, that is the closest delegation to the parent of qname.
+func (r *Recursor) closest(qname string, qtype uint16) *delegation {
+	off, end := 0, qname == "."
+	if qtype == dns.TypeDS && !end {
+		off, end = dns.NextLabel(qname, 0)
+	}
+	now := time.Now()
+	for ; !end; off, end = dns.NextLabel(qname, off) {
+		if d := r.infra.delegation(qname[off:], now); d != nil {
+			return d
+		}
+	}
+	return r.root
+}
+
+// referral returns the zone m delegates to, when m is a referral from a server for zone to a zone that
+// is closer to name. Otherwise it returns "".
+func referral(m *dns.Msg, zone, name string) string {
+	if m.Rcode != dns.RcodeSuccess || len(m.Answer) > 0 {
+		return ""
+	}
+	for _, rr := range m.Ns {
+		if rr.Header().Rrtype != dns.TypeNS {
+			continue
+		}
+		z := strings.ToLower(rr.Header().Name)
+		if z != zone && dns.IsSubDomain(zone, z) && dns.IsSubDomain(z, name) {
+			return z
+		}
+	}
+	return ""
+}
+
+// lame returns true if m, from a server for zone, is neither an answer nor a referral down from zone.
+func lame(m *dns.Msg, zone string) bool {
+	if m.Authoritative || m.Rcode != dns.RcodeSuccess || len(m.Answer) > 0 {
+		return false
+	}
+	for _, rr := range m.Ns {
+		if rr.Header().Rrtype == dns.TypeNS && !dns.IsSubDomain(zone, strings.ToLower(rr.Header().Name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// delegate returns the delegation to zone in the referral m, from a server for parent, and caches it.
+// Only glue within parent is used.
+func (r *Recursor) delegate(m *dns.Msg, zone, parent string) *delegation {
+	d := &delegation{zone: zone, addrs: map[string][]string{}}
+	ttl := uint32(maxTTL)
+	for _, rr := range m.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok || strings.ToLower(ns.Hdr.Name) != zone {
+			continue
+		}
+		d.ns = append(d.ns, strings.ToLower(ns.Ns))
+		if ns.Hdr.Ttl < ttl {
+			ttl = ns.Hdr.Ttl
+		}
+	}
+	for _, rr := range m.Extra {
+		name := strings.ToLower(rr.Header().Name)
+		if !dns.IsSubDomain(parent, name) {
+			continue
+		}
+		switch x := rr.(type) {
+		case *dns.A:
+			d.addrs[name] = append(d.addrs[name], x.A.String())
+		case *dns.AAAA:
+			d.addrs[name] = append(d.addrs[name], x.AAAA.String())
+		}
+	}
+	d.expire = time.Now().Add(time.Duration(ttl) * time.Second)
+	r.infra.addDelegation(d)
+	return d
+}
+
+// ask sends the query for name and qtype to the name servers of d, until one of them gives a usable
+// reply.
+func (r *Recursor) ask(ctx context.Context, d *delegation, name string, qtype uint16, depth int) (*dns.Msg, error) {
+	var err error
+	tries := 0
+	for _, i := range mrand.Perm(len(d.ns)) {
+		ns := d.ns[i]
+		addrs := d.addrs[ns]
+		if len(addrs) == 0 {
+			addrs = r.hostAddrs(ctx, ns, depth)
+		}
+		for _, addr := range addrs {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if tries++; tries > maxTries {
+				return nil, err
+			}
+			var m *dns.Msg
+			m, err = r.exchange(addr, name, qtype)
+			if err != nil {
+				OutgoingErrorCount.Add(1)
+				continue
+			}
+			if m.Rcode == dns.RcodeServerFailure || m.Rcode == dns.RcodeRefused || lame(m, d.zone) {
+				OutgoingErrorCount.Add(1)
+				err = fmt.Errorf("unusable reply (%s) from %s for %s", rcode.ToString(m.Rcode), addr, d.zone)
+				continue
+			}
+			return m, nil
+		}
+	}
+	if err == nil {
+		err = fmt.Errorf("no addresses for the name servers of %s", d.zone)
+	}
+	return nil, err
+}
+
+// hostAddrs returns the addresses of the name server name, resolving them when they're not cached.
+func (r *Recursor) hostAddrs(ctx context.Context, name string, depth int) []string {
+	now := time.Now()
+	if addrs := r.infra.host(name, now); addrs != nil {
+		return addrs
+	}
+
+	var addrs []string
+	ttl := uint32(maxTTL)
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		m, err := r.resolve(ctx, name, qtype, depth+1)
+		if err != nil {
+			log.Debugf("Failed to resolve name server %s: %s", name, err)
+			continue
+		}
+		for _, rr := range m.Answer {
+			switch x := rr.(type) {
+			case *dns.A:
+				addrs = append(addrs, x.A.String())
+			case *dns.AAAA:
+				addrs = append(addrs, x.AAAA.String())
+			default:
+				continue
+			}
+			if rr.Header().Ttl < ttl {
+				ttl = rr.Header().Ttl
+			}
+		}
+		if len(addrs) > 0 {
+			break
+		}
+	}
+	if len(addrs) > 0 {
+		r.infra.addHost(name, addrs, now.Add(time.Duration(ttl)*time.Second))
+	}
+	return addrs
+}
+
+// exchange sends the query for name and qtype to the server at addr. Unless disabled, the case of the
+// letters of name is randomised and the reply must echo it. When the reply is truncated the query is
+// sent again over TCP. The DO bit is always set, so the reply can be validated.
+func (r *Recursor) exchange(addr, name string, qtype uint16) (*dns.Msg, error) {
+	q := name
+	if r.random {
+		q = randomCase(name)
+	}
+	m := new(dns.Msg)
+	m.SetQuestion(q, qtype)
+	m.RecursionDesired = false
+	m.SetEdns0(ednsSize, true)
+
+	hostport := net.JoinHostPort(addr, r.port)
+	c := &dns.Client{Net: "udp", UDPSize: ednsSize, Timeout: r.timeout}
+	OutgoingCount.WithLabelValues("udp").Add(1)
+	ret, _, err := c.Exchange(m, hostport)
+	if err == nil && ret.Truncated {
+		c.Net = "tcp"
+		OutgoingCount.WithLabelValues("tcp").Add(1)
+		ret, _, err = c.Exchange(m, hostport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ret.Question) != 1 || ret.Question[0].Name != q || ret.Question[0].Qtype != qtype {
+		return nil, errMismatch
+	}
+	ret.Question[0].Name = name
+	for _, section := range [][]dns.RR{ret.Answer, ret.Ns, ret.Extra} {
+		for _, rr := range section {
+			if rr.Header().Name == q {
+				rr.Header().Name = name
+			}
+		}
+	}
+	return ret, nil
+}
+
+// randomCase returns name with the case of its letters randomised, see draft-vixie-dnsext-dns0x20.
+func randomCase(name string) string {
+	b := []byte(name)
+	bits := make([]byte, (len(b)+7)/8)
+	if _, err := rand.Read(bits); err != nil {
+		return name
+	}
+	for i, c := range b {
+		if c >= 'a' && c <= 'z' && bits[i/8]&(1<<uint(i%8)) != 0 {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
+
+var (
+	errMaxDepth  = errors.New("too many name servers to resolve")
+	errMaxCNAMEs = errors.New("too many CNAMEs")
+	errMaxSteps  = errors.New("too many referrals")
+	errMismatch  = errors.New("reply doesn't match the question")
+)
+
+const (
+	maxDepth  = 6     // name servers resolved to resolve a name
+	maxCNAMEs = 8     // CNAMEs followed
+	maxSteps  = 32    // referrals, and labels added with QNAME minimisation
+	maxTries  = 8     // servers tried per query
+	maxTTL    = 86400 // how long delegations and name server addresses are cached at most
+	ednsSize  = 1232
+)
@@ -0,0 +1,117 @@
+package recursor
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/caddyserver/caddy"
+)
+
+func init() {
+	caddy.RegisterPlugin("recursor", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	r, err := parseRecursor(c)
+	if err != nil {
+		return plugin.Error("recursor", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		r.Next = next
+		return r
+	})
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, OutgoingCount, OutgoingErrorCount)
+		return nil
+	})
+
+	return nil
+}
+
+func parseRecursor(c *caddy.Controller) (*Recursor, error) {
+	r := New()
+	config := dnsserver.GetConfig(c)
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) > 1 {
+			return nil, c.ArgErr()
+		}
+		if len(args) == 1 {
+			r.from = plugin.Host(args[0]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "except":
+				ignore := c.RemainingArgs()
+				if len(ignore) == 0 {
+					return nil, c.ArgErr()
+				}
+				for i := range ignore {
+					ignore[i] = plugin.Host(ignore[i]).Normalize()
+				}
+				r.ignored = ignore
+			case "hints":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				file := c.Val()
+				if !filepath.IsAbs(file) && config.Root != "" {
+					file = filepath.Join(config.Root, file)
+				}
+				f, err := os.Open(file)
+				if err != nil {
+					return nil, c.Errf("failed to open hints file '%s': %s", file, err)
+				}
+				d, err := parseHints(f, file)
+				f.Close()
+				if err != nil {
+					return nil, err
+				}
+				r.root = d
+			case "timeout":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				dur, err := time.ParseDuration(c.Val())
+				if err != nil {
+					return nil, c.Errf("invalid timeout '%s': %s", c.Val(), err)
+				}
+				if dur <= 0 {
+					return nil, c.Errf("timeout must be positive: %s", dur)
+				}
+				r.timeout = dur
+			case "no_qname_minimization":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				r.qmin = false
+			case "no_case_randomization":
+				if c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				r.random = false
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	return r, nil
+}
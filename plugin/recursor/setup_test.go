@@ -0,0 +1,93 @@
+package recursor
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		from      string
+		ignored   []string
+		qmin      bool
+		random    bool
+		timeout   time.Duration
+	}{
+		{`recursor`, false, ".", nil, true, true, defaultTimeout},
+		{`recursor example.org`, false, "example.org.", nil, true, true, defaultTimeout},
+		{"recursor {\nexcept example.org corp.example.net\n}", false, ".", []string{"example.org.", "corp.example.net."}, true, true, defaultTimeout},
+		{"recursor {\nno_qname_minimization\nno_case_randomization\ntimeout 500ms\n}", false, ".", nil, false, false, 500 * time.Millisecond},
+		// fails
+		{`recursor example.org example.net`, true, "", nil, false, false, 0},
+		{"recursor {\nexcept\n}", true, "", nil, false, false, 0},
+		{"recursor {\ntimeout 0s\n}", true, "", nil, false, false, 0},
+		{"recursor {\nno_qname_minimization please\n}", true, "", nil, false, false, 0},
+		{"recursor {\nhints /does/not/exist\n}", true, "", nil, false, false, 0},
+		{"recursor {\nforward\n}", true, "", nil, false, false, 0},
+		{"recursor\nrecursor", true, "", nil, false, false, 0},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		r, err := parseRecursor(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if r.from != tc.from || !reflect.DeepEqual(r.ignored, tc.ignored) {
+			t.Errorf("Test %d: expected %s except %v, got %s except %v", i, tc.from, tc.ignored, r.from, r.ignored)
+		}
+		if r.qmin != tc.qmin || r.random != tc.random || r.timeout != tc.timeout {
+			t.Errorf("Test %d: expected qmin %t, random %t, timeout %s, got %t, %t, %s", i, tc.qmin, tc.random, tc.timeout, r.qmin, r.random, r.timeout)
+		}
+	}
+}
+
+func TestSetupHints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recursor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "named.root")
+	hints := `.                3600000 NS   ns.root.test.
+NS.ROOT.TEST.    3600000 A    192.0.2.53
+ns.root.test.    3600000 AAAA 2001:db8::53
+`
+	if err := ioutil.WriteFile(file, []byte(hints), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := caddy.NewTestController("dns", "recursor {\nhints "+file+"\n}")
+	r, err := parseRecursor(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if !reflect.DeepEqual(r.root.ns, []string{"ns.root.test."}) {
+		t.Errorf("Expected root server ns.root.test., got %v", r.root.ns)
+	}
+	if x := r.root.addrs["ns.root.test."]; !reflect.DeepEqual(x, []string{"192.0.2.53", "2001:db8::53"}) {
+		t.Errorf("Expected the addresses of ns.root.test., got %v", x)
+	}
+
+	if _, err := parseHints(strings.NewReader("example.org. 3600 IN A 192.0.2.1\n"), "test"); err == nil {
+		t.Errorf("Expected an error for hints without root servers")
+	}
+	if d := defaultHints(); len(d.ns) != 13 || len(d.addrs) != 13 {
+		t.Errorf("Expected 13 root servers with addresses, got %d and %d", len(d.ns), len(d.addrs))
+	}
+}
@@ -0,0 +1,314 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+
+	"github.com/caddyserver/caddy"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+)
+
+var log = clog.NewWithPlugin("trace")
+
+// exporter sends finished spans in batches to an OpenTelemetry collector, using OTLP over gRPC or, when
+// the endpoint is an http(s) URL, over HTTP. Both use the protobuf encoding.
+type exporter struct {
+	endpoint string
+	resource []byte // encoded Resource, describing this CoreDNS
+
+	conn   *grpc.ClientConn // OTLP/gRPC
+	client *http.Client     // OTLP/HTTP
+
+	mu      sync.Mutex
+	spans   []*otlpSpan
+	dropped int
+
+	flush chan struct{}
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+// newExporter returns an exporter for endpoint that reports its spans as coming from service.
+func newExporter(endpoint, service string) (*exporter, error) {
+	e := &exporter{
+		endpoint: endpoint,
+		resource: pbuf(nil).message(1, keyValue("service.name", service)),
+		flush:    make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+		e.client = &http.Client{Timeout: exportTimeout}
+		return e, nil
+	}
+	conn, err := grpc.Dial(endpoint, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	e.conn = conn
+	return e, nil
+}
+
+// start starts sending the spans, every exportInterval or as soon as a batch is full.
+func (e *exporter) start() {
+	go func() {
+		defer close(e.done)
+		ticker := time.NewTicker(exportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.quit:
+				if err := e.export(); err != nil {
+					log.Warningf("Failed to export spans to %s: %s", e.endpoint, err)
+				}
+				return
+			case <-ticker.C:
+			case <-e.flush:
+			}
+			if err := e.export(); err != nil {
+				log.Warningf("Failed to export spans to %s: %s", e.endpoint, err)
+			}
+		}
+	}()
+}
+
+// stop sends the remaining spans and closes the connection to the collector.
+func (e *exporter) stop() {
+	close(e.quit)
+	<-e.done
+	if e.conn != nil {
+		e.conn.Close()
+	}
+}
+
+// add queues s for export. When the queue is full, because the collector can't keep up, s is dropped.
+func (e *exporter) add(s *otlpSpan) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.spans) >= maxQueue {
+		e.dropped++
+		return
+	}
+	e.spans = append(e.spans, s)
+	if len(e.spans) >= batchSize {
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// export sends all queued spans, in batches of at most batchSize spans.
+func (e *exporter) export() error {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	dropped := e.dropped
+	e.dropped = 0
+	e.mu.Unlock()
+
+	if dropped > 0 {
+		log.Warningf("Dropped %d spans, the export queue was full", dropped)
+	}
+	for len(spans) > 0 {
+		n := batchSize
+		if n > len(spans) {
+			n = len(spans)
+		}
+		if err := e.send(e.encode(spans[:n])); err != nil {
+			return err
+		}
+		spans = spans[n:]
+	}
+	return nil
+}
+
+// send sends an encoded ExportTraceServiceRequest to the collector.
+func (e *exporter) send(req []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	defer cancel()
+
+	if e.conn != nil {
+		var reply []byte
+		return e.conn.Invoke(ctx, exportMethod, req, &reply, grpc.ForceCodec(rawCodec{}))
+	}
+
+	r, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(req))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := e.client.Do(r.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+// encode returns the ExportTraceServiceRequest holding spans.
+func (e *exporter) encode(spans []*otlpSpan) []byte {
+	scope := pbuf(nil).message(1, pbuf(nil).str(1, "coredns").str(2, caddy.AppVersion))
+	for _, s := range spans {
+		scope = scope.message(2, encodeSpan(s))
+	}
+	rs := pbuf(nil).message(1, e.resource).message(2, scope)
+	return pbuf(nil).message(1, rs)
+}
+
+// encodeSpan returns s encoded as an OTLP Span.
+func encodeSpan(s *otlpSpan) pbuf {
+	s.Lock()
+	defer s.Unlock()
+
+	b := pbuf(nil).bytes(1, s.ctx.traceID[:]).bytes(2, s.ctx.spanID[:])
+	if s.ctx.state != "" {
+		b = b.str(3, s.ctx.state)
+	}
+	if s.parent != [8]byte{} {
+		b = b.bytes(4, s.parent[:])
+	}
+	b = b.str(5, s.name).varint(6, uint64(s.kind))
+	b = b.fixed64(7, uint64(s.start.UnixNano())).fixed64(8, uint64(s.end.UnixNano()))
+
+	failed := false
+	for k, v := range s.tags {
+		if k == string(ext.Error) {
+			failed, _ = v.(bool)
+			continue
+		}
+		b = b.message(9, keyValue(k, v))
+	}
+	for _, ev := range s.events {
+		eb := pbuf(nil).fixed64(1, uint64(ev.time.UnixNano())).str(2, ev.name)
+		for _, f := range ev.fields {
+			if f.Key() == "event" {
+				continue
+			}
+			eb = eb.message(3, keyValue(f.Key(), f.Value()))
+		}
+		b = b.message(11, eb)
+	}
+	if failed {
+		b = b.message(15, pbuf(nil).varint(3, statusError))
+	}
+	return b
+}
+
+// keyValue returns an encoded KeyValue with key k and value v.
+func keyValue(k string, v interface{}) pbuf {
+	var val pbuf
+	switch v := v.(type) {
+	case string:
+		val = val.str(1, v)
+	case bool:
+		b := uint64(0)
+		if v {
+			b = 1
+		}
+		val = val.varint(2, b)
+	case int:
+		val = val.varint(3, uint64(v))
+	case int8:
+		val = val.varint(3, uint64(v))
+	case int16:
+		val = val.varint(3, uint64(v))
+	case int32:
+		val = val.varint(3, uint64(v))
+	case int64:
+		val = val.varint(3, uint64(v))
+	case uint:
+		val = val.varint(3, uint64(v))
+	case uint8:
+		val = val.varint(3, uint64(v))
+	case uint16:
+		val = val.varint(3, uint64(v))
+	case uint32:
+		val = val.varint(3, uint64(v))
+	case uint64:
+		val = val.varint(3, v)
+	case float32:
+		val = val.fixed64(4, math.Float64bits(float64(v)))
+	case float64:
+		val = val.fixed64(4, math.Float64bits(v))
+	default:
+		val = val.str(1, fmt.Sprint(v))
+	}
+	return pbuf(nil).str(1, k).message(2, val)
+}
+
+// pbuf is a protocol buffer encoded message, the methods append a field to it. Only what is needed to
+// encode OTLP requests is implemented.
+type pbuf []byte
+
+func (b pbuf) tag(field, wire int) pbuf { return b.uvarint(uint64(field<<3 | wire)) }
+
+func (b pbuf) uvarint(v uint64) pbuf {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func (b pbuf) varint(field int, v uint64) pbuf { return b.tag(field, 0).uvarint(v) }
+
+func (b pbuf) fixed64(field int, v uint64) pbuf {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b.tag(field, 1), buf[:]...)
+}
+
+func (b pbuf) bytes(field int, v []byte) pbuf {
+	return append(b.tag(field, 2).uvarint(uint64(len(v))), v...)
+}
+
+func (b pbuf) str(field int, v string) pbuf { return b.bytes(field, []byte(v)) }
+
+func (b pbuf) message(field int, m pbuf) pbuf { return b.bytes(field, m) }
+
+// rawCodec passes already encoded messages to gRPC as is.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type: %T", v)
+	}
+	return b, nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	b, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("unexpected message type: %T", v)
+	}
+	*b = append((*b)[:0], data...)
+	return nil
+}
+
+// Name returns the name of the protobuf codec, the messages are protocol buffers.
+func (rawCodec) Name() string { return "proto" }
+
+const (
+	exportMethod   = "/opentelemetry.proto.collector.trace.v1.TraceService/Export"
+	exportInterval = time.Second
+	exportTimeout  = 10 * time.Second
+	batchSize      = 512
+	maxQueue       = 4096
+	statusError    = 2
+)
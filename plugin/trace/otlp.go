@@ -0,0 +1,362 @@
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	otlog "github.com/opentracing/opentracing-go/log"
+)
+
+// otlpTracer is an OpenTracing tracer that sends its spans to an OpenTelemetry collector. Span contexts
+// are propagated with the W3C Trace Context headers, traceparent and tracestate, and the W3C baggage header.
+type otlpTracer struct {
+	exp *exporter
+}
+
+// otlpContext implements ot.SpanContext.
+type otlpContext struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+	state   string // tracestate, passed on unchanged
+	baggage map[string]string
+}
+
+// ForeachBaggageItem implements the ot.SpanContext interface.
+func (c otlpContext) ForeachBaggageItem(handler func(k, v string) bool) {
+	for k, v := range c.baggage {
+		if !handler(k, v) {
+			return
+		}
+	}
+}
+
+// otlpSpan implements ot.Span.
+type otlpSpan struct {
+	tracer *otlpTracer
+	parent [8]byte
+
+	sync.Mutex
+	ctx    otlpContext
+	name   string
+	kind   int
+	start  time.Time
+	end    time.Time
+	tags   map[string]interface{}
+	events []otlpEvent
+}
+
+type otlpEvent struct {
+	time   time.Time
+	name   string
+	fields []otlog.Field
+}
+
+// StartSpan implements the ot.Tracer interface.
+func (t *otlpTracer) StartSpan(name string, opts ...ot.StartSpanOption) ot.Span {
+	o := ot.StartSpanOptions{}
+	for _, opt := range opts {
+		opt.Apply(&o)
+	}
+
+	s := &otlpSpan{tracer: t, name: name, kind: kindInternal, start: o.StartTime, tags: map[string]interface{}{}}
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+
+	for _, ref := range o.References {
+		parent, ok := ref.ReferencedContext.(otlpContext)
+		if !ok {
+			continue
+		}
+		s.ctx.traceID = parent.traceID
+		s.ctx.sampled = parent.sampled
+		s.ctx.state = parent.state
+		if len(parent.baggage) > 0 {
+			s.ctx.baggage = make(map[string]string, len(parent.baggage))
+			for k, v := range parent.baggage {
+				s.ctx.baggage[k] = v
+			}
+		}
+		s.parent = parent.spanID
+		break
+	}
+	if s.ctx.traceID == [16]byte{} {
+		rand.Read(s.ctx.traceID[:])
+		s.ctx.sampled = true
+	}
+	rand.Read(s.ctx.spanID[:])
+
+	for k, v := range o.Tags {
+		s.SetTag(k, v)
+	}
+	return s
+}
+
+// Inject implements the ot.Tracer interface. The TextMap and HTTPHeaders formats are supported.
+func (t *otlpTracer) Inject(sc ot.SpanContext, format interface{}, carrier interface{}) error {
+	c, ok := sc.(otlpContext)
+	if !ok {
+		return ot.ErrInvalidSpanContext
+	}
+	if format != ot.TextMap && format != ot.HTTPHeaders {
+		return ot.ErrUnsupportedFormat
+	}
+	w, ok := carrier.(ot.TextMapWriter)
+	if !ok {
+		return ot.ErrInvalidCarrier
+	}
+
+	flags := 0
+	if c.sampled {
+		flags = 1
+	}
+	w.Set(headerTraceparent, fmt.Sprintf("00-%x-%x-%02x", c.traceID, c.spanID, flags))
+	if c.state != "" {
+		w.Set(headerTracestate, c.state)
+	}
+	if len(c.baggage) > 0 {
+		items := make([]string, 0, len(c.baggage))
+		for k, v := range c.baggage {
+			items = append(items, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+		w.Set(headerBaggage, strings.Join(items, ","))
+	}
+	return nil
+}
+
+// Extract implements the ot.Tracer interface. The TextMap and HTTPHeaders formats are supported.
+func (t *otlpTracer) Extract(format interface{}, carrier interface{}) (ot.SpanContext, error) {
+	if format != ot.TextMap && format != ot.HTTPHeaders {
+		return nil, ot.ErrUnsupportedFormat
+	}
+	r, ok := carrier.(ot.TextMapReader)
+	if !ok {
+		return nil, ot.ErrInvalidCarrier
+	}
+
+	var parent, state, baggage string
+	r.ForeachKey(func(k, v string) error {
+		switch strings.ToLower(k) {
+		case headerTraceparent:
+			parent = v
+		case headerTracestate:
+			state = v
+		case headerBaggage:
+			baggage = v
+		}
+		return nil
+	})
+	if parent == "" {
+		return nil, ot.ErrSpanContextNotFound
+	}
+
+	c, err := parseTraceparent(parent)
+	if err != nil {
+		return nil, err
+	}
+	c.state = state
+	for _, item := range strings.Split(baggage, ",") {
+		// Properties, after a ';', are dropped.
+		item = strings.TrimSpace(strings.SplitN(item, ";", 2)[0])
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		k, err1 := url.QueryUnescape(strings.TrimSpace(kv[0]))
+		v, err2 := url.QueryUnescape(strings.TrimSpace(kv[1]))
+		if err1 != nil || err2 != nil || k == "" {
+			continue
+		}
+		if c.baggage == nil {
+			c.baggage = map[string]string{}
+		}
+		c.baggage[k] = v
+	}
+	return c, nil
+}
+
+// parseTraceparent parses the value of a traceparent header: version-traceid-parentid-flags.
+func parseTraceparent(s string) (otlpContext, error) {
+	c := otlpContext{}
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return c, ot.ErrSpanContextCorrupted
+	}
+	// Version 00 has exactly four fields, later versions may add more.
+	if parts[0] == "00" && len(parts) != 4 {
+		return c, ot.ErrSpanContextCorrupted
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return c, ot.ErrSpanContextCorrupted
+	}
+	if _, err := hex.Decode(c.traceID[:], []byte(parts[1])); err != nil {
+		return c, ot.ErrSpanContextCorrupted
+	}
+	if _, err := hex.Decode(c.spanID[:], []byte(parts[2])); err != nil {
+		return c, ot.ErrSpanContextCorrupted
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return c, ot.ErrSpanContextCorrupted
+	}
+	if c.traceID == [16]byte{} || c.spanID == [8]byte{} {
+		return c, ot.ErrSpanContextCorrupted
+	}
+	c.sampled = flags[0]&1 == 1
+	return c, nil
+}
+
+// Finish implements the ot.Span interface.
+func (s *otlpSpan) Finish() { s.FinishWithOptions(ot.FinishOptions{}) }
+
+// FinishWithOptions implements the ot.Span interface. Sampled spans are queued for export.
+func (s *otlpSpan) FinishWithOptions(opts ot.FinishOptions) {
+	s.Lock()
+	s.end = opts.FinishTime
+	if s.end.IsZero() {
+		s.end = time.Now()
+	}
+	for _, lr := range opts.LogRecords {
+		s.events = append(s.events, otlpEvent{time: lr.Timestamp, name: eventName(lr.Fields), fields: lr.Fields})
+	}
+	sampled := s.ctx.sampled
+	s.Unlock()
+
+	if sampled && s.tracer.exp != nil {
+		s.tracer.exp.add(s)
+	}
+}
+
+// Context implements the ot.Span interface.
+func (s *otlpSpan) Context() ot.SpanContext {
+	s.Lock()
+	defer s.Unlock()
+	return s.ctx
+}
+
+// SetOperationName implements the ot.Span interface.
+func (s *otlpSpan) SetOperationName(name string) ot.Span {
+	s.Lock()
+	defer s.Unlock()
+	s.name = name
+	return s
+}
+
+// SetTag implements the ot.Span interface. The span.kind tag sets the kind of the span, the error tag
+// its status.
+func (s *otlpSpan) SetTag(key string, value interface{}) ot.Span {
+	s.Lock()
+	defer s.Unlock()
+	if key == string(ext.SpanKind) {
+		s.kind = spanKind(value)
+		return s
+	}
+	s.tags[key] = value
+	return s
+}
+
+// LogFields implements the ot.Span interface.
+func (s *otlpSpan) LogFields(fields ...otlog.Field) {
+	s.Lock()
+	defer s.Unlock()
+	s.events = append(s.events, otlpEvent{time: time.Now(), name: eventName(fields), fields: fields})
+}
+
+// LogKV implements the ot.Span interface.
+func (s *otlpSpan) LogKV(kv ...interface{}) {
+	fields, err := otlog.InterleavedKVToFields(kv...)
+	if err != nil {
+		fields = []otlog.Field{otlog.Error(err)}
+	}
+	s.LogFields(fields...)
+}
+
+// SetBaggageItem implements the ot.Span interface.
+func (s *otlpSpan) SetBaggageItem(key, value string) ot.Span {
+	s.Lock()
+	defer s.Unlock()
+	baggage := make(map[string]string, len(s.ctx.baggage)+1)
+	for k, v := range s.ctx.baggage {
+		baggage[k] = v
+	}
+	baggage[key] = value
+	s.ctx.baggage = baggage
+	return s
+}
+
+// BaggageItem implements the ot.Span interface.
+func (s *otlpSpan) BaggageItem(key string) string {
+	s.Lock()
+	defer s.Unlock()
+	return s.ctx.baggage[key]
+}
+
+// Tracer implements the ot.Span interface.
+func (s *otlpSpan) Tracer() ot.Tracer { return s.tracer }
+
+// LogEvent implements the deprecated part of the ot.Span interface.
+func (s *otlpSpan) LogEvent(event string) { s.LogFields(otlog.String("event", event)) }
+
+// LogEventWithPayload implements the deprecated part of the ot.Span interface.
+func (s *otlpSpan) LogEventWithPayload(event string, payload interface{}) {
+	s.LogFields(otlog.String("event", event), otlog.Object("payload", payload))
+}
+
+// Log implements the deprecated part of the ot.Span interface.
+func (s *otlpSpan) Log(ld ot.LogData) {
+	if ld.Timestamp.IsZero() {
+		ld.Timestamp = time.Now()
+	}
+	lr := ld.ToLogRecord()
+	s.Lock()
+	defer s.Unlock()
+	s.events = append(s.events, otlpEvent{time: lr.Timestamp, name: eventName(lr.Fields), fields: lr.Fields})
+}
+
+// eventName returns the value of the event field, which OpenTracing uses to name a log record.
+func eventName(fields []otlog.Field) string {
+	for _, f := range fields {
+		if f.Key() == "event" {
+			return fmt.Sprint(f.Value())
+		}
+	}
+	return "log"
+}
+
+// spanKind returns the OTLP span kind of the value of a span.kind tag.
+func spanKind(v interface{}) int {
+	switch fmt.Sprint(v) {
+	case string(ext.SpanKindRPCServerEnum):
+		return kindServer
+	case string(ext.SpanKindRPCClientEnum):
+		return kindClient
+	case string(ext.SpanKindProducerEnum):
+		return kindProducer
+	case string(ext.SpanKindConsumerEnum):
+		return kindConsumer
+	}
+	return kindInternal
+}
+
+// Span kinds, as defined by OTLP.
+const (
+	kindInternal = 1
+	kindServer   = 2
+	kindClient   = 3
+	kindProducer = 4
+	kindConsumer = 5
+)
+
+const (
+	headerTraceparent = "traceparent"
+	headerTracestate  = "tracestate"
+	headerBaggage     = "baggage"
+)
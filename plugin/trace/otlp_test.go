@@ -0,0 +1,205 @@
+package trace
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ot "github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"google.golang.org/grpc"
+)
+
+func TestOTLPPropagation(t *testing.T) {
+	tr := &otlpTracer{}
+	span := tr.StartSpan("root")
+	span.SetBaggageItem("tenant", "a b")
+
+	h := http.Header{}
+	if err := tr.Inject(span.Context(), ot.HTTPHeaders, ot.HTTPHeadersCarrier(h)); err != nil {
+		t.Fatalf("Failed to inject: %s", err)
+	}
+	c := span.Context().(otlpContext)
+	if x := h.Get("traceparent"); len(x) != 55 || x[:3] != "00-" || x[len(x)-3:] != "-01" {
+		t.Errorf("Expected a sampled version 00 traceparent, got %q", x)
+	}
+
+	sc, err := tr.Extract(ot.HTTPHeaders, ot.HTTPHeadersCarrier(h))
+	if err != nil {
+		t.Fatalf("Failed to extract: %s", err)
+	}
+	child := tr.StartSpan("child", ot.ChildOf(sc)).(*otlpSpan)
+	if child.ctx.traceID != c.traceID {
+		t.Errorf("Expected trace ID %x, got %x", c.traceID, child.ctx.traceID)
+	}
+	if child.parent != c.spanID {
+		t.Errorf("Expected parent %x, got %x", c.spanID, child.parent)
+	}
+	if x := child.BaggageItem("tenant"); x != "a b" {
+		t.Errorf("Expected baggage %q, got %q", "a b", x)
+	}
+
+	tests := []struct {
+		traceparent string
+		err         error
+		sampled     bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", nil, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", nil, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", nil, true},
+		{"", ot.ErrSpanContextNotFound, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", ot.ErrSpanContextCorrupted, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", ot.ErrSpanContextCorrupted, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", ot.ErrSpanContextCorrupted, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ot.ErrSpanContextCorrupted, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01", ot.ErrSpanContextCorrupted, false},
+	}
+	for i, tc := range tests {
+		carrier := ot.TextMapCarrier{}
+		if tc.traceparent != "" {
+			carrier["traceparent"] = tc.traceparent
+		}
+		sc, err := tr.Extract(ot.TextMap, carrier)
+		if err != tc.err {
+			t.Errorf("Test %d: expected error %v, got %v", i, tc.err, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if sc.(otlpContext).sampled != tc.sampled {
+			t.Errorf("Test %d: expected sampled %t, got %t", i, tc.sampled, sc.(otlpContext).sampled)
+		}
+	}
+}
+
+func TestOTLPExportHTTP(t *testing.T) {
+	reqs := make(chan []byte, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		buf, _ := ioutil.ReadAll(r.Body)
+		reqs <- buf
+	}))
+	defer ts.Close()
+
+	_, ep, _ := normalizeEndpoint("otlp", ts.URL)
+	exp, err := newExporter(ep, "coredns-test")
+	if err != nil {
+		t.Fatalf("Failed to create exporter: %s", err)
+	}
+	testExport(t, exp, reqs)
+}
+
+func TestOTLPExportGRPC(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	reqs := make(chan []byte, 1)
+	srv := grpc.NewServer(grpc.CustomCodec(collectorCodec{}), grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+		var buf []byte
+		if err := stream.RecvMsg(&buf); err != nil {
+			return err
+		}
+		if m, _ := grpc.MethodFromServerStream(stream); m == exportMethod {
+			reqs <- buf
+		}
+		return stream.SendMsg([]byte{})
+	}))
+	go srv.Serve(l)
+	defer srv.Stop()
+
+	exp, err := newExporter(l.Addr().String(), "coredns-test")
+	if err != nil {
+		t.Fatalf("Failed to create exporter: %s", err)
+	}
+	testExport(t, exp, reqs)
+}
+
+// testExport finishes a span with exp's tracer and checks the request received by the collector.
+func testExport(t *testing.T, exp *exporter, reqs chan []byte) {
+	exp.start()
+	tr := &otlpTracer{exp: exp}
+	span := tr.StartSpan("servedns", ext.SpanKindRPCServer)
+	span.SetTag(tagName, "example.org.")
+	span.SetTag("coredns.io/retries", 1)
+	span.SetTag(string(ext.Error), true)
+	span.Finish()
+	exp.stop()
+
+	var req []byte
+	select {
+	case req = <-reqs:
+	default:
+		t.Fatal("Expected the collector to receive a request")
+	}
+
+	rs := decode(t, decode(t, req)[1][0])
+	service := decode(t, decode(t, decode(t, rs[1][0])[1][0])[2][0])[1][0]
+	if string(service) != "coredns-test" {
+		t.Errorf("Expected service name %q, got %q", "coredns-test", service)
+	}
+	spans := decode(t, rs[2][0])[2]
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	s := decode(t, spans[0])
+	if string(s[5][0]) != "servedns" {
+		t.Errorf("Expected span name %q, got %q", "servedns", s[5][0])
+	}
+	if k, _ := binary.Uvarint(s[6][0]); k != kindServer {
+		t.Errorf("Expected span kind %d, got %d", kindServer, k)
+	}
+	if len(s[15]) != 1 {
+		t.Errorf("Expected the span to have a status")
+	}
+	attrs := map[string]map[int][][]byte{}
+	for _, a := range s[9] {
+		kv := decode(t, a)
+		attrs[string(kv[1][0])] = decode(t, kv[2][0])
+	}
+	if v := attrs[tagName]; v == nil || string(v[1][0]) != "example.org." {
+		t.Errorf("Expected attribute %s to be %q", tagName, "example.org.")
+	}
+	if v := attrs["coredns.io/retries"]; v == nil || len(v[3]) != 1 || v[3][0][0] != 1 {
+		t.Errorf("Expected attribute %s to be 1", "coredns.io/retries")
+	}
+}
+
+// decode returns the fields of the protocol buffer message b. Varints are returned encoded.
+func decode(t *testing.T, b []byte) map[int][][]byte {
+	fields := map[int][][]byte{}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("Invalid tag")
+		}
+		b = b[n:]
+		var v []byte
+		switch tag & 7 {
+		case 0:
+			_, n = binary.Uvarint(b)
+			v, b = b[:n], b[n:]
+		case 1:
+			v, b = b[:8], b[8:]
+		case 2:
+			l, n := binary.Uvarint(b)
+			v, b = b[n:n+int(l)], b[n+int(l):]
+		default:
+			t.Fatalf("Unexpected wire type %d", tag&7)
+		}
+		fields[int(tag>>3)] = append(fields[int(tag>>3)], v)
+	}
+	return fields
+}
+
+// collectorCodec is the server side of rawCodec.
+type collectorCodec struct{ rawCodec }
+
+func (collectorCodec) String() string { return "proto" }
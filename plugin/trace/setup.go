@@ -2,6 +2,7 @@ package trace
 
 import (
 	"fmt"
+	"net/url"
 	"strconv"
 	"strings"
 
@@ -30,6 +31,7 @@ func setup(c *caddy.Controller) error {
 	})
 
 	c.OnStartup(t.OnStartup)
+	c.OnShutdown(t.OnShutdown)
 
 	return nil
 }
@@ -50,6 +52,10 @@ func traceParse(c *caddy.Controller) (*trace, error) {
 		case 0:
 			tr.EndpointType, tr.Endpoint, err = normalizeEndpoint(defEpType, "")
 		case 1:
+			if _, ok := supportedProviders[strings.ToLower(args[0])]; ok {
+				tr.EndpointType, tr.Endpoint, err = normalizeEndpoint(strings.ToLower(args[0]), "")
+				break
+			}
 			tr.EndpointType, tr.Endpoint, err = normalizeEndpoint(defEpType, args[0])
 		case 2:
 			epType := strings.ToLower(args[0])
@@ -110,12 +116,21 @@ func normalizeEndpoint(epType, ep string) (string, string, error) {
 		}
 	}
 
+	// OTLP/HTTP is used for URLs, which get the default path if they have none; OTLP/gRPC otherwise.
+	if epType == "otlp" {
+		if u, err := url.Parse(ep); err == nil && (u.Scheme == "http" || u.Scheme == "https") && (u.Path == "" || u.Path == "/") {
+			u.Path = "/v1/traces"
+			ep = u.String()
+		}
+	}
+
 	return epType, ep, nil
 }
 
 var supportedProviders = map[string]string{
 	"zipkin":  "localhost:9411",
 	"datadog": "localhost:8126",
+	"otlp":    "localhost:4317",
 }
 
 const (
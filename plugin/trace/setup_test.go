@@ -22,6 +22,10 @@ func TestTraceParse(t *testing.T) {
 		{`trace zipkin localhost:1234`, false, "http://localhost:1234/api/v1/spans", 1, `coredns`, false},
 		{`trace datadog localhost`, false, "localhost", 1, `coredns`, false},
 		{`trace datadog http://localhost:8127`, false, "http://localhost:8127", 1, `coredns`, false},
+		{`trace otlp`, false, "localhost:4317", 1, `coredns`, false},
+		{`trace otlp collector:4317`, false, "collector:4317", 1, `coredns`, false},
+		{`trace otlp http://collector:4318`, false, "http://collector:4318/v1/traces", 1, `coredns`, false},
+		{`trace otlp https://collector/otlp/v1/traces`, false, "https://collector/otlp/v1/traces", 1, `coredns`, false},
 		{"trace {\n every 100\n}", false, "http://localhost:9411/api/v1/spans", 100, `coredns`, false},
 		{"trace {\n every 100\n service foobar\nclient_server\n}", false, "http://localhost:9411/api/v1/spans", 100, `foobar`, true},
 		{"trace {\n every 2\n client_server true\n}", false, "http://localhost:9411/api/v1/spans", 2, `coredns`, true},
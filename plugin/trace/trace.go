@@ -39,6 +39,7 @@ type trace struct {
 	clientServer    bool
 	every           uint64
 	count           uint64
+	exporter        *exporter
 	Once            sync.Once
 }
 
@@ -56,6 +57,8 @@ func (t *trace) OnStartup() error {
 		case "datadog":
 			tracer := opentracer.New(tracer.WithAgentAddr(t.Endpoint), tracer.WithServiceName(t.serviceName), tracer.WithDebugMode(true))
 			t.tracer = tracer
+		case "otlp":
+			err = t.setupOTLP()
 		default:
 			err = fmt.Errorf("unknown endpoint type: %s", t.EndpointType)
 		}
@@ -76,6 +79,26 @@ func (t *trace) setupZipkin() error {
 	return err
 }
 
+func (t *trace) setupOTLP() error {
+	exp, err := newExporter(t.Endpoint, t.serviceName)
+	if err != nil {
+		return err
+	}
+	exp.start()
+	t.exporter = exp
+	t.tracer = &otlpTracer{exp: exp}
+	return nil
+}
+
+// OnShutdown sends the spans that have not been exported yet.
+func (t *trace) OnShutdown() error {
+	if t.exporter != nil {
+		t.exporter.stop()
+		t.exporter = nil
+	}
+	return nil
+}
+
 // Name implements the Handler interface.
 func (t *trace) Name() string { return "trace" }
 
@@ -89,13 +112,17 @@ func (t *trace) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg)
 			trace = true
 		}
 	}
-	span := ot.SpanFromContext(ctx)
-	if !trace || span != nil {
+	if !trace {
 		return plugin.NextOrFailure(t.Name(), t.Next, ctx, w, r)
 	}
 
 	req := request.Request{W: w, Req: r}
-	span = t.Tracer().StartSpan(spanName(ctx, req))
+	var opts []ot.StartSpanOption
+	// A server that continues the trace of the client already put a span in the context.
+	if parent := ot.SpanFromContext(ctx); parent != nil {
+		opts = append(opts, ot.ChildOf(parent.Context()))
+	}
+	span := t.Tracer().StartSpan(spanName(ctx, req), opts...)
 	defer span.Finish()
 
 	rw := dnstest.NewRecorder(w)
@@ -11,6 +11,7 @@ import (
 
 	"github.com/caddyserver/caddy"
 	"github.com/miekg/dns"
+	ot "github.com/opentracing/opentracing-go"
 	"github.com/opentracing/opentracing-go/mocktracer"
 )
 
@@ -96,3 +97,39 @@ func TestTrace(t *testing.T) {
 		})
 	}
 }
+
+func TestTraceChild(t *testing.T) {
+	m := mocktracer.New()
+	tr := &trace{
+		Next: test.HandlerFunc(func(_ context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+			m := new(dns.Msg)
+			m.SetRcode(r, dns.RcodeNameError)
+			w.WriteMsg(m)
+			return dns.RcodeNameError, nil
+		}),
+		every:  1,
+		tracer: m,
+	}
+
+	// The span of a server that continues the trace of its client, like DoH does.
+	parent := m.StartSpan("doh")
+	ctx := ot.ContextWithSpan(context.TODO(), parent)
+
+	w := dnstest.NewRecorder(&test.ResponseWriter{})
+	q := new(dns.Msg).SetQuestion("example.org.", dns.TypeA)
+	if _, err := tr.ServeDNS(ctx, w, q); err != nil {
+		t.Fatalf("Error during tr.ServeDNS(ctx, w, %v): %v", q, err)
+	}
+
+	fs := m.FinishedSpans()
+	if len(fs) != 2 {
+		t.Fatalf("Unexpected span count: len(fs): want 2, got %v", len(fs))
+	}
+	span := fs[1]
+	if span.ParentID != parent.Context().(mocktracer.MockSpanContext).SpanID {
+		t.Errorf("Expected the span to be a child of %v, got parent %v", parent.Context(), span.ParentID)
+	}
+	if span.Tag(tagName) != "example.org." || span.Tag(tagRcode) != "NXDOMAIN" {
+		t.Errorf("Expected name and rcode tags, got %v", span.Tags())
+	}
+}
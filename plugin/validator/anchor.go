@@ -0,0 +1,109 @@
+package validator
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/miekg/dns"
+)
+
+// rootAnchors are the DS records of the root zone's key signing keys, KSK-2017 and KSK-2024, as
+// published by IANA.
+const rootAnchors = `
+. IN DS 20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D
+. IN DS 38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16
+`
+
+// defaultAnchors returns the trust anchors for the root zone.
+func defaultAnchors() map[string][]*dns.DS {
+	anchors := map[string][]*dns.DS{}
+	if err := parseAnchors(anchors, strings.NewReader(rootAnchors), "root anchors"); err != nil {
+		panic(err)
+	}
+	return anchors
+}
+
+// parseAnchors adds the DS and DNSKEY records in zone file format read from r to anchors.
+func parseAnchors(anchors map[string][]*dns.DS, r io.Reader, file string) error {
+	zp := dns.NewZoneParser(r, ".", file)
+	n := 0
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		if err := addAnchor(anchors, rr); err != nil {
+			return err
+		}
+		n++
+	}
+	if err := zp.Err(); err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("no trust anchors in %s", file)
+	}
+	return nil
+}
+
+// addAnchor adds the trust anchor rr to anchors, a DNSKEY record is added as its DS record.
+func addAnchor(anchors map[string][]*dns.DS, rr dns.RR) error {
+	var ds *dns.DS
+	switch x := rr.(type) {
+	case *dns.DS:
+		ds = x
+	case *dns.DNSKEY:
+		if ds = x.ToDS(dns.SHA256); ds == nil {
+			return fmt.Errorf("unsupported trust anchor: %s", rr)
+		}
+	default:
+		return fmt.Errorf("trust anchor is not a DS or DNSKEY record: %s", rr)
+	}
+	if !supported(ds) {
+		return fmt.Errorf("unsupported algorithm or digest type in trust anchor: %s", rr)
+	}
+	zone := plugin.Name(ds.Hdr.Name).Normalize()
+	anchors[zone] = append(anchors[zone], ds)
+	return nil
+}
+
+// anchor returns the zone of the closest trust anchor above or at name, or "" when there is none.
+func (v *Validator) anchor(name string) string {
+	zone := ""
+	for z := range v.anchors {
+		if plugin.Name(z).Matches(name) && len(z) > len(zone) {
+			zone = z
+		}
+	}
+	return zone
+}
+
+// untrusted returns true if name is at or below a negative trust anchor.
+func (v *Validator) untrusted(name string) bool {
+	for _, n := range v.negative {
+		if plugin.Name(n).Matches(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// supported returns true if we can validate the key ds points to.
+func supported(ds *dns.DS) bool {
+	switch ds.DigestType {
+	case dns.SHA1, dns.SHA256, dns.SHA384:
+	default:
+		return false
+	}
+	return algorithms[ds.Algorithm]
+}
+
+// algorithms are the signing algorithms we can validate.
+var algorithms = map[uint8]bool{
+	dns.RSASHA1:          true,
+	dns.RSASHA1NSEC3SHA1: true,
+	dns.RSASHA256:        true,
+	dns.RSASHA512:        true,
+	dns.ECDSAP256SHA256:  true,
+	dns.ECDSAP384SHA384:  true,
+	dns.ED25519:          true,
+}
@@ -0,0 +1,52 @@
+package validator
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/miekg/dns"
+)
+
+// bogusError is returned when the validation fails. It carries the Extended DNS Error (RFC 8914) that
+// is added to the SERVFAIL reply.
+type bogusError struct {
+	code uint16
+	text string
+}
+
+func (b *bogusError) Error() string { return b.text }
+
+func bogus(code uint16, format string, a ...interface{}) error {
+	return &bogusError{code: code, text: fmt.Sprintf(format, a...)}
+}
+
+// servfail returns the SERVFAIL reply to r for b. If r uses EDNS0, the Extended DNS Error is added.
+func servfail(r *dns.Msg, b *bogusError) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetRcode(r, dns.RcodeServerFailure)
+	m.RecursionAvailable = true
+	opt := r.IsEdns0()
+	if opt == nil {
+		return m
+	}
+	m.SetEdns0(opt.UDPSize(), opt.Do())
+	data := make([]byte, 2, 2+len(b.text))
+	binary.BigEndian.PutUint16(data, b.code)
+	data = append(data, b.text...)
+	m.IsEdns0().Option = append(m.IsEdns0().Option, &dns.EDNS0_LOCAL{Code: optionEDE, Data: data})
+	return m
+}
+
+// optionEDE is the EDNS0 option code of Extended DNS Errors.
+const optionEDE = 15
+
+// Extended DNS Error codes, RFC 8914.
+const (
+	edeBogus                = 6
+	edeSignatureExpired     = 7
+	edeSignatureNotYetValid = 8
+	edeDNSKEYMissing        = 9
+	edeRRSIGsMissing        = 10
+	edeNoZoneKey            = 11
+	edeNSECMissing          = 12
+)
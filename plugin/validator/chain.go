@@ -0,0 +1,271 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+	"github.com/coredns/coredns/plugin/pkg/rcode"
+
+	"github.com/miekg/dns"
+)
+
+// validation is the validation of a single reply. It holds what is needed to query the next plugin for
+// the DS and DNSKEY records of the chain of trust.
+type validation struct {
+	*Validator
+	ctx context.Context
+	w   dns.ResponseWriter
+	now time.Time
+}
+
+// node is what the walk down the chain of trust found out about a name.
+type node struct {
+	name   string
+	state  int
+	keys   []*dns.DNSKEY // the validated keys of the zone, for a secure zone cut
+	expire time.Time
+}
+
+const (
+	secureCut   = iota // a zone cut to a signed zone
+	insecureCut        // a zone cut to an unsigned zone, or one signed with algorithms we don't support
+	noCut              // a name within the zone above it
+	noName             // a name that doesn't exist
+)
+
+// walk walks the chain of trust from the closest trust anchor down to name, and returns the closest
+// signed zone at or above name. Nil is returned when name isn't below a trust anchor, or when there
+// is an insecure zone cut on the way.
+func (val *validation) walk(name string) (*node, error) {
+	if val.untrusted(name) {
+		return nil, nil
+	}
+	anchor := val.anchor(name)
+	if anchor == "" {
+		return nil, nil
+	}
+	z, err := val.anchored(anchor)
+	if err != nil {
+		return nil, err
+	}
+	for cut := anchor; cut != name; {
+		cut = dnsutil.Below(name, cut)
+		n, err := val.cut(cut, z)
+		if err != nil {
+			return nil, err
+		}
+		switch n.state {
+		case secureCut:
+			z = n
+		case insecureCut:
+			return nil, nil
+		case noName:
+			return z, nil
+		}
+	}
+	return z, nil
+}
+
+// anchored returns the node of the trust anchor zone.
+func (val *validation) anchored(zone string) (*node, error) {
+	if n := val.cached(zone); n != nil {
+		return n, nil
+	}
+	keys, ttl, err := val.keys(zone, val.anchors[zone])
+	if err != nil {
+		return nil, err
+	}
+	return val.add(&node{name: zone, state: secureCut, keys: keys}, ttl), nil
+}
+
+// cut finds out if name, directly below the signed zone z, is a zone cut, by asking for its DS records.
+func (val *validation) cut(name string, z *node) (*node, error) {
+	if n := val.cached(name); n != nil {
+		return n, nil
+	}
+	m, err := val.query(name, dns.TypeDS)
+	if err != nil {
+		return nil, err
+	}
+
+	n := &node{name: name}
+	var ttl uint32
+	switch {
+	case m.Rcode == dns.RcodeNameError:
+		nsec, nsec3, err := val.authority(m.Ns, z)
+		if err != nil {
+			return nil, err
+		}
+		if !nxdomain(name, z.name, nsec, nsec3) {
+			return nil, bogus(edeNSECMissing, "no proof that %s doesn't exist", name)
+		}
+		n.state, ttl = noName, minTTL(m.Ns)
+		if insecureNSEC3(nsec3) || optedOut(name, z.name, nsec3) {
+			// The proof can't rule out an insecure delegation.
+			n.state = insecureCut
+		}
+
+	case m.Rcode != dns.RcodeSuccess:
+		return nil, fmt.Errorf("query for %s DS failed: %s", name, rcode.ToString(m.Rcode))
+
+	case len(m.Answer) > 0:
+		var set *rrset
+		for _, s := range rrsets(m.Answer) {
+			// A CNAME can't be at a zone cut.
+			if s.name == name && (s.rtype == dns.TypeDS || s.rtype == dns.TypeCNAME) {
+				set = s
+				break
+			}
+		}
+		if set == nil {
+			return nil, bogus(edeBogus, "no DS records for %s in the answer", name)
+		}
+		if _, err := val.verify(set, z); err != nil {
+			return nil, err
+		}
+		n.state, ttl = noCut, set.rrs[0].Header().Ttl
+		if set.rtype == dns.TypeCNAME {
+			break
+		}
+
+		var ds []*dns.DS
+		for _, rr := range set.rrs {
+			if d := rr.(*dns.DS); supported(d) {
+				ds = append(ds, d)
+			}
+		}
+		if len(ds) == 0 {
+			n.state = insecureCut
+			break
+		}
+		keys, kttl, err := val.keys(name, ds)
+		if err != nil {
+			return nil, err
+		}
+		if kttl < ttl {
+			ttl = kttl
+		}
+		n.state, n.keys = secureCut, keys
+
+	default:
+		nsec, nsec3, err := val.authority(m.Ns, z)
+		if err != nil {
+			return nil, err
+		}
+		n.state, err = noDS(name, z.name, nsec, nsec3)
+		if err != nil {
+			return nil, err
+		}
+		ttl = minTTL(m.Ns)
+	}
+	return val.add(n, ttl), nil
+}
+
+// keys returns the keys of zone, after checking that the key set is signed by a key that one of ds
+// points to. The TTL of the key set is returned too.
+func (val *validation) keys(zone string, ds []*dns.DS) ([]*dns.DNSKEY, uint32, error) {
+	m, err := val.query(zone, dns.TypeDNSKEY)
+	if err != nil {
+		return nil, 0, err
+	}
+	if m.Rcode != dns.RcodeSuccess {
+		return nil, 0, fmt.Errorf("query for %s DNSKEY failed: %s", zone, rcode.ToString(m.Rcode))
+	}
+	var set *rrset
+	for _, s := range rrsets(m.Answer) {
+		if s.name == zone && s.rtype == dns.TypeDNSKEY {
+			set = s
+		}
+	}
+	if set == nil {
+		return nil, 0, bogus(edeDNSKEYMissing, "no DNSKEY records for %s", zone)
+	}
+
+	var keys, entry []*dns.DNSKEY
+	for _, rr := range set.rrs {
+		k := rr.(*dns.DNSKEY)
+		if k.Flags&dns.ZONE == 0 {
+			continue
+		}
+		keys = append(keys, k)
+		for _, d := range ds {
+			if d.KeyTag != k.KeyTag() || d.Algorithm != k.Algorithm {
+				continue
+			}
+			if x := k.ToDS(d.DigestType); x != nil && strings.EqualFold(x.Digest, d.Digest) {
+				entry = append(entry, k)
+				break
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, 0, bogus(edeNoZoneKey, "no zone keys for %s", zone)
+	}
+	if len(entry) == 0 {
+		return nil, 0, bogus(edeDNSKEYMissing, "no DNSKEY of %s matches its DS records", zone)
+	}
+	if _, err := val.verify(set, &node{name: zone, keys: entry}); err != nil {
+		return nil, 0, err
+	}
+	return keys, set.rrs[0].Header().Ttl, nil
+}
+
+// query asks the next plugin for the records of name and qtype, with their signatures.
+func (val *validation) query(name string, qtype uint16) (*dns.Msg, error) {
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	req.CheckingDisabled = true
+	req.SetEdns0(dns.DefaultMsgSize, true)
+
+	nw := nonwriter.New(val.w)
+	rc, err := plugin.NextOrFailure(val.Name(), val.Next, val.ctx, nw, req)
+	if nw.Msg == nil {
+		if err == nil {
+			err = fmt.Errorf("no reply: %s", rcode.ToString(rc))
+		}
+		return nil, fmt.Errorf("query for %s %s failed: %s", name, dns.TypeToString[qtype], err)
+	}
+	return nw.Msg, nil
+}
+
+// cached returns the cached node of name, or nil when there is none or it expired.
+func (val *validation) cached(name string) *node {
+	el, ok := val.nodes.Get(cache.Hash([]byte(name)))
+	if !ok {
+		return nil
+	}
+	n := el.(*node)
+	if n.name != name || val.now.After(n.expire) {
+		return nil
+	}
+	return n
+}
+
+// add caches n for ttl seconds, but no longer than a day, and returns it.
+func (val *validation) add(n *node, ttl uint32) *node {
+	if ttl > maxTTL {
+		ttl = maxTTL
+	}
+	n.expire = val.now.Add(time.Duration(ttl) * time.Second)
+	val.nodes.Add(cache.Hash([]byte(n.name)), n)
+	return n
+}
+
+// minTTL returns the lowest TTL of rrs.
+func minTTL(rrs []dns.RR) uint32 {
+	ttl := uint32(maxTTL)
+	for _, rr := range rrs {
+		if rr.Header().Ttl < ttl {
+			ttl = rr.Header().Ttl
+		}
+	}
+	return ttl
+}
+
+const maxTTL = 86400
@@ -0,0 +1,222 @@
+package validator
+
+import (
+	"strings"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+
+	"github.com/miekg/dns"
+)
+
+// nxdomain returns true if nsec or nsec3 prove that name doesn't exist in zone: name is covered, and
+// so is the wildcard that could have matched it.
+func nxdomain(name, zone string, nsec []*dns.NSEC, nsec3 []*dns.NSEC3) bool {
+	if n := nsecCover(name, zone, nsec); n != nil && usable(n, name) {
+		return nsecCover(wildcard(dnsutil.ClosestEncloser(name, n.Hdr.Name, n.NextDomain)), zone, nsec) != nil
+	}
+	ce, nc := nsec3Encloser(name, zone, nsec3)
+	return nc != nil && nsec3Cover(wildcard(ce), nsec3) != nil
+}
+
+// optedOut returns true if the NSEC3 record that covers the next closer name of name has the opt-out
+// flag set. Then there may be an insecure delegation the records don't prove anything about.
+func optedOut(name, zone string, nsec3 []*dns.NSEC3) bool {
+	_, nc := nsec3Encloser(name, zone, nsec3)
+	return nc != nil && nc.Flags&optOut != 0
+}
+
+// nodata returns true if nsec or nsec3 prove that name exists in zone, but has no records of qtype. The
+// second return value is true when the proof relies on an opt-out NSEC3 record.
+func nodata(name string, qtype uint16, zone string, nsec []*dns.NSEC, nsec3 []*dns.NSEC3) (bool, bool) {
+	if bitmap, ok := exact(name, nsec, nsec3); ok {
+		if qtype != dns.TypeDS && delegation(bitmap) {
+			return false, false
+		}
+		return noType(bitmap, qtype), false
+	}
+
+	if n := nsecCover(name, zone, nsec); n != nil {
+		// An empty non-terminal: the next name is below name.
+		next := strings.ToLower(n.NextDomain)
+		if next != name && dns.IsSubDomain(name, next) {
+			return true, false
+		}
+		// Matched by a wildcard that doesn't have qtype.
+		if w := nsecMatch(wildcard(dnsutil.ClosestEncloser(name, n.Hdr.Name, n.NextDomain)), nsec); w != nil && usable(n, name) {
+			return noType(w.TypeBitMap, qtype), false
+		}
+		return false, false
+	}
+
+	ce, nc := nsec3Encloser(name, zone, nsec3)
+	if nc == nil {
+		return false, false
+	}
+	if qtype == dns.TypeDS && nc.Flags&optOut != 0 {
+		return true, true
+	}
+	if w := nsec3Match(wildcard(ce), nsec3); w != nil {
+		return noType(w.TypeBitMap, qtype), false
+	}
+	return false, false
+}
+
+// noDS returns the state of name, directly below zone, when nsec or nsec3 prove it has no DS records.
+func noDS(name, zone string, nsec []*dns.NSEC, nsec3 []*dns.NSEC3) (int, error) {
+	if insecureNSEC3(nsec3) {
+		return insecureCut, nil
+	}
+	if bitmap, ok := exact(name, nsec, nsec3); ok {
+		if dnsutil.HasType(bitmap, dns.TypeDS) {
+			return 0, bogus(edeBogus, "the proof that %s has no DS records shows it has", name)
+		}
+		if delegation(bitmap) {
+			return insecureCut, nil
+		}
+		return noCut, nil
+	}
+	proven, optout := nodata(name, dns.TypeDS, zone, nsec, nsec3)
+	switch {
+	case optout:
+		return insecureCut, nil
+	case proven:
+		return noCut, nil
+	}
+	return 0, bogus(edeNSECMissing, "no proof that %s has no DS records", name)
+}
+
+// expanded returns true if nsec or nsec3 prove that name, which was expanded from a wildcard with
+// labels labels, doesn't exist itself.
+func expanded(name string, labels int, nsec []*dns.NSEC, nsec3 []*dns.NSEC3) bool {
+	l := dns.SplitDomainName(name)
+	ce := dns.Fqdn(strings.Join(l[len(l)-labels:], "."))
+	if nsecCover(name, ce, nsec) != nil {
+		return true
+	}
+	next := dns.Fqdn(strings.Join(l[len(l)-labels-1:], "."))
+	return nsec3Cover(next, nsec3) != nil
+}
+
+// exact returns the type bitmap of the NSEC or NSEC3 record of name.
+func exact(name string, nsec []*dns.NSEC, nsec3 []*dns.NSEC3) ([]uint16, bool) {
+	if n := nsecMatch(name, nsec); n != nil {
+		return n.TypeBitMap, true
+	}
+	if n := nsec3Match(name, nsec3); n != nil {
+		return n.TypeBitMap, true
+	}
+	return nil, false
+}
+
+func nsecMatch(name string, nsec []*dns.NSEC) *dns.NSEC {
+	for _, n := range nsec {
+		if strings.EqualFold(n.Hdr.Name, name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// nsecCover returns the NSEC record that covers name, name falls strictly between its owner and next
+// name.
+func nsecCover(name, zone string, nsec []*dns.NSEC) *dns.NSEC {
+	if !dns.IsSubDomain(zone, name) {
+		return nil
+	}
+	for _, n := range nsec {
+		owner, next := strings.ToLower(n.Hdr.Name), strings.ToLower(n.NextDomain)
+		if dnsutil.CanonicalCompare(owner, name) >= 0 {
+			continue
+		}
+		// The last NSEC record in the zone points back to the apex.
+		if dnsutil.CanonicalCompare(next, owner) <= 0 || dnsutil.CanonicalCompare(name, next) < 0 {
+			return n
+		}
+	}
+	return nil
+}
+
+// usable returns false when n is at a delegation point or a DNAME above name, then it can't deny names
+// below its owner.
+func usable(n *dns.NSEC, name string) bool {
+	if !dns.IsSubDomain(strings.ToLower(n.Hdr.Name), name) {
+		return true
+	}
+	return !dnsutil.HasType(n.TypeBitMap, dns.TypeDNAME) && !delegation(n.TypeBitMap)
+}
+
+// wildcard returns the wildcard name directly below ce.
+func wildcard(ce string) string {
+	if ce == "." {
+		return "*."
+	}
+	return "*." + ce
+}
+
+// nsec3Encloser returns the closest encloser of name in zone proven by nsec3 (RFC 5155, section 8.3),
+// with the NSEC3 record that covers the next closer name. The record is nil if there is no proof.
+func nsec3Encloser(name, zone string, nsec3 []*dns.NSEC3) (string, *dns.NSEC3) {
+	for ce := name; ce != zone && dns.IsSubDomain(zone, ce); {
+		next := ce
+		ce = dnsutil.Parent(ce)
+		m := nsec3Match(ce, nsec3)
+		if m == nil {
+			continue
+		}
+		if dnsutil.HasType(m.TypeBitMap, dns.TypeDNAME) || delegation(m.TypeBitMap) {
+			return "", nil
+		}
+		return ce, nsec3Cover(next, nsec3)
+	}
+	return "", nil
+}
+
+func nsec3Match(name string, nsec3 []*dns.NSEC3) *dns.NSEC3 {
+	for _, n := range nsec3 {
+		if n.Match(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// nsec3Cover returns the NSEC3 record that covers name. The hash of name must fall strictly between the
+// hashed owner name and the next hash, dns.NSEC3.Cover also covers the owner itself.
+func nsec3Cover(name string, nsec3 []*dns.NSEC3) *dns.NSEC3 {
+	for _, n := range nsec3 {
+		if n.Cover(name) && !n.Match(name) {
+			return n
+		}
+	}
+	return nil
+}
+
+// insecureNSEC3 returns true if one of nsec3 uses a hash we don't know, or more iterations than we are
+// willing to compute (RFC 9276). Such proofs are treated as insecure.
+func insecureNSEC3(nsec3 []*dns.NSEC3) bool {
+	for _, n := range nsec3 {
+		if n.Hash != dns.SHA1 || n.Iterations > maxIterations {
+			return true
+		}
+	}
+	return false
+}
+
+// noType returns true if the bitmap proves there is no record of qtype, nor a CNAME.
+func noType(bitmap []uint16, qtype uint16) bool {
+	if dnsutil.HasType(bitmap, qtype) || dnsutil.HasType(bitmap, dns.TypeCNAME) {
+		return false
+	}
+	// A DS record lives in the parent zone, the child's apex can't deny it.
+	return !(qtype == dns.TypeDS && dnsutil.HasType(bitmap, dns.TypeSOA))
+}
+
+// delegation returns true if the bitmap is of a zone cut, with NS records but no SOA.
+func delegation(bitmap []uint16) bool {
+	return dnsutil.HasType(bitmap, dns.TypeNS) && !dnsutil.HasType(bitmap, dns.TypeSOA)
+}
+
+const (
+	optOut        = 1
+	maxIterations = 150
+)
@@ -0,0 +1,117 @@
+package validator
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+
+	"github.com/miekg/dns"
+)
+
+// nsec3Chain returns the NSEC3 chain for the names with their types, with the opt-out flag set if optout
+// is true.
+func nsec3Chain(names map[string][]uint16, optout bool) []*dns.NSEC3 {
+	var chain []*dns.NSEC3
+	for name, types := range names {
+		h := dns.HashName(name, dns.SHA1, 1, "AABB")
+		n := &dns.NSEC3{
+			Hdr:        dns.RR_Header{Name: h + ".example.org.", Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: 3600},
+			Hash:       dns.SHA1,
+			Iterations: 1,
+			Salt:       "AABB",
+			SaltLength: 2,
+			HashLength: 20,
+			TypeBitMap: types,
+		}
+		if optout {
+			n.Flags = optOut
+		}
+		chain = append(chain, n)
+	}
+	sort.Slice(chain, func(i, j int) bool { return chain[i].Hdr.Name < chain[j].Hdr.Name })
+	for i, n := range chain {
+		next := chain[(i+1)%len(chain)].Hdr.Name
+		n.NextDomain = next[:len(next)-len(".example.org.")]
+	}
+	return chain
+}
+
+func TestNSEC3Denial(t *testing.T) {
+	chain := nsec3Chain(map[string][]uint16{
+		"example.org.":     {dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeDNSKEY, dns.TypeNSEC3PARAM},
+		"www.example.org.": {dns.TypeA, dns.TypeRRSIG},
+		"sub.example.org.": {dns.TypeNS},
+	}, false)
+
+	if !nxdomain("nope.example.org.", "example.org.", nil, chain) {
+		t.Errorf("Expected nope.example.org. to be proven not to exist")
+	}
+	if nxdomain("www.example.org.", "example.org.", nil, chain) {
+		t.Errorf("Expected www.example.org. not to be proven not to exist")
+	}
+	if ok, _ := nodata("www.example.org.", dns.TypeMX, "example.org.", nil, chain); !ok {
+		t.Errorf("Expected www.example.org. to be proven to have no MX records")
+	}
+	if ok, _ := nodata("www.example.org.", dns.TypeA, "example.org.", nil, chain); ok {
+		t.Errorf("Expected www.example.org. not to be proven to have no A records")
+	}
+
+	tests := []struct {
+		name   string
+		state  int
+		optout bool
+		bogus  bool
+	}{
+		{"sub.example.org.", insecureCut, false, false},
+		{"www.example.org.", noCut, false, false},
+		{"other.example.org.", 0, false, true},
+		{"other.example.org.", insecureCut, true, false},
+	}
+	for i, tc := range tests {
+		names := map[string][]uint16{
+			"example.org.":     {dns.TypeNS, dns.TypeSOA, dns.TypeRRSIG, dns.TypeDNSKEY, dns.TypeNSEC3PARAM},
+			"www.example.org.": {dns.TypeA, dns.TypeRRSIG},
+			"sub.example.org.": {dns.TypeNS},
+		}
+		state, err := noDS(tc.name, "example.org.", nil, nsec3Chain(names, tc.optout))
+		if tc.bogus {
+			if _, ok := err.(*bogusError); !ok {
+				t.Errorf("Test %d: expected a bogus error, got %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if state != tc.state {
+			t.Errorf("Test %d: expected state %d, got %d", i, tc.state, state)
+		}
+	}
+}
+
+func TestInsecureNSEC3(t *testing.T) {
+	chain := nsec3Chain(map[string][]uint16{"example.org.": {dns.TypeSOA}}, false)
+	if insecureNSEC3(chain) {
+		t.Errorf("Expected a single iteration to be secure")
+	}
+	chain[0].Iterations = 500
+	if !insecureNSEC3(chain) {
+		t.Errorf("Expected %d iterations to be insecure", chain[0].Iterations)
+	}
+}
+
+func TestCanonicalCompare(t *testing.T) {
+	names := []string{"example.", "a.example.", "yljkjljk.a.example.", "z.a.example.", "zabc.a.example.", "z.example.", "*.z.example.", "a.z.example."}
+	// Sorted as in RFC 4034, section 6.1, without the upper case and escaped names.
+	expected := []string{"example.", "a.example.", "yljkjljk.a.example.", "z.a.example.", "zabc.a.example.", "z.example.", "*.z.example.", "a.z.example."}
+	sorted := append([]string(nil), names...)
+	sort.Slice(sorted, func(i, j int) bool { return dnsutil.CanonicalCompare(sorted[i], sorted[j]) < 0 })
+	for i := range expected {
+		if sorted[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, sorted)
+			break
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package validator
+
+import (
+	"github.com/coredns/coredns/plugin"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Variables declared for monitoring.
+var (
+	ValidationCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: plugin.Namespace,
+		Subsystem: "validator",
+		Name:      "validation_count_total",
+		Help:      "Counter of validated replies per result.",
+	}, []string{"result"})
+)
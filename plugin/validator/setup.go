@@ -0,0 +1,122 @@
+package validator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/coredns/coredns/core/dnsserver"
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/metrics"
+
+	"github.com/caddyserver/caddy"
+	"github.com/miekg/dns"
+)
+
+func init() {
+	caddy.RegisterPlugin("validator", caddy.Plugin{
+		ServerType: "dns",
+		Action:     setup,
+	})
+}
+
+func setup(c *caddy.Controller) error {
+	v, err := parseValidator(c)
+	if err != nil {
+		return plugin.Error("validator", err)
+	}
+
+	dnsserver.GetConfig(c).AddPlugin(func(next plugin.Handler) plugin.Handler {
+		v.Next = next
+		return v
+	})
+
+	c.OnStartup(func() error {
+		metrics.MustRegister(c, ValidationCount)
+		return nil
+	})
+
+	return nil
+}
+
+func parseValidator(c *caddy.Controller) (*Validator, error) {
+	v := New()
+	config := dnsserver.GetConfig(c)
+	anchors := map[string][]*dns.DS{}
+
+	i := 0
+	for c.Next() {
+		if i > 0 {
+			return nil, plugin.ErrOnce
+		}
+		i++
+
+		args := c.RemainingArgs()
+		if len(args) > 1 {
+			return nil, c.ArgErr()
+		}
+		if len(args) == 1 {
+			v.from = plugin.Host(args[0]).Normalize()
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "except":
+				ignore := c.RemainingArgs()
+				if len(ignore) == 0 {
+					return nil, c.ArgErr()
+				}
+				for i := range ignore {
+					ignore[i] = plugin.Host(ignore[i]).Normalize()
+				}
+				v.ignored = ignore
+			case "trust_anchor":
+				rr := c.RemainingArgs()
+				if len(rr) == 0 {
+					return nil, c.ArgErr()
+				}
+				a, err := dns.NewRR(strings.Join(rr, " "))
+				if err != nil {
+					return nil, err
+				}
+				if a == nil {
+					return nil, c.ArgErr()
+				}
+				if err := addAnchor(anchors, a); err != nil {
+					return nil, err
+				}
+			case "trust_anchor_file":
+				if !c.NextArg() {
+					return nil, c.ArgErr()
+				}
+				file := c.Val()
+				if !filepath.IsAbs(file) && config.Root != "" {
+					file = filepath.Join(config.Root, file)
+				}
+				f, err := os.Open(file)
+				if err != nil {
+					return nil, err
+				}
+				err = parseAnchors(anchors, f, file)
+				f.Close()
+				if err != nil {
+					return nil, err
+				}
+			case "negative_trust_anchor":
+				names := c.RemainingArgs()
+				if len(names) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, n := range names {
+					v.negative = append(v.negative, plugin.Host(n).Normalize())
+				}
+			default:
+				return nil, c.Errf("unknown property '%s'", c.Val())
+			}
+		}
+	}
+	if len(anchors) > 0 {
+		v.anchors = anchors
+	}
+	return v, nil
+}
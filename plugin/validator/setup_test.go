@@ -0,0 +1,97 @@
+package validator
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/caddyserver/caddy"
+)
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		from      string
+		ignored   []string
+		anchors   []string
+		negative  []string
+	}{
+		{`validator`, false, ".", nil, []string{"."}, nil},
+		{`validator example.org`, false, "example.org.", nil, []string{"."}, nil},
+		{"validator {\nexcept example.org\n}", false, ".", []string{"example.org."}, []string{"."}, nil},
+		{"validator {\nnegative_trust_anchor corp.example.org example.net\n}", false, ".", nil, []string{"."}, []string{"corp.example.org.", "example.net."}},
+		{"validator {\ntrust_anchor example.org. DS 12345 13 2 6F7FAD6AB4EA4F1F0D0BA1D3B4C2F1C1C1B0A1B5D1E0F2A3B4C5D6E7F8091A2B\n}", false, ".", nil, []string{"example.org."}, nil},
+		{"validator {\ntrust_anchor example.org. DNSKEY 257 3 13 ZWxlY3Ryb25pYyBtYWlsIGZvciB0aGUgdGVzdCBvZiB0aGUgdmFsaWRhdG9yIHBsdWdpbiBvayBvayBvaw==\n}", false, ".", nil, []string{"example.org."}, nil},
+		// fails
+		{`validator example.org example.net`, true, "", nil, nil, nil},
+		{"validator {\nexcept\n}", true, "", nil, nil, nil},
+		{"validator {\nnegative_trust_anchor\n}", true, "", nil, nil, nil},
+		{"validator {\ntrust_anchor\n}", true, "", nil, nil, nil},
+		{"validator {\ntrust_anchor example.org. A 192.0.2.1\n}", true, "", nil, nil, nil},
+		{"validator {\ntrust_anchor example.org. DS 12345 99 2 6F7FAD6AB4EA4F1F0D0BA1D3B4C2F1C1C1B0A1B5D1E0F2A3B4C5D6E7F8091A2B\n}", true, "", nil, nil, nil},
+		{"validator {\ntrust_anchor_file /does/not/exist\n}", true, "", nil, nil, nil},
+		{"validator {\nforward\n}", true, "", nil, nil, nil},
+		{"validator\nvalidator", true, "", nil, nil, nil},
+	}
+	for i, tc := range tests {
+		c := caddy.NewTestController("dns", tc.input)
+		v, err := parseValidator(c)
+		if tc.shouldErr {
+			if err == nil {
+				t.Errorf("Test %d: expected error, got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if v.from != tc.from || !reflect.DeepEqual(v.ignored, tc.ignored) {
+			t.Errorf("Test %d: expected %s except %v, got %s except %v", i, tc.from, tc.ignored, v.from, v.ignored)
+		}
+		var anchors []string
+		for z := range v.anchors {
+			anchors = append(anchors, z)
+		}
+		if !reflect.DeepEqual(anchors, tc.anchors) {
+			t.Errorf("Test %d: expected trust anchors for %v, got %v", i, tc.anchors, anchors)
+		}
+		if !reflect.DeepEqual(v.negative, tc.negative) {
+			t.Errorf("Test %d: expected negative trust anchors %v, got %v", i, tc.negative, v.negative)
+		}
+	}
+}
+
+func TestSetupTrustAnchorFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "validator")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "anchors")
+	if err := ioutil.WriteFile(file, []byte(rootAnchors), 0644); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := ioutil.WriteFile(empty, []byte("; nothing here\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := caddy.NewTestController("dns", "validator {\ntrust_anchor_file "+file+"\n}")
+	v, err := parseValidator(c)
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if x := len(v.anchors["."]); x != 2 {
+		t.Errorf("Expected 2 trust anchors for the root, got %d", x)
+	}
+
+	c = caddy.NewTestController("dns", "validator {\ntrust_anchor_file "+empty+"\n}")
+	if _, err := parseValidator(c); err == nil {
+		t.Errorf("Expected an error for a file without trust anchors")
+	}
+}
@@ -0,0 +1,143 @@
+// Package validator implements a DNSSEC validating plugin. It validates the replies of the next plugin,
+// walking the chain of trust down from a trust anchor.
+package validator
+
+import (
+	"context"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	clog "github.com/coredns/coredns/plugin/pkg/log"
+	"github.com/coredns/coredns/plugin/pkg/nonwriter"
+	"github.com/coredns/coredns/request"
+
+	"github.com/miekg/dns"
+)
+
+var log = clog.NewWithPlugin("validator")
+
+// Validator is a plugin that validates the replies of the next plugin.
+type Validator struct {
+	from    string
+	ignored []string
+
+	anchors  map[string][]*dns.DS // trust anchors per zone, DNSKEY anchors are kept as their DS
+	negative []string             // negative trust anchors (RFC 7646), names that aren't validated
+
+	nodes *cache.Cache // the zone cuts found walking the chain of trust
+
+	Next plugin.Handler
+}
+
+// New returns a new Validator that uses the root zone's trust anchors.
+func New() *Validator {
+	return &Validator{
+		from:    ".",
+		anchors: defaultAnchors(),
+		nodes:   cache.New(nodesSize),
+	}
+}
+
+// ServeDNS implements plugin.Handler.
+func (v *Validator) ServeDNS(ctx context.Context, w dns.ResponseWriter, r *dns.Msg) (int, error) {
+	state := request.Request{W: w, Req: r}
+	if !v.match(state) {
+		return plugin.NextOrFailure(v.Name(), v.Next, ctx, w, r)
+	}
+
+	// Ask for the DNSSEC records, and for the data even if it doesn't validate upstream: we validate it
+	// ourselves.
+	req := r.Copy()
+	req.CheckingDisabled = true
+	if opt := req.IsEdns0(); opt != nil {
+		opt.SetDo()
+	} else {
+		req.SetEdns0(dns.DefaultMsgSize, true)
+	}
+
+	nw := nonwriter.New(w)
+	rcode, err := plugin.NextOrFailure(v.Name(), v.Next, ctx, nw, req)
+	if !plugin.ClientWrite(rcode) || nw.Msg == nil {
+		return rcode, err
+	}
+	m := nw.Msg
+	m.AuthenticatedData = false
+	m.CheckingDisabled = r.CheckingDisabled
+
+	if !r.CheckingDisabled && (m.Rcode == dns.RcodeSuccess || m.Rcode == dns.RcodeNameError) {
+		val := &validation{Validator: v, ctx: ctx, w: w, now: time.Now()}
+		secure, err := val.validate(state.Name(), state.QType(), m)
+		if err != nil {
+			if b, ok := err.(*bogusError); ok {
+				ValidationCount.WithLabelValues("bogus").Add(1)
+				w.WriteMsg(servfail(r, b))
+				return dns.RcodeSuccess, err
+			}
+			return dns.RcodeServerFailure, err
+		}
+		if secure {
+			ValidationCount.WithLabelValues("secure").Add(1)
+			m.AuthenticatedData = state.Do() || r.AuthenticatedData
+		} else {
+			ValidationCount.WithLabelValues("insecure").Add(1)
+		}
+	}
+
+	if !state.Do() {
+		strip(m, state.QType(), r.IsEdns0() != nil)
+	}
+	w.WriteMsg(m)
+	return rcode, err
+}
+
+// Name implements plugin.Handler.
+func (v *Validator) Name() string { return "validator" }
+
+func (v *Validator) match(state request.Request) bool {
+	if !plugin.Name(v.from).Matches(state.Name()) {
+		return false
+	}
+	for _, ignore := range v.ignored {
+		if plugin.Name(ignore).Matches(state.Name()) {
+			return false
+		}
+	}
+	return true
+}
+
+// strip removes the DNSSEC records the client didn't ask for from m, and the DO bit we added to the
+// query. If the client didn't use EDNS0, the OPT record is removed.
+func strip(m *dns.Msg, qtype uint16, edns bool) {
+	m.Answer = stripSection(m.Answer, qtype)
+	m.Ns = stripSection(m.Ns, qtype)
+	m.Extra = stripSection(m.Extra, qtype)
+	for i, rr := range m.Extra {
+		opt, ok := rr.(*dns.OPT)
+		if !ok {
+			continue
+		}
+		if !edns {
+			m.Extra = append(m.Extra[:i], m.Extra[i+1:]...)
+			break
+		}
+		opt.SetDo(false)
+	}
+}
+
+func stripSection(rrs []dns.RR, qtype uint16) []dns.RR {
+	j := 0
+	for _, rr := range rrs {
+		switch t := rr.Header().Rrtype; t {
+		case dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3:
+			if t != qtype {
+				continue
+			}
+		}
+		rrs[j] = rr
+		j++
+	}
+	return rrs[:j]
+}
+
+const nodesSize = 10000
@@ -0,0 +1,386 @@
+package validator
+
+import (
+	"context"
+	"crypto"
+	"encoding/binary"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coredns/coredns/plugin"
+	"github.com/coredns/coredns/plugin/file"
+	"github.com/coredns/coredns/plugin/pkg/cache"
+	"github.com/coredns/coredns/plugin/pkg/dnstest"
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+// signer holds the key a test zone is signed with.
+type signer struct {
+	key  *dns.DNSKEY
+	priv crypto.Signer
+}
+
+func newSigner(t *testing.T, origin string) *signer {
+	k := &dns.DNSKEY{
+		Hdr:       dns.RR_Header{Name: origin, Rrtype: dns.TypeDNSKEY, Class: dns.ClassINET, Ttl: 3600},
+		Flags:     dns.ZONE | dns.SEP,
+		Protocol:  3,
+		Algorithm: dns.ECDSAP256SHA256,
+	}
+	priv, err := k.Generate(256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signer{key: k, priv: priv.(crypto.Signer)}
+}
+
+func (s *signer) ds() string { return s.key.ToDS(dns.SHA256).String() }
+
+// sign signs rrset, valid from inception until expiration.
+func (s *signer) sign(t *testing.T, rrset []dns.RR, inception, expiration time.Time) *dns.RRSIG {
+	sig := &dns.RRSIG{
+		Hdr:        dns.RR_Header{Ttl: rrset[0].Header().Ttl},
+		Algorithm:  s.key.Algorithm,
+		KeyTag:     s.key.KeyTag(),
+		SignerName: s.key.Hdr.Name,
+		Inception:  uint32(inception.Unix()),
+		Expiration: uint32(expiration.Unix()),
+	}
+	if err := sig.Sign(s.priv, rrset); err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// signZone returns the records of the zone origin with the NSEC chain and the signatures added.
+func (s *signer) signZone(t *testing.T, origin string, records []string) []dns.RR {
+	rrs := []dns.RR{s.key}
+	for _, r := range records {
+		rr, err := dns.NewRR(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rrs = append(rrs, rr)
+	}
+
+	types := map[string][]uint16{}
+	cuts := map[string]bool{}
+	for _, rr := range rrs {
+		name := rr.Header().Name
+		types[name] = append(types[name], rr.Header().Rrtype)
+		if rr.Header().Rrtype == dns.TypeNS && name != origin {
+			cuts[name] = true
+		}
+	}
+	// Names below a zone cut are glue, they are not part of the zone.
+	var names []string
+	for name := range types {
+		glue := false
+		for cut := range cuts {
+			if name != cut && dns.IsSubDomain(cut, name) {
+				glue = true
+			}
+		}
+		if !glue {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool { return dnsutil.CanonicalCompare(names[i], names[j]) < 0 })
+
+	for i, name := range names {
+		bitmap := append(types[name], dns.TypeNSEC, dns.TypeRRSIG)
+		sort.Slice(bitmap, func(i, j int) bool { return bitmap[i] < bitmap[j] })
+		rrs = append(rrs, &dns.NSEC{
+			Hdr:        dns.RR_Header{Name: name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: 3600},
+			NextDomain: names[(i+1)%len(names)],
+			TypeBitMap: bitmap,
+		})
+	}
+
+	now := time.Now()
+	var sigs []dns.RR
+	for _, name := range names {
+		sets := map[uint16][]dns.RR{}
+		for _, rr := range rrs {
+			if rr.Header().Name == name {
+				sets[rr.Header().Rrtype] = append(sets[rr.Header().Rrtype], rr)
+			}
+		}
+		for rtype, set := range sets {
+			if cuts[name] && rtype != dns.TypeDS && rtype != dns.TypeNSEC {
+				continue
+			}
+			sigs = append(sigs, s.sign(t, set, now.Add(-time.Hour), now.Add(time.Hour)))
+		}
+	}
+	return append(rrs, sigs...)
+}
+
+// world serves the zones of a small signed hierarchy, as a recursive resolver would return it.
+type world struct {
+	zones map[string]*file.File
+	names []string
+
+	queries int
+}
+
+// newWorld returns the hierarchy and the key of its root zone:
+//
+//	.                signed
+//	org.             signed
+//	example.org.     signed, with a wildcard, an empty non-terminal, a CNAME into insecure.org., a
+//	                 record with a broken signature and one with an expired signature
+//	insecure.org.    unsigned
+//	bogus.org.       signed, but with a different key than its DS record points to
+func newWorld(t *testing.T) (*world, *signer) {
+	root, org, example, bogus, other := newSigner(t, "."), newSigner(t, "org."), newSigner(t, "example.org."), newSigner(t, "bogus.org."), newSigner(t, "bogus.org.")
+
+	zones := map[string][]dns.RR{
+		".": root.signZone(t, ".", []string{
+			". 3600 IN SOA a.root-servers.net. hostmaster.root. 1 7200 3600 1209600 3600",
+			". 3600 IN NS a.root-servers.net.",
+			"org. 3600 IN NS ns.org.",
+			"ns.org. 3600 IN A 192.0.2.53",
+			org.ds(),
+		}),
+		"org.": org.signZone(t, "org.", []string{
+			"org. 3600 IN SOA ns.org. hostmaster.org. 1 7200 3600 1209600 3600",
+			"org. 3600 IN NS ns.org.",
+			"ns.org. 3600 IN A 192.0.2.53",
+			"example.org. 3600 IN NS ns.example.org.",
+			"ns.example.org. 3600 IN A 192.0.2.54",
+			example.ds(),
+			"insecure.org. 3600 IN NS ns.insecure.org.",
+			"ns.insecure.org. 3600 IN A 192.0.2.55",
+			"bogus.org. 3600 IN NS ns.bogus.org.",
+			"ns.bogus.org. 3600 IN A 192.0.2.56",
+			other.ds(),
+		}),
+		"example.org.": example.signZone(t, "example.org.", []string{
+			"example.org. 3600 IN SOA ns.example.org. hostmaster.example.org. 1 7200 3600 1209600 3600",
+			"example.org. 3600 IN NS ns.example.org.",
+			"ns.example.org. 3600 IN A 192.0.2.54",
+			"www.example.org. 3600 IN A 192.0.2.1",
+			"*.wild.example.org. 3600 IN A 192.0.2.2",
+			"deep.ent.example.org. 3600 IN A 192.0.2.3",
+			"alias.example.org. 3600 IN CNAME www.insecure.org.",
+			"broken.example.org. 3600 IN A 192.0.2.4",
+			"expired.example.org. 3600 IN A 192.0.2.5",
+		}),
+		"insecure.org.": {
+			test.SOA("insecure.org. 3600 IN SOA ns.insecure.org. hostmaster.insecure.org. 1 7200 3600 1209600 3600"),
+			test.NS("insecure.org. 3600 IN NS ns.insecure.org."),
+			test.A("ns.insecure.org. 3600 IN A 192.0.2.55"),
+			test.A("www.insecure.org. 3600 IN A 192.0.2.10"),
+		},
+		"bogus.org.": bogus.signZone(t, "bogus.org.", []string{
+			"bogus.org. 3600 IN SOA ns.bogus.org. hostmaster.bogus.org. 1 7200 3600 1209600 3600",
+			"bogus.org. 3600 IN NS ns.bogus.org.",
+			"ns.bogus.org. 3600 IN A 192.0.2.56",
+			"www.bogus.org. 3600 IN A 192.0.2.20",
+		}),
+	}
+
+	// Break the signature of broken.example.org. and let the one of expired.example.org. expire.
+	for i, rr := range zones["example.org."] {
+		switch rr.Header().Name {
+		case "broken.example.org.":
+			if a, ok := rr.(*dns.A); ok {
+				a.A = a.A.To4()
+				a.A[3]++
+			}
+		case "expired.example.org.":
+			if sig, ok := rr.(*dns.RRSIG); ok && sig.TypeCovered == dns.TypeA {
+				a := test.A("expired.example.org. 3600 IN A 192.0.2.5")
+				zones["example.org."][i] = example.sign(t, []dns.RR{a}, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+			}
+		}
+	}
+
+	w := &world{zones: map[string]*file.File{}}
+	for origin, rrs := range zones {
+		text := ""
+		for _, rr := range rrs {
+			text += rr.String() + "\n"
+		}
+		z, err := file.Parse(strings.NewReader(text), origin, "stdin", 0)
+		if err != nil {
+			t.Fatalf("Failed to parse zone %s: %s", origin, err)
+		}
+		w.zones[origin] = &file.File{Zones: file.Zones{Z: map[string]*file.Zone{origin: z}, Names: []string{origin}}}
+		w.names = append(w.names, origin)
+	}
+	return w, root
+}
+
+// ServeDNS implements plugin.Handler, it answers from the zone the query would be sent to.
+func (w *world) ServeDNS(ctx context.Context, rw dns.ResponseWriter, r *dns.Msg) (int, error) {
+	w.queries++
+	name := strings.ToLower(r.Question[0].Name)
+	if r.Question[0].Qtype == dns.TypeDS {
+		name = dnsutil.Parent(name)
+	}
+	zone := plugin.Zones(w.names).Matches(name)
+	return w.zones[zone].ServeDNS(ctx, rw, r)
+}
+
+func (w *world) Name() string { return "world" }
+
+func newTestValidator(t *testing.T) (*Validator, *world) {
+	w, root := newWorld(t)
+	v := New()
+	v.anchors = map[string][]*dns.DS{".": {root.key.ToDS(dns.SHA256)}}
+	v.Next = w
+	return v, w
+}
+
+func TestValidator(t *testing.T) {
+	v, _ := newTestValidator(t)
+
+	tests := []struct {
+		qname string
+		qtype uint16
+		do    bool
+		rcode int
+		ad    bool
+		ede   int // expected Extended DNS Error code, -1 for none
+	}{
+		{"www.example.org.", dns.TypeA, true, dns.RcodeSuccess, true, -1},
+		{"www.example.org.", dns.TypeMX, true, dns.RcodeSuccess, true, -1},
+		{"nope.example.org.", dns.TypeA, true, dns.RcodeNameError, true, -1},
+		{"a.wild.example.org.", dns.TypeA, true, dns.RcodeSuccess, true, -1},
+		{"a.wild.example.org.", dns.TypeTXT, true, dns.RcodeSuccess, true, -1},
+		{"ent.example.org.", dns.TypeA, true, dns.RcodeSuccess, true, -1},
+		{"example.org.", dns.TypeDNSKEY, true, dns.RcodeSuccess, true, -1},
+		{"example.org.", dns.TypeDS, true, dns.RcodeSuccess, true, -1},
+		{"insecure.org.", dns.TypeDS, true, dns.RcodeSuccess, true, -1},
+		{"www.insecure.org.", dns.TypeA, true, dns.RcodeSuccess, false, -1},
+		{"alias.example.org.", dns.TypeA, true, dns.RcodeSuccess, false, -1},
+		{"www.bogus.org.", dns.TypeA, true, dns.RcodeServerFailure, false, edeDNSKEYMissing},
+		{"broken.example.org.", dns.TypeA, true, dns.RcodeServerFailure, false, edeBogus},
+		{"expired.example.org.", dns.TypeA, true, dns.RcodeServerFailure, false, edeSignatureExpired},
+		// Without DO the AD bit is only set when the query has it.
+		{"www.example.org.", dns.TypeA, false, dns.RcodeSuccess, false, -1},
+	}
+
+	for i, tc := range tests {
+		m := new(dns.Msg)
+		m.SetQuestion(tc.qname, tc.qtype)
+		m.SetEdns0(4096, tc.do)
+
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		_, err := v.ServeDNS(context.TODO(), rec, m)
+		if tc.rcode == dns.RcodeServerFailure && err == nil {
+			t.Errorf("Test %d: expected an error, got none", i)
+		}
+		if tc.rcode != dns.RcodeServerFailure && err != nil {
+			t.Errorf("Test %d: expected no error, got %s", i, err)
+			continue
+		}
+		if rec.Msg == nil {
+			t.Errorf("Test %d: expected a reply", i)
+			continue
+		}
+		if rec.Msg.Rcode != tc.rcode {
+			t.Errorf("Test %d: expected rcode %s, got %s", i, dns.RcodeToString[tc.rcode], dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if rec.Msg.AuthenticatedData != tc.ad {
+			t.Errorf("Test %d: expected AD %t, got %t", i, tc.ad, rec.Msg.AuthenticatedData)
+		}
+		if x := ede(rec.Msg); x != tc.ede {
+			t.Errorf("Test %d: expected extended error %d, got %d", i, tc.ede, x)
+		}
+		if !tc.do {
+			for _, rr := range rec.Msg.Answer {
+				if rr.Header().Rrtype == dns.TypeRRSIG {
+					t.Errorf("Test %d: expected no signatures without DO, got %s", i, rr)
+				}
+			}
+			if opt := rec.Msg.IsEdns0(); opt != nil && opt.Do() {
+				t.Errorf("Test %d: expected no DO bit in the reply", i)
+			}
+		}
+	}
+}
+
+// ede returns the Extended DNS Error code in m, or -1 if there is none.
+func ede(m *dns.Msg) int {
+	opt := m.IsEdns0()
+	if opt == nil {
+		return -1
+	}
+	for _, o := range opt.Option {
+		if l, ok := o.(*dns.EDNS0_LOCAL); ok && l.Code == optionEDE && len(l.Data) >= 2 {
+			return int(binary.BigEndian.Uint16(l.Data))
+		}
+	}
+	return -1
+}
+
+func TestValidatorCheckingDisabled(t *testing.T) {
+	v, _ := newTestValidator(t)
+
+	m := new(dns.Msg)
+	m.SetQuestion("broken.example.org.", dns.TypeA)
+	m.CheckingDisabled = true
+	rec := dnstest.NewRecorder(&test.ResponseWriter{})
+	if _, err := v.ServeDNS(context.TODO(), rec, m); err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	if rec.Msg.Rcode != dns.RcodeSuccess || len(rec.Msg.Answer) != 1 || rec.Msg.AuthenticatedData {
+		t.Errorf("Expected the unvalidated answer, got %s", rec.Msg)
+	}
+	if rec.Msg.IsEdns0() != nil {
+		t.Errorf("Expected no OPT record in the reply to a query without one")
+	}
+}
+
+func TestValidatorNegativeTrustAnchor(t *testing.T) {
+	v, _ := newTestValidator(t)
+	v.negative = []string{"bogus.org."}
+
+	for _, name := range []string{"www.bogus.org.", "www.example.org."} {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		m.SetEdns0(4096, true)
+		rec := dnstest.NewRecorder(&test.ResponseWriter{})
+		if _, err := v.ServeDNS(context.TODO(), rec, m); err != nil {
+			t.Fatalf("Expected no error for %s, got %s", name, err)
+		}
+		if rec.Msg.Rcode != dns.RcodeSuccess {
+			t.Errorf("Expected NOERROR for %s, got %s", name, dns.RcodeToString[rec.Msg.Rcode])
+		}
+		if ad := name == "www.example.org."; rec.Msg.AuthenticatedData != ad {
+			t.Errorf("Expected AD %t for %s, got %t", ad, name, rec.Msg.AuthenticatedData)
+		}
+	}
+}
+
+func TestValidatorCache(t *testing.T) {
+	v, w := newTestValidator(t)
+
+	m := new(dns.Msg)
+	m.SetQuestion("www.example.org.", dns.TypeA)
+	m.SetEdns0(4096, true)
+	v.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	first := w.queries
+
+	m.SetQuestion("nope.example.org.", dns.TypeA)
+	v.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	if x := w.queries - first; x != 1 {
+		t.Errorf("Expected the chain of trust to be cached, got %d queries", x)
+	}
+
+	// Without a cache, the chain is walked again.
+	v.nodes = cache.New(nodesSize)
+	w.queries = 0
+	v.ServeDNS(context.TODO(), dnstest.NewRecorder(&test.ResponseWriter{}), m)
+	if w.queries != first {
+		t.Errorf("Expected %d queries, got %d", first, w.queries)
+	}
+}
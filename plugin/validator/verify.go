@@ -0,0 +1,281 @@
+package validator
+
+import (
+	"strings"
+	"time"
+
+	"github.com/coredns/coredns/plugin/pkg/dnsutil"
+
+	"github.com/miekg/dns"
+)
+
+// rrset is a set of records with the same owner name and type, and their signatures.
+type rrset struct {
+	name  string // lower cased owner name
+	rtype uint16
+	rrs   []dns.RR
+	sigs  []*dns.RRSIG
+}
+
+// rrsets groups the records in section into rrsets, in the order they first appear in.
+func rrsets(section []dns.RR) []*rrset {
+	var sets []*rrset
+	index := map[string]*rrset{}
+	get := func(name string, rtype uint16) *rrset {
+		name = strings.ToLower(name)
+		k := name + "/" + dns.TypeToString[rtype]
+		s, ok := index[k]
+		if !ok {
+			s = &rrset{name: name, rtype: rtype}
+			index[k] = s
+			sets = append(sets, s)
+		}
+		return s
+	}
+	for _, rr := range section {
+		if sig, ok := rr.(*dns.RRSIG); ok {
+			s := get(sig.Hdr.Name, sig.TypeCovered)
+			s.sigs = append(s.sigs, sig)
+			continue
+		}
+		if rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		s := get(rr.Header().Name, rr.Header().Rrtype)
+		s.rrs = append(s.rrs, rr)
+	}
+	// Drop the signatures without records.
+	j := 0
+	for _, s := range sets {
+		if len(s.rrs) > 0 {
+			sets[j] = s
+			j++
+		}
+	}
+	return sets[:j]
+}
+
+// validate validates the reply m to the query for qname and qtype. It returns true if the reply is
+// secure, and false if it's insecure. An error of type *bogusError is returned when m is bogus.
+func (val *validation) validate(qname string, qtype uint16, m *dns.Msg) (bool, error) {
+	qname = strings.ToLower(qname)
+	secure := true
+
+	sets := rrsets(m.Answer)
+	for _, s := range sets {
+		if s.rtype == dns.TypeCNAME && len(s.sigs) == 0 && synthesized(s, sets) {
+			// A CNAME synthesized from a DNAME isn't signed, the DNAME is.
+			continue
+		}
+		ok, err := val.rrset(s, m.Ns)
+		if err != nil {
+			return false, err
+		}
+		secure = secure && ok
+	}
+
+	target := qname
+	if qtype != dns.TypeCNAME {
+		target = chase(sets, qname)
+	}
+	if m.Rcode == dns.RcodeNameError || (qtype != dns.TypeANY && !answered(sets, target, qtype)) {
+		ok, err := val.denial(target, qtype, m)
+		if err != nil {
+			return false, err
+		}
+		secure = secure && ok
+	}
+	return secure, nil
+}
+
+// rrset validates s, and returns true if it is secure. When s is expanded from a wildcard, the proof
+// that its owner name doesn't exist is taken from ns.
+func (val *validation) rrset(s *rrset, ns []dns.RR) (bool, error) {
+	zone := s.name
+	if s.rtype == dns.TypeDS && zone != "." {
+		zone = dnsutil.Parent(zone)
+	}
+	if len(s.sigs) == 0 {
+		z, err := val.walk(zone)
+		if err != nil || z == nil {
+			return false, err
+		}
+		return false, bogus(edeRRSIGsMissing, "no signatures for %s %s", s.name, dns.TypeToString[s.rtype])
+	}
+
+	signer := strings.ToLower(s.sigs[0].SignerName)
+	if !dns.IsSubDomain(signer, zone) {
+		return false, bogus(edeBogus, "%s %s is signed by %s", s.name, dns.TypeToString[s.rtype], signer)
+	}
+	z, err := val.walk(signer)
+	if err != nil || z == nil {
+		return false, err
+	}
+	if z.name != signer {
+		return false, bogus(edeBogus, "signer %s of %s %s is not a zone", signer, s.name, dns.TypeToString[s.rtype])
+	}
+	sig, err := val.verify(s, z)
+	if err != nil {
+		return false, err
+	}
+
+	if labels := dns.CountLabel(s.name); int(sig.Labels) < labels {
+		// Expanded from a wildcard, the owner name itself must not exist.
+		nsec, nsec3, err := val.authority(ns, z)
+		if err != nil {
+			return false, err
+		}
+		if !expanded(s.name, int(sig.Labels), nsec, nsec3) {
+			return false, bogus(edeNSECMissing, "no proof that %s doesn't exist for the wildcard", s.name)
+		}
+	}
+	return true, nil
+}
+
+// denial validates the proof in the authority section of m that qname, or qtype at qname, doesn't
+// exist. It returns true if the proof is secure.
+func (val *validation) denial(qname string, qtype uint16, m *dns.Msg) (bool, error) {
+	zone := qname
+	if qtype == dns.TypeDS && zone != "." {
+		zone = dnsutil.Parent(zone)
+	}
+	signer := ""
+	for _, rr := range m.Ns {
+		if sig, ok := rr.(*dns.RRSIG); ok && dns.IsSubDomain(strings.ToLower(sig.SignerName), zone) {
+			signer = strings.ToLower(sig.SignerName)
+			break
+		}
+	}
+	if signer == "" {
+		z, err := val.walk(zone)
+		if err != nil || z == nil {
+			return false, err
+		}
+		return false, bogus(edeNSECMissing, "no signed proof that %s %s doesn't exist", qname, dns.TypeToString[qtype])
+	}
+	z, err := val.walk(signer)
+	if err != nil || z == nil {
+		return false, err
+	}
+	if z.name != signer {
+		return false, bogus(edeBogus, "signer %s of the proof for %s is not a zone", signer, qname)
+	}
+	nsec, nsec3, err := val.authority(m.Ns, z)
+	if err != nil {
+		return false, err
+	}
+	if insecureNSEC3(nsec3) {
+		return false, nil
+	}
+
+	proven, optout := false, false
+	if m.Rcode == dns.RcodeNameError {
+		proven, optout = nxdomain(qname, z.name, nsec, nsec3), optedOut(qname, z.name, nsec3)
+	} else {
+		proven, optout = nodata(qname, qtype, z.name, nsec, nsec3)
+	}
+	if optout {
+		return false, nil
+	}
+	if !proven {
+		return false, bogus(edeNSECMissing, "no proof that %s %s doesn't exist", qname, dns.TypeToString[qtype])
+	}
+	return true, nil
+}
+
+// authority validates the SOA, NSEC and NSEC3 records in the authority section ns that are signed by
+// the zone z, and returns the NSEC and NSEC3 records.
+func (val *validation) authority(ns []dns.RR, z *node) ([]*dns.NSEC, []*dns.NSEC3, error) {
+	var (
+		nsec  []*dns.NSEC
+		nsec3 []*dns.NSEC3
+	)
+	for _, s := range rrsets(ns) {
+		switch s.rtype {
+		case dns.TypeSOA, dns.TypeNSEC, dns.TypeNSEC3:
+		default:
+			continue
+		}
+		if len(s.sigs) == 0 || !strings.EqualFold(s.sigs[0].SignerName, z.name) {
+			continue
+		}
+		if _, err := val.verify(s, z); err != nil {
+			return nil, nil, err
+		}
+		for _, rr := range s.rrs {
+			switch x := rr.(type) {
+			case *dns.NSEC:
+				nsec = append(nsec, x)
+			case *dns.NSEC3:
+				nsec3 = append(nsec3, x)
+			}
+		}
+	}
+	return nsec, nsec3, nil
+}
+
+// verify returns the signature of s that is valid now and verifies with one of the keys of z.
+func (val *validation) verify(s *rrset, z *node) (*dns.RRSIG, error) {
+	err := bogus(edeDNSKEYMissing, "no key of %s for the signatures of %s %s", z.name, s.name, dns.TypeToString[s.rtype])
+	for _, sig := range s.sigs {
+		if !strings.EqualFold(sig.SignerName, z.name) || !algorithms[sig.Algorithm] {
+			continue
+		}
+		if !sig.ValidityPeriod(val.now) {
+			if val.now.Before(time.Unix(int64(sig.Inception), 0)) {
+				err = bogus(edeSignatureNotYetValid, "signature of %s %s is not yet valid", s.name, dns.TypeToString[s.rtype])
+			} else {
+				err = bogus(edeSignatureExpired, "signature of %s %s expired", s.name, dns.TypeToString[s.rtype])
+			}
+			continue
+		}
+		for _, k := range z.keys {
+			if k.KeyTag() != sig.KeyTag || k.Algorithm != sig.Algorithm {
+				continue
+			}
+			if e := sig.Verify(k, s.rrs); e != nil {
+				err = bogus(edeBogus, "signature of %s %s failed to verify: %s", s.name, dns.TypeToString[s.rtype], e)
+				continue
+			}
+			return sig, nil
+		}
+	}
+	return nil, err
+}
+
+// synthesized returns true if the CNAME s is synthesized from a DNAME in sets.
+func synthesized(s *rrset, sets []*rrset) bool {
+	for _, d := range sets {
+		if d.rtype == dns.TypeDNAME && d.name != s.name && dns.IsSubDomain(d.name, s.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// chase follows the CNAMEs in sets from qname, and returns the name at the end of the chain.
+func chase(sets []*rrset, qname string) string {
+	for i := 0; i <= len(sets); i++ {
+		next := ""
+		for _, s := range sets {
+			if s.name == qname && s.rtype == dns.TypeCNAME {
+				next = strings.ToLower(s.rrs[0].(*dns.CNAME).Target)
+			}
+		}
+		if next == "" {
+			return qname
+		}
+		qname = next
+	}
+	return qname
+}
+
+// answered returns true if sets hold the records of qtype at qname.
+func answered(sets []*rrset, qname string, qtype uint16) bool {
+	for _, s := range sets {
+		if s.name == qname && s.rtype == qtype {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,10 @@
 package request
 
-import "github.com/miekg/dns"
+import (
+	"github.com/coredns/coredns/plugin/pkg/edns"
+
+	"github.com/miekg/dns"
+)
 
 // ScrubWriter will, when writing the message, call scrub to make it fit the client's buffer.
 type ScrubWriter struct {
@@ -15,7 +19,26 @@ func NewScrubWriter(req *dns.Msg, w dns.ResponseWriter) *ScrubWriter { return &S
 // scrub on the message m and will then write it to the client.
 func (s *ScrubWriter) WriteMsg(m *dns.Msg) error {
 	state := Request{Req: s.req, W: s.ResponseWriter}
+	unsolicited(s.req, m)
 	state.SizeAndDo(m)
 	state.Scrub(m)
 	return s.ResponseWriter.WriteMsg(m)
 }
+
+// unsolicited removes the OPT RR and the EDNS0 Client Subnet option from m when they were not in
+// the request req. They may have been added by a plugin when it sent the request to an upstream.
+func unsolicited(req, m *dns.Msg) {
+	if req.IsEdns0() == nil {
+		extra := m.Extra[:0]
+		for _, rr := range m.Extra {
+			if rr.Header().Rrtype != dns.TypeOPT {
+				extra = append(extra, rr)
+			}
+		}
+		m.Extra = extra
+		return
+	}
+	if edns.Subnet(req) == nil {
+		edns.RemoveSubnet(m)
+	}
+}
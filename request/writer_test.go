@@ -0,0 +1,54 @@
+package request
+
+import (
+	"net"
+	"testing"
+
+	"github.com/coredns/coredns/plugin/pkg/edns"
+	"github.com/coredns/coredns/plugin/test"
+
+	"github.com/miekg/dns"
+)
+
+type recordWriter struct {
+	test.ResponseWriter
+	msg *dns.Msg
+}
+
+func (r *recordWriter) WriteMsg(m *dns.Msg) error { r.msg = m; return nil }
+
+func TestScrubWriterUnsolicited(t *testing.T) {
+	reply := func() *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion("example.org.", dns.TypeA)
+		m.Response = true
+		edns.SetSubnet(m, net.ParseIP("10.240.0.1"), 24, 56)
+		return m
+	}
+
+	// Request without OPT RR.
+	req := new(dns.Msg)
+	req.SetQuestion("example.org.", dns.TypeA)
+	rw := &recordWriter{}
+	NewScrubWriter(req, rw).WriteMsg(reply())
+	if rw.msg.IsEdns0() != nil {
+		t.Errorf("Expected OPT RR to be removed")
+	}
+
+	// Request with OPT RR, but without a subnet option.
+	req.SetEdns0(4096, false)
+	NewScrubWriter(req, rw).WriteMsg(reply())
+	if rw.msg.IsEdns0() == nil {
+		t.Errorf("Expected OPT RR to be kept")
+	}
+	if edns.Subnet(rw.msg) != nil {
+		t.Errorf("Expected subnet option to be removed")
+	}
+
+	// Request with a subnet option.
+	edns.SetSubnet(req, net.ParseIP("10.240.0.1"), 24, 56)
+	NewScrubWriter(req, rw).WriteMsg(reply())
+	if edns.Subnet(rw.msg) == nil {
+		t.Errorf("Expected subnet option to be kept")
+	}
+}